@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"shop/internal/app"
+	"shop/internal/config"
+	"shop/internal/lifecycle"
+	zapper "shop/internal/logger"
+	"shop/internal/notifier"
+	"shop/internal/storage/sqlite"
+)
+
+func main() {
+	cfg := config.MustLoad()
+
+	logger := zapper.NewLogger(cfg.Env)
+
+	// With the memory driver events never leave the shop process, which runs the notifier itself.
+	if cfg.Events.Driver != config.EventsDriverKafka {
+		logger.Fatal("notifier requires the kafka events driver", zap.String("driver", cfg.Events.Driver))
+	}
+
+	logger.Info("starting notifier", zap.String("sender", cfg.Notifier.Sender))
+
+	lc := lifecycle.New(logger, cfg.ShutdownTimeout)
+
+	storage, err := sqlite.New(cfg.StoragePath)
+	if err != nil {
+		logger.Fatal("failed to init storage", zap.Error(err))
+	}
+	lc.OnClose("storage", storage.Close)
+
+	broker, err := app.NewBroker(cfg.Events, logger)
+	if err != nil {
+		logger.Fatal("failed to init event broker", zap.Error(err))
+	}
+	// Closing the broker lets the consumers finish the messages they hold.
+	lc.OnClose("event broker", broker.Close)
+
+	n := notifier.New(logger, app.NewEmailSender(cfg.Notifier), storage, cfg.Notifier.BaseURL)
+	if err := n.Subscribe(context.Background(), app.NewConsumer(cfg.Events.Consumer, broker, storage, logger)); err != nil {
+		logger.Fatal("failed to subscribe notifier", zap.Error(err))
+	}
+
+	if err := lc.Wait(); err != nil {
+		logger.Fatal("notifier stopped with errors", zap.Error(err))
+	}
+
+	logger.Info("notifier stopped")
+}
@@ -2,30 +2,69 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net"
+	"io/fs"
 	"net/http"
-	"strconv"
+	"os"
 	"time"
 
 	ssov1 "github.com/Yuukiine/protos/gen/go/sso"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"shop/internal/app"
 	"shop/internal/config"
+	"shop/internal/domain/models"
+	"shop/internal/events/consumer"
+	accountsgrpc "shop/internal/grpc/accounts"
+	"shop/internal/grpc/interceptors"
+	"shop/internal/http-server/handlers/admin"
+	"shop/internal/http-server/handlers/admin/dlq"
+	webhookshandler "shop/internal/http-server/handlers/admin/webhooks"
+	authapi "shop/internal/http-server/handlers/api/auth"
+	cartapi "shop/internal/http-server/handlers/api/cart"
+	catalogapi "shop/internal/http-server/handlers/api/catalog"
+	ordersapi "shop/internal/http-server/handlers/api/orders"
+	v1 "shop/internal/http-server/handlers/api/v1"
 	"shop/internal/http-server/handlers/cart"
+	"shop/internal/http-server/handlers/health"
 	"shop/internal/http-server/handlers/home"
 	"shop/internal/http-server/handlers/products"
 	"shop/internal/http-server/handlers/users/login"
+	"shop/internal/http-server/handlers/users/notifications"
 	"shop/internal/http-server/handlers/users/register"
+	"shop/internal/http-server/handlers/users/social"
+	"shop/internal/http-server/middleware/authz"
+	"shop/internal/http-server/middleware/csrf"
+	"shop/internal/http-server/middleware/locale"
+	"shop/internal/http-server/middleware/realip"
+	"shop/internal/http-server/views"
+	"shop/internal/i18n"
+	"shop/internal/lifecycle"
 	zapper "shop/internal/logger"
 	mwLogger "shop/internal/logger/middleware"
-	"shop/internal/storage/sqlite"
-	kafka2 "shop/kafka"
+	"shop/internal/notifier"
+	"shop/internal/outbox"
+	"shop/internal/webhooks"
+	"shop/lib/certs"
+	"shop/lib/oidc"
+	accountsv1 "shop/protos/gen/go/accounts"
+	cartv1 "shop/protos/gen/go/cart"
+	catalogv1 "shop/protos/gen/go/catalog"
+	ordersv1 "shop/protos/gen/go/orders"
+	"shop/web"
+)
+
+const (
+	envLocal = "local"
+	webDir   = "web"
 )
 
 func main() {
@@ -33,96 +72,302 @@ func main() {
 
 	logger := zapper.NewLogger(cfg.Env)
 
-	logger.Info("starting application", zap.Any("cfg", cfg))
+	logger.Info("starting application", zap.Any("cfg", cfg.Redacted()))
 
-	application := app.New(logger, cfg.GRPC.Port, cfg.StoragePath, cfg.TokenTTL)
+	// Shutdown hooks run in reverse order: the HTTP server and workers stop first,
+	// then gRPC, the event broker and last the storage.
+	lc := lifecycle.New(logger, cfg.ShutdownTimeout)
+
+	var (
+		application *app.App
+		authConn    grpc.ClientConnInterface
+		remote      []health.Target
+	)
+	switch cfg.Auth.Mode {
+	case config.AuthModeRemote:
+		g, err := setupGRPCClient(cfg, logger)
+		if err != nil {
+			logger.Fatal("failed to setup gRPC client", zap.Error(err))
+		}
 
-	kafka := kafka2.New(logger)
+		// Only the client of sso is built: the users are its own, so it checks their
+		// permissions for the shop services too.
+		application = app.NewWithRemoteAuth(logger, cfg.GRPC.Timeout, cfg.StoragePath, cfg.CartEvents,
+			accountsgrpc.NewClient(g))
+		lc.OnClose("storage", application.Close)
+		lc.OnClose("grpc client", g.Close)
 
-	go func() {
-		application.GRPCServ.MustRun()
-	}()
+		authConn = g
+		remote = append(remote, health.Target{
+			Name:     "sso",
+			Client:   healthpb.NewHealthClient(g),
+			Services: []string{"", ssov1.Auth_ServiceDesc.ServiceName, accountsv1.Accounts_ServiceDesc.ServiceName},
+		})
+	default:
+		application = app.New(logger, cfg.Env, cfg.GRPC, cfg.StoragePath, cfg.TokenTTL, cfg.Lockout, cfg.Password, cfg.CartEvents)
+		lc.OnClose("storage", application.Close)
+
+		if err := application.AuthService.BootstrapAdmins(context.Background(), cfg.Auth.Admins); err != nil {
+			logger.Fatal("failed to bootstrap admins", zap.Error(err))
+		}
+
+		authConn = application.GRPCServ.ClientConn(middleware.GetReqID)
+	}
 
-	time.Sleep(2 * time.Second)
+	storage := application.Storage
+	lc.Go("change log poller", storage.PollChanges)
 
-	g, err := setupGRPCClient(cfg, logger)
+	broker, err := app.NewBroker(cfg.Events, logger)
 	if err != nil {
-		logger.Fatal("failed to setup gRPC client", zap.Error(err))
+		logger.Fatal("failed to init event broker", zap.Error(err))
+	}
+	// Closing the broker lets the consumers finish the messages they hold.
+	lc.OnClose("event broker", broker.Close)
+
+	// With a remote auth service the shop services are only served in-process, and Run just
+	// keeps their health in line with the storage.
+	lc.Serve("grpc", application.GRPCServ.Run, application.GRPCServ.Stop)
+	conn := application.GRPCServ.ClientConn(middleware.GetReqID)
+
+	healthTargets := append([]health.Target{{
+		Name:     "server",
+		Client:   application.GRPCServ.HealthClient(),
+		Services: application.GRPCServ.HealthServices(),
+	}}, remote...)
+	for _, target := range healthTargets {
+		if err := waitForGRPC(target.Client, logger); err != nil {
+			logger.Fatal("gRPC server is not serving", zap.String("server", target.Name), zap.Error(err))
+		}
+	}
+	authClient := ssov1.NewAuthClient(authConn)
+	accounts := accountsgrpc.NewClient(authConn)
+
+	eventConsumer := app.NewConsumer(cfg.Events.Consumer, broker, storage, logger)
+
+	// The in-memory broker only reaches this process, so the notifier has to run here;
+	// with Kafka it runs as cmd/notifier.
+	if cfg.Events.Driver == config.EventsDriverMemory {
+		n := notifier.New(logger, app.NewEmailSender(cfg.Notifier), storage, cfg.Notifier.BaseURL)
+		if err := n.Subscribe(context.Background(), eventConsumer); err != nil {
+			logger.Fatal("failed to subscribe notifier", zap.Error(err))
+		}
+	}
+
+	if err := webhooks.NewDispatcher(logger, storage).Subscribe(context.Background(), eventConsumer); err != nil {
+		logger.Fatal("failed to subscribe webhooks", zap.Error(err))
 	}
-	var authClient = ssov1.NewAuthClient(g)
+	deliverer := webhooks.NewDeliverer(logger, storage, cfg.Webhooks.Timeout, cfg.Webhooks.Interval,
+		cfg.Webhooks.BatchSize, cfg.Webhooks.MaxAttempts, cfg.Webhooks.BaseBackoff, cfg.Webhooks.MaxBackoff)
+	lc.Go("webhooks deliverer", deliverer.Run)
 
-	defer g.Close()
+	relay := outbox.New(logger, storage, broker,
+		cfg.Outbox.Interval, cfg.Outbox.BatchSize, cfg.Outbox.MaxAttempts, cfg.Outbox.BaseBackoff, cfg.Outbox.MaxBackoff)
+	lc.Go("outbox relay", relay.Run)
 
-	storage, err := sqlite.New(cfg.StoragePath)
+	files, reload := webFiles(cfg.Env, logger)
+	pages, err := views.New(logger, files, reload)
+	if err != nil {
+		logger.Fatal("failed to load views", zap.Error(err))
+	}
+	bundle, err := i18n.New(files, cfg.I18n.DefaultLocale)
 	if err != nil {
-		logger.Fatal("failed to init storage",
-			zap.Time("time:", time.Now()),
-			zap.String("error:", fmt.Sprintf("failed to init sqlite storage: %v", err)))
+		logger.Fatal("failed to load translations", zap.Error(err))
 	}
 
-	homeHandler := home.NewHomeHandler(storage, logger)
-	loginHandler := login.NewLoginHandler(storage, authClient, logger)
-	registerHandler := register.NewRegisterHandler(kafka, authClient, logger)
-	productsHandler := products.NewProductsHandler(storage, logger)
-	cartHandler := cart.NewCartHandler(storage, logger)
+	homeHandler := home.NewHomeHandler(storage, pages, logger)
+	var (
+		oidcProviders []social.Provider
+		providerNames []string
+	)
+	for _, p := range cfg.OIDC {
+		oidcProviders = append(oidcProviders, oidc.New(oidc.Config{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+		}, nil))
+		providerNames = append(providerNames, p.Name)
+	}
+
+	loginHandler := login.NewLoginHandler(storage, application.Cart, authClient, providerNames, pages, logger)
+	socialHandler := social.NewSocialHandler(oidcProviders, accounts, application.Cart, storage, logger)
+	registerHandler := register.NewRegisterHandler(authClient, cfg.Password.MinLength, pages, logger)
+	productsHandler := products.NewProductsHandler(storage, application.Catalog, application.Cart, pages, logger)
+	cartHandler := cart.NewCartHandler(storage, application.Cart, cfg.CartEvents.Heartbeat,
+		cfg.CartEvents.MaxStreams, cfg.CartEvents.MaxConnectionsPerIP, pages, logger)
+	adminHandler := admin.NewAdminHandler(accounts, accounts, pages, logger)
+	notificationsHandler := notifications.NewNotificationsHandler(storage, pages, logger)
+	dlqHandler := dlq.NewDLQHandler(storage, consumer.NewReplayer(storage, broker), pages, logger)
+	webhooksHandler := webhookshandler.NewWebhooksHandler(storage, pages, logger)
+	healthHandler := health.NewHealthHandler(healthTargets, logger)
+	apiHandler := v1.NewHandler(logger,
+		authapi.NewAuthHandler(authClient, logger),
+		catalogapi.NewCatalogHandler(catalogv1.NewCatalogClient(conn), logger),
+		cartapi.NewCartHandler(cartv1.NewCartClient(conn), logger),
+		ordersapi.NewOrdersHandler(ordersv1.NewOrdersClient(conn), logger),
+	)
+
+	realIP, err := realip.New(cfg.HTTPServer.TrustedProxies)
+	if err != nil {
+		logger.Fatal("invalid trusted proxies", zap.Error(err))
+	}
 
 	router := chi.NewRouter()
 
 	router.Use(middleware.RequestID)
+	router.Use(realIP)
 	router.Use(middleware.Logger)
 	router.Use(mwLogger.New(logger))
 	router.Use(middleware.Recoverer)
+	// Strips the locale prefix of the URL, so it has to run before the routes are matched.
+	router.Use(locale.New(bundle))
 	router.Use(middleware.URLFormat)
 
+	web := webRouter(router, apiHandler, csrf.New(pages, logger))
+
 	logger.Info("starting server", zap.String("address", cfg.Address))
-	router.Get("/", homeHandler.ServeHTTP)
-	router.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok","timestamp":"` + time.Now().Format(time.RFC3339) + `"}`))
-	})
+	web.Get("/", homeHandler.ServeHTTP)
+	web.Get("/health", healthHandler.ServeHTTP)
+	router.Handle(views.StaticPrefix+"*", pages.Static())
 
-	go router.Route("/login", func(r chi.Router) {
+	web.Route("/login", func(r chi.Router) {
 		r.Get("/", loginHandler.ServeHTTP)
 		r.Post("/", loginHandler.HandleLogin)
 	})
-	router.Route("/register", func(r chi.Router) {
+	web.Route("/register", func(r chi.Router) {
 		r.Get("/", registerHandler.ServeHTTP)
 		r.Post("/", registerHandler.HandleRegister)
 	})
-	router.Get("/logout", loginHandler.HandleLogout)
+	web.Post("/logout", loginHandler.HandleLogout)
+	web.Route("/auth/oidc/{provider}", func(r chi.Router) {
+		r.Get("/login", socialHandler.HandleLogin)
+		r.Get("/callback", socialHandler.HandleCallback)
+	})
 
-	go router.Route("/products", func(r chi.Router) {
+	web.Route("/products", func(r chi.Router) {
 		r.Get("/", productsHandler.ServeHTTP)
 	})
 
-	go router.Route("/cart", func(r chi.Router) {
+	web.Route("/cart", func(r chi.Router) {
 		r.Get("/", cartHandler.ServeHTTP)
 		r.Post("/add", productsHandler.AddToCart)
 		r.Post("/update", cartHandler.UpdateHandler)
 		r.Post("/remove", cartHandler.RemoveHandler)
+		r.Get("/events", cartHandler.EventsHandler)
+	})
+
+	web.Route("/account/notifications", func(r chi.Router) {
+		r.Get("/", notificationsHandler.ServeHTTP)
+		r.Post("/", notificationsHandler.HandleSave)
+	})
+
+	web.Route("/admin", func(r chi.Router) {
+		requirePermission := func(permission string) func(http.Handler) http.Handler {
+			return authz.RequirePermission(accounts, storage, logger, permission)
+		}
+
+		r.With(requirePermission(models.PermUsersRead)).Get("/", adminHandler.ServeHTTP)
+		r.With(requirePermission(models.PermUsersUnlock)).Post("/unlock", adminHandler.HandleUnlock)
+		r.With(requirePermission(models.PermRolesManage)).Post("/roles/assign", adminHandler.HandleAssignRole)
+		r.With(requirePermission(models.PermRolesManage)).Post("/roles/revoke", adminHandler.HandleRevokeRole)
+		r.With(requirePermission(models.PermEventsManage)).Get("/dlq", dlqHandler.ServeHTTP)
+		r.With(requirePermission(models.PermEventsManage)).Post("/dlq/replay", dlqHandler.HandleReplay)
+		r.With(requirePermission(models.PermWebhooksManage)).Get("/webhooks", webhooksHandler.ServeHTTP)
+		r.With(requirePermission(models.PermWebhooksManage)).Post("/webhooks", webhooksHandler.HandleCreate)
+		r.With(requirePermission(models.PermWebhooksManage)).Get("/webhooks/{id}", webhooksHandler.ServeHTTP)
+		r.With(requirePermission(models.PermWebhooksManage)).Post("/webhooks/{id}/toggle", webhooksHandler.HandleToggle)
+		r.With(requirePermission(models.PermWebhooksManage)).Post("/webhooks/{id}/delete", webhooksHandler.HandleDelete)
 	})
 
 	srv := &http.Server{
-		Addr:    cfg.Address,
-		Handler: router,
+		Addr:         cfg.Address,
+		Handler:      router,
+		ReadTimeout:  cfg.HTTPServer.Timeout,
+		WriteTimeout: cfg.HTTPServer.Timeout,
+		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
+	}
+
+	// Shutdown waits for open requests, so the cart streams are ended as it starts.
+	srv.RegisterOnShutdown(application.CartUpdates.Close)
+
+	lc.Serve("http", func() error {
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}, func(ctx context.Context) error {
+		// Shutdown waits for in-flight requests; past the deadline the rest are cut off.
+		if err := srv.Shutdown(ctx); err != nil {
+			return errors.Join(err, srv.Close())
+		}
+		return nil
+	})
+
+	metricsSrv := &http.Server{
+		Addr:              cfg.Metrics.Address,
+		Handler:           promhttp.Handler(),
+		ReadHeaderTimeout: cfg.HTTPServer.Timeout,
 	}
 
-	if err := srv.ListenAndServe(); err != nil {
-		logger.Fatal("failed to start server", zap.Error(err))
+	lc.Serve("metrics", func() error {
+		if err := metricsSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}, metricsSrv.Shutdown)
+
+	if err := lc.Wait(); err != nil {
+		logger.Fatal("shop stopped with errors", zap.Error(err))
+	}
+
+	logger.Info("shop stopped")
+}
+
+// webRouter mounts the JSON API on router and returns the router of the web pages, which
+// are behind protect. The API authenticates by bearer token, so CSRF protection only covers
+// the web pages.
+func webRouter(router chi.Router, api http.Handler, protect func(http.Handler) http.Handler) chi.Router {
+	router.Mount("/api/v1", api)
+
+	return router.With(protect)
+}
+
+// webFiles returns the embedded templates, assets and catalogs. In the local env they are
+// read from the web directory instead, if the shop runs from the repository, and the views
+// reload on every request; catalogs are still read once at start.
+func webFiles(env string, logger *zap.Logger) (fs.FS, bool) {
+	if env == envLocal {
+		if _, err := os.Stat(webDir); err == nil {
+			return os.DirFS(webDir), true
+		}
+		logger.Warn("web directory not found, using embedded templates", zap.String("dir", webDir))
 	}
+
+	return web.FS, false
 }
 
 func setupGRPCClient(cfg *config.Config, logger *zap.Logger) (*grpc.ClientConn, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	address := cfg.Auth.Address
+	logger.Info("attempting gRPC connection",
+		zap.String("address", address),
+		zap.Bool("tls", cfg.GRPC.TLS.Enabled))
+
+	creds := insecure.NewCredentials()
+	if tlsCfg := cfg.GRPC.TLS; tlsCfg.Enabled {
+		reloader, err := certs.NewReloader(tlsCfg.ClientCertFile, tlsCfg.ClientKeyFile, tlsCfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load gRPC client certificates: %w", err)
+		}
+		go reloader.Watch(context.Background(), tlsCfg.ReloadInterval, logger)
 
-	address := grpcAddress(cfg)
-	logger.Info("attempting gRPC connection", zap.String("address", address))
+		creds = credentials.NewTLS(reloader.ClientConfig(tlsCfg.ServerName))
+	}
 
-	conn, err := grpc.DialContext(ctx, address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
+	conn, err := grpc.NewClient(address,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(interceptors.RequestIDClient(middleware.GetReqID)),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to gRPC server: %w", err)
@@ -131,6 +376,28 @@ func setupGRPCClient(cfg *config.Config, logger *zap.Logger) (*grpc.ClientConn,
 	return conn, nil
 }
 
-func grpcAddress(cfg *config.Config) string {
-	return net.JoinHostPort("localhost", strconv.Itoa(cfg.GRPC.Port))
+// waitForGRPC blocks until the gRPC server reports itself as serving.
+func waitForGRPC(client healthpb.HealthClient, logger *zap.Logger) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		res, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+		if err == nil && res.GetStatus() == healthpb.HealthCheckResponse_SERVING {
+			logger.Info("gRPC server is serving")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("failed to check gRPC health: %w", err)
+			}
+			return fmt.Errorf("gRPC server status is %s", res.GetStatus())
+		case <-ticker.C:
+		}
+	}
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"shop/internal/http-server/middleware/csrf"
+	"shop/internal/http-server/middleware/locale"
+	"shop/internal/http-server/views"
+	"shop/internal/i18n"
+	"shop/web"
+)
+
+func TestWebRouterExemptsTheAPIFromCSRF(t *testing.T) {
+	pages, err := views.New(zap.NewNop(), web.FS, false)
+	if err != nil {
+		t.Fatalf("views.New: %v", err)
+	}
+	bundle, err := i18n.New(web.FS, "en")
+	if err != nil {
+		t.Fatalf("i18n.New: %v", err)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	router := chi.NewRouter()
+	router.Use(locale.New(bundle))
+	webRouter(router, ok, csrf.New(pages, zap.NewNop())).Post("/cart/add", ok)
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+	}{
+		{name: "API with a bearer token", path: "/api/v1/cart/items", wantCode: http.StatusOK},
+		{name: "web page", path: "/cart/add", wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			r.Header.Set("Authorization", "Bearer token")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"shop/internal/app"
+	"shop/internal/config"
+	"shop/internal/lifecycle"
+	zapper "shop/internal/logger"
+)
+
+func main() {
+	cfg := config.MustLoad()
+
+	logger := zapper.NewLogger(cfg.Env)
+
+	logger.Info("starting sso", zap.Any("cfg", cfg.Redacted()))
+
+	lc := lifecycle.New(logger, cfg.ShutdownTimeout)
+
+	// The accounts service only answers the shop when it presents a certificate sso verified.
+	if !cfg.GRPC.TLS.Enabled || cfg.GRPC.TLS.ClientCAFile == "" {
+		logger.Warn("grpc.tls.client_ca_file is not set, the shop cannot administer accounts or log in with OIDC")
+	}
+
+	application := app.NewSSO(logger, cfg.Env, cfg.GRPC, cfg.StoragePath, cfg.TokenTTL, cfg.Lockout, cfg.Password)
+	lc.OnClose("storage", application.Close)
+
+	if err := application.AuthService.BootstrapAdmins(context.Background(), cfg.Auth.Admins); err != nil {
+		logger.Fatal("failed to bootstrap admins", zap.Error(err))
+	}
+
+	lc.Serve("grpc", application.GRPCServ.Run, application.GRPCServ.Stop)
+
+	if err := lc.Wait(); err != nil {
+		logger.Fatal("sso stopped with errors", zap.Error(err))
+	}
+
+	logger.Info("sso stopped")
+}
@@ -6,30 +6,150 @@ import (
 	"go.uber.org/zap"
 
 	grpcapp "shop/internal/app/grpc"
+	"shop/internal/config"
+	"shop/internal/grpc/interceptors"
 	"shop/internal/services/auth"
+	"shop/internal/services/cart"
+	"shop/internal/services/catalog"
+	"shop/internal/services/checkout"
 	"shop/internal/storage/sqlite"
+	"shop/lib/password"
 )
 
 type App struct {
 	GRPCServ *grpcapp.App
+	// AuthService is nil in a shop whose auth service runs remotely.
+	AuthService *auth.Auth
+	// Catalog, Cart, Checkout and CartUpdates are nil in the auth server of cmd/sso.
+	Catalog     *catalog.Catalog
+	Cart        *cart.Cart
+	Checkout    *checkout.Checkout
+	CartUpdates *cart.Hub
+	// Storage is shared with the rest of the process, so its change bus sees every local write.
+	Storage *sqlite.Storage
 }
 
+// New builds the shop with the auth service in-process; the gRPC server serves it next to
+// the shop services.
 func New(
 	log *zap.Logger,
-	grpcPort int,
+	env string,
+	grpcCfg config.GRPCConfig,
 	storagePath string,
 	tokenTTL time.Duration,
+	lockout config.LockoutConfig,
+	passwords config.PasswordConfig,
+	cartEvents config.CartEventsConfig,
 ) *App {
+	a := newShop(log, storagePath, cartEvents)
+	a.AuthService = newAuthService(log, a.Storage, tokenTTL, lockout, passwords)
+
+	services := a.services()
+	services.Auth = a.AuthService
+	services.Accounts = a.AuthService
+	a.GRPCServ = grpcapp.New(log, services, a.AuthService, a.Storage, a.Storage, grpcCfg, env)
+
+	return a
+}
+
+// NewWithRemoteAuth builds the shop for an auth service running in cmd/sso. Neither the auth
+// service nor a gRPC server is built: the shop services are served in-process only, and the
+// permissions of their callers are checked by checker, which asks the auth service.
+func NewWithRemoteAuth(
+	log *zap.Logger,
+	grpcTimeout time.Duration,
+	storagePath string,
+	cartEvents config.CartEventsConfig,
+	checker interceptors.PermissionChecker,
+) *App {
+	a := newShop(log, storagePath, cartEvents)
+	a.GRPCServ = grpcapp.NewLocal(log, a.services(), checker, a.Storage, a.Storage, grpcTimeout)
+
+	return a
+}
+
+// NewSSO builds the standalone auth server of cmd/sso, serving the auth and accounts services only.
+func NewSSO(
+	log *zap.Logger,
+	env string,
+	grpcCfg config.GRPCConfig,
+	storagePath string,
+	tokenTTL time.Duration,
+	lockout config.LockoutConfig,
+	passwords config.PasswordConfig,
+) *App {
+	storage := newStorage(storagePath)
+	authService := newAuthService(log, storage, tokenTTL, lockout, passwords)
+
+	services := grpcapp.Services{Auth: authService, Accounts: authService}
+
+	return &App{
+		GRPCServ:    grpcapp.New(log, services, authService, storage, storage, grpcCfg, env),
+		AuthService: authService,
+		Storage:     storage,
+	}
+}
+
+func newStorage(storagePath string) *sqlite.Storage {
 	storage, err := sqlite.New(storagePath)
 	if err != nil {
 		panic(err)
 	}
 
-	authService := auth.New(log, storage, storage, storage, tokenTTL)
+	return storage
+}
 
-	grpcApp := grpcapp.New(log, authService, grpcPort)
+func newAuthService(
+	log *zap.Logger,
+	storage *sqlite.Storage,
+	tokenTTL time.Duration,
+	lockout config.LockoutConfig,
+	passwords config.PasswordConfig,
+) *auth.Auth {
+	hasher, err := password.NewHasher(passwords.Algorithm, passwords.BcryptCost, password.Argon2Params{
+		Time:       passwords.Argon2.Time,
+		Memory:     passwords.Argon2.Memory,
+		Threads:    passwords.Argon2.Threads,
+		KeyLength:  passwords.Argon2.KeyLength,
+		SaltLength: passwords.Argon2.SaltLength,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	policy, err := password.NewPolicy(passwords.MinLength, passwords.BreachedListPath)
+	if err != nil {
+		panic(err)
+	}
+
+	return auth.New(log, storage, storage, storage, storage, storage, tokenTTL, lockout, hasher, policy)
+}
+
+// newShop builds the storage and the shop services, without auth.
+func newShop(log *zap.Logger, storagePath string, cartEvents config.CartEventsConfig) *App {
+	storage := newStorage(storagePath)
+	cartUpdates := cart.NewHub(cartEvents.MaxConnections)
 
 	return &App{
-		GRPCServ: grpcApp,
+		Catalog:     catalog.New(log, storage),
+		Cart:        cart.New(log, storage, cartUpdates),
+		Checkout:    checkout.New(log, storage, cartUpdates),
+		CartUpdates: cartUpdates,
+		Storage:     storage,
 	}
 }
+
+// services returns the shop services for the gRPC app.
+func (a *App) services() grpcapp.Services {
+	return grpcapp.Services{
+		Catalog:  a.Catalog,
+		Feed:     a.Storage,
+		Cart:     a.Cart,
+		Checkout: a.Checkout,
+	}
+}
+
+// Close closes the storage of the services. Stop the gRPC server first.
+func (a *App) Close() error {
+	return a.Storage.Close()
+}
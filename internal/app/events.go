@@ -0,0 +1,37 @@
+package app
+
+import (
+	"go.uber.org/zap"
+
+	"shop/internal/config"
+	"shop/internal/events"
+	"shop/internal/events/consumer"
+	"shop/internal/events/kafka"
+	"shop/internal/events/memory"
+	"shop/internal/notifier"
+)
+
+// NewBroker returns the event broker selected by the config.
+func NewBroker(cfg config.EventsConfig, log *zap.Logger) (events.Broker, error) {
+	log.Info("starting event broker", zap.String("driver", cfg.Driver))
+
+	if cfg.Driver == config.EventsDriverKafka {
+		return kafka.New(cfg.Kafka.Brokers, cfg.Kafka.ClientID, cfg.Kafka.Retries, log)
+	}
+
+	return memory.New(log), nil
+}
+
+// NewConsumer returns a consumer retrying and dead-lettering as the config says.
+func NewConsumer(cfg config.ConsumerConfig, broker consumer.Broker, storage consumer.Storage, log *zap.Logger) *consumer.Consumer {
+	return consumer.New(log, broker, storage, cfg.Attempts, cfg.Backoff, cfg.MaxBackoff, cfg.RetryDelays)
+}
+
+// NewEmailSender returns the email sender selected by the config.
+func NewEmailSender(cfg config.NotifierConfig) notifier.EmailSender {
+	if cfg.Sender == config.EmailSenderFile {
+		return notifier.NewFileSender(cfg.FileDir, cfg.From)
+	}
+
+	return notifier.NewSMTPSender(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.From)
+}
@@ -1,59 +1,300 @@
 package grpcapp
 
 import (
+	"context"
 	"fmt"
+	"maps"
 	"net"
+	"strconv"
+	"time"
 
+	ssov1 "github.com/Yuukiine/protos/gen/go/sso"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionpbalpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
 
+	"shop/internal/config"
+	"shop/internal/domain/models"
+	accountsgrpc "shop/internal/grpc/accounts"
 	authgrpc "shop/internal/grpc/auth"
+	cartgrpc "shop/internal/grpc/cart"
+	cataloggrpc "shop/internal/grpc/catalog"
+	"shop/internal/grpc/interceptors"
+	"shop/internal/grpc/local"
+	ordersgrpc "shop/internal/grpc/orders"
+	"shop/internal/storage/changes"
+	"shop/lib/certs"
+	"shop/lib/jwt"
+	accountsv1 "shop/protos/gen/go/accounts"
+	cartv1 "shop/protos/gen/go/cart"
+	catalogv1 "shop/protos/gen/go/catalog"
+	ordersv1 "shop/protos/gen/go/orders"
 )
 
+const envProd = "prod"
+
 type App struct {
-	log        *zap.Logger
-	gRPCServer *grpc.Server
-	port       int
+	log *zap.Logger
+	// gRPCServer is nil in apps serving in-process only.
+	gRPCServer     *grpc.Server
+	unary          grpc.UnaryServerInterceptor
+	health         *health.Server
+	healthServices []string
+	pinger         Pinger
+	// healthInterval is how often watchStorage pings the storage.
+	healthInterval time.Duration
+	certs          *certs.Reloader
+	local          *local.Conn
+	changes        *changes.Bus
+	cfg            config.GRPCConfig
+
+	// ctx is cancelled by Stop and ends the background work started by Run.
+	ctx  context.Context
+	stop context.CancelFunc
+}
+
+// policy lists the methods callable without a token and the permissions required by the rest.
+var policy = interceptors.Policy{
+	Public: map[string]bool{
+		ssov1.Auth_Register_FullMethodName: true,
+		ssov1.Auth_Login_FullMethodName:    true,
+
+		catalogv1.Catalog_GetProduct_FullMethodName:       true,
+		catalogv1.Catalog_ListProducts_FullMethodName:     true,
+		catalogv1.Catalog_ListCategories_FullMethodName:   true,
+		catalogv1.Catalog_BatchGetProducts_FullMethodName: true,
+		catalogv1.Catalog_WatchInventory_FullMethodName:   true,
+
+		healthpb.Health_Check_FullMethodName: true,
+		healthpb.Health_List_FullMethodName:  true,
+		healthpb.Health_Watch_FullMethodName: true,
+
+		reflectionpb.ServerReflection_ServerReflectionInfo_FullMethodName:      true,
+		reflectionpbalpha.ServerReflection_ServerReflectionInfo_FullMethodName: true,
+	},
+	Internal: accountsgrpc.Internal(),
+	Permissions: map[string]string{
+		ssov1.Auth_IsAdmin_FullMethodName: models.PermUsersRead,
+	},
 }
 
+// Services are the services an app serves; nil ones are not registered.
+type Services struct {
+	Auth     authgrpc.Auth
+	Accounts accountsgrpc.Accounts
+	Catalog  cataloggrpc.Catalog
+	Feed     cataloggrpc.Feed
+	Cart     cartgrpc.Cart
+	Checkout ordersgrpc.Checkout
+}
+
+func (s Services) register(registrar grpc.ServiceRegistrar) {
+	if s.Auth != nil {
+		authgrpc.Register(registrar, s.Auth)
+	}
+	if s.Accounts != nil {
+		accountsgrpc.Register(registrar, s.Accounts)
+	}
+	if s.Catalog != nil {
+		cataloggrpc.Register(registrar, s.Catalog, s.Feed)
+	}
+	if s.Cart != nil {
+		cartgrpc.Register(registrar, s.Cart)
+	}
+	if s.Checkout != nil {
+		ordersgrpc.Register(registrar, s.Checkout)
+	}
+}
+
+// healthServices returns the names the health service reports: the empty name is the status
+// of the app as a whole, followed by the registered services.
+func (s Services) healthServices() []string {
+	services := []string{""}
+	for _, registered := range []struct {
+		ok   bool
+		name string
+	}{
+		{s.Auth != nil, ssov1.Auth_ServiceDesc.ServiceName},
+		{s.Accounts != nil, accountsv1.Accounts_ServiceDesc.ServiceName},
+		{s.Catalog != nil, catalogv1.Catalog_ServiceDesc.ServiceName},
+		{s.Cart != nil, cartv1.Cart_ServiceDesc.ServiceName},
+		{s.Checkout != nil, ordersv1.Orders_ServiceDesc.ServiceName},
+	} {
+		if registered.ok {
+			services = append(services, registered.name)
+		}
+	}
+
+	return services
+}
+
+// New returns an app serving services over gRPC, and in-process through ClientConn.
 func New(
 	log *zap.Logger,
-	authService authgrpc.Auth,
-	port int,
+	services Services,
+	checker interceptors.PermissionChecker,
+	apps jwt.AppProvider,
+	pinger Pinger,
+	cfg config.GRPCConfig,
+	env string,
 ) *App {
-	gRPCServer := grpc.NewServer()
+	a := newApp(log, services, checker, apps, pinger, cfg)
+
+	var opts []grpc.ServerOption
+	if cfg.TLS.Enabled {
+		reloader, err := certs.NewReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.ClientCAFile)
+		if err != nil {
+			panic(err)
+		}
+
+		opts = append(opts, grpc.Creds(credentials.NewTLS(reloader.ServerConfig())))
+		a.certs = reloader
+	}
+
+	opts = append(opts,
+		grpc.UnaryInterceptor(a.unary),
+		grpc.ChainStreamInterceptor(
+			interceptors.RequestIDStream(),
+			interceptors.LoggingStream(log),
+			interceptors.RecoveryStream(log),
+			interceptors.AuthzStream(log, apps, checker, policy),
+			interceptors.ValidationStream(validators()),
+		),
+	)
+
+	a.gRPCServer = grpc.NewServer(opts...)
+	services.register(a.gRPCServer)
+	healthpb.RegisterHealthServer(a.gRPCServer, a.health)
 
-	authgrpc.Register(gRPCServer, authService)
+	if env != envProd {
+		reflection.Register(a.gRPCServer)
+	}
+
+	return a
+}
+
+// NewLocal returns an app serving services in-process only, through ClientConn, for a shop
+// whose auth service runs remotely. No server is built, so no listener or certificate is set
+// up; Run only keeps the health status in line with the storage until Stop.
+func NewLocal(
+	log *zap.Logger,
+	services Services,
+	checker interceptors.PermissionChecker,
+	apps jwt.AppProvider,
+	pinger Pinger,
+	timeout time.Duration,
+) *App {
+	return newApp(log, services, checker, apps, pinger, config.GRPCConfig{Timeout: timeout})
+}
+
+func newApp(
+	log *zap.Logger,
+	services Services,
+	checker interceptors.PermissionChecker,
+	apps jwt.AppProvider,
+	pinger Pinger,
+	cfg config.GRPCConfig,
+) *App {
+	unary := interceptors.ChainUnary(
+		interceptors.RequestIDUnary(),
+		interceptors.LoggingUnary(log),
+		interceptors.RecoveryUnary(log),
+		interceptors.DeadlineUnary(cfg.Timeout),
+		interceptors.AuthzUnary(log, apps, checker, policy),
+		interceptors.ValidationUnary(validators()),
+	)
+
+	localConn := local.NewConn(unary)
+	services.register(localConn)
+
+	healthServices := services.healthServices()
+	healthServer := health.NewServer()
+	for _, service := range healthServices {
+		healthServer.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+
+	var bus *changes.Bus
+	if services.Feed != nil {
+		bus = services.Feed.Changes()
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
 
 	return &App{
-		log:        log,
-		gRPCServer: gRPCServer,
-		port:       port,
+		log:            log,
+		unary:          unary,
+		health:         healthServer,
+		healthServices: healthServices,
+		pinger:         pinger,
+		healthInterval: healthCheckInterval,
+		local:          localConn,
+		changes:        bus,
+		cfg:            cfg,
+		ctx:            ctx,
+		stop:           stop,
 	}
 }
 
-// MustRun runs gRPC server and panics if any error occurs.
-func (a *App) MustRun() {
-	if err := a.Run(); err != nil {
-		panic(err)
+func validators() map[string]interceptors.Validator {
+	validators := map[string]interceptors.Validator{}
+	for _, v := range []map[string]interceptors.Validator{
+		authgrpc.Validators(),
+		accountsgrpc.Validators(),
+		cataloggrpc.Validators(),
+		cartgrpc.Validators(),
+		ordersgrpc.Validators(),
+	} {
+		maps.Copy(validators, v)
 	}
+
+	return validators
+}
+
+// ClientConn returns a connection to the app's services served in-process, going through the
+// same unary interceptors as remote calls. requestID returns the ID of the request the call is
+// made for.
+func (a *App) ClientConn(requestID func(ctx context.Context) string) grpc.ClientConnInterface {
+	return a.local.WithRequestID(requestID)
 }
 
-// Run runs gRPC server
+// HealthServices returns the services reported by the grpc.health.v1 service of the app.
+// The empty name is the status of the app as a whole.
+func (a *App) HealthServices() []string {
+	return a.healthServices
+}
+
+// Run runs gRPC server. An app serving in-process only watches the storage until Stop.
 func (a *App) Run() error {
 	const op = "grpcapp.Run"
 
+	if a.gRPCServer == nil {
+		a.watchStorage(a.ctx)
+		return nil
+	}
+
 	log := a.log.With(
 		zap.String("op", op),
-		zap.Int("port", a.port),
+		zap.Int("port", a.cfg.Port),
 	)
 
-	l, err := net.Listen("tcp", fmt.Sprintf(":%d", a.port))
+	l, err := net.Listen("tcp", net.JoinHostPort(a.cfg.Host, strconv.Itoa(a.cfg.Port)))
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("gRPC server is running", zap.String("addr", l.Addr().String()))
+	log.Info("gRPC server is running",
+		zap.String("addr", l.Addr().String()),
+		zap.Bool("tls", a.cfg.TLS.Enabled))
+
+	go a.watchStorage(a.ctx)
+	if a.certs != nil {
+		go a.certs.Watch(a.ctx, a.cfg.TLS.ReloadInterval, a.log)
+	}
 
 	if err := a.gRPCServer.Serve(l); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
@@ -62,12 +303,34 @@ func (a *App) Run() error {
 	return err
 }
 
-// Stop stops gRPC server.
-func (a *App) Stop() {
+// Stop stops gRPC server, letting pending RPCs finish until ctx is done.
+func (a *App) Stop(ctx context.Context) error {
 	const op = "grpcapp.Stop"
 
 	a.log.With(zap.String("op", op)).
-		Info("gRPC server is stopping", zap.Int("port", a.port))
+		Info("gRPC server is stopping", zap.Int("port", a.cfg.Port))
 
-	a.gRPCServer.GracefulStop()
+	a.stop()
+	a.health.Shutdown()
+	// Watch streams never end on their own; closing the bus ends them so the server can drain.
+	if a.changes != nil {
+		a.changes.Close()
+	}
+	if a.gRPCServer == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.gRPCServer.GracefulStop()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		a.gRPCServer.Stop()
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
 }
@@ -0,0 +1,509 @@
+package grpcapp
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	ssov1 "github.com/Yuukiine/protos/gen/go/sso"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"shop/internal/config"
+	"shop/internal/domain/models"
+	accountsgrpc "shop/internal/grpc/accounts"
+	authgrpc "shop/internal/grpc/auth"
+	"shop/internal/services/cart"
+	"shop/internal/services/catalog"
+	"shop/internal/services/checkout"
+	"shop/internal/storage"
+	"shop/internal/storage/changes"
+	"shop/lib/jwt"
+	accountsv1 "shop/protos/gen/go/accounts"
+	cartv1 "shop/protos/gen/go/cart"
+	catalogv1 "shop/protos/gen/go/catalog"
+	ordersv1 "shop/protos/gen/go/orders"
+)
+
+const testUserID = 7
+
+type fakeCatalog struct{}
+
+func (fakeCatalog) Product(_ context.Context, id int64) (models.Product, error) {
+	if id != 1 {
+		return models.Product{}, catalog.ErrProductNotFound
+	}
+
+	return models.Product{ID: 1, Name: "Mug", Price: 10, Stock: 1}, nil
+}
+
+func (fakeCatalog) ListProducts(context.Context, models.ProductFilter, string, int) (catalog.ProductPage, error) {
+	return catalog.ProductPage{}, catalog.ErrInvalidPageToken
+}
+
+func (fakeCatalog) ProductsByIDs(context.Context, []int64) ([]models.Product, []int64, error) {
+	return nil, nil, nil
+}
+
+func (fakeCatalog) Categories(context.Context) ([]models.Category, error) {
+	return nil, nil
+}
+
+type fakeFeed struct {
+	bus *changes.Bus
+}
+
+func (fakeFeed) ProductChanges(context.Context, int64, int) ([]models.ProductChange, error) {
+	return nil, nil
+}
+
+func (fakeFeed) LastProductChange(context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (f fakeFeed) Changes() *changes.Bus {
+	return f.bus
+}
+
+// fakeCart holds one unit of product 1 in stock; other products don't exist.
+type fakeCart struct {
+	owner any
+}
+
+func (c *fakeCart) Contents(_ context.Context, owner any) (cart.Contents, error) {
+	c.owner = owner
+	return cart.Contents{}, nil
+}
+
+func (c *fakeCart) AddItem(_ context.Context, owner any, productID int64, quantity int) (cart.Contents, error) {
+	c.owner = owner
+	switch {
+	case productID != 1:
+		return cart.Contents{}, cart.ErrProductNotFound
+	case quantity > 1:
+		return cart.Contents{}, cart.ErrOutOfStock
+	}
+
+	return cart.Contents{}, nil
+}
+
+func (c *fakeCart) UpdateItem(context.Context, any, int64, int) (cart.Contents, error) {
+	return cart.Contents{}, cart.ErrNotInCart
+}
+
+func (c *fakeCart) RemoveItem(context.Context, any, int64) (cart.Contents, error) {
+	return cart.Contents{}, cart.ErrNotInCart
+}
+
+func (c *fakeCart) MergeGuestCart(context.Context, int64, string) (cart.Contents, error) {
+	return cart.Contents{}, cart.ErrSessionNotFound
+}
+
+// fakeCheckout has an empty cart and no orders.
+type fakeCheckout struct{}
+
+func (fakeCheckout) PlaceOrder(context.Context, int64) (models.Order, error) {
+	return models.Order{}, checkout.ErrEmptyCart
+}
+
+func (fakeCheckout) Order(context.Context, int64, int64) (models.Order, error) {
+	return models.Order{}, checkout.ErrOrderNotFound
+}
+
+func (fakeCheckout) Orders(context.Context, int64, string, int) (checkout.OrderPage, error) {
+	return checkout.OrderPage{}, checkout.ErrInvalidPageToken
+}
+
+func (fakeCheckout) CancelOrder(context.Context, int64, int64) (models.Order, error) {
+	return models.Order{}, checkout.ErrOrderFinalized
+}
+
+// fakeChecker grants no permissions.
+type fakeChecker struct{}
+
+func (fakeChecker) HasPermission(context.Context, int64, string) (bool, error) {
+	return false, nil
+}
+
+// fakeApps knows app 1, which signs the tokens of withToken.
+type fakeApps struct{}
+
+func (fakeApps) App(_ context.Context, appID int) (models.App, error) {
+	if appID != 1 {
+		return models.App{}, storage.ErrAppNotFound
+	}
+
+	return models.App{ID: 1, Secret: "test-secret"}, nil
+}
+
+type fakeAuth struct {
+	authgrpc.Auth
+}
+
+type fakeAccounts struct {
+	accountsgrpc.Accounts
+}
+
+type fakePinger struct{}
+
+func (fakePinger) Ping(context.Context) error {
+	return nil
+}
+
+// testServices returns the services of an embedded shop backed by the fakes and c.
+func testServices(c *fakeCart) Services {
+	return Services{
+		Auth:     fakeAuth{},
+		Catalog:  fakeCatalog{},
+		Feed:     fakeFeed{bus: changes.NewBus()},
+		Cart:     c,
+		Checkout: fakeCheckout{},
+	}
+}
+
+// serve starts the app's gRPC server on an in-memory listener and returns a client connection to it.
+func serve(t *testing.T, c *fakeCart) *grpc.ClientConn {
+	t.Helper()
+
+	a := New(zap.NewNop(), testServices(c), fakeChecker{}, fakeApps{}, fakePinger{},
+		config.GRPCConfig{Timeout: time.Second}, "test")
+
+	lis := bufconn.Listen(1 << 20)
+	go func() {
+		_ = a.gRPCServer.Serve(lis)
+	}()
+	t.Cleanup(a.gRPCServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+func withToken(t *testing.T) context.Context {
+	t.Helper()
+
+	token, err := jwt.NewToken(
+		models.User{ID: testUserID, Email: "user@example.com"},
+		models.App{ID: 1, Secret: "test-secret"},
+		[]string{models.RoleCustomer},
+		time.Hour,
+	)
+	if err != nil {
+		t.Fatalf("NewToken: %v", err)
+	}
+
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestStatusCodes(t *testing.T) {
+	c := &fakeCart{}
+	conn := serve(t, c)
+	catalogClient := catalogv1.NewCatalogClient(conn)
+	cartClient := cartv1.NewCartClient(conn)
+	ordersClient := ordersv1.NewOrdersClient(conn)
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context) error
+		want codes.Code
+	}{
+		{
+			name: "get product",
+			call: func(ctx context.Context) error {
+				_, err := catalogClient.GetProduct(ctx, &catalogv1.GetProductRequest{ProductId: 1})
+				return err
+			},
+			want: codes.OK,
+		},
+		{
+			name: "unknown product",
+			call: func(ctx context.Context) error {
+				_, err := catalogClient.GetProduct(ctx, &catalogv1.GetProductRequest{ProductId: 2})
+				return err
+			},
+			want: codes.NotFound,
+		},
+		{
+			name: "product without id",
+			call: func(ctx context.Context) error {
+				_, err := catalogClient.GetProduct(ctx, &catalogv1.GetProductRequest{})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "invalid page token",
+			call: func(ctx context.Context) error {
+				_, err := catalogClient.ListProducts(ctx, &catalogv1.ListProductsRequest{PageToken: "forged"})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "add to cart",
+			call: func(ctx context.Context) error {
+				_, err := cartClient.AddItem(ctx, &cartv1.AddItemRequest{ProductId: 1, Quantity: 1})
+				return err
+			},
+			want: codes.OK,
+		},
+		{
+			name: "add unknown product",
+			call: func(ctx context.Context) error {
+				_, err := cartClient.AddItem(ctx, &cartv1.AddItemRequest{ProductId: 2, Quantity: 1})
+				return err
+			},
+			want: codes.NotFound,
+		},
+		{
+			name: "add out of stock",
+			call: func(ctx context.Context) error {
+				_, err := cartClient.AddItem(ctx, &cartv1.AddItemRequest{ProductId: 1, Quantity: 2})
+				return err
+			},
+			want: codes.FailedPrecondition,
+		},
+		{
+			name: "add invalid quantity",
+			call: func(ctx context.Context) error {
+				_, err := cartClient.AddItem(ctx, &cartv1.AddItemRequest{ProductId: 1, Quantity: 100})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "remove missing item",
+			call: func(ctx context.Context) error {
+				_, err := cartClient.RemoveItem(ctx, &cartv1.RemoveItemRequest{ProductId: 1})
+				return err
+			},
+			want: codes.NotFound,
+		},
+		{
+			name: "place order with empty cart",
+			call: func(ctx context.Context) error {
+				_, err := ordersClient.PlaceOrder(ctx, &ordersv1.PlaceOrderRequest{})
+				return err
+			},
+			want: codes.FailedPrecondition,
+		},
+		{
+			name: "unknown order",
+			call: func(ctx context.Context) error {
+				_, err := ordersClient.GetOrder(ctx, &ordersv1.GetOrderRequest{OrderId: 1})
+				return err
+			},
+			want: codes.NotFound,
+		},
+		{
+			name: "cancel finalized order",
+			call: func(ctx context.Context) error {
+				_, err := ordersClient.CancelOrder(ctx, &ordersv1.CancelOrderRequest{OrderId: 1})
+				return err
+			},
+			want: codes.FailedPrecondition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call(withToken(t))
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("code = %v, want %v (%v)", got, tt.want, err)
+			}
+		})
+	}
+
+	if c.owner != int64(testUserID) {
+		t.Errorf("cart owner = %v, want the user of the token", c.owner)
+	}
+}
+
+func TestAuthz(t *testing.T) {
+	conn := serve(t, &fakeCart{})
+	catalogClient := catalogv1.NewCatalogClient(conn)
+	cartClient := cartv1.NewCartClient(conn)
+	authClient := ssov1.NewAuthClient(conn)
+
+	withHeader := func(value string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", value)
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		call func(ctx context.Context) error
+		want codes.Code
+	}{
+		{
+			name: "public method without token",
+			ctx:  context.Background(),
+			call: func(ctx context.Context) error {
+				_, err := catalogClient.GetProduct(ctx, &catalogv1.GetProductRequest{ProductId: 1})
+				return err
+			},
+			want: codes.OK,
+		},
+		{
+			name: "cart without token",
+			ctx:  context.Background(),
+			call: func(ctx context.Context) error {
+				_, err := cartClient.GetCart(ctx, &cartv1.GetCartRequest{})
+				return err
+			},
+			want: codes.Unauthenticated,
+		},
+		{
+			name: "cart without bearer scheme",
+			ctx:  withHeader("Basic dXNlcjpwYXNz"),
+			call: func(ctx context.Context) error {
+				_, err := cartClient.GetCart(ctx, &cartv1.GetCartRequest{})
+				return err
+			},
+			want: codes.Unauthenticated,
+		},
+		{
+			name: "cart with forged token",
+			ctx:  withHeader("Bearer forged"),
+			call: func(ctx context.Context) error {
+				_, err := cartClient.GetCart(ctx, &cartv1.GetCartRequest{})
+				return err
+			},
+			want: codes.Unauthenticated,
+		},
+		{
+			name: "cart with token",
+			ctx:  withToken(t),
+			call: func(ctx context.Context) error {
+				_, err := cartClient.GetCart(ctx, &cartv1.GetCartRequest{})
+				return err
+			},
+			want: codes.OK,
+		},
+		{
+			name: "admin method without permission",
+			ctx:  withToken(t),
+			call: func(ctx context.Context) error {
+				_, err := authClient.IsAdmin(ctx, &ssov1.IsAdminRequest{UserId: testUserID})
+				return err
+			},
+			want: codes.PermissionDenied,
+		},
+		{
+			name: "unauthenticated before validation",
+			ctx:  context.Background(),
+			call: func(ctx context.Context) error {
+				_, err := cartClient.AddItem(ctx, &cartv1.AddItemRequest{})
+				return err
+			},
+			want: codes.Unauthenticated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call(tt.ctx)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("code = %v, want %v (%v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+// TestStopWhileRunStarts stops the app while Run is starting, as a signal arriving right after
+// startup does; the race detector reports unsynchronized state between the two.
+func TestStopWhileRunStarts(t *testing.T) {
+	a := New(zap.NewNop(), testServices(&fakeCart{}), fakeChecker{}, fakeApps{}, fakePinger{},
+		config.GRPCConfig{Host: "127.0.0.1", Timeout: time.Second}, "test")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = a.Run()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.Stop(ctx); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after Stop")
+	}
+	if a.ctx.Err() == nil {
+		t.Error("background work of Run was not stopped")
+	}
+}
+
+func TestNewLocal(t *testing.T) {
+	services := testServices(&fakeCart{})
+	services.Auth = nil
+	a := NewLocal(zap.NewNop(), services, fakeChecker{}, fakeApps{}, fakePinger{}, time.Second)
+
+	if a.gRPCServer != nil || a.certs != nil {
+		t.Fatal("an app serving in-process only built a server")
+	}
+	want := []string{"", catalogv1.Catalog_ServiceDesc.ServiceName, cartv1.Cart_ServiceDesc.ServiceName,
+		ordersv1.Orders_ServiceDesc.ServiceName}
+	if got := a.HealthServices(); !slices.Equal(got, want) {
+		t.Errorf("health services = %v, want %v", got, want)
+	}
+
+	client := catalogv1.NewCatalogClient(a.ClientConn(func(context.Context) string { return "" }))
+	if _, err := client.GetProduct(context.Background(), &catalogv1.GetProductRequest{ProductId: 1}); err != nil {
+		t.Errorf("GetProduct in-process: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := a.Run(); err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	}()
+	if err := a.Stop(context.Background()); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after Stop")
+	}
+}
+
+func TestAuthServerServesOnlyAuth(t *testing.T) {
+	a := New(zap.NewNop(), Services{Auth: fakeAuth{}, Accounts: fakeAccounts{}}, fakeChecker{}, fakeApps{}, fakePinger{},
+		config.GRPCConfig{Timeout: time.Second}, "test")
+
+	want := []string{"", ssov1.Auth_ServiceDesc.ServiceName, accountsv1.Accounts_ServiceDesc.ServiceName}
+	if got := a.HealthServices(); !slices.Equal(got, want) {
+		t.Errorf("health services = %v, want %v", got, want)
+	}
+	for name := range a.gRPCServer.GetServiceInfo() {
+		if !slices.Contains(want, name) && name != healthpb.Health_ServiceDesc.ServiceName &&
+			!strings.HasPrefix(name, "grpc.reflection.") {
+			t.Errorf("auth server serves %s", name)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package grpcapp
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	healthCheckInterval = 5 * time.Second
+	pingTimeout         = time.Second
+)
+
+// Pinger reports whether the storage behind the services is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// watchStorage keeps the health status of every service in line with storage connectivity
+// until ctx is cancelled.
+func (a *App) watchStorage(ctx context.Context) {
+	const op = "grpcapp.watchStorage"
+
+	log := a.log.With(zap.String("op", op))
+
+	ticker := time.NewTicker(a.healthInterval)
+	defer ticker.Stop()
+
+	current := healthpb.HealthCheckResponse_UNKNOWN
+
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err := a.pinger.Ping(pingCtx)
+		cancel()
+
+		next := healthpb.HealthCheckResponse_SERVING
+		if err != nil {
+			next = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+
+		if next != current {
+			if err != nil {
+				log.Error("storage is unreachable", zap.Error(err))
+			} else {
+				log.Info("storage is reachable")
+			}
+
+			for _, service := range a.healthServices {
+				a.health.SetServingStatus(service, next)
+			}
+			current = next
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// HealthClient returns a health client served in-process by the app's health server.
+func (a *App) HealthClient() healthpb.HealthClient {
+	return &localHealthClient{server: a.health}
+}
+
+type localHealthClient struct {
+	server *health.Server
+}
+
+func (c *localHealthClient) Check(
+	ctx context.Context,
+	in *healthpb.HealthCheckRequest,
+	_ ...grpc.CallOption,
+) (*healthpb.HealthCheckResponse, error) {
+	return c.server.Check(ctx, in)
+}
+
+func (c *localHealthClient) List(
+	ctx context.Context,
+	in *healthpb.HealthListRequest,
+	_ ...grpc.CallOption,
+) (*healthpb.HealthListResponse, error) {
+	return c.server.List(ctx, in)
+}
+
+func (c *localHealthClient) Watch(
+	_ context.Context,
+	_ *healthpb.HealthCheckRequest,
+	_ ...grpc.CallOption,
+) (grpc.ServerStreamingClient[healthpb.HealthCheckResponse], error) {
+	return nil, status.Error(codes.Unimplemented, "watch is not supported in-process")
+}
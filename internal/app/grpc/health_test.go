@@ -0,0 +1,109 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"shop/internal/config"
+)
+
+// switchPinger fails while err is set.
+type switchPinger struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (p *switchPinger) Ping(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
+
+func (p *switchPinger) set(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.err = err
+}
+
+func newHealthApp(pinger Pinger) *App {
+	a := New(zap.NewNop(), testServices(&fakeCart{}), fakeChecker{}, fakeApps{}, pinger,
+		config.GRPCConfig{Timeout: time.Second}, "test")
+	a.healthInterval = 10 * time.Millisecond
+
+	return a
+}
+
+// waitForStatus fails the test unless every service reports want within a second.
+func waitForStatus(t *testing.T, a *App, want healthpb.HealthCheckResponse_ServingStatus) {
+	t.Helper()
+
+	client := a.HealthClient()
+	deadline := time.Now().Add(time.Second)
+	for _, service := range a.HealthServices() {
+		for {
+			resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+			if err != nil {
+				t.Fatalf("Check(%q): %v", service, err)
+			}
+			if resp.GetStatus() == want {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("service %q is %s, want %s", service, resp.GetStatus(), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+func TestHealthFollowsStorage(t *testing.T) {
+	pinger := &switchPinger{err: errors.New("database is locked")}
+	a := newHealthApp(pinger)
+
+	waitForStatus(t, a, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.watchStorage(ctx)
+
+	// A failing probe at start keeps every service NOT_SERVING.
+	time.Sleep(3 * a.healthInterval)
+	waitForStatus(t, a, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	pinger.set(nil)
+	waitForStatus(t, a, healthpb.HealthCheckResponse_SERVING)
+
+	pinger.set(errors.New("database is locked"))
+	waitForStatus(t, a, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	pinger.set(nil)
+	waitForStatus(t, a, healthpb.HealthCheckResponse_SERVING)
+}
+
+func TestHealthIsNotServingAfterStop(t *testing.T) {
+	a := newHealthApp(fakePinger{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.watchStorage(ctx)
+	waitForStatus(t, a, healthpb.HealthCheckResponse_SERVING)
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), time.Second)
+	defer stopCancel()
+	if err := a.Stop(stopCtx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	waitForStatus(t, a, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	// Probes still succeeding don't bring the services back while the server drains.
+	time.Sleep(3 * a.healthInterval)
+	waitForStatus(t, a, healthpb.HealthCheckResponse_NOT_SERVING)
+}
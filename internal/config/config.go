@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
@@ -10,22 +11,230 @@ import (
 )
 
 type Config struct {
-	Env         string        `yaml:"env" env-default:"local"`
-	StoragePath string        `yaml:"storage_path" env-required:"./storage"`
-	TokenTTL    time.Duration `yaml:"token_ttl" env-required:"true"`
-	HTTPServer  `yaml:"http_server"`
-	GRPC        GRPCConfig `yaml:"grpc"`
+	Env             string        `yaml:"env" env-default:"local"`
+	StoragePath     string        `yaml:"storage_path" env-required:"./storage"`
+	TokenTTL        time.Duration `yaml:"token_ttl" env-required:"true"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"15s"`
+	HTTPServer      `yaml:"http_server"`
+	GRPC            GRPCConfig       `yaml:"grpc"`
+	Auth            AuthConfig       `yaml:"auth"`
+	Lockout         LockoutConfig    `yaml:"lockout"`
+	OIDC            []OIDCProvider   `yaml:"oidc"`
+	Password        PasswordConfig   `yaml:"password"`
+	Events          EventsConfig     `yaml:"events"`
+	Outbox          OutboxConfig     `yaml:"outbox"`
+	Notifier        NotifierConfig   `yaml:"notifier"`
+	Webhooks        WebhooksConfig   `yaml:"webhooks"`
+	CartEvents      CartEventsConfig `yaml:"cart_events"`
+	I18n            I18nConfig       `yaml:"i18n"`
+	Metrics         MetricsConfig    `yaml:"metrics"`
+}
+
+const redacted = "[redacted]"
+
+// Redacted returns a copy of the config with the secrets masked, safe to log.
+func (c Config) Redacted() Config {
+	c.OIDC = slices.Clone(c.OIDC)
+	for i := range c.OIDC {
+		c.OIDC[i].ClientSecret = redact(c.OIDC[i].ClientSecret)
+	}
+	c.Notifier.SMTP.Password = redact(c.Notifier.SMTP.Password)
+
+	return c
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	return redacted
 }
 
 type GRPCConfig struct {
+	Host    string        `yaml:"host"`
 	Port    int           `yaml:"port"`
 	Timeout time.Duration `yaml:"timeout"`
+	TLS     GRPCTLSConfig `yaml:"tls"`
+}
+
+const (
+	AuthModeEmbedded = "embedded"
+	AuthModeRemote   = "remote"
+)
+
+// AuthConfig tells cmd/shop where the auth service runs. In embedded mode the shop serves gRPC
+// itself and calls auth in-process. In remote mode it builds no gRPC server and no auth
+// service: catalog, cart and orders are served in-process only, and every auth call, from
+// login to role admin, permission checks and OIDC logins, goes to the cmd/sso server at
+// Address over mutual TLS, using the client settings of GRPC.TLS. sso only answers the
+// accounts methods and believes forwarded client IPs for a caller with a verified certificate.
+// Admins are the emails of users granted the admin role at startup by the process running
+// the auth service, to create the first admin.
+type AuthConfig struct {
+	Mode    string   `yaml:"mode" env-default:"embedded"`
+	Address string   `yaml:"address"`
+	Admins  []string `yaml:"admins"`
+}
+
+// GRPCTLSConfig enables TLS on the gRPC channel. CertFile, KeyFile and ClientCAFile are used by
+// the server; setting ClientCAFile requires clients to present a certificate signed by it (mTLS).
+// CAFile, ClientCertFile, ClientKeyFile and ServerName are used by the client.
+// Changed files are picked up every ReloadInterval.
+type GRPCTLSConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	CertFile       string        `yaml:"cert_file"`
+	KeyFile        string        `yaml:"key_file"`
+	ClientCAFile   string        `yaml:"client_ca_file"`
+	CAFile         string        `yaml:"ca_file"`
+	ClientCertFile string        `yaml:"client_cert_file"`
+	ClientKeyFile  string        `yaml:"client_key_file"`
+	ServerName     string        `yaml:"server_name"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env-default:"30s"`
+}
+
+// LockoutConfig describes how failed logins are throttled.
+// The first FreeAttempts failures are not delayed; every later one delays the next attempt by
+// BaseDelay doubled per failure (capped at MaxDelay), and reaching the max attempts locks the
+// email or IP for LockoutDuration.
+type LockoutConfig struct {
+	FreeAttempts     int           `yaml:"free_attempts" env-default:"3"`
+	MaxEmailAttempts int           `yaml:"max_email_attempts" env-default:"5"`
+	MaxIPAttempts    int           `yaml:"max_ip_attempts" env-default:"20"`
+	BaseDelay        time.Duration `yaml:"base_delay" env-default:"1s"`
+	MaxDelay         time.Duration `yaml:"max_delay" env-default:"1m"`
+	LockoutDuration  time.Duration `yaml:"lockout_duration" env-default:"15m"`
+}
+
+// OIDCProvider is an external identity provider users can sign in with.
+type OIDCProvider struct {
+	Name         string   `yaml:"name"`
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// PasswordConfig describes the password policy and how passwords are hashed.
+// Stored hashes made with another algorithm or cost are upgraded on the next successful login.
+type PasswordConfig struct {
+	MinLength        int          `yaml:"min_length" env-default:"8"`
+	BreachedListPath string       `yaml:"breached_list_path"`
+	Algorithm        string       `yaml:"algorithm" env-default:"argon2id"`
+	BcryptCost       int          `yaml:"bcrypt_cost" env-default:"10"`
+	Argon2           Argon2Config `yaml:"argon2"`
+}
+
+type Argon2Config struct {
+	Time       uint32 `yaml:"time" env-default:"1"`
+	Memory     uint32 `yaml:"memory" env-default:"65536"`
+	Threads    uint8  `yaml:"threads" env-default:"4"`
+	KeyLength  uint32 `yaml:"key_length" env-default:"32"`
+	SaltLength uint32 `yaml:"salt_length" env-default:"16"`
+}
+
+const (
+	EventsDriverMemory = "memory"
+	EventsDriverKafka  = "kafka"
+)
+
+// EventsConfig selects the event broker. The memory driver needs no external services
+// but loses undelivered events on restart.
+type EventsConfig struct {
+	Driver   string         `yaml:"driver" env-default:"memory"`
+	Kafka    KafkaConfig    `yaml:"kafka"`
+	Consumer ConsumerConfig `yaml:"consumer"`
 }
 
+// ConsumerConfig sets how consumers retry a failing message: Attempts times in place with
+// Backoff doubled per attempt, at most MaxBackoff, then once per retry topic after each of
+// RetryDelays, before giving up and dead-lettering it.
+type ConsumerConfig struct {
+	Attempts    int             `yaml:"attempts" env-default:"3"`
+	Backoff     time.Duration   `yaml:"backoff" env-default:"100ms"`
+	MaxBackoff  time.Duration   `yaml:"max_backoff" env-default:"2s"`
+	RetryDelays []time.Duration `yaml:"retry_delays" env-default:"10s,1m,10m"`
+}
+
+type KafkaConfig struct {
+	Brokers  []string `yaml:"brokers" env-default:"localhost:9092"`
+	ClientID string   `yaml:"client_id" env-default:"shop"`
+	Retries  int      `yaml:"retries" env-default:"5"`
+}
+
+// OutboxConfig tunes the relay publishing the outbox. A failed message is retried after
+// BaseBackoff doubled per failed attempt, at most MaxBackoff, and parked after MaxAttempts.
+type OutboxConfig struct {
+	Interval    time.Duration `yaml:"interval" env-default:"1s"`
+	BatchSize   int           `yaml:"batch_size" env-default:"100"`
+	MaxAttempts int           `yaml:"max_attempts" env-default:"10"`
+	BaseBackoff time.Duration `yaml:"base_backoff" env-default:"1s"`
+	MaxBackoff  time.Duration `yaml:"max_backoff" env-default:"5m"`
+}
+
+// WebhooksConfig tunes webhook delivery. A failed delivery is retried after BaseBackoff
+// doubled per failed attempt, at most MaxBackoff, and given up after MaxAttempts.
+type WebhooksConfig struct {
+	Timeout     time.Duration `yaml:"timeout" env-default:"10s"`
+	Interval    time.Duration `yaml:"interval" env-default:"1s"`
+	BatchSize   int           `yaml:"batch_size" env-default:"50"`
+	MaxAttempts int           `yaml:"max_attempts" env-default:"8"`
+	BaseBackoff time.Duration `yaml:"base_backoff" env-default:"30s"`
+	MaxBackoff  time.Duration `yaml:"max_backoff" env-default:"1h"`
+}
+
+// CartEventsConfig tunes the live cart updates streamed to pages. Heartbeat is how often an
+// idle stream is written to so proxies keep it open; MaxConnections caps the streams of a
+// user or guest session, MaxConnectionsPerIP those of a client IP and MaxStreams all of them.
+type CartEventsConfig struct {
+	Heartbeat           time.Duration `yaml:"heartbeat" env-default:"15s"`
+	MaxConnections      int           `yaml:"max_connections" env-default:"5"`
+	MaxConnectionsPerIP int           `yaml:"max_connections_per_ip" env-default:"20"`
+	MaxStreams          int           `yaml:"max_streams" env-default:"1000"`
+}
+
+// I18nConfig configures the translations. DefaultLocale is used when neither the URL,
+// the cookie nor the Accept-Language header names a supported locale.
+type I18nConfig struct {
+	DefaultLocale string `yaml:"default_locale" env-default:"en"`
+}
+
+// MetricsConfig sets where the Prometheus metrics are served: a listener of their own at
+// Address, kept off the public web server and meant to be reachable only by the scraper.
+type MetricsConfig struct {
+	Address string `yaml:"address" env-default:"localhost:9091"`
+}
+
+const (
+	EmailSenderSMTP = "smtp"
+	EmailSenderFile = "file"
+)
+
+// NotifierConfig configures the emails of the notifier. Sender "file" writes them into FileDir
+// instead of sending. BaseURL is the shop address links in emails point to.
+type NotifierConfig struct {
+	Sender  string     `yaml:"sender" env-default:"smtp"`
+	From    string     `yaml:"from" env:"EMAIL_ADDRESS"`
+	BaseURL string     `yaml:"base_url" env-default:"http://localhost:8082"`
+	FileDir string     `yaml:"file_dir" env-default:"./storage/mail"`
+	SMTP    SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" env-default:"smtp.gmail.com"`
+	Port     int    `yaml:"port" env-default:"587"`
+	Username string `yaml:"username" env:"EMAIL_ADDRESS"`
+	Password string `yaml:"password" env:"EMAIL_PASSWORD"`
+}
+
+// HTTPServer configures the web server. X-Forwarded-For and X-Real-IP are only believed from
+// TrustedProxies, the IPs or CIDR ranges of the reverse proxies in front of the shop.
 type HTTPServer struct {
-	Address     string        `yaml:"address" env-default:":8082"`
-	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
-	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	Address        string        `yaml:"address" env-default:":8082"`
+	Timeout        time.Duration `yaml:"timeout" env-default:"4s"`
+	IdleTimeout    time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	TrustedProxies []string      `yaml:"trusted_proxies"`
 }
 
 func MustLoad() *Config {
@@ -50,5 +259,34 @@ func MustLoad() *Config {
 		log.Fatalf("cannot read config: %v", err)
 	}
 
+	switch cfg.Auth.Mode {
+	case AuthModeEmbedded:
+	case AuthModeRemote:
+		if cfg.Auth.Address == "" {
+			log.Fatal("auth.address is required in remote auth mode")
+		}
+		if tls := cfg.GRPC.TLS; !tls.Enabled || tls.ClientCertFile == "" || tls.ClientKeyFile == "" {
+			log.Fatal("grpc.tls with a client certificate is required in remote auth mode")
+		}
+	default:
+		log.Fatalf("unknown auth mode: %q", cfg.Auth.Mode)
+	}
+
+	switch cfg.Notifier.Sender {
+	case EmailSenderSMTP, EmailSenderFile:
+	default:
+		log.Fatalf("unknown notifier sender: %q", cfg.Notifier.Sender)
+	}
+
+	switch cfg.Events.Driver {
+	case EventsDriverMemory:
+	case EventsDriverKafka:
+		if len(cfg.Events.Kafka.Brokers) == 0 {
+			log.Fatal("events.kafka.brokers is required with the kafka events driver")
+		}
+	default:
+		log.Fatalf("unknown events driver: %q", cfg.Events.Driver)
+	}
+
 	return &cfg
 }
@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestRedacted(t *testing.T) {
+	cfg := Config{
+		OIDC:     []OIDCProvider{{Name: "google", ClientSecret: "oidc-secret"}, {Name: "public"}},
+		Notifier: NotifierConfig{SMTP: SMTPConfig{Username: "shop", Password: "smtp-secret"}},
+	}
+
+	got := cfg.Redacted()
+
+	if got.OIDC[0].ClientSecret != redacted || got.Notifier.SMTP.Password != redacted {
+		t.Errorf("Redacted kept secrets: %+v", got)
+	}
+	if got.OIDC[1].ClientSecret != "" {
+		t.Errorf("Redacted masked an unset secret: %q", got.OIDC[1].ClientSecret)
+	}
+	if got.OIDC[0].Name != "google" || got.Notifier.SMTP.Username != "shop" {
+		t.Errorf("Redacted dropped settings: %+v", got)
+	}
+	if cfg.OIDC[0].ClientSecret != "oidc-secret" || cfg.Notifier.SMTP.Password != "smtp-secret" {
+		t.Error("Redacted changed the original config")
+	}
+}
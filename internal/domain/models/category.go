@@ -0,0 +1,6 @@
+package models
+
+type Category struct {
+	ID   int64  `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// DeadLetter is a message a consumer group gave up on, kept for inspection and replay.
+type DeadLetter struct {
+	ID            int64
+	Group         string
+	OriginalTopic string
+	ReplayTopic   string // where a replay is published, so only Group receives it again
+	Key           string
+	Value         []byte
+	Headers       map[string]string
+	Error         string
+	Attempts      int
+	FailedAt      time.Time
+	ReplayedAt    *time.Time
+}
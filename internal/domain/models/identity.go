@@ -0,0 +1,10 @@
+package models
+
+// Identity links a user to an account at an external OpenID Connect provider.
+type Identity struct {
+	ID       int64  `json:"id" db:"id"`
+	UserID   int64  `json:"user_id" db:"user_id"`
+	Provider string `json:"provider" db:"provider"`
+	Subject  string `json:"subject" db:"subject"`
+	Email    string `json:"email" db:"email"`
+}
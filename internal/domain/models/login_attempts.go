@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type LoginAttempts struct {
+	Key          string    `json:"key" db:"key"`
+	Failures     int       `json:"failures" db:"failures"`
+	LastFailedAt time.Time `json:"last_failed_at" db:"last_failed_at"`
+	LockedUntil  time.Time `json:"locked_until" db:"locked_until"`
+	LastIP       string    `json:"last_ip" db:"last_ip"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// NotificationPreferences says which optional emails a user gets. Security emails,
+// such as password resets, are always sent.
+type NotificationPreferences struct {
+	UserID  int64
+	Account bool // welcome and other account news
+	Orders  bool // order confirmations
+}
+
+// DefaultNotificationPreferences are the preferences of users who have not changed them.
+func DefaultNotificationPreferences(userID int64) NotificationPreferences {
+	return NotificationPreferences{UserID: userID, Account: true, Orders: true}
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	OrderStatusPlaced    = "placed"
+	OrderStatusCancelled = "cancelled"
+)
+
+type Order struct {
+	ID        int64       `json:"id" db:"id"`
+	UserID    int64       `json:"user_id" db:"user_id"`
+	Status    string      `json:"status" db:"status"`
+	Items     []OrderItem `json:"items"`
+	Subtotal  float64     `json:"subtotal" db:"subtotal"`
+	Shipping  float64     `json:"shipping" db:"shipping"`
+	Tax       float64     `json:"tax" db:"tax"`
+	Total     float64     `json:"total" db:"total"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+}
+
+type OrderItem struct {
+	ProductID   int64   `json:"product_id" db:"product_id"`
+	ProductName string  `json:"product_name" db:"product_name"`
+	Price       float64 `json:"price" db:"price"`
+	Quantity    int     `json:"quantity" db:"quantity"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OutboxMessage is an event saved together with the change that caused it, waiting to be published.
+type OutboxMessage struct {
+	ID            int64
+	Topic         string
+	Key           string
+	Value         []byte
+	Headers       map[string]string
+	CreatedAt     time.Time
+	Attempts      int
+	NextAttemptAt time.Time
+}
@@ -8,3 +8,12 @@ type Product struct {
 	Stock       int     `json:"stock" db:"stock"`
 	Category    string  `json:"category_id" db:"category_id"`
 }
+
+// ProductFilter narrows a product listing; zero values mean no restriction.
+type ProductFilter struct {
+	Category    string
+	Query       string
+	MinPrice    float64
+	MaxPrice    float64
+	InStockOnly bool
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+const (
+	ProductChangeStock = "stock"
+	ProductChangePrice = "price"
+)
+
+// ProductChange is a stock or price change of a product, numbered by Seq in the order of writes.
+type ProductChange struct {
+	Seq       int64
+	ProductID int64
+	Category  string
+	Kind      string
+	Price     float64
+	Stock     int
+	ChangedAt time.Time
+}
@@ -0,0 +1,31 @@
+package models
+
+const (
+	RoleCustomer       = "customer"
+	RoleSupport        = "support"
+	RoleCatalogManager = "catalog_manager"
+	RoleAdmin          = "admin"
+)
+
+const (
+	PermCatalogWrite   = "catalog:write"
+	PermOrdersRead     = "orders:read"
+	PermOrdersManage   = "orders:manage"
+	PermUsersRead      = "users:read"
+	PermUsersUnlock    = "users:unlock"
+	PermRolesManage    = "roles:manage"
+	PermEventsManage   = "events:manage"
+	PermWebhooksManage = "webhooks:manage"
+)
+
+type Role struct {
+	ID          int      `json:"id" db:"id"`
+	Name        string   `json:"name" db:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+type UserRoles struct {
+	UserID int      `json:"user_id" db:"user_id"`
+	Email  string   `json:"email" db:"email"`
+	Roles  []string `json:"roles"`
+}
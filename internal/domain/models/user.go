@@ -5,3 +5,9 @@ type User struct {
 	Email    string `json:"email" db:"email"`
 	PassHash []byte `json:"pass_hash" db:"pass_hash"`
 }
+
+// How a user account was created.
+const (
+	RegistrationPassword = "password"
+	RegistrationOIDC     = "oidc"
+)
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook is a partner endpoint receiving the events of the listed types.
+type Webhook struct {
+	ID        int64
+	URL       string
+	Events    []string
+	Secret    string
+	Active    bool
+	CreatedAt time.Time
+}
+
+// WebhookDelivery is an event to deliver to a webhook; URL and Secret are those of the webhook.
+type WebhookDelivery struct {
+	ID            int64
+	WebhookID     int64
+	URL           string
+	Secret        string
+	EventID       string
+	EventType     string
+	Payload       []byte
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	ResponseCode  int
+	LastError     string
+	CreatedAt     time.Time
+	DeliveredAt   *time.Time
+}
+
+// WebhookAttempt is one request made for a delivery. ResponseCode is 0 if no response arrived.
+type WebhookAttempt struct {
+	ID           int64
+	DeliveryID   int64
+	EventType    string
+	AttemptedAt  time.Time
+	ResponseCode int
+	Error        string
+	Duration     time.Duration
+}
@@ -0,0 +1,105 @@
+package events
+
+import (
+	"errors"
+	"time"
+
+	"shop/internal/domain/models"
+)
+
+// Default is the registry of the domain events below, used by Subscribe. errDefault is the
+// error registering them, returned by Subscribe.
+var Default, errDefault = newDefaultRegistry()
+
+var (
+	TopicUserRegistered         = Topic[UserRegistered]{Name: "users.registered"}
+	TopicPasswordResetRequested = Topic[PasswordResetRequested]{Name: "users.password_reset_requested"}
+	TopicOrderPlaced            = Topic[OrderPlaced]{Name: "orders.placed"}
+	TopicCartItemAdded          = Topic[CartItemAdded]{Name: "carts.item_added"}
+	TopicStockChanged           = Topic[StockChanged]{Name: "inventory.stock_changed", Codec: Protobuf}
+)
+
+// UserRegistered is published after a new account is created. Method is one of the
+// models.Registration* constants.
+//
+// v2 added Method and dropped registered_at, which the envelope carries as occurred_at.
+type UserRegistered struct {
+	UserID int64  `json:"user_id"`
+	Email  string `json:"email"`
+	Method string `json:"method"`
+}
+
+func (UserRegistered) EventType() string { return "user.registered" }
+func (UserRegistered) EventVersion() int { return 2 }
+
+// PasswordResetRequested is published when a user asks to reset a forgotten password.
+type PasswordResetRequested struct {
+	UserID    int64     `json:"user_id"`
+	Email     string    `json:"email"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (PasswordResetRequested) EventType() string { return "user.password_reset_requested" }
+func (PasswordResetRequested) EventVersion() int { return 1 }
+
+// OrderPlaced is published after an order is saved and its items are taken out of stock.
+type OrderPlaced struct {
+	OrderID int64             `json:"order_id"`
+	UserID  int64             `json:"user_id"`
+	Items   []OrderPlacedItem `json:"items"`
+	Total   float64           `json:"total"`
+}
+
+type OrderPlacedItem struct {
+	ProductID int64   `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	Price     float64 `json:"price"`
+}
+
+func (OrderPlaced) EventType() string { return "order.placed" }
+func (OrderPlaced) EventVersion() int { return 1 }
+
+// CartItemAdded is published when a product is put into a cart. CartID is the user ID
+// of a signed in user or the session ID of a guest.
+type CartItemAdded struct {
+	CartID    string `json:"cart_id"`
+	ProductID int64  `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+func (CartItemAdded) EventType() string { return "cart.item_added" }
+func (CartItemAdded) EventVersion() int { return 1 }
+
+// StockChanged is published when the stock of a product changes.
+type StockChanged struct {
+	ProductID int64 `json:"product_id"`
+	Stock     int   `json:"stock"`
+	Delta     int   `json:"delta"`
+}
+
+func (StockChanged) EventType() string { return "inventory.stock_changed" }
+func (StockChanged) EventVersion() int { return 1 }
+
+func newDefaultRegistry() (*Registry, error) {
+	r := NewRegistry()
+
+	err := errors.Join(
+		Register[UserRegistered](r, upcastUserRegisteredV1),
+		Register[PasswordResetRequested](r),
+		Register[OrderPlaced](r),
+		Register[CartItemAdded](r),
+		Register[StockChanged](r),
+	)
+
+	return r, err
+}
+
+// upcastUserRegisteredV1 upcasts registrations published before sign in methods were recorded;
+// those could only be password registrations.
+func upcastUserRegisteredV1(data map[string]any) (map[string]any, error) {
+	delete(data, "registered_at")
+	data["method"] = models.RegistrationPassword
+
+	return data, nil
+}
@@ -0,0 +1,309 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/events"
+)
+
+// Headers added to messages moved to a retry or dead-letter topic.
+const (
+	OriginalTopicHeader = "x-original-topic"
+	RetryAfterHeader    = "x-retry-after"
+	AttemptsHeader      = "x-attempts"
+	ErrorHeader         = "x-error"
+	FailedAtHeader      = "x-failed-at"
+)
+
+// RetryTopic is the topic of the given retry stage, counted from 1, of a consumer group.
+func RetryTopic(topic, group string, stage int) string {
+	return fmt.Sprintf("%s.%s.retry.%d", topic, group, stage)
+}
+
+// ReplayTopic is the topic dead letters of a consumer group are replayed to. It reaches only
+// the group and is handled like the topic itself, so a replay gets the full set of attempts.
+func ReplayTopic(topic, group string) string {
+	return topic + "." + group + ".replay"
+}
+
+// DeadLetterTopic is the topic receiving the messages the consumer group gave up on.
+func DeadLetterTopic(topic, group string) string {
+	return topic + "." + group + ".dlq"
+}
+
+type Storage interface {
+	EventProcessed(ctx context.Context, group, eventID string) (bool, error)
+	MarkEventProcessed(ctx context.Context, group, eventID string) error
+	SaveDeadLetter(ctx context.Context, dl models.DeadLetter) (int64, error)
+}
+
+// Broker is where the consumer reads messages and publishes the ones to retry.
+type Broker interface {
+	events.Publisher
+	events.Subscriber
+}
+
+var (
+	retriedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "shop",
+		Subsystem: "consumer",
+		Name:      "retried_total",
+		Help:      "Messages moved to a retry topic.",
+	}, []string{"topic", "group"})
+	deadLetteredCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "shop",
+		Subsystem: "consumer",
+		Name:      "dead_lettered_total",
+		Help:      "Messages moved to the dead-letter topic.",
+	}, []string{"topic", "group"})
+	duplicatesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "shop",
+		Subsystem: "consumer",
+		Name:      "duplicates_total",
+		Help:      "Messages skipped because the group had already processed them.",
+	}, []string{"topic", "group"})
+)
+
+func init() {
+	prometheus.MustRegister(retriedCounter, deadLetteredCounter, duplicatesCounter)
+}
+
+// Consumer is an events.Subscriber that makes handlers safe to fail. A failing message is
+// retried in place with exponential backoff up to attempts times, then moved along the
+// ladder of retry topics, each delivered after its delay, and finally to the dead-letter
+// topic, where it is archived for replay. Errors marked events.Permanent skip the ladder.
+// Messages already processed by the group are skipped, so redeliveries are harmless.
+type Consumer struct {
+	log        *zap.Logger
+	broker     Broker
+	storage    Storage
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	delays     []time.Duration
+}
+
+func New(
+	log *zap.Logger,
+	broker Broker,
+	storage Storage,
+	attempts int,
+	backoff time.Duration,
+	maxBackoff time.Duration,
+	delays []time.Duration,
+) *Consumer {
+	return &Consumer{
+		log:        log.With(zap.String("component", "events/consumer")),
+		broker:     broker,
+		storage:    storage,
+		attempts:   max(attempts, 1),
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+		delays:     delays,
+	}
+}
+
+// Subscribe subscribes handler to the topic, its replay topic and its retry topics, and
+// archives the messages reaching the dead-letter topic of the group.
+func (c *Consumer) Subscribe(ctx context.Context, topic, group string, handler events.Handler) error {
+	const op = "consumer.Subscribe"
+
+	for _, t := range []string{topic, ReplayTopic(topic, group)} {
+		if err := c.broker.Subscribe(ctx, t, group, c.handle(topic, group, 0, handler)); err != nil {
+			return fmt.Errorf("%s, %w", op, err)
+		}
+	}
+
+	for stage := 1; stage <= len(c.delays); stage++ {
+		retryTopic := RetryTopic(topic, group, stage)
+		if err := c.broker.Subscribe(ctx, retryTopic, group, c.handle(topic, group, stage, handler)); err != nil {
+			return fmt.Errorf("%s, %w", op, err)
+		}
+	}
+
+	if err := c.broker.Subscribe(ctx, DeadLetterTopic(topic, group), group+".dlq", c.archive(topic, group)); err != nil {
+		return fmt.Errorf("%s, %w", op, err)
+	}
+
+	return nil
+}
+
+// handle wraps handler for the messages of the given stage, 0 being the topic itself and
+// its replay topic.
+func (c *Consumer) handle(topic, group string, stage int, handler events.Handler) events.Handler {
+	log := c.log.With(zap.String("topic", topic), zap.String("group", group), zap.Int("stage", stage))
+
+	return func(ctx context.Context, msg events.Message) error {
+		// Delays only grow along the ladder, so waiting for the head of a retry topic
+		// never holds back a message that is due earlier.
+		if retryAfter, err := time.Parse(time.RFC3339Nano, msg.Headers[RetryAfterHeader]); err == nil {
+			if err := sleep(ctx, time.Until(retryAfter)); err != nil {
+				return err
+			}
+		}
+
+		id := events.MessageID(msg)
+		if id != "" {
+			processed, err := c.storage.EventProcessed(ctx, group, id)
+			if err != nil {
+				// Handling it again is safer than dropping it.
+				log.Error("failed to check processed event", zap.String("event_id", id), zap.Error(err))
+			}
+			if processed {
+				duplicatesCounter.WithLabelValues(topic, group).Inc()
+				log.Debug("skipping processed event", zap.String("event_id", id))
+				return nil
+			}
+		}
+
+		var (
+			err      error
+			attempts int
+		)
+		for attempts = 1; ; attempts++ {
+			err = handler(ctx, msg)
+			if err == nil || events.IsPermanent(err) || attempts == c.attempts {
+				break
+			}
+			if err := sleep(ctx, c.delay(attempts)); err != nil {
+				return err
+			}
+		}
+		if ctx.Err() != nil {
+			// Stopping, not failing: leave the message to the broker to redeliver.
+			return ctx.Err()
+		}
+
+		if err == nil {
+			if id != "" {
+				if err := c.storage.MarkEventProcessed(ctx, group, id); err != nil {
+					log.Error("failed to mark event processed", zap.String("event_id", id), zap.Error(err))
+				}
+			}
+			return nil
+		}
+
+		return c.escalate(ctx, log, msg, topic, group, stage, attempts, err)
+	}
+}
+
+// escalate moves a message that failed at the stage to the next retry topic, or to the
+// dead-letter topic if the ladder is exhausted or the error is permanent.
+func (c *Consumer) escalate(
+	ctx context.Context,
+	log *zap.Logger,
+	msg events.Message,
+	topic, group string,
+	stage, attempts int,
+	cause error,
+) error {
+	const op = "consumer.escalate"
+
+	previous, _ := strconv.Atoi(msg.Headers[AttemptsHeader])
+	now := time.Now().UTC()
+
+	headers := maps.Clone(msg.Headers)
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	headers[OriginalTopicHeader] = topic
+	headers[AttemptsHeader] = strconv.Itoa(previous + attempts)
+	headers[ErrorHeader] = cause.Error()
+	headers[FailedAtHeader] = now.Format(time.RFC3339Nano)
+	delete(headers, RetryAfterHeader)
+
+	next := events.Message{Key: msg.Key, Value: msg.Value, Headers: headers}
+
+	if stage < len(c.delays) && !events.IsPermanent(cause) {
+		next.Topic = RetryTopic(topic, group, stage+1)
+		headers[RetryAfterHeader] = now.Add(c.delays[stage]).Format(time.RFC3339Nano)
+		retriedCounter.WithLabelValues(topic, group).Inc()
+		log.Warn("failed to handle message, retrying later",
+			zap.String("key", msg.Key),
+			zap.String("retry_topic", next.Topic),
+			zap.Duration("delay", c.delays[stage]),
+			zap.Error(cause))
+	} else {
+		next.Topic = DeadLetterTopic(topic, group)
+		deadLetteredCounter.WithLabelValues(topic, group).Inc()
+		log.Error("failed to handle message, dead-lettering",
+			zap.String("key", msg.Key),
+			zap.String("dead_letter_topic", next.Topic),
+			zap.Error(cause))
+	}
+
+	if err := c.broker.Publish(ctx, next); err != nil {
+		return fmt.Errorf("%s: failed to move message to %s: %w", op, next.Topic, err)
+	}
+
+	return nil
+}
+
+// archive saves the messages of the dead-letter topic so they can be inspected and replayed.
+func (c *Consumer) archive(topic, group string) events.Handler {
+	replayTopic := ReplayTopic(topic, group)
+
+	return func(ctx context.Context, msg events.Message) error {
+		attempts, _ := strconv.Atoi(msg.Headers[AttemptsHeader])
+		failedAt, err := time.Parse(time.RFC3339Nano, msg.Headers[FailedAtHeader])
+		if err != nil {
+			failedAt = time.Now().UTC()
+		}
+
+		id, err := c.storage.SaveDeadLetter(ctx, models.DeadLetter{
+			Group:         group,
+			OriginalTopic: topic,
+			ReplayTopic:   replayTopic,
+			Key:           msg.Key,
+			Value:         msg.Value,
+			Headers:       msg.Headers,
+			Error:         msg.Headers[ErrorHeader],
+			Attempts:      attempts,
+			FailedAt:      failedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("consumer.archive: %w", err)
+		}
+
+		c.log.Info("dead letter archived", zap.Int64("id", id), zap.String("topic", topic), zap.String("group", group))
+
+		return nil
+	}
+}
+
+// delay returns the backoff after the given number of failed attempts.
+func (c *Consumer) delay(attempts int) time.Duration {
+	delay := c.backoff
+	for range attempts - 1 {
+		delay *= 2
+		if delay >= c.maxBackoff {
+			return c.maxBackoff
+		}
+	}
+
+	return delay
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,244 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/events"
+	"shop/internal/events/memory"
+	"shop/internal/storage"
+)
+
+// fakeStorage keeps processed events and dead letters in memory and reports every dead letter.
+type fakeStorage struct {
+	mu          sync.Mutex
+	processed   map[string]bool
+	deadLetters []models.DeadLetter
+	archived    chan models.DeadLetter
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{processed: make(map[string]bool), archived: make(chan models.DeadLetter, 10)}
+}
+
+func (s *fakeStorage) EventProcessed(_ context.Context, group, eventID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.processed[group+"|"+eventID], nil
+}
+
+func (s *fakeStorage) MarkEventProcessed(_ context.Context, group, eventID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.processed[group+"|"+eventID] = true
+	return nil
+}
+
+func (s *fakeStorage) SaveDeadLetter(_ context.Context, dl models.DeadLetter) (int64, error) {
+	s.mu.Lock()
+	dl.ID = int64(len(s.deadLetters) + 1)
+	s.deadLetters = append(s.deadLetters, dl)
+	s.mu.Unlock()
+
+	s.archived <- dl
+
+	return dl.ID, nil
+}
+
+func (s *fakeStorage) DeadLetter(_ context.Context, id int64) (models.DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id < 1 || int(id) > len(s.deadLetters) {
+		return models.DeadLetter{}, storage.ErrDeadLetterNotFound
+	}
+
+	return s.deadLetters[id-1], nil
+}
+
+func (s *fakeStorage) MarkDeadLetterReplayed(context.Context, int64) error {
+	return nil
+}
+
+// recorder is a handler recording the topics it was called for and failing while fail is set.
+type recorder struct {
+	mu     sync.Mutex
+	topics []string
+	fail   error
+}
+
+func (r *recorder) handle(_ context.Context, msg events.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.topics = append(r.topics, msg.Topic)
+	return r.fail
+}
+
+func (r *recorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	topics := r.topics
+	r.topics = nil
+	return topics
+}
+
+func (r *recorder) setFail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fail = err
+}
+
+func setup(t *testing.T, attempts int) (*memory.Broker, *fakeStorage, *recorder) {
+	t.Helper()
+
+	broker := memory.New(zap.NewNop())
+	t.Cleanup(func() { _ = broker.Close() })
+
+	s := newFakeStorage()
+	rec := &recorder{}
+
+	c := New(zap.NewNop(), broker, s, attempts, time.Millisecond, time.Millisecond,
+		[]time.Duration{time.Millisecond, time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := c.Subscribe(ctx, "orders", "billing", rec.handle); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	return broker, s, rec
+}
+
+func waitDeadLetter(t *testing.T, s *fakeStorage) models.DeadLetter {
+	t.Helper()
+
+	select {
+	case dl := <-s.archived:
+		return dl
+	case <-time.After(5 * time.Second):
+		t.Fatal("no dead letter archived")
+		return models.DeadLetter{}
+	}
+}
+
+func TestFailingMessageClimbsTheLadder(t *testing.T) {
+	broker, s, rec := setup(t, 2)
+	rec.setFail(errors.New("billing is down"))
+
+	if err := broker.Publish(context.Background(), events.Message{Topic: "orders", Key: "1"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	dl := waitDeadLetter(t, s)
+
+	want := []string{
+		"orders", "orders",
+		RetryTopic("orders", "billing", 1), RetryTopic("orders", "billing", 1),
+		RetryTopic("orders", "billing", 2), RetryTopic("orders", "billing", 2),
+	}
+	if got := rec.take(); !slices.Equal(got, want) {
+		t.Errorf("handled on %v, want %v", got, want)
+	}
+	if dl.Attempts != 6 || dl.Error != "billing is down" || dl.Key != "1" {
+		t.Errorf("dead letter = %+v, want 6 attempts of key 1 with the error", dl)
+	}
+	if dl.ReplayTopic != ReplayTopic("orders", "billing") {
+		t.Errorf("replay topic = %q, want %q", dl.ReplayTopic, ReplayTopic("orders", "billing"))
+	}
+}
+
+func TestPermanentErrorSkipsTheLadder(t *testing.T) {
+	broker, s, rec := setup(t, 3)
+	rec.setFail(events.Permanent(errors.New("malformed")))
+
+	if err := broker.Publish(context.Background(), events.Message{Topic: "orders"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	dl := waitDeadLetter(t, s)
+
+	if got := rec.take(); !slices.Equal(got, []string{"orders"}) {
+		t.Errorf("handled on %v, want only the topic", got)
+	}
+	if dl.Attempts != 1 {
+		t.Errorf("attempts = %d, want 1", dl.Attempts)
+	}
+}
+
+func TestReplayGetsTheFullLadder(t *testing.T) {
+	broker, s, rec := setup(t, 1)
+	rec.setFail(errors.New("billing is down"))
+
+	if err := broker.Publish(context.Background(), events.Message{Topic: "orders", Key: "1"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	first := waitDeadLetter(t, s)
+	rec.take()
+
+	// The replay fails again: it must go through every stage once more, not resume the ladder.
+	if err := NewReplayer(s, broker).Replay(context.Background(), first.ID); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	second := waitDeadLetter(t, s)
+
+	want := []string{
+		ReplayTopic("orders", "billing"),
+		RetryTopic("orders", "billing", 1),
+		RetryTopic("orders", "billing", 2),
+	}
+	if got := rec.take(); !slices.Equal(got, want) {
+		t.Errorf("replay handled on %v, want %v", got, want)
+	}
+	if second.Attempts != 3 {
+		t.Errorf("attempts of the replay = %d, want 3", second.Attempts)
+	}
+
+	// A replay that succeeds is handled once.
+	rec.setFail(nil)
+	if err := NewReplayer(s, broker).Replay(context.Background(), second.ID); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	var got []string
+	for len(got) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		got = rec.take()
+	}
+	if !slices.Equal(got, []string{ReplayTopic("orders", "billing")}) {
+		t.Errorf("successful replay handled on %v, want only the replay topic", got)
+	}
+}
+
+func TestProcessedEventIsSkipped(t *testing.T) {
+	broker, s, rec := setup(t, 1)
+
+	msg := events.Message{Topic: "orders", Headers: map[string]string{events.IDHeader: "event-1"}}
+	for range 2 {
+		if err := broker.Publish(context.Background(), msg); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	// Closing the broker waits for the queued messages to be handled.
+	if err := broker.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if got := rec.take(); len(got) != 1 {
+		t.Errorf("handled %d times, want once", len(got))
+	}
+	if processed, _ := s.EventProcessed(context.Background(), "billing", "event-1"); !processed {
+		t.Error("event not marked processed")
+	}
+}
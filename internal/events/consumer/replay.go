@@ -0,0 +1,59 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"shop/internal/domain/models"
+	"shop/internal/events"
+)
+
+type ReplayStorage interface {
+	DeadLetter(ctx context.Context, id int64) (models.DeadLetter, error)
+	MarkDeadLetterReplayed(ctx context.Context, id int64) error
+}
+
+// Replayer sends dead letters back to the consumer group that gave up on them.
+type Replayer struct {
+	storage   ReplayStorage
+	publisher events.Publisher
+}
+
+func NewReplayer(storage ReplayStorage, publisher events.Publisher) *Replayer {
+	return &Replayer{storage: storage, publisher: publisher}
+}
+
+// Replay publishes the dead letter to the replay topic of its group with the retry history
+// cleared, so it gets the full set of attempts again. A replay failing again becomes a new
+// dead letter. The topic is derived rather than read from the dead letter, since letters
+// archived by earlier versions name the first retry topic.
+func (r *Replayer) Replay(ctx context.Context, id int64) error {
+	const op = "consumer.Replay"
+
+	dl, err := r.storage.DeadLetter(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s, %w", op, err)
+	}
+
+	headers := maps.Clone(dl.Headers)
+	for _, h := range []string{RetryAfterHeader, AttemptsHeader, ErrorHeader, FailedAtHeader} {
+		delete(headers, h)
+	}
+
+	err = r.publisher.Publish(ctx, events.Message{
+		Topic:   ReplayTopic(dl.OriginalTopic, dl.Group),
+		Key:     dl.Key,
+		Value:   dl.Value,
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("%s, %w", op, err)
+	}
+
+	if err := r.storage.MarkDeadLetterReplayed(ctx, id); err != nil {
+		return fmt.Errorf("%s, %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	eventsv1 "shop/protos/gen/go/events"
+)
+
+const (
+	// ContentTypeHeader is the message header naming the codec of the envelope.
+	ContentTypeHeader = "content-type"
+	// IDHeader is the message header carrying the envelope ID, so consumers can deduplicate
+	// messages without decoding them.
+	IDHeader = "event-id"
+)
+
+// Envelope carries a domain event with its metadata. Data is the JSON encoded event
+// in the schema of Version.
+type Envelope struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	TraceID    string          `json:"trace_id,omitempty"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Codec serializes envelopes into message values.
+type Codec interface {
+	ContentType() string
+	Marshal(env Envelope) ([]byte, error)
+	Unmarshal(value []byte, env *Envelope) error
+}
+
+var (
+	JSON     Codec = jsonCodec{}
+	Protobuf Codec = protoCodec{}
+)
+
+var codecs = map[string]Codec{
+	JSON.ContentType():     JSON,
+	Protobuf.ContentType(): Protobuf,
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return "application/json" }
+
+func (jsonCodec) Marshal(env Envelope) ([]byte, error) {
+	return json.Marshal(env)
+}
+
+func (jsonCodec) Unmarshal(value []byte, env *Envelope) error {
+	return json.Unmarshal(value, env)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) ContentType() string { return "application/x-protobuf" }
+
+func (protoCodec) Marshal(env Envelope) ([]byte, error) {
+	return proto.Marshal(&eventsv1.Envelope{
+		Id:         env.ID,
+		Type:       env.Type,
+		Version:    int32(env.Version),
+		OccurredAt: timestamppb.New(env.OccurredAt),
+		TraceId:    env.TraceID,
+		Data:       env.Data,
+	})
+}
+
+func (protoCodec) Unmarshal(value []byte, env *Envelope) error {
+	var pb eventsv1.Envelope
+	if err := proto.Unmarshal(value, &pb); err != nil {
+		return err
+	}
+
+	*env = Envelope{
+		ID:         pb.GetId(),
+		Type:       pb.GetType(),
+		Version:    int(pb.GetVersion()),
+		OccurredAt: pb.GetOccurredAt().AsTime(),
+		TraceID:    pb.GetTraceId(),
+		Data:       pb.GetData(),
+	}
+
+	return nil
+}
+
+// unmarshalEnvelope decodes the envelope of msg with the codec named in its headers.
+// Messages without the header and without an envelope predate envelopes; their value is
+// taken as version 1 of the event of the topic.
+func unmarshalEnvelope(msg Message, eventType string) (Envelope, error) {
+	contentType, ok := msg.Headers[ContentTypeHeader]
+	if !ok {
+		var env Envelope
+		if err := json.Unmarshal(msg.Value, &env); err == nil && env.Type != "" {
+			return env, nil
+		}
+
+		return Envelope{Type: eventType, Version: 1, Data: msg.Value}, nil
+	}
+
+	codec, ok := codecs[contentType]
+	if !ok {
+		return Envelope{}, fmt.Errorf("unknown content type %q", contentType)
+	}
+
+	var env Envelope
+	if err := codec.Unmarshal(msg.Value, &env); err != nil {
+		return Envelope{}, fmt.Errorf("failed to decode envelope: %w", err)
+	}
+
+	return env, nil
+}
+
+// MessageID returns the ID of the event in msg, or "" for messages predating envelopes.
+func MessageID(msg Message) string {
+	if id := msg.Headers[IDHeader]; id != "" {
+		return id
+	}
+
+	env, err := unmarshalEnvelope(msg, "")
+	if err != nil {
+		return ""
+	}
+
+	return env.ID
+}
+
+type traceIDKey struct{}
+
+type envelopeKey struct{}
+
+// ContextWithTraceID returns ctx whose published events carry the trace ID.
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext returns the trace ID set by ContextWithTraceID or, in handlers,
+// the trace ID of the event being handled.
+func TraceIDFromContext(ctx context.Context) string {
+	if traceID, ok := ctx.Value(traceIDKey{}).(string); ok {
+		return traceID
+	}
+	if env, ok := EnvelopeFromContext(ctx); ok {
+		return env.TraceID
+	}
+
+	return ""
+}
+
+// EnvelopeFromContext returns the envelope of the event being handled, upcast to the current version.
+func EnvelopeFromContext(ctx context.Context) (Envelope, bool) {
+	env, ok := ctx.Value(envelopeKey{}).(Envelope)
+	return env, ok
+}
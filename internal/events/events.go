@@ -0,0 +1,165 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message is a record on a topic. Key decides the partition, so messages with the same key
+// are delivered in order.
+type Message struct {
+	Topic   string
+	Key     string
+	Value   []byte
+	Headers map[string]string
+}
+
+// Handler processes a delivered message. Handlers of one subscription are called one at a time.
+type Handler func(ctx context.Context, msg Message) error
+
+type Publisher interface {
+	Publish(ctx context.Context, msgs ...Message) error
+}
+
+type Subscriber interface {
+	// Subscribe starts delivering messages of the topic to handler in the background until ctx
+	// is done or the subscriber is closed. Subscriptions with the same group share the messages,
+	// every group gets each message once.
+	Subscribe(ctx context.Context, topic, group string, handler Handler) error
+}
+
+// Broker is a Publisher and Subscriber backed by the same transport.
+type Broker interface {
+	Publisher
+	Subscriber
+	Close() error
+}
+
+// permanentError marks a failure that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure that retrying cannot fix, such as a malformed message,
+// so consumers dead-letter the message at once.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err or any error it wraps was marked by Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Topic is a topic carrying events of type T in envelopes encoded with Codec, JSON if nil.
+type Topic[T Event] struct {
+	Name  string
+	Codec Codec
+}
+
+// NewMessage wraps the event in an envelope carrying the trace ID of ctx and returns it as
+// a message for the topic with the key.
+func NewMessage[T Event](ctx context.Context, topic Topic[T], key string, event T) (Message, error) {
+	const op = "events.NewMessage"
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return Message{}, fmt.Errorf("%s: failed to encode %s: %w", op, event.EventType(), err)
+	}
+
+	codec := topic.Codec
+	if codec == nil {
+		codec = JSON
+	}
+
+	id := uuid.NewString()
+
+	value, err := codec.Marshal(Envelope{
+		ID:         id,
+		Type:       event.EventType(),
+		Version:    event.EventVersion(),
+		OccurredAt: time.Now().UTC(),
+		TraceID:    TraceIDFromContext(ctx),
+		Data:       data,
+	})
+	if err != nil {
+		return Message{}, fmt.Errorf("%s: failed to encode envelope of %s: %w", op, event.EventType(), err)
+	}
+
+	return Message{
+		Topic:   topic.Name,
+		Key:     key,
+		Value:   value,
+		Headers: map[string]string{ContentTypeHeader: codec.ContentType(), IDHeader: id},
+	}, nil
+}
+
+// Publish publishes the event to the topic with the key.
+func Publish[T Event](ctx context.Context, p Publisher, topic Topic[T], key string, event T) error {
+	const op = "events.Publish"
+
+	msg, err := NewMessage(ctx, topic, key, event)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Publish(ctx, msg); err != nil {
+		return fmt.Errorf("%s, %w", op, err)
+	}
+
+	return nil
+}
+
+// Subscribe subscribes handler to the events of the topic. Events of older versions are
+// upcast with the Default registry; the envelope is available from EnvelopeFromContext.
+// Messages that cannot be decoded fail with a permanent error.
+func Subscribe[T Event](
+	ctx context.Context,
+	s Subscriber,
+	topic Topic[T],
+	group string,
+	handler func(ctx context.Context, event T) error,
+) error {
+	const op = "events.Subscribe"
+
+	if errDefault != nil {
+		return fmt.Errorf("%s, %w", op, errDefault)
+	}
+
+	return s.Subscribe(ctx, topic.Name, group, func(ctx context.Context, msg Message) error {
+		var event T
+
+		env, err := unmarshalEnvelope(msg, event.EventType())
+		if err != nil {
+			return Permanent(fmt.Errorf("%s: %w", topic.Name, err))
+		}
+		if env.Type != event.EventType() {
+			return Permanent(fmt.Errorf("%s: unexpected event type %q", topic.Name, env.Type))
+		}
+
+		env.Data, err = Default.Upcast(env.Type, env.Version, env.Data)
+		if err != nil {
+			return Permanent(fmt.Errorf("%s: %w", topic.Name, err))
+		}
+		env.Version = event.EventVersion()
+
+		if err := json.Unmarshal(env.Data, &event); err != nil {
+			return Permanent(fmt.Errorf("%s: failed to decode %s: %w", topic.Name, env.Type, err))
+		}
+
+		return handler(context.WithValue(ctx, envelopeKey{}, env), event)
+	})
+}
@@ -0,0 +1,179 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+
+	"shop/internal/events"
+)
+
+// Broker is an events.Broker on Kafka. Publishing waits for all in-sync replicas and
+// subscriptions are Kafka consumer groups starting from the oldest offset.
+type Broker struct {
+	log      *zap.Logger
+	brokers  []string
+	config   *sarama.Config
+	producer sarama.SyncProducer
+
+	mu     sync.Mutex
+	groups []sarama.ConsumerGroup
+	wg     sync.WaitGroup
+}
+
+func New(brokers []string, clientID string, retries int, log *zap.Logger) (*Broker, error) {
+	const op = "kafka.New"
+
+	config := sarama.NewConfig()
+	config.ClientID = clientID
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Max = retries
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Return.Errors = true
+
+	producer, err := sarama.NewSyncProducer(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to create producer: %w", op, err)
+	}
+
+	return &Broker{
+		log:      log.With(zap.String("component", "events/kafka")),
+		brokers:  brokers,
+		config:   config,
+		producer: producer,
+	}, nil
+}
+
+func (b *Broker) Publish(_ context.Context, msgs ...events.Message) error {
+	const op = "kafka.Publish"
+
+	batch := make([]*sarama.ProducerMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		pm := &sarama.ProducerMessage{
+			Topic: msg.Topic,
+			Value: sarama.ByteEncoder(msg.Value),
+		}
+		if msg.Key != "" {
+			pm.Key = sarama.StringEncoder(msg.Key)
+		}
+		for k, v := range msg.Headers {
+			pm.Headers = append(pm.Headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
+		}
+		batch = append(batch, pm)
+	}
+
+	if err := b.producer.SendMessages(batch); err != nil {
+		return fmt.Errorf("%s: failed to send messages: %w", op, err)
+	}
+
+	return nil
+}
+
+func (b *Broker) Subscribe(ctx context.Context, topic, group string, handler events.Handler) error {
+	const op = "kafka.Subscribe"
+
+	consumerGroup, err := sarama.NewConsumerGroup(b.brokers, group, b.config)
+	if err != nil {
+		return fmt.Errorf("%s: failed to create consumer group %s: %w", op, group, err)
+	}
+
+	b.mu.Lock()
+	b.groups = append(b.groups, consumerGroup)
+	b.mu.Unlock()
+
+	log := b.log.With(zap.String("topic", topic), zap.String("group", group))
+
+	b.wg.Add(2)
+	go func() {
+		defer b.wg.Done()
+
+		for err := range consumerGroup.Errors() {
+			log.Error("consumer group error", zap.Error(err))
+		}
+	}()
+	go func() {
+		defer b.wg.Done()
+
+		h := &groupHandler{handler: handler, log: log}
+		for {
+			// Consume returns on every rebalance and has to be called again.
+			err := consumerGroup.Consume(ctx, []string{topic}, h)
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) || ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Error("failed to consume", zap.Error(err))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close closes the consumer groups and the producer.
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	groups := b.groups
+	b.groups = nil
+	b.mu.Unlock()
+
+	var errs []error
+	for _, g := range groups {
+		errs = append(errs, g.Close())
+	}
+	b.wg.Wait()
+
+	errs = append(errs, b.producer.Close())
+
+	return errors.Join(errs...)
+}
+
+type groupHandler struct {
+	handler events.Handler
+	log     *zap.Logger
+}
+
+func (h *groupHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
+func (h *groupHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+// ConsumeClaim handles the messages of a partition in order. Failed messages are logged and skipped;
+// use consumer.Consumer to retry them.
+func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case <-session.Context().Done():
+			return nil
+		case m, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			msg := events.Message{
+				Topic:   m.Topic,
+				Key:     string(m.Key),
+				Value:   m.Value,
+				Headers: make(map[string]string, len(m.Headers)),
+			}
+			for _, header := range m.Headers {
+				msg.Headers[string(header.Key)] = string(header.Value)
+			}
+
+			if err := h.handler(session.Context(), msg); err != nil {
+				if session.Context().Err() != nil {
+					// Interrupted by a rebalance or shutdown: leave it unmarked to be redelivered.
+					return nil
+				}
+				h.log.Error("failed to handle message",
+					zap.Int32("partition", m.Partition),
+					zap.Int64("offset", m.Offset),
+					zap.Error(err))
+			}
+			session.MarkMessage(m, "")
+		}
+	}
+}
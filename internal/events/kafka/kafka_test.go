@@ -0,0 +1,131 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap"
+
+	"shop/internal/events"
+)
+
+func newTestBroker(t *testing.T) (*Broker, *mocks.SyncProducer) {
+	t.Helper()
+
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewSyncProducer(t, config)
+
+	return &Broker{log: zap.NewNop(), config: config, producer: producer}, producer
+}
+
+func TestPublish(t *testing.T) {
+	b, producer := newTestBroker(t)
+	t.Cleanup(func() { _ = b.Close() })
+
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(m *sarama.ProducerMessage) error {
+		key, err := m.Key.Encode()
+		if err != nil {
+			return err
+		}
+		if m.Topic != "orders" || string(key) != "42" {
+			return fmt.Errorf("got %s/%s, want orders/42", m.Topic, key)
+		}
+		if len(m.Headers) != 1 || string(m.Headers[0].Key) != events.IDHeader || string(m.Headers[0].Value) != "event-1" {
+			return fmt.Errorf("headers = %v, want the event ID", m.Headers)
+		}
+		return nil
+	})
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(m *sarama.ProducerMessage) error {
+		if m.Key != nil {
+			return errors.New("message without a key was sent with one")
+		}
+		return nil
+	})
+
+	err := b.Publish(context.Background(),
+		events.Message{Topic: "orders", Key: "42", Value: []byte("{}"), Headers: map[string]string{events.IDHeader: "event-1"}},
+		events.Message{Topic: "orders", Value: []byte("{}")},
+	)
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+}
+
+func TestPublishFails(t *testing.T) {
+	b, producer := newTestBroker(t)
+	t.Cleanup(func() { _ = b.Close() })
+
+	producer.ExpectSendMessageAndFail(sarama.ErrNotEnoughReplicas)
+
+	err := b.Publish(context.Background(), events.Message{Topic: "orders", Value: []byte("{}")})
+	if !errors.Is(err, sarama.ErrNotEnoughReplicas) {
+		t.Errorf("Publish error = %v, want %v", err, sarama.ErrNotEnoughReplicas)
+	}
+}
+
+// fakeSession and fakeClaim feed messages to a groupHandler and record the marked offsets.
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	marked []int64
+}
+
+func (s *fakeSession) Context() context.Context { return s.ctx }
+
+func (s *fakeSession) MarkMessage(m *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, m.Offset)
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func TestConsumeClaim(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	session := &fakeSession{ctx: ctx}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 3)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders", Offset: 1, Key: []byte("a"),
+		Headers: []*sarama.RecordHeader{{Key: []byte(events.IDHeader), Value: []byte("event-1")}}}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders", Offset: 2, Key: []byte("fail")}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders", Offset: 3, Key: []byte("stop")}
+	close(claim.messages)
+
+	var handled []events.Message
+	h := &groupHandler{log: zap.NewNop(), handler: func(_ context.Context, msg events.Message) error {
+		handled = append(handled, msg)
+		switch msg.Key {
+		case "fail":
+			return errors.New("handler failed")
+		case "stop":
+			// Shutting down while handling: the message must be left for redelivery.
+			cancel()
+			return context.Canceled
+		}
+		return nil
+	}}
+
+	if err := h.ConsumeClaim(session, claim); err != nil {
+		t.Fatalf("ConsumeClaim: %v", err)
+	}
+
+	if len(handled) != 3 {
+		t.Fatalf("handled %d messages, want 3", len(handled))
+	}
+	if got := handled[0]; got.Key != "a" || got.Headers[events.IDHeader] != "event-1" {
+		t.Errorf("first message = %+v, want key a with the event ID header", got)
+	}
+	// A failed message is skipped, an interrupted one is not marked.
+	if len(session.marked) != 2 || session.marked[0] != 1 || session.marked[1] != 2 {
+		t.Errorf("marked offsets %v, want [1 2]", session.marked)
+	}
+}
@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"shop/internal/events"
+)
+
+// queueSize is how many messages a group may have pending before Publish waits.
+const queueSize = 1024
+
+var ErrClosed = errors.New("broker is closed")
+
+// Broker is an in-process events.Broker for running without Kafka. Messages published while
+// a topic has no subscribers are discarded, and nothing survives a restart.
+type Broker struct {
+	log *zap.Logger
+
+	mu     sync.RWMutex
+	groups map[string]map[string]chan events.Message // topic -> group -> queue
+	closed bool
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func New(log *zap.Logger) *Broker {
+	return &Broker{
+		log:    log.With(zap.String("component", "events/memory")),
+		groups: make(map[string]map[string]chan events.Message),
+		done:   make(chan struct{}),
+	}
+}
+
+// Publish queues the messages for every group subscribed to their topics, waiting while a
+// queue is full until ctx is done or the broker is closed. The lock is not held while waiting,
+// so handlers publishing to a full queue cannot stall subscribing or closing.
+func (b *Broker) Publish(ctx context.Context, msgs ...events.Message) error {
+	for _, msg := range msgs {
+		b.mu.RLock()
+		if b.closed {
+			b.mu.RUnlock()
+			return ErrClosed
+		}
+		queues := make([]chan events.Message, 0, len(b.groups[msg.Topic]))
+		for _, queue := range b.groups[msg.Topic] {
+			queues = append(queues, queue)
+		}
+		b.mu.RUnlock()
+
+		for _, queue := range queues {
+			select {
+			case queue <- msg:
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-b.done:
+				return ErrClosed
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *Broker) Subscribe(ctx context.Context, topic, group string, handler events.Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	if b.groups[topic] == nil {
+		b.groups[topic] = make(map[string]chan events.Message)
+	}
+	queue, ok := b.groups[topic][group]
+	if !ok {
+		queue = make(chan events.Message, queueSize)
+		b.groups[topic][group] = queue
+	}
+
+	log := b.log.With(zap.String("topic", topic), zap.String("group", group))
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		handle := func(msg events.Message) {
+			if err := handler(ctx, msg); err != nil {
+				log.Error("failed to handle message", zap.String("key", msg.Key), zap.Error(err))
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-queue:
+				handle(msg)
+			case <-b.done:
+				// Handle what was published before the close, then stop.
+				for {
+					select {
+					case msg := <-queue:
+						handle(msg)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close stops the subscriptions once they have handled the messages already published.
+// The queues are not closed, since a Publish may still be sending to them.
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.done)
+	b.mu.Unlock()
+
+	b.wg.Wait()
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"shop/internal/events"
+)
+
+func TestPublishDeliversToEveryGroup(t *testing.T) {
+	b := New(zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	got := make(chan string, 2)
+	for _, group := range []string{"a", "b"} {
+		err := b.Subscribe(ctx, "orders", group, func(_ context.Context, msg events.Message) error {
+			got <- group + ":" + msg.Key
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+	}
+
+	if err := b.Publish(ctx, events.Message{Topic: "orders", Key: "1"}, events.Message{Topic: "other", Key: "2"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	close(got)
+	seen := map[string]bool{}
+	for g := range got {
+		seen[g] = true
+	}
+	if len(seen) != 2 || !seen["a:1"] || !seen["b:1"] {
+		t.Errorf("delivered %v, want a:1 and b:1", seen)
+	}
+}
+
+// fill subscribes a handler that blocks until release is closed and fills the queue behind it.
+func fill(t *testing.T, b *Broker, release <-chan struct{}) {
+	t.Helper()
+
+	ctx := context.Background()
+	started := make(chan struct{}, 1)
+	err := b.Subscribe(ctx, "orders", "slow", func(context.Context, events.Message) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if err := b.Publish(ctx, events.Message{Topic: "orders"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	<-started
+	for range queueSize {
+		if err := b.Publish(ctx, events.Message{Topic: "orders"}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+}
+
+func TestPublishToFullQueueStopsWithContext(t *testing.T) {
+	b := New(zap.NewNop())
+	release := make(chan struct{})
+	defer close(release)
+	fill(t, b, release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := b.Publish(ctx, events.Message{Topic: "orders"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Publish error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestPublishToFullQueueDoesNotBlockTheBroker(t *testing.T) {
+	b := New(zap.NewNop())
+	release := make(chan struct{})
+	fill(t, b, release)
+
+	published := make(chan error, 1)
+	go func() {
+		published <- b.Publish(context.Background(), events.Message{Topic: "orders"})
+	}()
+
+	// A Publish waiting for room must not keep others from subscribing.
+	subscribed := make(chan error, 1)
+	go func() {
+		subscribed <- b.Subscribe(context.Background(), "other", "group", func(context.Context, events.Message) error {
+			return nil
+		})
+	}()
+	select {
+	case err := <-subscribed:
+		if err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Subscribe blocked behind a Publish waiting for room")
+	}
+
+	// Closing ends the waiting Publish and the queued messages are still handled.
+	close(release)
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	select {
+	case err := <-published:
+		if err != nil && !errors.Is(err, ErrClosed) {
+			t.Errorf("Publish error = %v, want nil or %v", err, ErrClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Publish still waiting after Close")
+	}
+
+	if err := b.Publish(context.Background(), events.Message{Topic: "orders"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Publish after Close error = %v, want %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,101 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Event is a domain event. EventVersion is bumped whenever the JSON schema of the type changes,
+// and the previous version gets an upcaster.
+type Event interface {
+	EventType() string
+	EventVersion() int
+}
+
+// Upcaster converts the data of an event from one version to the next.
+type Upcaster func(data map[string]any) (map[string]any, error)
+
+// Registry knows the event types, their current versions and how to upcast older versions.
+// The compatibility of the types with their recorded schemas is checked by the package tests.
+type Registry struct {
+	mu    sync.RWMutex
+	types map[string]registration
+}
+
+type registration struct {
+	typ       reflect.Type
+	version   int
+	upcasters []Upcaster
+}
+
+func NewRegistry() *Registry {
+	return &Registry{types: make(map[string]registration)}
+}
+
+// Register adds the event type T. upcasters[i] converts version i+1 to i+2, so an event at
+// version N needs N-1 upcasters.
+func Register[T Event](r *Registry, upcasters ...Upcaster) error {
+	var event T
+
+	if len(upcasters) != event.EventVersion()-1 {
+		return fmt.Errorf("%s v%d: want %d upcasters, got %d",
+			event.EventType(), event.EventVersion(), event.EventVersion()-1, len(upcasters))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.types[event.EventType()]; ok {
+		return fmt.Errorf("%s: already registered", event.EventType())
+	}
+	r.types[event.EventType()] = registration{
+		typ:       reflect.TypeOf(event),
+		version:   event.EventVersion(),
+		upcasters: upcasters,
+	}
+
+	return nil
+}
+
+// Upcast returns the data of an event of the type at version, converted to the current version.
+func (r *Registry) Upcast(eventType string, version int, data json.RawMessage) (json.RawMessage, error) {
+	r.mu.RLock()
+	reg, ok := r.types[eventType]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %q", eventType)
+	}
+	if version < 1 || version > reg.version {
+		return nil, fmt.Errorf("unsupported version %d of %s, current is %d", version, eventType, reg.version)
+	}
+	if version == reg.version {
+		return data, nil
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode %s v%d: %w", eventType, version, err)
+	}
+
+	doc, err := reg.upcast(doc, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upcast %s: %w", eventType, err)
+	}
+
+	return json.Marshal(doc)
+}
+
+func (reg registration) upcast(doc map[string]any, version int) (map[string]any, error) {
+	for v := version; v < reg.version; v++ {
+		var err error
+		doc, err = reg.upcasters[v-1](doc)
+		if err != nil {
+			return nil, fmt.Errorf("v%d to v%d: %w", v, v+1, err)
+		}
+	}
+
+	return doc, nil
+}
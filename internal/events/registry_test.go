@@ -0,0 +1,192 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// schema maps the JSON fields of an event version to their kinds.
+type schema map[string]string
+
+// loadGolden reads schemas.json, the record of the schema of every version of every event.
+// Changing an event means adding its new version there, never editing an existing one.
+func loadGolden(t *testing.T) map[string]map[int]schema {
+	t.Helper()
+
+	data, err := os.ReadFile("schemas.json")
+	if err != nil {
+		t.Fatalf("read golden schemas: %v", err)
+	}
+
+	var golden map[string]map[int]schema
+	if err := json.Unmarshal(data, &golden); err != nil {
+		t.Fatalf("decode golden schemas: %v", err)
+	}
+
+	return golden
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	if _, err := newDefaultRegistry(); err != nil {
+		t.Fatalf("newDefaultRegistry: %v", err)
+	}
+}
+
+// TestSchemaCompatibility checks every registered event type against its golden schemas. The
+// current schema must match its golden schema, so a changed event cannot ship without a version
+// bump, and a document of every older golden version must upcast to exactly the current schema.
+func TestSchemaCompatibility(t *testing.T) {
+	golden := loadGolden(t)
+
+	for eventType, reg := range Default.types {
+		t.Run(eventType, func(t *testing.T) {
+			versions, ok := golden[eventType]
+			if !ok {
+				t.Fatal("no golden schema")
+			}
+
+			current, ok := versions[reg.version]
+			if !ok {
+				t.Fatalf("no golden schema for current version %d", reg.version)
+			}
+			if got := schemaOf(reg.typ); !maps.Equal(current, got) {
+				t.Fatalf("v%d schema %v differs from golden %v; bump the version and add an upcaster",
+					reg.version, got, current)
+			}
+
+			for v := 1; v < reg.version; v++ {
+				old, ok := versions[v]
+				if !ok {
+					t.Fatalf("no golden schema for version %d", v)
+				}
+
+				doc, err := reg.upcast(old.sample(), v)
+				if err != nil {
+					t.Fatalf("upcast v%d: %v", v, err)
+				}
+
+				got := slices.Sorted(maps.Keys(doc))
+				if want := slices.Sorted(maps.Keys(current)); !slices.Equal(got, want) {
+					t.Fatalf("v%d upcasts to fields %v, want %v", v, got, want)
+				}
+
+				data, err := json.Marshal(doc)
+				if err != nil {
+					t.Fatalf("encode v%d upcast: %v", v, err)
+				}
+				dec := json.NewDecoder(bytes.NewReader(data))
+				dec.DisallowUnknownFields()
+				if err := dec.Decode(reflect.New(reg.typ).Interface()); err != nil {
+					t.Fatalf("v%d upcast does not decode: %v", v, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGoldenSchemasAreRegistered(t *testing.T) {
+	for eventType := range loadGolden(t) {
+		if _, ok := Default.types[eventType]; !ok {
+			t.Errorf("%s has golden schemas but is not registered", eventType)
+		}
+	}
+}
+
+type testEvent struct {
+	ID int64 `json:"id"`
+}
+
+func (testEvent) EventType() string { return "test.event" }
+func (testEvent) EventVersion() int { return 2 }
+
+func TestRegister(t *testing.T) {
+	upcaster := func(data map[string]any) (map[string]any, error) { return data, nil }
+
+	r := NewRegistry()
+	if err := Register[testEvent](r); err == nil {
+		t.Error("Register accepted v2 without an upcaster")
+	}
+	if err := Register[testEvent](r, upcaster); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := Register[testEvent](r, upcaster); err == nil {
+		t.Error("Register accepted the type twice")
+	}
+}
+
+// sample returns a document of the schema with zero values.
+func (s schema) sample() map[string]any {
+	doc := make(map[string]any, len(s))
+	for field, kind := range s {
+		switch kind {
+		case "string":
+			doc[field] = ""
+		case "time":
+			doc[field] = time.Time{}
+		case "integer", "number":
+			doc[field] = 0
+		case "boolean":
+			doc[field] = false
+		case "array":
+			doc[field] = []any{}
+		default:
+			doc[field] = map[string]any{}
+		}
+	}
+
+	return doc
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf returns the schema of the JSON encoding of a struct type.
+func schemaOf(t reflect.Type) schema {
+	s := schema{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+
+		s[name] = kindOf(f.Type)
+	}
+
+	return s
+}
+
+func kindOf(t reflect.Type) string {
+	if t == timeType {
+		return "time"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
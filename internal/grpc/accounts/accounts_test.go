@@ -0,0 +1,166 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"shop/internal/domain/models"
+	"shop/internal/grpc/interceptors"
+	"shop/internal/grpc/local"
+	"shop/internal/services/auth"
+)
+
+// fakeAccounts knows the customer and admin roles and user 7, who may read users.
+type fakeAccounts struct {
+	assigned map[int64]string
+	unlocked string
+}
+
+func (a *fakeAccounts) UsersWithRoles(context.Context) ([]models.UserRoles, error) {
+	return []models.UserRoles{{UserID: 7, Email: "admin@example.com", Roles: []string{models.RoleAdmin}}}, nil
+}
+
+func (a *fakeAccounts) Roles(context.Context) ([]models.Role, error) {
+	return []models.Role{{ID: 1, Name: models.RoleAdmin, Permissions: []string{models.PermUsersRead}}}, nil
+}
+
+func (a *fakeAccounts) AssignRole(_ context.Context, userID int64, role string) error {
+	if role != models.RoleAdmin && role != models.RoleCustomer {
+		return auth.ErrRoleNotFound
+	}
+	a.assigned[userID] = role
+
+	return nil
+}
+
+func (a *fakeAccounts) RevokeRole(_ context.Context, userID int64, _ string) error {
+	delete(a.assigned, userID)
+	return nil
+}
+
+func (a *fakeAccounts) UnlockUser(_ context.Context, email string) error {
+	a.unlocked = email
+	return nil
+}
+
+func (a *fakeAccounts) HasPermission(_ context.Context, userID int64, permission string) (bool, error) {
+	return userID == 7 && permission == models.PermUsersRead, nil
+}
+
+func (a *fakeAccounts) LoginWithIdentity(
+	_ context.Context,
+	_ string,
+	_ string,
+	email string,
+	emailVerified bool,
+	_ int,
+) (string, error) {
+	if !emailVerified {
+		return "", auth.ErrUnverifiedEmail
+	}
+
+	return "token-of-" + email, nil
+}
+
+func newFakeAccounts() *fakeAccounts {
+	return &fakeAccounts{assigned: make(map[int64]string)}
+}
+
+var testPolicy = interceptors.Policy{Internal: Internal()}
+
+func authz() grpc.UnaryServerInterceptor {
+	return interceptors.ChainUnary(
+		interceptors.AuthzUnary(zap.NewNop(), nil, nil, testPolicy),
+		interceptors.ValidationUnary(Validators()),
+	)
+}
+
+func TestClient(t *testing.T) {
+	accounts := newFakeAccounts()
+	conn := local.NewConn(authz())
+	Register(conn, accounts)
+	c := NewClient(conn)
+	ctx := context.Background()
+
+	users, err := c.UsersWithRoles(ctx)
+	if err != nil || len(users) != 1 || users[0].UserID != 7 || users[0].Roles[0] != models.RoleAdmin {
+		t.Errorf("UsersWithRoles = %+v, %v; want the admin", users, err)
+	}
+	roles, err := c.Roles(ctx)
+	if err != nil || len(roles) != 1 || roles[0].Name != models.RoleAdmin ||
+		roles[0].Permissions[0] != models.PermUsersRead {
+		t.Errorf("Roles = %+v, %v; want the admin role", roles, err)
+	}
+
+	if err := c.AssignRole(ctx, 8, models.RoleCustomer); err != nil || accounts.assigned[8] != models.RoleCustomer {
+		t.Errorf("AssignRole = %v, assigned %v", err, accounts.assigned)
+	}
+	if err := c.AssignRole(ctx, 8, "owner"); !errors.Is(err, auth.ErrRoleNotFound) {
+		t.Errorf("AssignRole of an unknown role error = %v, want %v", err, auth.ErrRoleNotFound)
+	}
+	if err := c.RevokeRole(ctx, 8, models.RoleCustomer); err != nil || len(accounts.assigned) != 0 {
+		t.Errorf("RevokeRole = %v, assigned %v", err, accounts.assigned)
+	}
+	if err := c.AssignRole(ctx, 0, models.RoleCustomer); status.Code(errors.Unwrap(err)) != codes.InvalidArgument {
+		t.Errorf("AssignRole without a user error = %v, want %v", err, codes.InvalidArgument)
+	}
+
+	if err := c.UnlockUser(ctx, "user@example.com"); err != nil || accounts.unlocked != "user@example.com" {
+		t.Errorf("UnlockUser = %v, unlocked %q", err, accounts.unlocked)
+	}
+
+	if has, err := c.HasPermission(ctx, 7, models.PermUsersRead); err != nil || !has {
+		t.Errorf("HasPermission of the admin = %v, %v; want true", has, err)
+	}
+	if has, err := c.HasPermission(ctx, 8, models.PermUsersRead); err != nil || has {
+		t.Errorf("HasPermission of a customer = %v, %v; want false", has, err)
+	}
+
+	token, err := c.LoginWithIdentity(ctx, "google", "sub-1", "user@example.com", true, 1)
+	if err != nil || token != "token-of-user@example.com" {
+		t.Errorf("LoginWithIdentity = %q, %v", token, err)
+	}
+	_, err = c.LoginWithIdentity(ctx, "google", "sub-1", "user@example.com", false, 1)
+	if !errors.Is(err, auth.ErrUnverifiedEmail) {
+		t.Errorf("LoginWithIdentity of an unverified email error = %v, want %v", err, auth.ErrUnverifiedEmail)
+	}
+}
+
+// TestExternalCallersAreRefused calls the service over a connection without a client
+// certificate, as anyone reaching the port of sso could.
+func TestExternalCallersAreRefused(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(authz()))
+	Register(srv, newFakeAccounts())
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	c := NewClient(conn)
+
+	_, err = c.LoginWithIdentity(context.Background(), "google", "sub-1", "admin@example.com", true, 1)
+	if status.Code(errors.Unwrap(err)) != codes.PermissionDenied {
+		t.Errorf("LoginWithIdentity error = %v, want %v", err, codes.PermissionDenied)
+	}
+	_, err = c.HasPermission(context.Background(), 7, models.PermUsersRead)
+	if status.Code(errors.Unwrap(err)) != codes.PermissionDenied {
+		t.Errorf("HasPermission error = %v, want %v", err, codes.PermissionDenied)
+	}
+}
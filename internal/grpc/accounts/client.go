@@ -0,0 +1,130 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"shop/internal/domain/models"
+	"shop/internal/services/auth"
+	accountsv1 "shop/protos/gen/go/accounts"
+)
+
+// Client calls the Accounts service and returns the errors of the auth service, so the
+// admin pages and the OIDC login work the same with an in-process or a remote auth service.
+type Client struct {
+	client accountsv1.AccountsClient
+}
+
+var _ Accounts = (*Client)(nil)
+
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{client: accountsv1.NewAccountsClient(conn)}
+}
+
+func (c *Client) UsersWithRoles(ctx context.Context) ([]models.UserRoles, error) {
+	const op = "accounts.UsersWithRoles"
+
+	resp, err := c.client.ListUsers(ctx, &accountsv1.ListUsersRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	users := make([]models.UserRoles, 0, len(resp.GetUsers()))
+	for _, u := range resp.GetUsers() {
+		users = append(users, models.UserRoles{UserID: int(u.GetId()), Email: u.GetEmail(), Roles: u.GetRoles()})
+	}
+
+	return users, nil
+}
+
+func (c *Client) Roles(ctx context.Context) ([]models.Role, error) {
+	const op = "accounts.Roles"
+
+	resp, err := c.client.ListRoles(ctx, &accountsv1.ListRolesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles := make([]models.Role, 0, len(resp.GetRoles()))
+	for _, r := range resp.GetRoles() {
+		roles = append(roles, models.Role{ID: int(r.GetId()), Name: r.GetName(), Permissions: r.GetPermissions()})
+	}
+
+	return roles, nil
+}
+
+func (c *Client) AssignRole(ctx context.Context, userID int64, role string) error {
+	const op = "accounts.AssignRole"
+
+	_, err := c.client.AssignRole(ctx, &accountsv1.AssignRoleRequest{UserId: userID, Role: role})
+	if status.Code(err) == codes.NotFound {
+		return fmt.Errorf("%s: %w", op, auth.ErrRoleNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *Client) RevokeRole(ctx context.Context, userID int64, role string) error {
+	const op = "accounts.RevokeRole"
+
+	if _, err := c.client.RevokeRole(ctx, &accountsv1.RevokeRoleRequest{UserId: userID, Role: role}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *Client) UnlockUser(ctx context.Context, email string) error {
+	const op = "accounts.UnlockUser"
+
+	if _, err := c.client.UnlockUser(ctx, &accountsv1.UnlockUserRequest{Email: email}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *Client) HasPermission(ctx context.Context, userID int64, permission string) (bool, error) {
+	const op = "accounts.HasPermission"
+
+	resp, err := c.client.HasPermission(ctx, &accountsv1.HasPermissionRequest{UserId: userID, Permission: permission})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return resp.GetAllowed(), nil
+}
+
+func (c *Client) LoginWithIdentity(
+	ctx context.Context,
+	provider string,
+	subject string,
+	email string,
+	emailVerified bool,
+	appID int,
+) (string, error) {
+	const op = "accounts.LoginWithIdentity"
+
+	resp, err := c.client.LoginWithIdentity(ctx, &accountsv1.LoginWithIdentityRequest{
+		Provider:      provider,
+		Subject:       subject,
+		Email:         email,
+		EmailVerified: emailVerified,
+		AppId:         int32(appID),
+	})
+	if status.Code(err) == codes.FailedPrecondition {
+		return "", fmt.Errorf("%s: %w", op, auth.ErrUnverifiedEmail)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return resp.GetToken(), nil
+}
@@ -0,0 +1,226 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"shop/internal/domain/models"
+	"shop/internal/grpc/interceptors"
+	"shop/internal/services/auth"
+	accountsv1 "shop/protos/gen/go/accounts"
+)
+
+var (
+	ErrRoleNotFound    = status.Error(codes.NotFound, "Role not found")
+	ErrUnverifiedEmail = status.Error(codes.FailedPrecondition, "Email is not verified by the identity provider")
+	ErrInternal        = status.Error(codes.Internal, "Internal error")
+)
+
+// Accounts is the part of the auth service administering users.
+type Accounts interface {
+	UsersWithRoles(ctx context.Context) ([]models.UserRoles, error)
+	Roles(ctx context.Context) ([]models.Role, error)
+	AssignRole(ctx context.Context, userID int64, role string) error
+	RevokeRole(ctx context.Context, userID int64, role string) error
+	UnlockUser(ctx context.Context, email string) error
+	HasPermission(ctx context.Context, userID int64, permission string) (bool, error)
+	LoginWithIdentity(
+		ctx context.Context,
+		provider string,
+		subject string,
+		email string,
+		emailVerified bool,
+		appID int,
+	) (string, error)
+}
+
+type ServerAPI struct {
+	accountsv1.UnimplementedAccountsServer
+	accounts Accounts
+}
+
+func Register(gRPC grpc.ServiceRegistrar, accounts Accounts) {
+	accountsv1.RegisterAccountsServer(gRPC, &ServerAPI{accounts: accounts})
+}
+
+// Internal returns the Accounts methods for the Internal list of the authz policy: the shop
+// checks the permissions of its users itself, so only it may call them.
+func Internal() map[string]bool {
+	methods := make(map[string]bool, len(accountsv1.Accounts_ServiceDesc.Methods))
+	for _, m := range accountsv1.Accounts_ServiceDesc.Methods {
+		methods["/"+accountsv1.Accounts_ServiceDesc.ServiceName+"/"+m.MethodName] = true
+	}
+
+	return methods
+}
+
+// Validators returns the payload validators of the Accounts methods for the validation interceptor.
+func Validators() map[string]interceptors.Validator {
+	return map[string]interceptors.Validator{
+		accountsv1.Accounts_AssignRole_FullMethodName:        interceptors.ValidateFunc(validateAssignRole),
+		accountsv1.Accounts_RevokeRole_FullMethodName:        interceptors.ValidateFunc(validateRevokeRole),
+		accountsv1.Accounts_UnlockUser_FullMethodName:        interceptors.ValidateFunc(validateUnlockUser),
+		accountsv1.Accounts_HasPermission_FullMethodName:     interceptors.ValidateFunc(validateHasPermission),
+		accountsv1.Accounts_LoginWithIdentity_FullMethodName: interceptors.ValidateFunc(validateLoginWithIdentity),
+	}
+}
+
+func (s *ServerAPI) ListUsers(
+	ctx context.Context,
+	_ *accountsv1.ListUsersRequest,
+) (*accountsv1.ListUsersResponse, error) {
+	users, err := s.accounts.UsersWithRoles(ctx)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	resp := &accountsv1.ListUsersResponse{Users: make([]*accountsv1.User, 0, len(users))}
+	for _, u := range users {
+		resp.Users = append(resp.Users, &accountsv1.User{Id: int64(u.UserID), Email: u.Email, Roles: u.Roles})
+	}
+
+	return resp, nil
+}
+
+func (s *ServerAPI) ListRoles(
+	ctx context.Context,
+	_ *accountsv1.ListRolesRequest,
+) (*accountsv1.ListRolesResponse, error) {
+	roles, err := s.accounts.Roles(ctx)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	resp := &accountsv1.ListRolesResponse{Roles: make([]*accountsv1.Role, 0, len(roles))}
+	for _, r := range roles {
+		resp.Roles = append(resp.Roles, &accountsv1.Role{Id: int64(r.ID), Name: r.Name, Permissions: r.Permissions})
+	}
+
+	return resp, nil
+}
+
+func (s *ServerAPI) AssignRole(
+	ctx context.Context,
+	req *accountsv1.AssignRoleRequest,
+) (*accountsv1.AssignRoleResponse, error) {
+	if err := s.accounts.AssignRole(ctx, req.GetUserId(), req.GetRole()); err != nil {
+		if errors.Is(err, auth.ErrRoleNotFound) {
+			return nil, ErrRoleNotFound
+		}
+
+		return nil, ErrInternal
+	}
+
+	return &accountsv1.AssignRoleResponse{}, nil
+}
+
+func (s *ServerAPI) RevokeRole(
+	ctx context.Context,
+	req *accountsv1.RevokeRoleRequest,
+) (*accountsv1.RevokeRoleResponse, error) {
+	if err := s.accounts.RevokeRole(ctx, req.GetUserId(), req.GetRole()); err != nil {
+		return nil, ErrInternal
+	}
+
+	return &accountsv1.RevokeRoleResponse{}, nil
+}
+
+func (s *ServerAPI) UnlockUser(
+	ctx context.Context,
+	req *accountsv1.UnlockUserRequest,
+) (*accountsv1.UnlockUserResponse, error) {
+	if err := s.accounts.UnlockUser(ctx, req.GetEmail()); err != nil {
+		return nil, ErrInternal
+	}
+
+	return &accountsv1.UnlockUserResponse{}, nil
+}
+
+func (s *ServerAPI) HasPermission(
+	ctx context.Context,
+	req *accountsv1.HasPermissionRequest,
+) (*accountsv1.HasPermissionResponse, error) {
+	allowed, err := s.accounts.HasPermission(ctx, req.GetUserId(), req.GetPermission())
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	return &accountsv1.HasPermissionResponse{Allowed: allowed}, nil
+}
+
+func (s *ServerAPI) LoginWithIdentity(
+	ctx context.Context,
+	req *accountsv1.LoginWithIdentityRequest,
+) (*accountsv1.LoginWithIdentityResponse, error) {
+	token, err := s.accounts.LoginWithIdentity(ctx,
+		req.GetProvider(), req.GetSubject(), req.GetEmail(), req.GetEmailVerified(), int(req.GetAppId()))
+	if err != nil {
+		if errors.Is(err, auth.ErrUnverifiedEmail) {
+			return nil, ErrUnverifiedEmail
+		}
+
+		return nil, ErrInternal
+	}
+
+	return &accountsv1.LoginWithIdentityResponse{Token: token}, nil
+}
+
+func validateAssignRole(req *accountsv1.AssignRoleRequest) error {
+	return validateRole(req.GetUserId(), req.GetRole())
+}
+
+func validateRevokeRole(req *accountsv1.RevokeRoleRequest) error {
+	return validateRole(req.GetUserId(), req.GetRole())
+}
+
+func validateRole(userID int64, role string) error {
+	if userID <= 0 {
+		return interceptors.FieldViolation("user_id", "user_id must be positive")
+	}
+
+	if role == "" {
+		return interceptors.FieldViolation("role", "role is required")
+	}
+
+	return nil
+}
+
+func validateUnlockUser(req *accountsv1.UnlockUserRequest) error {
+	if req.GetEmail() == "" {
+		return interceptors.FieldViolation("email", "email is required")
+	}
+
+	return nil
+}
+
+func validateHasPermission(req *accountsv1.HasPermissionRequest) error {
+	if req.GetUserId() <= 0 {
+		return interceptors.FieldViolation("user_id", "user_id must be positive")
+	}
+
+	if req.GetPermission() == "" {
+		return interceptors.FieldViolation("permission", "permission is required")
+	}
+
+	return nil
+}
+
+func validateLoginWithIdentity(req *accountsv1.LoginWithIdentityRequest) error {
+	if req.GetProvider() == "" {
+		return interceptors.FieldViolation("provider", "provider is required")
+	}
+
+	if req.GetSubject() == "" {
+		return interceptors.FieldViolation("subject", "subject is required")
+	}
+
+	if req.GetAppId() <= 0 {
+		return interceptors.FieldViolation("app_id", "app_id must be positive")
+	}
+
+	return nil
+}
@@ -3,13 +3,21 @@ package auth
 import (
 	"context"
 	"errors"
+	"net"
+	"time"
 
 	ssov1 "github.com/Yuukiine/protos/gen/go/sso"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 
+	"shop/internal/grpc/interceptors"
 	"shop/internal/services/auth"
+	"shop/lib/password"
 )
 
 var (
@@ -17,14 +25,30 @@ var (
 	ErrNotFound        = status.Error(codes.NotFound, "User not found")
 	ErrInternal        = status.Error(codes.Internal, "failed to login")
 	ErrExists          = status.Error(codes.AlreadyExists, "User already exists")
+	ErrLocked          = status.Error(codes.ResourceExhausted, "Too many failed login attempts")
+	ErrDelayed         = status.Error(codes.ResourceExhausted, "Too many failed login attempts, retry later")
 )
 
+// Reasons of the ErrorInfo detail of locked logins, next to a RetryInfo detail telling when
+// to try again. A delayed login is only throttled between attempts, a locked one is
+// refused for the lockout duration.
+const (
+	errorDomain        = "shop"
+	ReasonLoginLocked  = "LOGIN_LOCKED"
+	ReasonLoginDelayed = "LOGIN_DELAYED"
+)
+
+// ClientIPKey is the metadata key the HTTP tier uses to forward the end user's IP address.
+// It is only honored from internal callers, see interceptors.InternalCaller.
+const ClientIPKey = "x-client-ip"
+
 type Auth interface {
 	Login(
 		ctx context.Context,
 		email string,
 		password string,
 		appID int,
+		clientIP string,
 	) (token string, err error)
 
 	RegisterNewUser(
@@ -41,22 +65,31 @@ type ServerAPI struct {
 	auth Auth
 }
 
-func Register(gRPC *grpc.Server, auth Auth) {
+func Register(gRPC grpc.ServiceRegistrar, auth Auth) {
 	ssov1.RegisterAuthServer(gRPC, &ServerAPI{auth: auth})
 }
 
+// Validators returns the payload validators of the Auth methods for the validation interceptor.
+func Validators() map[string]interceptors.Validator {
+	return map[string]interceptors.Validator{
+		ssov1.Auth_Login_FullMethodName:    interceptors.ValidateFunc(validateLogin),
+		ssov1.Auth_Register_FullMethodName: interceptors.ValidateFunc(validateRegister),
+		ssov1.Auth_IsAdmin_FullMethodName:  interceptors.ValidateFunc(validateIsAdmin),
+	}
+}
+
 const emptyValue = 0
 
 func (s *ServerAPI) Login(
 	ctx context.Context,
 	req *ssov1.LoginRequest,
 ) (*ssov1.LoginResponse, error) {
-	if err := validateLogin(req); err != nil {
-		return nil, ErrInvalidArgument
-	}
-
-	token, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()))
+	token, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()), clientIP(ctx))
 	if err != nil {
+		var lockErr *auth.LockError
+		if errors.As(err, &lockErr) {
+			return nil, lockedError(lockErr)
+		}
 		if errors.Is(err, auth.ErrInvalidCredentials) {
 			return nil, ErrInvalidArgument
 		}
@@ -70,19 +103,50 @@ func (s *ServerAPI) Login(
 	return &ssov1.LoginResponse{Token: token}, nil
 }
 
+// lockedError is ErrLocked, or ErrDelayed during the backoff, with the reason and the time
+// left until the next attempt as details.
+func lockedError(lockErr *auth.LockError) error {
+	st, reason := status.Convert(ErrLocked), ReasonLoginLocked
+	if errors.Is(lockErr, auth.ErrLoginDelayed) {
+		st, reason = status.Convert(ErrDelayed), ReasonLoginDelayed
+	}
+
+	detailed, err := st.WithDetails(
+		&errdetails.ErrorInfo{Reason: reason, Domain: errorDomain},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Until(lockErr.Until).Round(time.Second))},
+	)
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// LockReason returns the reason of a locked login error returned by Login, or "" for other
+// errors.
+func LockReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetDomain() == errorDomain {
+			return info.GetReason()
+		}
+	}
+
+	return ""
+}
+
 func (s *ServerAPI) Register(
 	ctx context.Context,
 	req *ssov1.RegisterRequest,
 ) (*ssov1.RegisterResponse, error) {
-	if err := validateRegister(req); err != nil {
-		return nil, ErrInvalidArgument
-	}
-
 	userID, err := s.auth.RegisterNewUser(ctx, req.GetEmail(), req.GetPassword())
 	if err != nil {
 		if errors.Is(err, auth.ErrUserExists) {
 			return nil, ErrExists
 		}
+		var violation *password.Violation
+		if errors.As(err, &violation) {
+			return nil, weakPasswordError(violation)
+		}
 
 		return nil, ErrInternal
 	}
@@ -90,14 +154,27 @@ func (s *ServerAPI) Register(
 	return &ssov1.RegisterResponse{UserId: userID}, nil
 }
 
+// weakPasswordError is an InvalidArgument status carrying the policy message and reason as a
+// field violation of "password", so clients can show it next to the field.
+func weakPasswordError(violation *password.Violation) error {
+	st := status.New(codes.InvalidArgument, violation.Message)
+
+	detailed, err := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "password", Description: violation.Message, Reason: violation.Reason},
+		},
+	})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
 func (s *ServerAPI) IsAdmin(
 	ctx context.Context,
 	req *ssov1.IsAdminRequest,
 ) (*ssov1.IsAdminResponse, error) {
-	if err := validateIsAdmin(req); err != nil {
-		return nil, ErrInvalidArgument
-	}
-
 	isAdmin, err := s.auth.IsAdmin(ctx, req.GetUserId())
 	if err != nil {
 		if errors.Is(err, auth.ErrUserNotFound) {
@@ -110,17 +187,45 @@ func (s *ServerAPI) IsAdmin(
 	return &ssov1.IsAdminResponse{IsAdmin: isAdmin}, nil
 }
 
+// clientIP returns the end user's IP forwarded in metadata by an internal caller, or the peer
+// address of callers forwarding none. Honoring it from anyone would let a client rotate the
+// IP the per-IP lockout counts failures for. An IP forwarded by an external caller is
+// unknown: counting the caller's own address instead would lock out everyone behind it, so
+// "" is returned and only the email is counted.
+func clientIP(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(ClientIPKey); len(v) > 0 && v[0] != "" {
+			if interceptors.InternalCaller(ctx) {
+				return v[0]
+			}
+
+			return ""
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			return p.Addr.String()
+		}
+
+		return host
+	}
+
+	return ""
+}
+
 func validateLogin(req *ssov1.LoginRequest) error {
 	if req.GetEmail() == "" {
-		return status.Error(codes.InvalidArgument, "email is required")
+		return interceptors.FieldViolation("email", "email is required")
 	}
 
 	if req.GetPassword() == "" {
-		return status.Error(codes.InvalidArgument, "password is required")
+		return interceptors.FieldViolation("password", "password is required")
 	}
 
 	if req.GetAppId() == emptyValue {
-		return status.Error(codes.InvalidArgument, "app_id is required")
+		return interceptors.FieldViolation("app_id", "app_id is required")
 	}
 
 	return nil
@@ -128,11 +233,11 @@ func validateLogin(req *ssov1.LoginRequest) error {
 
 func validateRegister(req *ssov1.RegisterRequest) error {
 	if req.GetEmail() == "" {
-		return status.Error(codes.InvalidArgument, "email is required")
+		return interceptors.FieldViolation("email", "email is required")
 	}
 
 	if req.GetPassword() == "" {
-		return status.Error(codes.InvalidArgument, "password is required")
+		return interceptors.FieldViolation("password", "password is required")
 	}
 
 	return nil
@@ -140,7 +245,7 @@ func validateRegister(req *ssov1.RegisterRequest) error {
 
 func validateIsAdmin(req *ssov1.IsAdminRequest) error {
 	if req.GetUserId() == emptyValue {
-		return status.Error(codes.InvalidArgument, "user_id is required")
+		return interceptors.FieldViolation("user_id", "user_id is required")
 	}
 
 	return nil
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	ssov1 "github.com/Yuukiine/protos/gen/go/sso"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"shop/internal/grpc/local"
+	"shop/internal/services/auth"
+)
+
+// fakeAuth records the client IP of the last login and fails it with err.
+type fakeAuth struct {
+	Auth
+
+	clientIP string
+	err      error
+}
+
+func (a *fakeAuth) Login(_ context.Context, _, _ string, _ int, clientIP string) (string, error) {
+	a.clientIP = clientIP
+	if a.err != nil {
+		return "", a.err
+	}
+
+	return "token", nil
+}
+
+func TestClientIP(t *testing.T) {
+	remote := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 5000}
+	verified := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{}}}}
+
+	tests := []struct {
+		name      string
+		peer      *peer.Peer
+		forwarded string
+		want      string
+	}{
+		{name: "direct caller", peer: &peer.Peer{Addr: remote}, want: "203.0.113.7"},
+		{name: "untrusted forwarder", peer: &peer.Peer{Addr: remote}, forwarded: "198.51.100.1", want: ""},
+		{name: "tls without client cert", peer: &peer.Peer{Addr: remote, AuthInfo: credentials.TLSInfo{}}, forwarded: "198.51.100.1", want: ""},
+		{name: "mtls caller", peer: &peer.Peer{Addr: remote, AuthInfo: verified}, forwarded: "198.51.100.1", want: "198.51.100.1"},
+		{name: "in-process caller", peer: &peer.Peer{AuthInfo: local.AuthInfo{}}, forwarded: "198.51.100.1", want: "198.51.100.1"},
+		{name: "in-process caller without ip", peer: &peer.Peer{AuthInfo: local.AuthInfo{}}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := peer.NewContext(context.Background(), tt.peer)
+			if tt.forwarded != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(ClientIPKey, tt.forwarded))
+			}
+
+			if got := clientIP(ctx); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginCountsTheClientIP(t *testing.T) {
+	shop := &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 5000}
+	verified := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{}}}}
+
+	tests := []struct {
+		name      string
+		peer      *peer.Peer
+		forwarded string
+		want      string
+	}{
+		{name: "login forwarded over mtls", peer: &peer.Peer{Addr: shop, AuthInfo: verified}, forwarded: "198.51.100.1",
+			want: "198.51.100.1"},
+		// Counting the forwarder's address would lock out every user of the shop at once.
+		{name: "login forwarded without a certificate", peer: &peer.Peer{Addr: shop}, forwarded: "198.51.100.1"},
+		{name: "login of a direct client", peer: &peer.Peer{Addr: shop}, want: "10.0.0.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := peer.NewContext(context.Background(), tt.peer)
+			if tt.forwarded != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(ClientIPKey, tt.forwarded))
+			}
+			a := &fakeAuth{}
+
+			_, err := (&ServerAPI{auth: a}).Login(ctx, &ssov1.LoginRequest{Email: "user@example.com", Password: "pass", AppId: 1})
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+			if a.clientIP != tt.want {
+				t.Errorf("client IP = %q, want %q", a.clientIP, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginLocked(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantReason string
+	}{
+		{name: "locked", err: auth.ErrAccountLocked, wantReason: ReasonLoginLocked},
+		{name: "delayed", err: auth.ErrLoginDelayed, wantReason: ReasonLoginDelayed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lockErr := &auth.LockError{Key: "email:user@example.com", Until: time.Now().Add(time.Minute), Err: tt.err}
+			a := &fakeAuth{err: fmt.Errorf("auth.Login, %w", lockErr)}
+
+			_, err := (&ServerAPI{auth: a}).Login(context.Background(), &ssov1.LoginRequest{})
+			if status.Code(err) != codes.ResourceExhausted {
+				t.Fatalf("Login error = %v, want ResourceExhausted", err)
+			}
+			if reason := LockReason(err); reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", reason, tt.wantReason)
+			}
+
+			var retry *errdetails.RetryInfo
+			for _, detail := range status.Convert(err).Details() {
+				if d, ok := detail.(*errdetails.RetryInfo); ok {
+					retry = d
+				}
+			}
+			if delay := retry.GetRetryDelay().AsDuration(); delay <= 0 || delay > time.Minute {
+				t.Errorf("retry delay = %v, want up to a minute", delay)
+			}
+		})
+	}
+
+	if reason := LockReason(errors.New("other")); reason != "" {
+		t.Errorf("reason of another error = %q", reason)
+	}
+}
@@ -0,0 +1,199 @@
+package cart
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"shop/internal/grpc/interceptors"
+	"shop/internal/services/cart"
+	cartv1 "shop/protos/gen/go/cart"
+)
+
+var (
+	ErrInvalidQuantity = interceptors.FieldViolation("quantity", "Quantity must be between 1 and 99")
+	ErrProductNotFound = status.Error(codes.NotFound, "Product not found")
+	ErrNotInCart       = status.Error(codes.NotFound, "Product is not in the cart")
+	ErrSessionNotFound = status.Error(codes.NotFound, "Guest session not found")
+	ErrOutOfStock      = status.Error(codes.FailedPrecondition, "Not enough items in stock")
+	ErrUnauthenticated = status.Error(codes.Unauthenticated, "missing authorization token")
+	ErrInternal        = status.Error(codes.Internal, "Internal error")
+)
+
+// Cart is the cart service.
+type Cart interface {
+	Contents(ctx context.Context, owner any) (cart.Contents, error)
+	AddItem(ctx context.Context, owner any, productID int64, quantity int) (cart.Contents, error)
+	UpdateItem(ctx context.Context, owner any, productID int64, quantity int) (cart.Contents, error)
+	RemoveItem(ctx context.Context, owner any, productID int64) (cart.Contents, error)
+	MergeGuestCart(ctx context.Context, userID int64, sessionID string) (cart.Contents, error)
+}
+
+type ServerAPI struct {
+	cartv1.UnimplementedCartServer
+	cart Cart
+}
+
+func Register(gRPC grpc.ServiceRegistrar, cart Cart) {
+	cartv1.RegisterCartServer(gRPC, &ServerAPI{cart: cart})
+}
+
+// Validators returns the payload validators of the Cart methods for the validation interceptor.
+func Validators() map[string]interceptors.Validator {
+	return map[string]interceptors.Validator{
+		cartv1.Cart_AddItem_FullMethodName:        interceptors.ValidateFunc(validateAddItem),
+		cartv1.Cart_UpdateItem_FullMethodName:     interceptors.ValidateFunc(validateUpdateItem),
+		cartv1.Cart_RemoveItem_FullMethodName:     interceptors.ValidateFunc(validateRemoveItem),
+		cartv1.Cart_MergeGuestCart_FullMethodName: interceptors.ValidateFunc(validateMergeGuestCart),
+	}
+}
+
+func (s *ServerAPI) GetCart(
+	ctx context.Context,
+	_ *cartv1.GetCartRequest,
+) (*cartv1.CartContents, error) {
+	uid, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply(s.cart.Contents(ctx, uid))
+}
+
+func (s *ServerAPI) AddItem(
+	ctx context.Context,
+	req *cartv1.AddItemRequest,
+) (*cartv1.CartContents, error) {
+	uid, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply(s.cart.AddItem(ctx, uid, req.GetProductId(), int(req.GetQuantity())))
+}
+
+func (s *ServerAPI) UpdateItem(
+	ctx context.Context,
+	req *cartv1.UpdateItemRequest,
+) (*cartv1.CartContents, error) {
+	uid, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply(s.cart.UpdateItem(ctx, uid, req.GetProductId(), int(req.GetQuantity())))
+}
+
+func (s *ServerAPI) RemoveItem(
+	ctx context.Context,
+	req *cartv1.RemoveItemRequest,
+) (*cartv1.CartContents, error) {
+	uid, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply(s.cart.RemoveItem(ctx, uid, req.GetProductId()))
+}
+
+func (s *ServerAPI) MergeGuestCart(
+	ctx context.Context,
+	req *cartv1.MergeGuestCartRequest,
+) (*cartv1.CartContents, error) {
+	uid, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply(s.cart.MergeGuestCart(ctx, uid, req.GetSessionId()))
+}
+
+// reply converts the result of a cart service call into the response or the status error.
+func reply(contents cart.Contents, err error) (*cartv1.CartContents, error) {
+	switch {
+	case err == nil:
+	case errors.Is(err, cart.ErrInvalidQuantity):
+		return nil, ErrInvalidQuantity
+	case errors.Is(err, cart.ErrProductNotFound):
+		return nil, ErrProductNotFound
+	case errors.Is(err, cart.ErrNotInCart):
+		return nil, ErrNotInCart
+	case errors.Is(err, cart.ErrSessionNotFound):
+		return nil, ErrSessionNotFound
+	case errors.Is(err, cart.ErrOutOfStock):
+		return nil, ErrOutOfStock
+	default:
+		return nil, ErrInternal
+	}
+
+	resp := &cartv1.CartContents{
+		TotalItems: int32(contents.TotalItems),
+		Subtotal:   contents.Subtotal,
+		Shipping:   contents.Shipping,
+		Tax:        contents.Tax,
+		Total:      contents.Total,
+	}
+	for _, item := range contents.Items {
+		resp.Items = append(resp.Items, &cartv1.CartItem{
+			ProductId:          int64(item.ProductID),
+			ProductName:        item.ProductName,
+			ProductDescription: item.ProductDescription,
+			Price:              item.ProductPrice,
+			Quantity:           int32(item.Quantity),
+		})
+	}
+
+	return resp, nil
+}
+
+func userID(ctx context.Context) (int64, error) {
+	claims, ok := interceptors.ClaimsFromContext(ctx)
+	if !ok {
+		return 0, ErrUnauthenticated
+	}
+
+	return claims.UID, nil
+}
+
+func validateAddItem(req *cartv1.AddItemRequest) error {
+	if req.GetProductId() <= 0 {
+		return interceptors.FieldViolation("product_id", "product_id is required")
+	}
+
+	if req.GetQuantity() < 1 || req.GetQuantity() > cart.MaxQuantity {
+		return ErrInvalidQuantity
+	}
+
+	return nil
+}
+
+func validateUpdateItem(req *cartv1.UpdateItemRequest) error {
+	if req.GetProductId() <= 0 {
+		return interceptors.FieldViolation("product_id", "product_id is required")
+	}
+
+	if req.GetQuantity() < 0 || req.GetQuantity() > cart.MaxQuantity {
+		return ErrInvalidQuantity
+	}
+
+	return nil
+}
+
+func validateRemoveItem(req *cartv1.RemoveItemRequest) error {
+	if req.GetProductId() <= 0 {
+		return interceptors.FieldViolation("product_id", "product_id is required")
+	}
+
+	return nil
+}
+
+func validateMergeGuestCart(req *cartv1.MergeGuestCartRequest) error {
+	if req.GetSessionId() == "" {
+		return interceptors.FieldViolation("session_id", "session_id is required")
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"shop/internal/domain/models"
+	"shop/internal/grpc/interceptors"
+	"shop/internal/services/catalog"
+	"shop/internal/storage/changes"
+	catalogv1 "shop/protos/gen/go/catalog"
+)
+
+// maxBatchSize caps the number of product ids of a request.
+const maxBatchSize = 100
+
+var (
+	ErrInvalidToken = interceptors.FieldViolation("page_token", "Invalid page token")
+	ErrNotFound     = status.Error(codes.NotFound, "Product not found")
+	ErrInternal     = status.Error(codes.Internal, "Internal error")
+)
+
+// Catalog is the catalog service.
+type Catalog interface {
+	Product(ctx context.Context, id int64) (models.Product, error)
+	ListProducts(ctx context.Context, filter models.ProductFilter, pageToken string, pageSize int) (catalog.ProductPage, error)
+	ProductsByIDs(ctx context.Context, ids []int64) ([]models.Product, []int64, error)
+	Categories(ctx context.Context) ([]models.Category, error)
+}
+
+// Feed is the log of inventory changes streamed by WatchInventory.
+type Feed interface {
+	ProductChanges(ctx context.Context, afterSeq int64, limit int) ([]models.ProductChange, error)
+	LastProductChange(ctx context.Context) (int64, error)
+	Changes() *changes.Bus
+}
+
+type ServerAPI struct {
+	catalogv1.UnimplementedCatalogServer
+	catalog Catalog
+	feed    Feed
+	// buffer is the subscription buffer of a watcher.
+	buffer int
+}
+
+func Register(gRPC grpc.ServiceRegistrar, catalog Catalog, feed Feed) {
+	catalogv1.RegisterCatalogServer(gRPC, &ServerAPI{catalog: catalog, feed: feed, buffer: watchBuffer})
+}
+
+// Validators returns the payload validators of the Catalog methods for the validation interceptor.
+func Validators() map[string]interceptors.Validator {
+	return map[string]interceptors.Validator{
+		catalogv1.Catalog_GetProduct_FullMethodName:       interceptors.ValidateFunc(validateGetProduct),
+		catalogv1.Catalog_ListProducts_FullMethodName:     interceptors.ValidateFunc(validateListProducts),
+		catalogv1.Catalog_BatchGetProducts_FullMethodName: interceptors.ValidateFunc(validateBatchGetProducts),
+		catalogv1.Catalog_WatchInventory_FullMethodName:   interceptors.ValidateFunc(validateWatchInventory),
+	}
+}
+
+func (s *ServerAPI) GetProduct(
+	ctx context.Context,
+	req *catalogv1.GetProductRequest,
+) (*catalogv1.GetProductResponse, error) {
+	product, err := s.catalog.Product(ctx, req.GetProductId())
+	if err != nil {
+		if errors.Is(err, catalog.ErrProductNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, ErrInternal
+	}
+
+	return &catalogv1.GetProductResponse{Product: toProto(product)}, nil
+}
+
+func (s *ServerAPI) ListProducts(
+	ctx context.Context,
+	req *catalogv1.ListProductsRequest,
+) (*catalogv1.ListProductsResponse, error) {
+	filter := models.ProductFilter{
+		Category:    req.GetCategory(),
+		Query:       req.GetQuery(),
+		MinPrice:    req.GetMinPrice(),
+		MaxPrice:    req.GetMaxPrice(),
+		InStockOnly: req.GetInStockOnly(),
+	}
+
+	page, err := s.catalog.ListProducts(ctx, filter, req.GetPageToken(), int(req.GetPageSize()))
+	if err != nil {
+		if errors.Is(err, catalog.ErrInvalidPageToken) {
+			return nil, ErrInvalidToken
+		}
+
+		return nil, ErrInternal
+	}
+
+	resp := &catalogv1.ListProductsResponse{NextPageToken: page.NextPageToken}
+	for _, p := range page.Products {
+		resp.Products = append(resp.Products, toProto(p))
+	}
+
+	return resp, nil
+}
+
+func (s *ServerAPI) ListCategories(
+	ctx context.Context,
+	_ *catalogv1.ListCategoriesRequest,
+) (*catalogv1.ListCategoriesResponse, error) {
+	categories, err := s.catalog.Categories(ctx)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	resp := &catalogv1.ListCategoriesResponse{}
+	for _, c := range categories {
+		resp.Categories = append(resp.Categories, &catalogv1.Category{Id: c.ID, Name: c.Name})
+	}
+
+	return resp, nil
+}
+
+func (s *ServerAPI) BatchGetProducts(
+	ctx context.Context,
+	req *catalogv1.BatchGetProductsRequest,
+) (*catalogv1.BatchGetProductsResponse, error) {
+	products, notFound, err := s.catalog.ProductsByIDs(ctx, req.GetProductIds())
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	resp := &catalogv1.BatchGetProductsResponse{NotFoundIds: notFound}
+	for _, p := range products {
+		resp.Products = append(resp.Products, toProto(p))
+	}
+
+	return resp, nil
+}
+
+func toProto(p models.Product) *catalogv1.Product {
+	return &catalogv1.Product{
+		Id:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		Stock:       int32(p.Stock),
+		Category:    p.Category,
+	}
+}
+
+func validateGetProduct(req *catalogv1.GetProductRequest) error {
+	if req.GetProductId() <= 0 {
+		return interceptors.FieldViolation("product_id", "product_id is required")
+	}
+
+	return nil
+}
+
+func validateListProducts(req *catalogv1.ListProductsRequest) error {
+	if req.GetPageSize() < 0 {
+		return interceptors.FieldViolation("page_size", "page_size must not be negative")
+	}
+
+	if req.GetMinPrice() < 0 {
+		return interceptors.FieldViolation("min_price", "prices must not be negative")
+	}
+
+	if req.GetMaxPrice() < 0 {
+		return interceptors.FieldViolation("max_price", "prices must not be negative")
+	}
+
+	if req.GetMaxPrice() > 0 && req.GetMinPrice() > req.GetMaxPrice() {
+		return interceptors.FieldViolation("min_price", "min_price must not exceed max_price")
+	}
+
+	return nil
+}
+
+func validateBatchGetProducts(req *catalogv1.BatchGetProductsRequest) error {
+	if len(req.GetProductIds()) == 0 {
+		return interceptors.FieldViolation("product_ids", "product_ids is required")
+	}
+
+	if len(req.GetProductIds()) > maxBatchSize {
+		return interceptors.FieldViolation("product_ids", "at most 100 product_ids are allowed")
+	}
+
+	return nil
+}
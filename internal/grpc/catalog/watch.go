@@ -0,0 +1,173 @@
+package catalog
+
+import (
+	"context"
+	"slices"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"shop/internal/domain/models"
+	"shop/internal/grpc/interceptors"
+	"shop/lib/cursor"
+	catalogv1 "shop/protos/gen/go/catalog"
+)
+
+const (
+	// watchBuffer is how many changes a watcher may lag behind before changes are dropped.
+	watchBuffer = 256
+	replayBatch = 100
+)
+
+var ErrInvalidCursor = interceptors.FieldViolation("cursor", "Invalid cursor")
+
+func (s *ServerAPI) WatchInventory(
+	req *catalogv1.WatchInventoryRequest,
+	stream grpc.ServerStreamingServer[catalogv1.InventoryEvent],
+) error {
+	ctx := stream.Context()
+	match := changeFilter(req)
+
+	// Subscribe before reading the log so no change falls between replay and live changes.
+	sub := s.feed.Changes().Subscribe(s.buffer)
+	defer sub.Close()
+
+	last, err := cursor.Decode(req.GetCursor())
+	if err != nil {
+		return ErrInvalidCursor
+	}
+
+	if req.GetCursor() == "" {
+		last, err = s.feed.LastProductChange(ctx)
+		if err != nil {
+			return ErrInternal
+		}
+	} else {
+		last, err = s.replay(ctx, stream, match, last)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case change, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.Unavailable, "Inventory feed closed")
+			}
+
+			if dropped := sub.Dropped(); dropped > 0 {
+				err := stream.Send(&catalogv1.InventoryEvent{
+					Event: &catalogv1.InventoryEvent_Dropped{Dropped: &catalogv1.ChangesDropped{
+						Count:  dropped,
+						Cursor: cursor.Encode(last),
+					}},
+				})
+				if err != nil {
+					return err
+				}
+
+				// Everything after last, dropped changes included, is in the log.
+				last, err = s.replay(ctx, stream, match, last)
+				if err != nil {
+					return err
+				}
+			}
+
+			if change.Seq <= last {
+				continue
+			}
+			last = change.Seq
+
+			if match(change) {
+				if err := stream.Send(changeEvent(change)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// replay sends the logged changes after the cursor and returns the seq of the last one.
+func (s *ServerAPI) replay(
+	ctx context.Context,
+	stream grpc.ServerStreamingServer[catalogv1.InventoryEvent],
+	match func(models.ProductChange) bool,
+	after int64,
+) (int64, error) {
+	for {
+		batch, err := s.feed.ProductChanges(ctx, after, replayBatch)
+		if err != nil {
+			return 0, ErrInternal
+		}
+
+		for _, change := range batch {
+			after = change.Seq
+			if !match(change) {
+				continue
+			}
+
+			if err := stream.Send(changeEvent(change)); err != nil {
+				return 0, err
+			}
+		}
+
+		if len(batch) < replayBatch {
+			return after, nil
+		}
+	}
+}
+
+func changeFilter(req *catalogv1.WatchInventoryRequest) func(models.ProductChange) bool {
+	category, ids := req.GetCategory(), req.GetProductIds()
+
+	return func(change models.ProductChange) bool {
+		if category != "" && change.Category != category {
+			return false
+		}
+
+		return len(ids) == 0 || slices.Contains(ids, change.ProductID)
+	}
+}
+
+func changeEvent(change models.ProductChange) *catalogv1.InventoryEvent {
+	kind := catalogv1.ProductChange_KIND_UNSPECIFIED
+	switch change.Kind {
+	case models.ProductChangeStock:
+		kind = catalogv1.ProductChange_KIND_STOCK
+	case models.ProductChangePrice:
+		kind = catalogv1.ProductChange_KIND_PRICE
+	}
+
+	return &catalogv1.InventoryEvent{
+		Event: &catalogv1.InventoryEvent_Change{Change: &catalogv1.ProductChange{
+			Cursor:    cursor.Encode(change.Seq),
+			ProductId: change.ProductID,
+			Category:  change.Category,
+			Kind:      kind,
+			Price:     change.Price,
+			Stock:     int32(change.Stock),
+			ChangedAt: timestamppb.New(change.ChangedAt),
+		}},
+	}
+}
+
+func validateWatchInventory(req *catalogv1.WatchInventoryRequest) error {
+	if len(req.GetProductIds()) > maxBatchSize {
+		return interceptors.FieldViolation("product_ids", "at most 100 product_ids are allowed")
+	}
+
+	if slices.ContainsFunc(req.GetProductIds(), func(id int64) bool { return id <= 0 }) {
+		return interceptors.FieldViolation("product_ids", "product_ids must be positive")
+	}
+
+	if _, err := cursor.Decode(req.GetCursor()); err != nil {
+		return ErrInvalidCursor
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package catalog
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"shop/internal/domain/models"
+	"shop/internal/storage/changes"
+	"shop/lib/cursor"
+	catalogv1 "shop/protos/gen/go/catalog"
+)
+
+// fakeFeed logs changes in memory and publishes them on its bus after logging them, as the
+// change log poller does.
+type fakeFeed struct {
+	mu  sync.Mutex
+	log []models.ProductChange
+	bus *changes.Bus
+
+	// When hold is set, the next read of the feed closes entered and waits until hold is closed.
+	entered, hold chan struct{}
+}
+
+func newFakeFeed() *fakeFeed {
+	return &fakeFeed{bus: changes.NewBus()}
+}
+
+// append logs changes of the products in the category and publishes them.
+func (f *fakeFeed) append(category string, productIDs ...int64) {
+	f.mu.Lock()
+	var added []models.ProductChange
+	for _, id := range productIDs {
+		change := models.ProductChange{
+			Seq:       int64(len(f.log) + 1),
+			ProductID: id,
+			Category:  category,
+			Kind:      models.ProductChangeStock,
+		}
+		f.log = append(f.log, change)
+		added = append(added, change)
+	}
+	f.mu.Unlock()
+
+	f.bus.Publish(added...)
+}
+
+// holdNextRead makes the next read of the feed wait until the returned func is called. The
+// returned channel is closed once a read waits, so the watcher has subscribed to the bus.
+func (f *fakeFeed) holdNextRead() (<-chan struct{}, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entered, hold := make(chan struct{}), make(chan struct{})
+	f.entered, f.hold = entered, hold
+
+	return entered, sync.OnceFunc(func() { close(hold) })
+}
+
+func (f *fakeFeed) wait() {
+	f.mu.Lock()
+	entered, hold := f.entered, f.hold
+	f.entered, f.hold = nil, nil
+	f.mu.Unlock()
+
+	if hold != nil {
+		close(entered)
+		<-hold
+	}
+}
+
+func (f *fakeFeed) ProductChanges(_ context.Context, afterSeq int64, limit int) ([]models.ProductChange, error) {
+	f.wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var batch []models.ProductChange
+	for _, change := range f.log {
+		if change.Seq > afterSeq && len(batch) < limit {
+			batch = append(batch, change)
+		}
+	}
+
+	return batch, nil
+}
+
+// LastProductChange reads the log before waiting, like a read that races with later writes.
+func (f *fakeFeed) LastProductChange(context.Context) (int64, error) {
+	f.mu.Lock()
+	last := int64(len(f.log))
+	f.mu.Unlock()
+
+	f.wait()
+
+	return last, nil
+}
+
+func (f *fakeFeed) Changes() *changes.Bus {
+	return f.bus
+}
+
+// watch starts WatchInventory on a server subscribing with buffer.
+func watch(
+	t *testing.T,
+	feed *fakeFeed,
+	buffer int,
+	req *catalogv1.WatchInventoryRequest,
+) grpc.ServerStreamingClient[catalogv1.InventoryEvent] {
+	t.Helper()
+
+	srv := grpc.NewServer()
+	catalogv1.RegisterCatalogServer(srv, &ServerAPI{feed: feed, buffer: buffer})
+
+	lis := bufconn.Listen(1 << 20)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	stream, err := catalogv1.NewCatalogClient(conn).WatchInventory(ctx, req)
+	if err != nil {
+		t.Fatalf("WatchInventory: %v", err)
+	}
+
+	return stream
+}
+
+// receive reads n events and describes them as the seq of a change or "dropped N after SEQ".
+func receive(t *testing.T, stream grpc.ServerStreamingClient[catalogv1.InventoryEvent], n int) []any {
+	t.Helper()
+
+	var events []any
+	for range n {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv after %v: %v", events, err)
+		}
+
+		if d := event.GetDropped(); d != nil {
+			after, err := cursor.Decode(d.GetCursor())
+			if err != nil {
+				t.Fatalf("cursor of %v: %v", d, err)
+			}
+			events = append(events, dropped{count: d.GetCount(), after: after})
+			continue
+		}
+
+		seq, err := cursor.Decode(event.GetChange().GetCursor())
+		if err != nil {
+			t.Fatalf("cursor of %v: %v", event, err)
+		}
+		events = append(events, seq)
+	}
+
+	return events
+}
+
+type dropped struct {
+	count, after int64
+}
+
+func TestWatchInventoryFilters(t *testing.T) {
+	tests := []struct {
+		name       string
+		category   string
+		productIDs []int64
+		want       []int64
+	}{
+		{name: "everything", want: []int64{1, 2, 3, 4, 5, 6, 7}},
+		{name: "category", category: "mugs", want: []int64{1, 3, 4, 7}},
+		{name: "products", productIDs: []int64{2, 3}, want: []int64{2, 3, 5, 6, 7}},
+		{name: "category and products", category: "mugs", productIDs: []int64{2, 3}, want: []int64{3, 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := newFakeFeed()
+			feed.append("mugs", 1)
+			feed.append("posters", 2)
+			feed.append("mugs", 3)
+
+			stream := watch(t, feed, 8, &catalogv1.WatchInventoryRequest{
+				Category:   tt.category,
+				ProductIds: tt.productIDs,
+				Cursor:     cursor.Encode(0),
+			})
+
+			// Changes 1 to 3 are replayed from the log, 4 to 7 come live.
+			replayed := receive(t, stream, slices.IndexFunc(tt.want, func(seq int64) bool { return seq > 3 }))
+			feed.append("mugs", 1)
+			feed.append("posters", 2, 3)
+			feed.append("mugs", 3)
+			got := append(replayed, receive(t, stream, len(tt.want)-len(replayed))...)
+
+			want := make([]any, 0, len(tt.want))
+			for _, seq := range tt.want {
+				want = append(want, seq)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("events = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWatchInventoryResumesAfterTheCursor(t *testing.T) {
+	feed := newFakeFeed()
+	feed.append("mugs", 1, 2, 3, 4, 5)
+
+	stream := watch(t, feed, 8, &catalogv1.WatchInventoryRequest{Cursor: cursor.Encode(3)})
+
+	if got, want := receive(t, stream, 2), []any{int64(4), int64(5)}; !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestWatchInventoryWithoutCursorStartsLive(t *testing.T) {
+	feed := newFakeFeed()
+	feed.append("mugs", 1, 2)
+
+	entered, release := feed.holdNextRead()
+	stream := watch(t, feed, 8, &catalogv1.WatchInventoryRequest{})
+	<-entered
+	release()
+	feed.append("mugs", 3)
+
+	if got, want := receive(t, stream, 1), []any{int64(3)}; !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestWatchInventoryReplayThenLive(t *testing.T) {
+	feed := newFakeFeed()
+	feed.append("mugs", 1, 2, 3)
+
+	entered, release := feed.holdNextRead()
+	stream := watch(t, feed, 8, &catalogv1.WatchInventoryRequest{Cursor: cursor.Encode(1)})
+
+	// Change 4 is logged after the watcher subscribed but before it reads the log, so it is
+	// both replayed and published.
+	<-entered
+	feed.append("mugs", 4)
+	release()
+	replayed := receive(t, stream, 3)
+
+	feed.append("mugs", 5)
+	got := append(replayed, receive(t, stream, 1)...)
+
+	if want := []any{int64(2), int64(3), int64(4), int64(5)}; !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestWatchInventoryReplaysDroppedChanges(t *testing.T) {
+	feed := newFakeFeed()
+
+	// The watcher reads the last seq before changes 1 to 5 are logged, and its buffer of 2
+	// drops 3 of them while it waits.
+	entered, release := feed.holdNextRead()
+	stream := watch(t, feed, 2, &catalogv1.WatchInventoryRequest{})
+	<-entered
+	feed.append("mugs", 1, 2, 3, 4, 5)
+	release()
+	recovered := receive(t, stream, 6)
+
+	feed.append("mugs", 6)
+	got := append(recovered, receive(t, stream, 1)...)
+
+	want := []any{dropped{count: 3, after: 0}, int64(1), int64(2), int64(3), int64(4), int64(5), int64(6)}
+	if !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
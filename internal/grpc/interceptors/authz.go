@@ -0,0 +1,191 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"shop/lib/jwt"
+)
+
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, userID int64, permission string) (bool, error)
+}
+
+// Policy says who may call each method, by full method name (e.g. "/auth.Auth/IsAdmin").
+// Public methods need no token. Internal methods need none either, but only answer internal
+// callers, see InternalCaller. Every other method needs a valid bearer token and, if listed
+// in Permissions, the caller must also hold that permission.
+type Policy struct {
+	Public      map[string]bool
+	Internal    map[string]bool
+	Permissions map[string]string
+}
+
+// InProcessAuthType is the auth type of the peer of in-process calls, see local.Conn.
+const InProcessAuthType = "in-process"
+
+// InternalCaller reports whether the call comes from within the deployment: from this
+// process, or from a client that authenticated with a certificate the server verified.
+func InternalCaller(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return false
+	}
+
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		return len(info.State.VerifiedChains) > 0
+	}
+
+	return p.AuthInfo.AuthType() == InProcessAuthType
+}
+
+type claimsKey struct{}
+
+// ClaimsFromContext returns claims of the caller authenticated by the authz interceptors.
+func ClaimsFromContext(ctx context.Context) (jwt.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(jwt.Claims)
+	return claims, ok
+}
+
+// AuthzUnary authenticates the caller by the bearer token and checks the policy of the method.
+func AuthzUnary(log *zap.Logger, apps jwt.AppProvider, checker PermissionChecker, policy Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if policy.Public[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		if policy.Internal[info.FullMethod] {
+			if err := internalOnly(ctx, log, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}
+
+		ctx, err := authorize(ctx, log, apps, checker, info.FullMethod, policy.Permissions[info.FullMethod])
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// AuthzStream is the streaming counterpart of AuthzUnary.
+func AuthzStream(log *zap.Logger, apps jwt.AppProvider, checker PermissionChecker, policy Policy) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if policy.Public[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		if policy.Internal[info.FullMethod] {
+			if err := internalOnly(ss.Context(), log, info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}
+
+		ctx, err := authorize(ss.Context(), log, apps, checker, info.FullMethod, policy.Permissions[info.FullMethod])
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func internalOnly(ctx context.Context, log *zap.Logger, method string) error {
+	if InternalCaller(ctx) {
+		return nil
+	}
+
+	log.Warn("external call of an internal method",
+		zap.String("method", method),
+		zap.String("request_id", RequestIDFromContext(ctx)))
+
+	return status.Error(codes.PermissionDenied, "internal method")
+}
+
+func authorize(
+	ctx context.Context,
+	log *zap.Logger,
+	apps jwt.AppProvider,
+	checker PermissionChecker,
+	method string,
+	permission string,
+) (context.Context, error) {
+	claims, err := claimsFromMetadata(ctx, apps)
+	if err != nil {
+		log.Warn("unauthenticated call",
+			zap.String("method", method),
+			zap.String("request_id", RequestIDFromContext(ctx)),
+			zap.Error(err))
+
+		return nil, err
+	}
+
+	if permission == "" {
+		return context.WithValue(ctx, claimsKey{}, claims), nil
+	}
+
+	has, err := checker.HasPermission(ctx, claims.UID, permission)
+	if err != nil {
+		log.Error("failed to check permission",
+			zap.String("method", method),
+			zap.Int64("uid", claims.UID),
+			zap.Error(err))
+
+		return nil, status.Error(codes.Internal, "failed to check permission")
+	}
+
+	if !has {
+		log.Warn("permission denied",
+			zap.String("method", method),
+			zap.Int64("uid", claims.UID),
+			zap.String("permission", permission))
+
+		return nil, status.Error(codes.PermissionDenied, "permission denied")
+	}
+
+	return context.WithValue(ctx, claimsKey{}, claims), nil
+}
+
+// claimsFromMetadata parses the "authorization: Bearer <token>" metadata and verifies the token
+// with the secret of its app.
+func claimsFromMetadata(ctx context.Context, apps jwt.AppProvider) (jwt.Claims, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return jwt.Claims{}, status.Error(codes.Unauthenticated, "missing metadata")
+	}
+
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return jwt.Claims{}, status.Error(codes.Unauthenticated, "missing authorization token")
+	}
+
+	token, found := strings.CutPrefix(values[0], "Bearer ")
+	if !found || token == "" {
+		return jwt.Claims{}, status.Error(codes.Unauthenticated, "invalid authorization header")
+	}
+
+	claims, err := jwt.ParseToken(ctx, apps, token)
+	if err != nil {
+		return jwt.Claims{}, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	return claims, nil
+}
+
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedStream) Context() context.Context {
+	return w.ctx
+}
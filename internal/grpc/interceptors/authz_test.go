@@ -0,0 +1,117 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"shop/internal/domain/models"
+	"shop/lib/jwt"
+)
+
+// fakeApps knows app 1, whose secret is "app-secret".
+type fakeApps struct{}
+
+func (fakeApps) App(_ context.Context, appID int) (models.App, error) {
+	if appID != 1 {
+		return models.App{}, errors.New("app not found")
+	}
+
+	return models.App{ID: 1, Secret: "app-secret"}, nil
+}
+
+func bearer(t *testing.T, secret string) string {
+	t.Helper()
+
+	token, err := jwt.NewToken(models.User{ID: 7}, models.App{ID: 1, Secret: secret}, nil, time.Hour)
+	if err != nil {
+		t.Fatalf("NewToken: %v", err)
+	}
+
+	return "Bearer " + token
+}
+
+func TestClaimsFromMetadata(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		want          codes.Code
+	}{
+		{name: "valid token", authorization: bearer(t, "app-secret"), want: codes.OK},
+		{name: "token signed with another key", authorization: bearer(t, "test-secret"), want: codes.Unauthenticated},
+		{name: "missing token", want: codes.Unauthenticated},
+		{name: "not a bearer token", authorization: "Basic dXNlcjpwYXNz", want: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := metadata.MD{}
+			if tt.authorization != "" {
+				md.Set("authorization", tt.authorization)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+
+			claims, err := claimsFromMetadata(ctx, fakeApps{})
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("code = %v, want %v (%v)", got, tt.want, err)
+			}
+			if err == nil && claims.UID != 7 {
+				t.Errorf("uid = %d, want 7", claims.UID)
+			}
+		})
+	}
+}
+
+func TestAuthzUnaryInternalMethods(t *testing.T) {
+	const method = "/accounts.Accounts/HasPermission"
+	policy := Policy{Internal: map[string]bool{method: true}}
+
+	verified := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{}}}}
+
+	tests := []struct {
+		name string
+		peer *peer.Peer
+		want codes.Code
+	}{
+		{name: "in-process caller", peer: &peer.Peer{AuthInfo: inProcess{}}, want: codes.OK},
+		{name: "mtls caller", peer: &peer.Peer{AuthInfo: verified}, want: codes.OK},
+		{name: "tls without client cert", peer: &peer.Peer{AuthInfo: credentials.TLSInfo{}}, want: codes.PermissionDenied},
+		{name: "insecure caller", peer: &peer.Peer{}, want: codes.PermissionDenied},
+		{name: "no peer", want: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.peer != nil {
+				ctx = peer.NewContext(ctx, tt.peer)
+			}
+
+			_, err := AuthzUnary(zap.NewNop(), fakeApps{}, nil, policy)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+				func(context.Context, any) (any, error) {
+					return nil, nil
+				})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("code = %v, want %v (%v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+// inProcess is the auth info local.Conn gives its calls.
+type inProcess struct{}
+
+func (inProcess) AuthType() string {
+	return InProcessAuthType
+}
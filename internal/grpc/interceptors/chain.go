@@ -0,0 +1,23 @@
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// ChainUnary combines interceptors into one, the first being the outermost,
+// like grpc.ChainUnaryInterceptor does for a server.
+func ChainUnary(chain ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		next := handler
+		for i := len(chain) - 1; i >= 0; i-- {
+			interceptor, inner := chain[i], next
+			next = func(ctx context.Context, req any) (any, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,52 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestDeadlineUnary(t *testing.T) {
+	tests := []struct {
+		name         string
+		timeout      time.Duration
+		callerLimit  time.Duration
+		wantDeadline time.Duration
+	}{
+		{name: "default", timeout: time.Second, wantDeadline: time.Second},
+		{name: "shorter caller deadline kept", timeout: time.Second, callerLimit: time.Minute / 100,
+			wantDeadline: time.Minute / 100},
+		{name: "longer caller deadline kept", timeout: time.Second, callerLimit: time.Minute,
+			wantDeadline: time.Minute},
+		{name: "no default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.callerLimit > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.callerLimit)
+				defer cancel()
+			}
+
+			start := time.Now()
+			_, _ = DeadlineUnary(tt.timeout)(ctx, nil, &grpc.UnaryServerInfo{},
+				func(ctx context.Context, _ any) (any, error) {
+					deadline, ok := ctx.Deadline()
+					if ok != (tt.wantDeadline > 0) {
+						t.Fatalf("deadline set = %v, want %v", ok, tt.wantDeadline > 0)
+					}
+					if !ok {
+						return nil, nil
+					}
+					if got := deadline.Sub(start); got < tt.wantDeadline-time.Second/10 || got > tt.wantDeadline+time.Second/10 {
+						t.Errorf("deadline in %v, want %v", got, tt.wantDeadline)
+					}
+					return nil, nil
+				})
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package interceptors
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// LoggingUnary writes an access log entry for every call.
+func LoggingUnary(log *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		logCall(ctx, log, info.FullMethod, start, err)
+
+		return resp, err
+	}
+}
+
+// LoggingStream is the streaming counterpart of LoggingUnary; the entry is written when the stream ends.
+func LoggingStream(log *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+
+		err := handler(srv, ss)
+
+		logCall(ss.Context(), log, info.FullMethod, start, err)
+
+		return err
+	}
+}
+
+func logCall(ctx context.Context, log *zap.Logger, method string, start time.Time, err error) {
+	code := status.Code(err)
+
+	fields := []zap.Field{
+		zap.String("method", method),
+		zap.String("code", code.String()),
+		zap.Duration("duration", time.Since(start)),
+		zap.String("request_id", RequestIDFromContext(ctx)),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, zap.String("peer", p.Addr.String()))
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+
+	log.Check(levelFor(code), "gRPC call").Write(fields...)
+}
+
+// levelFor logs server faults as errors and client mistakes as warnings.
+func levelFor(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.OK:
+		return zapcore.InfoLevel
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable, codes.Unimplemented:
+		return zapcore.ErrorLevel
+	default:
+		return zapcore.WarnLevel
+	}
+}
@@ -0,0 +1,47 @@
+package interceptors
+
+import (
+	"context"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RecoveryUnary turns a panic in the handler into a codes.Internal error instead of crashing the server.
+func RecoveryUnary(log *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ctx, log, info.FullMethod, r)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStream is the streaming counterpart of RecoveryUnary.
+func RecoveryStream(log *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), log, info.FullMethod, r)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, log *zap.Logger, method string, r any) error {
+	log.Error("panic in gRPC handler",
+		zap.String("method", method),
+		zap.String("request_id", RequestIDFromContext(ctx)),
+		zap.Any("panic", r),
+		zap.ByteString("stack", debug.Stack()))
+
+	return status.Error(codes.Internal, "internal error")
+}
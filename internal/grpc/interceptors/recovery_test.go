@@ -0,0 +1,51 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecovery(t *testing.T) {
+	const secret = "db password is hunter2"
+
+	unary := RecoveryUnary(zap.NewNop())
+	_, unaryErr := unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/shop.v1.Catalog/GetProduct"},
+		func(context.Context, any) (any, error) {
+			panic(secret)
+		})
+
+	stream := RecoveryStream(zap.NewNop())
+	streamErr := stream(nil, &wrappedStream{ctx: context.Background()},
+		&grpc.StreamServerInfo{FullMethod: "/shop.v1.Catalog/WatchInventory"},
+		func(any, grpc.ServerStream) error {
+			panic(secret)
+		})
+
+	for name, err := range map[string]error{"unary": unaryErr, "stream": streamErr} {
+		st := status.Convert(err)
+		if st.Code() != codes.Internal {
+			t.Errorf("%s code = %s, want %s", name, st.Code(), codes.Internal)
+		}
+		if msg := st.Message(); strings.Contains(msg, secret) || strings.Contains(msg, "goroutine") {
+			t.Errorf("%s message %q leaks the panic", name, msg)
+		}
+	}
+}
+
+func TestRecoveryPassesResults(t *testing.T) {
+	want := status.Error(codes.NotFound, "product not found")
+
+	resp, err := RecoveryUnary(zap.NewNop())(context.Background(), nil, &grpc.UnaryServerInfo{},
+		func(context.Context, any) (any, error) {
+			return "product", want
+		})
+	if resp != "product" || err != want {
+		t.Errorf("RecoveryUnary = %v, %v; want the results of the handler", resp, err)
+	}
+}
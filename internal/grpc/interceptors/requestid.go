@@ -0,0 +1,79 @@
+package interceptors
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"shop/internal/events"
+)
+
+// RequestIDKey is the metadata key carrying the request ID, so a call can be traced from the HTTP tier.
+const RequestIDKey = "x-request-id"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID set by the request ID interceptors.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDUnary takes the request ID from incoming metadata, or generates one, stores it
+// in the context and sends it back in the response header. Events published by the call
+// carry it as their trace ID.
+func RequestIDUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx = withRequestID(ctx)
+
+		return handler(ctx, req)
+	}
+}
+
+// RequestIDStream is the streaming counterpart of RequestIDUnary.
+func RequestIDStream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := withRequestID(ss.Context())
+
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// RequestIDClient forwards the request ID found by fromContext (e.g. chi's middleware.GetReqID)
+// in outgoing metadata.
+func RequestIDClient(fromContext func(ctx context.Context) string) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if id := fromContext(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func withRequestID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(RequestIDKey); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+
+	ctx = events.ContextWithTraceID(ctx, id)
+
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
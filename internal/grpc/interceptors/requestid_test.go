@@ -0,0 +1,119 @@
+package interceptors
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+
+	"shop/internal/events"
+)
+
+// healthClient serves the health service with the request ID interceptor and sends the
+// request IDs the handler saw, and their trace IDs, to seen.
+func healthClient(t *testing.T, seen chan<- [2]string) healthv1.HealthClient {
+	t.Helper()
+
+	record := func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		seen <- [2]string{RequestIDFromContext(ctx), events.TraceIDFromContext(ctx)}
+		return handler(ctx, req)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(RequestIDUnary(), record))
+	healthv1.RegisterHealthServer(srv, health.NewServer())
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return healthv1.NewHealthClient(conn)
+}
+
+func TestRequestIDUnary(t *testing.T) {
+	seen := make(chan [2]string, 1)
+	client := healthClient(t, seen)
+
+	tests := []struct {
+		name string
+		sent string
+	}{
+		{name: "propagated", sent: "req-42"},
+		{name: "generated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.sent != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, RequestIDKey, tt.sent)
+			}
+
+			var header metadata.MD
+			if _, err := client.Check(ctx, &healthv1.HealthCheckRequest{}, grpc.Header(&header)); err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+
+			ids := <-seen
+			id := ids[0]
+			if tt.sent != "" && id != tt.sent {
+				t.Errorf("request ID = %q, want %q", id, tt.sent)
+			}
+			if tt.sent == "" {
+				if _, err := uuid.Parse(id); err != nil {
+					t.Errorf("generated request ID %q is not a UUID: %v", id, err)
+				}
+			}
+			if ids[1] != id {
+				t.Errorf("trace ID = %q, want the request ID %q", ids[1], id)
+			}
+			if got := header.Get(RequestIDKey); len(got) != 1 || got[0] != id {
+				t.Errorf("header %s = %v, want [%s]", RequestIDKey, got, id)
+			}
+		})
+	}
+}
+
+func TestRequestIDClient(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want []string
+	}{
+		{name: "forwarded", id: "req-42", want: []string{"req-42"}},
+		{name: "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := RequestIDClient(func(context.Context) string { return tt.id })
+
+			err := client(context.Background(), "/grpc.health.v1.Health/Check", nil, nil, nil,
+				func(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+					md, _ := metadata.FromOutgoingContext(ctx)
+					if got := md.Get(RequestIDKey); len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+						t.Errorf("outgoing %s = %v, want %v", RequestIDKey, got, tt.want)
+					}
+					return nil
+				})
+			if err != nil {
+				t.Fatalf("invoke: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Validator checks the payload of a request before it reaches the handler.
+type Validator func(req any) error
+
+// ValidateFunc adapts a validation function of a concrete request type to a Validator.
+func ValidateFunc[T any](fn func(T) error) Validator {
+	return func(req any) error {
+		r, ok := req.(T)
+		if !ok {
+			return status.Error(codes.InvalidArgument, "unexpected request type")
+		}
+
+		return fn(r)
+	}
+}
+
+// ValidationUnary runs the validator registered for the method (full method name) on the request.
+// Errors that are not gRPC statuses are returned as codes.InvalidArgument.
+func ValidationUnary(validators map[string]Validator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if validate, ok := validators[info.FullMethod]; ok {
+			if err := validate(req); err != nil {
+				return nil, invalidArgument(err)
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// ValidationStream validates every message received on the stream.
+func ValidationStream(validators map[string]Validator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		validate, ok := validators[info.FullMethod]
+		if !ok {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &validatingStream{ServerStream: ss, validate: validate})
+	}
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+	validate Validator
+}
+
+func (s *validatingStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if err := s.validate(m); err != nil {
+		return invalidArgument(err)
+	}
+
+	return nil
+}
+
+// FieldViolation is an InvalidArgument status with the description as its message and as
+// a BadRequest field violation of field, so clients can show it next to the field.
+func FieldViolation(field, description string) error {
+	return FieldViolations(&errdetails.BadRequest_FieldViolation{Field: field, Description: description})
+}
+
+// FieldViolations returns an InvalidArgument status carrying all the violations in a
+// BadRequest detail. The message is the description of the first one.
+func FieldViolations(violations ...*errdetails.BadRequest_FieldViolation) error {
+	st := status.New(codes.InvalidArgument, violations[0].GetDescription())
+
+	detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+func invalidArgument(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	return status.Error(codes.InvalidArgument, err.Error())
+}
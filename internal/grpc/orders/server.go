@@ -0,0 +1,203 @@
+package orders
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"shop/internal/domain/models"
+	"shop/internal/grpc/interceptors"
+	"shop/internal/services/checkout"
+	"shop/lib/cursor"
+	ordersv1 "shop/protos/gen/go/orders"
+)
+
+var (
+	ErrNotFound        = status.Error(codes.NotFound, "Order not found")
+	ErrEmptyCart       = status.Error(codes.FailedPrecondition, "Cart is empty")
+	ErrOutOfStock      = status.Error(codes.FailedPrecondition, "Not enough items in stock")
+	ErrFinalized       = status.Error(codes.FailedPrecondition, "Order can no longer be cancelled")
+	ErrUnauthenticated = status.Error(codes.Unauthenticated, "missing authorization token")
+	ErrInternal        = status.Error(codes.Internal, "Internal error")
+	ErrInvalidToken    = interceptors.FieldViolation("page_token", "Invalid page token")
+)
+
+// Checkout is the checkout service.
+type Checkout interface {
+	PlaceOrder(ctx context.Context, userID int64) (models.Order, error)
+	Order(ctx context.Context, userID, orderID int64) (models.Order, error)
+	Orders(ctx context.Context, userID int64, pageToken string, pageSize int) (checkout.OrderPage, error)
+	CancelOrder(ctx context.Context, userID, orderID int64) (models.Order, error)
+}
+
+type ServerAPI struct {
+	ordersv1.UnimplementedOrdersServer
+	checkout Checkout
+}
+
+func Register(gRPC grpc.ServiceRegistrar, checkout Checkout) {
+	ordersv1.RegisterOrdersServer(gRPC, &ServerAPI{checkout: checkout})
+}
+
+// Validators returns the payload validators of the Orders methods for the validation interceptor.
+func Validators() map[string]interceptors.Validator {
+	return map[string]interceptors.Validator{
+		ordersv1.Orders_GetOrder_FullMethodName:    interceptors.ValidateFunc(validateGetOrder),
+		ordersv1.Orders_ListOrders_FullMethodName:  interceptors.ValidateFunc(validateListOrders),
+		ordersv1.Orders_CancelOrder_FullMethodName: interceptors.ValidateFunc(validateCancelOrder),
+	}
+}
+
+func (s *ServerAPI) PlaceOrder(
+	ctx context.Context,
+	_ *ordersv1.PlaceOrderRequest,
+) (*ordersv1.PlaceOrderResponse, error) {
+	uid, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.checkout.PlaceOrder(ctx, uid)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ordersv1.PlaceOrderResponse{Order: toProto(order)}, nil
+}
+
+func (s *ServerAPI) GetOrder(
+	ctx context.Context,
+	req *ordersv1.GetOrderRequest,
+) (*ordersv1.GetOrderResponse, error) {
+	uid, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.checkout.Order(ctx, uid, req.GetOrderId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ordersv1.GetOrderResponse{Order: toProto(order)}, nil
+}
+
+func (s *ServerAPI) ListOrders(
+	ctx context.Context,
+	req *ordersv1.ListOrdersRequest,
+) (*ordersv1.ListOrdersResponse, error) {
+	uid, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.checkout.Orders(ctx, uid, req.GetPageToken(), int(req.GetPageSize()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &ordersv1.ListOrdersResponse{NextPageToken: page.NextPageToken}
+	for _, o := range page.Orders {
+		resp.Orders = append(resp.Orders, toProto(o))
+	}
+
+	return resp, nil
+}
+
+func (s *ServerAPI) CancelOrder(
+	ctx context.Context,
+	req *ordersv1.CancelOrderRequest,
+) (*ordersv1.CancelOrderResponse, error) {
+	uid, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.checkout.CancelOrder(ctx, uid, req.GetOrderId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ordersv1.CancelOrderResponse{Order: toProto(order)}, nil
+}
+
+// toStatus converts an error of the checkout service into a status error.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, checkout.ErrEmptyCart):
+		return ErrEmptyCart
+	case errors.Is(err, checkout.ErrOutOfStock):
+		return ErrOutOfStock
+	case errors.Is(err, checkout.ErrOrderNotFound):
+		return ErrNotFound
+	case errors.Is(err, checkout.ErrOrderFinalized):
+		return ErrFinalized
+	case errors.Is(err, checkout.ErrInvalidPageToken):
+		return ErrInvalidToken
+	default:
+		return ErrInternal
+	}
+}
+
+func toProto(o models.Order) *ordersv1.Order {
+	order := &ordersv1.Order{
+		Id:        o.ID,
+		Status:    o.Status,
+		Subtotal:  o.Subtotal,
+		Shipping:  o.Shipping,
+		Tax:       o.Tax,
+		Total:     o.Total,
+		CreatedAt: timestamppb.New(o.CreatedAt),
+	}
+	for _, item := range o.Items {
+		order.Items = append(order.Items, &ordersv1.OrderItem{
+			ProductId:   item.ProductID,
+			ProductName: item.ProductName,
+			Price:       item.Price,
+			Quantity:    int32(item.Quantity),
+		})
+	}
+
+	return order
+}
+
+func userID(ctx context.Context) (int64, error) {
+	claims, ok := interceptors.ClaimsFromContext(ctx)
+	if !ok {
+		return 0, ErrUnauthenticated
+	}
+
+	return claims.UID, nil
+}
+
+func validateGetOrder(req *ordersv1.GetOrderRequest) error {
+	if req.GetOrderId() <= 0 {
+		return interceptors.FieldViolation("order_id", "order_id is required")
+	}
+
+	return nil
+}
+
+func validateListOrders(req *ordersv1.ListOrdersRequest) error {
+	if req.GetPageSize() < 0 {
+		return interceptors.FieldViolation("page_size", "page_size must not be negative")
+	}
+
+	if _, err := cursor.Decode(req.GetPageToken()); err != nil {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+func validateCancelOrder(req *ordersv1.CancelOrderRequest) error {
+	if req.GetOrderId() <= 0 {
+		return interceptors.FieldViolation("order_id", "order_id is required")
+	}
+
+	return nil
+}
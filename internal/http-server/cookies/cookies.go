@@ -66,3 +66,63 @@ func ClearSessionCookie(w http.ResponseWriter) {
 func generateUUIDSession() string {
 	return uuid.New().String()
 }
+
+const OIDCCookieName = "oidc_flow"
+
+// SetOIDCCookie stores the state of an OpenID Connect login until the provider redirects back.
+func SetOIDCCookie(w http.ResponseWriter, value string, expiresAt time.Time) {
+	cookie := &http.Cookie{
+		Name:     OIDCCookieName,
+		Value:    value,
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/auth/oidc",
+	}
+	http.SetCookie(w, cookie)
+}
+
+func ClearOIDCCookie(w http.ResponseWriter) {
+	cookie := &http.Cookie{
+		Name:     OIDCCookieName,
+		Value:    "",
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/auth/oidc",
+		MaxAge:   -1,
+	}
+	http.SetCookie(w, cookie)
+}
+
+const CSRFCookieName = "csrf_token"
+
+func SetCSRFCookie(w http.ResponseWriter, token string) {
+	cookie := &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    token,
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/",
+	}
+	http.SetCookie(w, cookie)
+}
+
+const LocaleCookieName = "lang"
+
+// SetLocaleCookie remembers the locale picked by the visitor for a year.
+func SetLocaleCookie(w http.ResponseWriter, locale string) {
+	cookie := &http.Cookie{
+		Name:     LocaleCookieName,
+		Value:    locale,
+		Expires:  time.Now().AddDate(1, 0, 0),
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/",
+	}
+	http.SetCookie(w, cookie)
+}
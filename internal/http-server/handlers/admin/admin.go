@@ -0,0 +1,233 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/http-server/middleware/csrf"
+	"shop/internal/http-server/views"
+	"shop/internal/i18n"
+	"shop/internal/services/auth"
+)
+
+type Storage interface {
+	UsersWithRoles(ctx context.Context) ([]models.UserRoles, error)
+	Roles(ctx context.Context) ([]models.Role, error)
+}
+
+type AuthService interface {
+	UnlockUser(ctx context.Context, email string) error
+	AssignRole(ctx context.Context, userID int64, role string) error
+	RevokeRole(ctx context.Context, userID int64, role string) error
+}
+
+type Handler struct {
+	storage Storage
+	auth    AuthService
+	logger  *zap.Logger
+	tmpl    *views.Page
+}
+
+func NewAdminHandler(storage Storage, authService AuthService, pages *views.Views, logger *zap.Logger) *Handler {
+	tmpl, err := pages.Page("users.html")
+	if err != nil {
+		logger.Fatal("failed to parse admin template", zap.Error(err))
+	}
+
+	return &Handler{
+		storage: storage,
+		auth:    authService,
+		logger:  logger,
+		tmpl:    tmpl,
+	}
+}
+
+// messages are the keys of the messages the page shows through its error and success query
+// parameters, and the query parameter naming their argument, if any. Other keys are ignored
+// and arguments have to name a listed user or role, so a link can't put arbitrary text on
+// the page.
+var messages = map[string]string{
+	"error.invalid_form":          "",
+	"error.internal_retry":        "",
+	"admin.error.email_required":  "",
+	"admin.error.invalid_user_id": "",
+	"admin.error.role_required":   "",
+	"admin.error.unknown_role":    "",
+	"admin.success.unlocked":      "email",
+	"admin.success.role_assigned": "role",
+	"admin.success.role_revoked":  "role",
+}
+
+type PageData struct {
+	Title     string
+	Error     string
+	Success   string
+	Users     []models.UserRoles
+	Roles     []models.Role
+	CSRFToken string
+	Locale    *i18n.Localizer
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	loc := i18n.FromContext(r.Context())
+	data := PageData{
+		Title:     loc.T("admin.title"),
+		CSRFToken: csrf.Token(r),
+		Locale:    loc,
+	}
+
+	users, err := h.storage.UsersWithRoles(r.Context())
+	if err != nil {
+		h.logger.Error("failed to fetch users", zap.Error(err))
+		data.Error = loc.T("admin.error.load_users")
+	}
+	data.Users = users
+
+	roles, err := h.storage.Roles(r.Context())
+	if err != nil {
+		h.logger.Error("failed to fetch roles", zap.Error(err))
+		data.Error = loc.T("admin.error.load_roles")
+	}
+	data.Roles = roles
+
+	if data.Error == "" {
+		data.Error = message(r, loc, "error", users, roles)
+	}
+	data.Success = message(r, loc, "success", users, roles)
+
+	if err := h.tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute admin template", zap.Error(err))
+		http.Error(w, loc.T("error.internal"), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleUnlock clears failed login attempts for the email from the form, so the user can log in again.
+func (h *Handler) HandleUnlock(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.logger.Error("failed to parse unlock form", zap.Error(err))
+		h.redirect(w, r, "error", "error.invalid_form")
+		return
+	}
+
+	email := r.FormValue("email")
+	if email == "" {
+		h.redirect(w, r, "error", "admin.error.email_required")
+		return
+	}
+
+	if err := h.auth.UnlockUser(r.Context(), email); err != nil {
+		h.logger.Error("failed to unlock user", zap.String("email", email), zap.Error(err))
+		h.redirect(w, r, "error", "error.internal_retry")
+		return
+	}
+
+	h.logger.Info("user unlocked by admin", zap.String("email", email))
+
+	h.redirect(w, r, "success", "admin.success.unlocked", email)
+}
+
+func (h *Handler) HandleAssignRole(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := h.parseRoleForm(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.auth.AssignRole(r.Context(), userID, role); err != nil {
+		h.logger.Error("failed to assign role", zap.Int64("userID", userID), zap.String("role", role), zap.Error(err))
+		if errors.Is(err, auth.ErrRoleNotFound) {
+			h.redirect(w, r, "error", "admin.error.unknown_role")
+			return
+		}
+		h.redirect(w, r, "error", "error.internal_retry")
+		return
+	}
+
+	h.redirect(w, r, "success", "admin.success.role_assigned", role)
+}
+
+func (h *Handler) HandleRevokeRole(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := h.parseRoleForm(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.auth.RevokeRole(r.Context(), userID, role); err != nil {
+		h.logger.Error("failed to revoke role", zap.Int64("userID", userID), zap.String("role", role), zap.Error(err))
+		h.redirect(w, r, "error", "error.internal_retry")
+		return
+	}
+
+	h.redirect(w, r, "success", "admin.success.role_revoked", role)
+}
+
+func (h *Handler) parseRoleForm(w http.ResponseWriter, r *http.Request) (int64, string, bool) {
+	if err := r.ParseForm(); err != nil {
+		h.logger.Error("failed to parse role form", zap.Error(err))
+		h.redirect(w, r, "error", "error.invalid_form")
+		return 0, "", false
+	}
+
+	userID, err := strconv.ParseInt(r.FormValue("user_id"), 10, 64)
+	if err != nil {
+		h.logger.Error("failed to parse user id", zap.Error(err))
+		h.redirect(w, r, "error", "admin.error.invalid_user_id")
+		return 0, "", false
+	}
+
+	role := r.FormValue("role")
+	if role == "" {
+		h.redirect(w, r, "error", "admin.error.role_required")
+		return 0, "", false
+	}
+
+	return userID, role, true
+}
+
+// redirect sends the user back to the page, showing the message of key as param. arg is the
+// argument of the message, put in the query parameter listed in messages.
+func (h *Handler) redirect(w http.ResponseWriter, r *http.Request, param, key string, arg ...string) {
+	q := url.Values{}
+	q.Set(param, key)
+	if name := messages[key]; name != "" && len(arg) > 0 {
+		q.Set(name, arg[0])
+	}
+
+	http.Redirect(w, r, "/admin?"+q.Encode(), http.StatusSeeOther)
+}
+
+// message returns the message of the key in the param query parameter if it is one of
+// messages and its argument names one of the users or roles, or "".
+func message(r *http.Request, loc *i18n.Localizer, param string, users []models.UserRoles, roles []models.Role) string {
+	key := r.URL.Query().Get(param)
+
+	name, ok := messages[key]
+	if !ok {
+		return ""
+	}
+
+	arg := r.URL.Query().Get(name)
+	switch name {
+	case "":
+		return loc.T(key)
+	case "email":
+		if !slices.ContainsFunc(users, func(u models.UserRoles) bool { return u.Email == arg }) {
+			return ""
+		}
+	case "role":
+		if !slices.ContainsFunc(roles, func(role models.Role) bool { return role.Name == arg }) {
+			return ""
+		}
+	}
+
+	return loc.T(key, arg)
+}
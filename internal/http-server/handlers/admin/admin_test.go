@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/text/language"
+
+	"shop/internal/domain/models"
+	"shop/internal/i18n"
+	"shop/web"
+)
+
+func TestMessage(t *testing.T) {
+	bundle, err := i18n.New(web.FS, "en")
+	if err != nil {
+		t.Fatalf("i18n.New: %v", err)
+	}
+	loc := bundle.Localizer(language.English, "/admin")
+	users := []models.UserRoles{{UserID: 1, Email: "user@example.com"}}
+	roles := []models.Role{{ID: 1, Name: models.RoleAdmin}}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "", want: ""},
+		{query: "success=admin.success.unlocked&email=user%40example.com", want: "Account user@example.com unlocked"},
+		{query: "success=admin.success.role_assigned&role=admin", want: "Role admin assigned"},
+		{query: "success=admin.success.unlocked&email=Call+%2B1-555+to+verify", want: ""},
+		{query: "success=admin.success.role_revoked&role=Call+%2B1-555", want: ""},
+		{query: "success=Your+account+is+suspended", want: ""},
+		{query: "success=login.error.locked", want: ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/admin?"+tt.query, nil)
+		if got := message(r, loc, "success", users, roles); got != tt.want {
+			t.Errorf("message(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	h := &Handler{}
+
+	tests := []struct {
+		param string
+		key   string
+		arg   []string
+		want  string
+	}{
+		{param: "success", key: "admin.success.unlocked", arg: []string{"user@example.com"},
+			want: "/admin?email=user%40example.com&success=admin.success.unlocked"},
+		{param: "error", key: "error.internal_retry", want: "/admin?error=error.internal_retry"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.redirect(w, httptest.NewRequest(http.MethodPost, "/admin/unlock", nil), tt.param, tt.key, tt.arg...)
+
+		if got := w.Header().Get("Location"); got != tt.want {
+			t.Errorf("Location = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/http-server/middleware/csrf"
+	"shop/internal/http-server/views"
+	"shop/internal/i18n"
+	"shop/internal/storage"
+)
+
+// pageSize is how many of the latest dead letters the page shows.
+const pageSize = 100
+
+type Storage interface {
+	DeadLetters(ctx context.Context, limit int) ([]models.DeadLetter, error)
+}
+
+type Replayer interface {
+	Replay(ctx context.Context, id int64) error
+}
+
+type Handler struct {
+	storage  Storage
+	replayer Replayer
+	logger   *zap.Logger
+	tmpl     *views.Page
+}
+
+func NewDLQHandler(storage Storage, replayer Replayer, pages *views.Views, logger *zap.Logger) *Handler {
+	tmpl, err := pages.Page("dlq.html")
+	if err != nil {
+		logger.Fatal("failed to parse dlq template", zap.Error(err))
+	}
+
+	return &Handler{
+		storage:  storage,
+		replayer: replayer,
+		logger:   logger,
+		tmpl:     tmpl,
+	}
+}
+
+// messages are the keys of the messages the page shows through its error and success query
+// parameters, and the query parameter naming their argument, if any. Other keys are ignored
+// and arguments have to be ids, so a link can't put arbitrary text on the page.
+var messages = map[string]string{
+	"error.invalid_form":   "",
+	"error.internal_retry": "",
+	"dlq.error.invalid_id": "",
+	"dlq.error.not_found":  "",
+	"dlq.success.replayed": "id",
+}
+
+// Letter is a dead letter as shown on the page.
+type Letter struct {
+	models.DeadLetter
+	Payload string
+}
+
+type PageData struct {
+	Title     string
+	Error     string
+	Success   string
+	Letters   []Letter
+	CSRFToken string
+	Locale    *i18n.Localizer
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	loc := i18n.FromContext(r.Context())
+	data := PageData{
+		Title:     loc.T("dlq.title"),
+		Error:     message(r, loc, "error"),
+		Success:   message(r, loc, "success"),
+		CSRFToken: csrf.Token(r),
+		Locale:    loc,
+	}
+
+	letters, err := h.storage.DeadLetters(r.Context(), pageSize)
+	if err != nil {
+		h.logger.Error("failed to fetch dead letters", zap.Error(err))
+		data.Error = loc.T("dlq.error.load")
+	}
+	for _, dl := range letters {
+		data.Letters = append(data.Letters, Letter{DeadLetter: dl, Payload: payload(loc, dl.Value)})
+	}
+
+	if err := h.tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute dlq template", zap.Error(err))
+		http.Error(w, loc.T("error.internal"), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleReplay sends the dead letter from the form back to its consumer group.
+func (h *Handler) HandleReplay(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.logger.Error("failed to parse replay form", zap.Error(err))
+		h.redirect(w, r, "error", "error.invalid_form")
+		return
+	}
+
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		h.redirect(w, r, "error", "dlq.error.invalid_id")
+		return
+	}
+
+	if err := h.replayer.Replay(r.Context(), id); err != nil {
+		h.logger.Error("failed to replay dead letter", zap.Int64("id", id), zap.Error(err))
+		if errors.Is(err, storage.ErrDeadLetterNotFound) {
+			h.redirect(w, r, "error", "dlq.error.not_found")
+			return
+		}
+		h.redirect(w, r, "error", "error.internal_retry")
+		return
+	}
+
+	h.logger.Info("dead letter replayed by admin", zap.Int64("id", id))
+
+	h.redirect(w, r, "success", "dlq.success.replayed", strconv.FormatInt(id, 10))
+}
+
+// redirect sends the user back to the page, showing the message of key as param. arg is the
+// argument of the message, put in the query parameter listed in messages.
+func (h *Handler) redirect(w http.ResponseWriter, r *http.Request, param, key string, arg ...string) {
+	q := url.Values{}
+	q.Set(param, key)
+	if name := messages[key]; name != "" && len(arg) > 0 {
+		q.Set(name, arg[0])
+	}
+
+	http.Redirect(w, r, "/admin/dlq?"+q.Encode(), http.StatusSeeOther)
+}
+
+// message returns the message of the key in the param query parameter if it is one of
+// messages and its argument is an id, or "".
+func message(r *http.Request, loc *i18n.Localizer, param string) string {
+	key := r.URL.Query().Get(param)
+
+	name, ok := messages[key]
+	if !ok {
+		return ""
+	}
+	if name == "" {
+		return loc.T(key)
+	}
+
+	id, err := strconv.ParseInt(r.URL.Query().Get(name), 10, 64)
+	if err != nil {
+		return ""
+	}
+
+	return loc.T(key, strconv.FormatInt(id, 10))
+}
+
+// payload returns the value as text, or its size if it is binary, e.g. a protobuf envelope.
+func payload(loc *i18n.Localizer, value []byte) string {
+	if !utf8.Valid(value) {
+		return loc.T("dlq.binary_payload", len(value))
+	}
+
+	return string(value)
+}
@@ -0,0 +1,68 @@
+package dlq
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/text/language"
+
+	"shop/internal/i18n"
+	"shop/web"
+)
+
+func TestMessage(t *testing.T) {
+	bundle, err := i18n.New(web.FS, "en")
+	if err != nil {
+		t.Fatalf("i18n.New: %v", err)
+	}
+	loc := bundle.Localizer(language.English, "/admin/dlq")
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "", want: ""},
+		{query: "success=dlq.success.replayed&id=12", want: "Dead letter #12 replayed"},
+		{query: "error=dlq.error.not_found", want: "Dead letter not found"},
+		{query: "success=dlq.success.replayed&id=Call+%2B1-555", want: ""},
+		{query: "success=dlq.success.replayed", want: ""},
+		{query: "success=Your+account+is+suspended", want: ""},
+		{query: "success=login.error.locked", want: ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/admin/dlq?"+tt.query, nil)
+		param := "success"
+		if r.URL.Query().Has("error") {
+			param = "error"
+		}
+		if got := message(r, loc, param); got != tt.want {
+			t.Errorf("message(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	h := &Handler{}
+
+	tests := []struct {
+		param string
+		key   string
+		arg   []string
+		want  string
+	}{
+		{param: "success", key: "dlq.success.replayed", arg: []string{"12"},
+			want: "/admin/dlq?id=12&success=dlq.success.replayed"},
+		{param: "error", key: "dlq.error.not_found", want: "/admin/dlq?error=dlq.error.not_found"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.redirect(w, httptest.NewRequest(http.MethodPost, "/admin/dlq/replay", nil), tt.param, tt.key, tt.arg...)
+
+		if got := w.Header().Get("Location"); got != tt.want {
+			t.Errorf("Location = %q, want %q", got, tt.want)
+		}
+	}
+}
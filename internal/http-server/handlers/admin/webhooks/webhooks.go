@@ -0,0 +1,272 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/http-server/middleware/csrf"
+	"shop/internal/http-server/views"
+	"shop/internal/i18n"
+	"shop/internal/storage"
+	hooks "shop/internal/webhooks"
+)
+
+// logSize is how many of the latest delivery attempts the log of a webhook shows.
+const logSize = 100
+
+type Storage interface {
+	Webhooks(ctx context.Context) ([]models.Webhook, error)
+	CreateWebhook(ctx context.Context, url string, eventTypes []string, secret string) (int64, error)
+	SetWebhookActive(ctx context.Context, id int64, active bool) error
+	DeleteWebhook(ctx context.Context, id int64) error
+	WebhookAttempts(ctx context.Context, webhookID int64, limit int) ([]models.WebhookAttempt, error)
+}
+
+type Handler struct {
+	storage Storage
+	logger  *zap.Logger
+	tmpl    *views.Page
+}
+
+func NewWebhooksHandler(storage Storage, pages *views.Views, logger *zap.Logger) *Handler {
+	tmpl, err := pages.Page("webhooks.html")
+	if err != nil {
+		logger.Fatal("failed to parse webhooks template", zap.Error(err))
+	}
+
+	return &Handler{
+		storage: storage,
+		logger:  logger,
+		tmpl:    tmpl,
+	}
+}
+
+// messages are the keys of the messages the page shows through its error and success query
+// parameters, and the query parameter naming their argument, if any. Other keys are ignored
+// and arguments have to be ids, so a link can't put arbitrary text on the page.
+var messages = map[string]string{
+	"error.invalid_form":           "",
+	"error.internal_retry":         "",
+	"webhooks.error.invalid_url":   "",
+	"webhooks.error.no_events":     "",
+	"webhooks.error.unknown_event": "",
+	"webhooks.error.invalid_id":    "",
+	"webhooks.error.not_found":     "",
+	"webhooks.success.created":     "id",
+	"webhooks.success.paused":      "id",
+	"webhooks.success.resumed":     "id",
+	"webhooks.success.deleted":     "id",
+}
+
+type PageData struct {
+	Title      string
+	Error      string
+	Success    string
+	Webhooks   []models.Webhook
+	EventTypes []string
+	Selected   *models.Webhook
+	Attempts   []models.WebhookAttempt
+	CSRFToken  string
+	Locale     *i18n.Localizer
+}
+
+// ServeHTTP lists the webhooks, with the delivery log of the one in the path if any.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	loc := i18n.FromContext(r.Context())
+	data := PageData{
+		Title:      loc.T("webhooks.title"),
+		Error:      message(r, loc, "error"),
+		Success:    message(r, loc, "success"),
+		EventTypes: hooks.EventTypes,
+		CSRFToken:  csrf.Token(r),
+		Locale:     loc,
+	}
+
+	webhooks, err := h.storage.Webhooks(r.Context())
+	if err != nil {
+		h.logger.Error("failed to fetch webhooks", zap.Error(err))
+		data.Error = loc.T("webhooks.error.load")
+	}
+	data.Webhooks = webhooks
+
+	if param := chi.URLParam(r, "id"); param != "" {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		i := slices.IndexFunc(webhooks, func(w models.Webhook) bool { return w.ID == id })
+		if i < 0 {
+			http.NotFound(w, r)
+			return
+		}
+		data.Selected = &webhooks[i]
+
+		data.Attempts, err = h.storage.WebhookAttempts(r.Context(), id, logSize)
+		if err != nil {
+			h.logger.Error("failed to fetch webhook attempts", zap.Int64("id", id), zap.Error(err))
+			data.Error = loc.T("webhooks.error.load_attempts")
+		}
+	}
+
+	if err := h.tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute webhooks template", zap.Error(err))
+		http.Error(w, loc.T("error.internal"), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleCreate adds a webhook from the form. A secret is generated if none is given.
+func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.logger.Error("failed to parse webhook form", zap.Error(err))
+		h.redirect(w, r, "error", "error.invalid_form")
+		return
+	}
+
+	target := r.FormValue("url")
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		h.redirect(w, r, "error", "webhooks.error.invalid_url")
+		return
+	}
+
+	eventTypes := r.Form["events"]
+	if len(eventTypes) == 0 {
+		h.redirect(w, r, "error", "webhooks.error.no_events")
+		return
+	}
+	for _, eventType := range eventTypes {
+		if !slices.Contains(hooks.EventTypes, eventType) {
+			h.logger.Warn("unknown webhook event type", zap.String("event", eventType))
+			h.redirect(w, r, "error", "webhooks.error.unknown_event")
+			return
+		}
+	}
+
+	secret := r.FormValue("secret")
+	if secret == "" {
+		secret, err = hooks.NewSecret()
+		if err != nil {
+			h.logger.Error("failed to generate webhook secret", zap.Error(err))
+			h.redirect(w, r, "error", "error.internal_retry")
+			return
+		}
+	}
+
+	id, err := h.storage.CreateWebhook(r.Context(), target, eventTypes, secret)
+	if err != nil {
+		h.logger.Error("failed to create webhook", zap.String("url", target), zap.Error(err))
+		h.redirect(w, r, "error", "error.internal_retry")
+		return
+	}
+
+	h.logger.Info("webhook created by admin", zap.Int64("id", id), zap.String("url", target), zap.Strings("events", eventTypes))
+
+	h.redirect(w, r, "success", "webhooks.success.created", strconv.FormatInt(id, 10))
+}
+
+// HandleToggle pauses or resumes a webhook; paused webhooks get no new deliveries.
+func (h *Handler) HandleToggle(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	active := r.FormValue("active") == "true"
+	if err := h.storage.SetWebhookActive(r.Context(), id, active); err != nil {
+		h.fail(w, r, id, "failed to update webhook", err)
+		return
+	}
+
+	key := "webhooks.success.paused"
+	if active {
+		key = "webhooks.success.resumed"
+	}
+	h.redirect(w, r, "success", key, strconv.FormatInt(id, 10))
+}
+
+func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.storage.DeleteWebhook(r.Context(), id); err != nil {
+		h.fail(w, r, id, "failed to delete webhook", err)
+		return
+	}
+
+	h.logger.Info("webhook deleted by admin", zap.Int64("id", id))
+
+	h.redirect(w, r, "success", "webhooks.success.deleted", strconv.FormatInt(id, 10))
+}
+
+func (h *Handler) webhookID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	if err := r.ParseForm(); err != nil {
+		h.logger.Error("failed to parse webhook form", zap.Error(err))
+		h.redirect(w, r, "error", "error.invalid_form")
+		return 0, false
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.redirect(w, r, "error", "webhooks.error.invalid_id")
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (h *Handler) fail(w http.ResponseWriter, r *http.Request, id int64, msg string, err error) {
+	h.logger.Error(msg, zap.Int64("id", id), zap.Error(err))
+	if errors.Is(err, storage.ErrWebhookNotFound) {
+		h.redirect(w, r, "error", "webhooks.error.not_found")
+		return
+	}
+	h.redirect(w, r, "error", "error.internal_retry")
+}
+
+// redirect sends the user back to the page, showing the message of key as param. arg is the
+// argument of the message, put in the query parameter listed in messages.
+func (h *Handler) redirect(w http.ResponseWriter, r *http.Request, param, key string, arg ...string) {
+	q := url.Values{}
+	q.Set(param, key)
+	if name := messages[key]; name != "" && len(arg) > 0 {
+		q.Set(name, arg[0])
+	}
+
+	http.Redirect(w, r, "/admin/webhooks?"+q.Encode(), http.StatusSeeOther)
+}
+
+// message returns the message of the key in the param query parameter if it is one of
+// messages and its argument is an id, or "".
+func message(r *http.Request, loc *i18n.Localizer, param string) string {
+	key := r.URL.Query().Get(param)
+
+	name, ok := messages[key]
+	if !ok {
+		return ""
+	}
+	if name == "" {
+		return loc.T(key)
+	}
+
+	id, err := strconv.ParseInt(r.URL.Query().Get(name), 10, 64)
+	if err != nil {
+		return ""
+	}
+
+	return loc.T(key, strconv.FormatInt(id, 10))
+}
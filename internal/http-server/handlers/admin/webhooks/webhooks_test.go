@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/text/language"
+
+	"shop/internal/i18n"
+	"shop/web"
+)
+
+func TestMessage(t *testing.T) {
+	bundle, err := i18n.New(web.FS, "en")
+	if err != nil {
+		t.Fatalf("i18n.New: %v", err)
+	}
+	loc := bundle.Localizer(language.English, "/admin/webhooks")
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "", want: ""},
+		{query: "success=webhooks.success.deleted&id=12", want: "Webhook #12 deleted"},
+		{query: "error=webhooks.error.not_found", want: "Webhook not found"},
+		{query: "error=webhooks.error.unknown_event&event=Call+%2B1-555", want: "Select only the listed events"},
+		{query: "success=webhooks.success.deleted&id=Call+%2B1-555", want: ""},
+		{query: "success=webhooks.success.deleted", want: ""},
+		{query: "success=Your+account+is+suspended", want: ""},
+		{query: "success=login.error.locked", want: ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/admin/webhooks?"+tt.query, nil)
+		param := "success"
+		if r.URL.Query().Has("error") {
+			param = "error"
+		}
+		if got := message(r, loc, param); got != tt.want {
+			t.Errorf("message(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	h := &Handler{}
+
+	tests := []struct {
+		param string
+		key   string
+		arg   []string
+		want  string
+	}{
+		{param: "success", key: "webhooks.success.deleted", arg: []string{"12"},
+			want: "/admin/webhooks?id=12&success=webhooks.success.deleted"},
+		{param: "error", key: "webhooks.error.not_found", want: "/admin/webhooks?error=webhooks.error.not_found"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.redirect(w, httptest.NewRequest(http.MethodPost, "/admin/webhooks/3/delete", nil), tt.param, tt.key, tt.arg...)
+
+		if got := w.Header().Get("Location"); got != tt.want {
+			t.Errorf("Location = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	authgrpc "shop/internal/grpc/auth"
+	"shop/internal/grpc/interceptors"
+	"shop/internal/http-server/middleware/realip"
+)
+
+const maxBodySize = 1 << 20
+
+// ErrorDomain is the domain of the ErrorInfo details of API errors.
+const ErrorDomain = "shop"
+
+// ReasonMethodNotAllowed is the ErrorInfo reason of requests with a method the route does not serve.
+const ReasonMethodNotAllowed = "method_not_allowed"
+
+var methods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodOptions,
+}
+
+var (
+	marshaler   = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+	unmarshaler = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// HTTPStatus maps a gRPC status code to the HTTP status the JSON API answers with.
+//
+// FailedPrecondition maps to 400, as in the Google API HTTP mapping, and not to 412: the services
+// return it for requests that cannot succeed in the current state, such as checking out an empty
+// cart, which the client has to change before retrying. 412 is kept for conditional headers,
+// which the API does not support.
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Decode reads a JSON request body into msg using the protobuf JSON mapping.
+func Decode(r *http.Request, msg proto.Message) error {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to read request body: %v", err)
+	}
+	if len(body) == 0 {
+		return nil
+	}
+
+	if err := unmarshaler.Unmarshal(body, msg); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+	}
+
+	return nil
+}
+
+// WriteProto writes msg as JSON with the 200 status.
+func WriteProto(w http.ResponseWriter, msg proto.Message, log *zap.Logger) {
+	body, err := marshaler.Marshal(msg)
+	if err != nil {
+		log.Error("failed to marshal response", zap.Error(err))
+		WriteError(w, status.Error(codes.Internal, "failed to marshal response"), log)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		log.Error("failed to write response", zap.Error(err))
+	}
+}
+
+// WriteError writes err as a google.rpc.Status JSON object, details included,
+// with the HTTP status mapped from its gRPC code.
+func WriteError(w http.ResponseWriter, err error, log *zap.Logger) {
+	st := status.Convert(err)
+	writeStatus(w, HTTPStatus(st.Code()), st, log)
+}
+
+func writeStatus(w http.ResponseWriter, code int, st *status.Status, log *zap.Logger) {
+	body, err := marshaler.Marshal(st.Proto())
+	if err != nil {
+		log.Error("failed to marshal error", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(body); err != nil {
+		log.Error("failed to write error response", zap.Error(err))
+	}
+}
+
+// WriteCallError logs the failed gRPC call made for the request and writes err.
+func WriteCallError(w http.ResponseWriter, r *http.Request, err error, log *zap.Logger) {
+	log.Warn("api call failed",
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("code", status.Code(err).String()),
+		zap.Error(err),
+	)
+
+	WriteError(w, err, log)
+}
+
+// NotFound answers requests to unknown routes with a NotFound status, so every API
+// response body is JSON.
+func NotFound(log *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, status.Errorf(codes.NotFound, "no route for %s %s", r.Method, r.URL.Path), log)
+	}
+}
+
+// MethodNotAllowed answers requests with a method the route does not serve with the 405 status
+// and the methods it serves in the Allow header. gRPC has no code for it, so the body is an
+// InvalidArgument status with an ErrorInfo detail of the method_not_allowed reason.
+func MethodNotAllowed(routes chi.Routes, log *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowedMethods(routes, r), ", "))
+
+		st := status.Newf(codes.InvalidArgument, "method %s is not allowed on %s", r.Method, r.URL.Path)
+		detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+			Reason:   ReasonMethodNotAllowed,
+			Domain:   ErrorDomain,
+			Metadata: map[string]string{"method": r.Method},
+		})
+		if err == nil {
+			st = detailed
+		}
+
+		writeStatus(w, http.StatusMethodNotAllowed, st, log)
+	}
+}
+
+// allowedMethods returns the methods routes serve on the path of the request.
+func allowedMethods(routes chi.Routes, r *http.Request) []string {
+	path := r.URL.Path
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+		// The path within the router it is mounted on.
+		path = rctx.RoutePath
+	}
+
+	var allowed []string
+	for _, method := range methods {
+		if routes.Match(chi.NewRouteContext(), method, path) {
+			allowed = append(allowed, method)
+		}
+	}
+
+	return allowed
+}
+
+// OutgoingContext forwards the bearer token and the client IP of the request as gRPC metadata.
+func OutgoingContext(r *http.Request) context.Context {
+	pairs := []string{authgrpc.ClientIPKey, realip.ClientIP(r)}
+	if token := r.Header.Get("Authorization"); token != "" {
+		pairs = append(pairs, "authorization", token)
+	}
+
+	return metadata.AppendToOutgoingContext(r.Context(), pairs...)
+}
+
+// PathID parses the named URL parameter as a positive ID.
+func PathID(r *http.Request, name string) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, name), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, interceptors.FieldViolation(name, name+" must be a positive integer")
+	}
+
+	return id, nil
+}
+
+// Query parses the query parameters of a list request. Parse errors are collected as field
+// violations and reported by Err, so a client learns about every malformed parameter at once.
+type Query struct {
+	r          *http.Request
+	violations []*errdetails.BadRequest_FieldViolation
+}
+
+func NewQuery(r *http.Request) *Query {
+	return &Query{r: r}
+}
+
+func (q *Query) String(name string) string {
+	return q.r.URL.Query().Get(name)
+}
+
+func (q *Query) Int32(name string) int32 {
+	v := q.String(name)
+	if v == "" {
+		return 0
+	}
+
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		q.violate(name, name+" must be an integer")
+	}
+
+	return int32(n)
+}
+
+func (q *Query) Float(name string) float64 {
+	v := q.String(name)
+	if v == "" {
+		return 0
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		q.violate(name, name+" must be a number")
+	}
+
+	return f
+}
+
+func (q *Query) Bool(name string) bool {
+	v := q.String(name)
+	if v == "" {
+		return false
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		q.violate(name, name+" must be true or false")
+	}
+
+	return b
+}
+
+func (q *Query) violate(name, description string) {
+	q.violations = append(q.violations, &errdetails.BadRequest_FieldViolation{Field: name, Description: description})
+}
+
+// Err returns an InvalidArgument status listing the malformed parameters, or nil.
+func (q *Query) Err() error {
+	if len(q.violations) == 0 {
+		return nil
+	}
+
+	return interceptors.FieldViolations(q.violations...)
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	cartv1 "shop/protos/gen/go/cart"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want int
+	}{
+		{codes.OK, http.StatusOK},
+		{codes.Canceled, 499},
+		{codes.InvalidArgument, http.StatusBadRequest},
+		{codes.OutOfRange, http.StatusBadRequest},
+		{codes.FailedPrecondition, http.StatusBadRequest},
+		{codes.DeadlineExceeded, http.StatusGatewayTimeout},
+		{codes.NotFound, http.StatusNotFound},
+		{codes.AlreadyExists, http.StatusConflict},
+		{codes.Aborted, http.StatusConflict},
+		{codes.PermissionDenied, http.StatusForbidden},
+		{codes.Unauthenticated, http.StatusUnauthorized},
+		{codes.ResourceExhausted, http.StatusTooManyRequests},
+		{codes.Unimplemented, http.StatusNotImplemented},
+		{codes.Unavailable, http.StatusServiceUnavailable},
+		{codes.Internal, http.StatusInternalServerError},
+		{codes.DataLoss, http.StatusInternalServerError},
+		{codes.Unknown, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			if got := HTTPStatus(tt.code); got != tt.want {
+				t.Errorf("HTTPStatus(%v) = %d, want %d", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantCode codes.Code
+		want     *cartv1.AddItemRequest
+	}{
+		{name: "valid", body: `{"product_id":"3","quantity":2}`, want: &cartv1.AddItemRequest{ProductId: 3, Quantity: 2}},
+		{name: "camel case", body: `{"productId":"3"}`, want: &cartv1.AddItemRequest{ProductId: 3}},
+		{name: "unknown field", body: `{"quantity":1,"gift":true}`, want: &cartv1.AddItemRequest{Quantity: 1}},
+		{name: "empty body", want: &cartv1.AddItemRequest{}},
+		{name: "malformed JSON", body: `{"quantity":`, wantCode: codes.InvalidArgument},
+		{name: "wrong type", body: `{"quantity":"many"}`, wantCode: codes.InvalidArgument},
+		{name: "body too large", body: `{"quantity":1}` + strings.Repeat(" ", maxBodySize),
+			wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/cart/items", strings.NewReader(tt.body))
+
+			var req cartv1.AddItemRequest
+			err := Decode(r, &req)
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("Decode error = %v, want code %v", err, tt.wantCode)
+			}
+			if err == nil && (req.GetProductId() != tt.want.GetProductId() || req.GetQuantity() != tt.want.GetQuantity()) {
+				t.Errorf("decoded %v, want %v", &req, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryErr(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet,
+		"/api/v1/products?page_size=ten&min_price=cheap&in_stock=maybe&category=mugs&max_price=9.5", nil)
+	q := NewQuery(r)
+
+	if got := q.String("category"); got != "mugs" {
+		t.Errorf("category = %q, want mugs", got)
+	}
+	if got := q.Float("max_price"); got != 9.5 {
+		t.Errorf("max_price = %v, want 9.5", got)
+	}
+	if got := q.Int32("page_token"); got != 0 {
+		t.Errorf("missing page_token = %d, want 0", got)
+	}
+	q.Int32("page_size")
+	q.Float("min_price")
+	q.Bool("in_stock")
+
+	err := q.Err()
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Err = %v, want InvalidArgument", err)
+	}
+
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		badRequest, _ := detail.(*errdetails.BadRequest)
+		for _, v := range badRequest.GetFieldViolations() {
+			fields = append(fields, v.GetField())
+		}
+	}
+	if want := []string{"page_size", "min_price", "in_stock"}; !slices.Equal(fields, want) {
+		t.Errorf("field violations = %v, want %v", fields, want)
+	}
+}
+
+func TestQueryErrWithoutViolations(t *testing.T) {
+	q := NewQuery(httptest.NewRequest(http.MethodGet, "/api/v1/products?page_size=10&in_stock=true", nil))
+
+	if q.Int32("page_size") != 10 || !q.Bool("in_stock") {
+		t.Error("well-formed parameters were not parsed")
+	}
+	if err := q.Err(); err != nil {
+		t.Errorf("Err = %v, want nil", err)
+	}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"net/http"
+
+	ssov1 "github.com/Yuukiine/protos/gen/go/sso"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"shop/internal/http-server/handlers/api"
+)
+
+// Handler translates JSON requests under /api/v1/auth into calls of the gRPC Auth API.
+type Handler struct {
+	client ssov1.AuthClient
+	logger *zap.Logger
+}
+
+func NewAuthHandler(client ssov1.AuthClient, logger *zap.Logger) *Handler {
+	return &Handler{
+		client: client,
+		logger: logger.With(zap.String("component", "api/auth")),
+	}
+}
+
+// Routes adds the auth routes to the v1 router.
+func (h *Handler) Routes(r chi.Router) {
+	r.Post("/auth/login", h.Login)
+	r.Post("/auth/register", h.Register)
+	r.Get("/auth/users/{user_id}/admin", h.IsAdmin)
+}
+
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var req ssov1.LoginRequest
+	if err := api.Decode(r, &req); err != nil {
+		api.WriteError(w, err, h.logger)
+		return
+	}
+
+	resp, err := h.client.Login(api.OutgoingContext(r), &req)
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
+
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	var req ssov1.RegisterRequest
+	if err := api.Decode(r, &req); err != nil {
+		api.WriteError(w, err, h.logger)
+		return
+	}
+
+	resp, err := h.client.Register(api.OutgoingContext(r), &req)
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
+
+func (h *Handler) IsAdmin(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.PathID(r, "user_id")
+	if err != nil {
+		api.WriteError(w, err, h.logger)
+		return
+	}
+
+	resp, err := h.client.IsAdmin(api.OutgoingContext(r), &ssov1.IsAdminRequest{UserId: userID})
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
@@ -0,0 +1,115 @@
+package cart
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"shop/internal/http-server/handlers/api"
+	cartv1 "shop/protos/gen/go/cart"
+)
+
+// Handler translates JSON requests for the cart of the authenticated user into calls of the
+// gRPC Cart API.
+type Handler struct {
+	client cartv1.CartClient
+	logger *zap.Logger
+}
+
+func NewCartHandler(client cartv1.CartClient, logger *zap.Logger) *Handler {
+	return &Handler{
+		client: client,
+		logger: logger.With(zap.String("component", "api/cart")),
+	}
+}
+
+// Routes adds the cart routes to the v1 router.
+func (h *Handler) Routes(r chi.Router) {
+	r.Get("/cart", h.GetCart)
+	r.Post("/cart/items", h.AddItem)
+	r.Patch("/cart/items/{product_id}", h.UpdateItem)
+	r.Delete("/cart/items/{product_id}", h.RemoveItem)
+	r.Post("/cart/merge", h.MergeGuestCart)
+}
+
+func (h *Handler) GetCart(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.client.GetCart(api.OutgoingContext(r), &cartv1.GetCartRequest{})
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
+
+func (h *Handler) AddItem(w http.ResponseWriter, r *http.Request) {
+	var req cartv1.AddItemRequest
+	if err := api.Decode(r, &req); err != nil {
+		api.WriteError(w, err, h.logger)
+		return
+	}
+
+	resp, err := h.client.AddItem(api.OutgoingContext(r), &req)
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
+
+func (h *Handler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	productID, err := api.PathID(r, "product_id")
+	if err != nil {
+		api.WriteError(w, err, h.logger)
+		return
+	}
+
+	var req cartv1.UpdateItemRequest
+	if err := api.Decode(r, &req); err != nil {
+		api.WriteError(w, err, h.logger)
+		return
+	}
+	req.ProductId = productID
+
+	resp, err := h.client.UpdateItem(api.OutgoingContext(r), &req)
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
+
+func (h *Handler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	productID, err := api.PathID(r, "product_id")
+	if err != nil {
+		api.WriteError(w, err, h.logger)
+		return
+	}
+
+	resp, err := h.client.RemoveItem(api.OutgoingContext(r), &cartv1.RemoveItemRequest{ProductId: productID})
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
+
+func (h *Handler) MergeGuestCart(w http.ResponseWriter, r *http.Request) {
+	var req cartv1.MergeGuestCartRequest
+	if err := api.Decode(r, &req); err != nil {
+		api.WriteError(w, err, h.logger)
+		return
+	}
+
+	resp, err := h.client.MergeGuestCart(api.OutgoingContext(r), &req)
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
@@ -0,0 +1,82 @@
+package catalog
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"shop/internal/http-server/handlers/api"
+	catalogv1 "shop/protos/gen/go/catalog"
+)
+
+// Handler translates JSON requests for products and categories into calls of the gRPC Catalog API.
+type Handler struct {
+	client catalogv1.CatalogClient
+	logger *zap.Logger
+}
+
+func NewCatalogHandler(client catalogv1.CatalogClient, logger *zap.Logger) *Handler {
+	return &Handler{
+		client: client,
+		logger: logger.With(zap.String("component", "api/catalog")),
+	}
+}
+
+// Routes adds the catalog routes to the v1 router.
+func (h *Handler) Routes(r chi.Router) {
+	r.Get("/products", h.ListProducts)
+	r.Get("/products/{product_id}", h.GetProduct)
+	r.Get("/categories", h.ListCategories)
+}
+
+func (h *Handler) ListProducts(w http.ResponseWriter, r *http.Request) {
+	q := api.NewQuery(r)
+	req := &catalogv1.ListProductsRequest{
+		Category:    q.String("category"),
+		Query:       q.String("query"),
+		MinPrice:    q.Float("min_price"),
+		MaxPrice:    q.Float("max_price"),
+		InStockOnly: q.Bool("in_stock_only"),
+		PageSize:    q.Int32("page_size"),
+		PageToken:   q.String("page_token"),
+	}
+	if err := q.Err(); err != nil {
+		api.WriteError(w, err, h.logger)
+		return
+	}
+
+	resp, err := h.client.ListProducts(api.OutgoingContext(r), req)
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
+
+func (h *Handler) GetProduct(w http.ResponseWriter, r *http.Request) {
+	productID, err := api.PathID(r, "product_id")
+	if err != nil {
+		api.WriteError(w, err, h.logger)
+		return
+	}
+
+	resp, err := h.client.GetProduct(api.OutgoingContext(r), &catalogv1.GetProductRequest{ProductId: productID})
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
+
+func (h *Handler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.client.ListCategories(api.OutgoingContext(r), &catalogv1.ListCategoriesRequest{})
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
@@ -0,0 +1,96 @@
+package orders
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"shop/internal/http-server/handlers/api"
+	ordersv1 "shop/protos/gen/go/orders"
+)
+
+// Handler translates JSON requests for the orders of the authenticated user into calls of the
+// gRPC Orders API.
+type Handler struct {
+	client ordersv1.OrdersClient
+	logger *zap.Logger
+}
+
+func NewOrdersHandler(client ordersv1.OrdersClient, logger *zap.Logger) *Handler {
+	return &Handler{
+		client: client,
+		logger: logger.With(zap.String("component", "api/orders")),
+	}
+}
+
+// Routes adds the orders routes to the v1 router.
+func (h *Handler) Routes(r chi.Router) {
+	r.Get("/orders", h.ListOrders)
+	r.Post("/orders", h.PlaceOrder)
+	r.Get("/orders/{order_id}", h.GetOrder)
+	r.Post("/orders/{order_id}/cancel", h.CancelOrder)
+}
+
+func (h *Handler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	q := api.NewQuery(r)
+	req := &ordersv1.ListOrdersRequest{
+		PageSize:  q.Int32("page_size"),
+		PageToken: q.String("page_token"),
+	}
+	if err := q.Err(); err != nil {
+		api.WriteError(w, err, h.logger)
+		return
+	}
+
+	resp, err := h.client.ListOrders(api.OutgoingContext(r), req)
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
+
+// PlaceOrder places an order for the contents of the cart.
+func (h *Handler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.client.PlaceOrder(api.OutgoingContext(r), &ordersv1.PlaceOrderRequest{})
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
+
+func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	orderID, err := api.PathID(r, "order_id")
+	if err != nil {
+		api.WriteError(w, err, h.logger)
+		return
+	}
+
+	resp, err := h.client.GetOrder(api.OutgoingContext(r), &ordersv1.GetOrderRequest{OrderId: orderID})
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
+
+func (h *Handler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	orderID, err := api.PathID(r, "order_id")
+	if err != nil {
+		api.WriteError(w, err, h.logger)
+		return
+	}
+
+	resp, err := h.client.CancelOrder(api.OutgoingContext(r), &ordersv1.CancelOrderRequest{OrderId: orderID})
+	if err != nil {
+		api.WriteCallError(w, r, err, h.logger)
+		return
+	}
+
+	api.WriteProto(w, resp, h.logger)
+}
@@ -0,0 +1,54 @@
+package v1
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"shop/internal/http-server/handlers/api"
+)
+
+//go:embed openapi.yaml
+var openAPI []byte
+
+// Resource is a group of API routes, such as the cart or the orders.
+type Resource interface {
+	Routes(r chi.Router)
+}
+
+// Handler is the router of the JSON API, to be mounted at /api/v1.
+type Handler struct {
+	router chi.Router
+	logger *zap.Logger
+}
+
+// NewHandler builds the router of the resources. The OpenAPI document served at /openapi
+// describes its routes; the tests check it with CheckSpec.
+func NewHandler(logger *zap.Logger, resources ...Resource) *Handler {
+	h := &Handler{
+		router: chi.NewRouter(),
+		logger: logger.With(zap.String("component", "api/v1")),
+	}
+
+	h.router.NotFound(api.NotFound(h.logger))
+	h.router.MethodNotAllowed(api.MethodNotAllowed(h.router, h.logger))
+	for _, res := range resources {
+		res.Routes(h.router)
+	}
+	h.router.Get("/openapi", h.OpenAPI)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *Handler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(openAPI); err != nil {
+		h.logger.Error("failed to write openapi document", zap.Error(err))
+	}
+}
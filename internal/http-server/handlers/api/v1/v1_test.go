@@ -0,0 +1,141 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"shop/internal/http-server/handlers/api"
+	authapi "shop/internal/http-server/handlers/api/auth"
+	cartapi "shop/internal/http-server/handlers/api/cart"
+	catalogapi "shop/internal/http-server/handlers/api/catalog"
+	ordersapi "shop/internal/http-server/handlers/api/orders"
+)
+
+// newTestHandler builds the handler of every resource main serves. The gRPC clients are never
+// called by these tests.
+func newTestHandler() *Handler {
+	log := zap.NewNop()
+
+	return NewHandler(log,
+		authapi.NewAuthHandler(nil, log),
+		catalogapi.NewCatalogHandler(nil, log),
+		cartapi.NewCartHandler(nil, log),
+		ordersapi.NewOrdersHandler(nil, log),
+	)
+}
+
+// TestSpecMatchesRoutes fails when a route is missing from the OpenAPI document or a documented
+// operation is not served.
+func TestSpecMatchesRoutes(t *testing.T) {
+	if err := checkSpec(openAPI, newTestHandler().router); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckSpecReportsMismatches(t *testing.T) {
+	spec := []byte(`
+paths:
+  /cart:
+    get: {}
+    parameters: []
+  /orders:
+    get: {}
+`)
+	r := chi.NewRouter()
+	r.Get("/cart", http.NotFound)
+	r.Post("/cart", http.NotFound)
+
+	err := checkSpec(spec, r)
+	if err == nil {
+		t.Fatal("checkSpec accepted a mismatching document")
+	}
+	if want := "not documented: POST /cart; not served: GET /orders"; err.Error() != want {
+		t.Errorf("checkSpec = %q, want %q", err, want)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	// Mounted as in main, so the routes are matched on the path within the API.
+	router := chi.NewRouter()
+	router.Mount("/api/v1", newTestHandler())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/v1/cart/items/1", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if got := rec.Header().Get("Allow"); got != "PATCH, DELETE" {
+		t.Errorf("Allow = %q, want PATCH, DELETE", got)
+	}
+
+	var body struct {
+		Details []struct {
+			Type   string `json:"@type"`
+			Reason string `json:"reason"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body %s: %v", rec.Body, err)
+	}
+	if len(body.Details) != 1 || body.Details[0].Reason != api.ReasonMethodNotAllowed {
+		t.Errorf("details = %+v, want an ErrorInfo with the %s reason", body.Details, api.ReasonMethodNotAllowed)
+	}
+}
+
+// checkSpec returns an error listing the operations of the OpenAPI document the router does
+// not serve and the routes the document does not describe.
+func checkSpec(spec []byte, routes chi.Routes) error {
+	var doc struct {
+		Paths map[string]map[string]yaml.Node `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(spec, &doc); err != nil {
+		return fmt.Errorf("failed to parse openapi document: %w", err)
+	}
+
+	documented := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method := range item {
+			switch method {
+			case "get", "put", "post", "delete", "options", "head", "patch", "trace":
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+
+	served := make(map[string]bool)
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		served[method+" "+route] = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk routes: %w", err)
+	}
+
+	var problems []string
+	for op := range documented {
+		if !served[op] {
+			problems = append(problems, "not served: "+op)
+		}
+	}
+	for op := range served {
+		if !documented[op] {
+			problems = append(problems, "not documented: "+op)
+		}
+	}
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
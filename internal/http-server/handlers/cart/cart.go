@@ -3,7 +3,7 @@ package cart
 import (
 	"context"
 	"encoding/json"
-	"html/template"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,29 +11,46 @@ import (
 	"go.uber.org/zap"
 
 	"shop/internal/domain/models"
+	"shop/internal/http-server/middleware/csrf"
+	"shop/internal/http-server/views"
+	"shop/internal/i18n"
+	"shop/internal/services/cart"
 	"shop/lib/jwt"
 )
 
-const (
-	shippingPrice = 4.99
-	taxPercent    = 0.21
-)
-
 type Storage interface {
 	User(ctx context.Context, email string) (models.User, error)
-	GetCart(ctx context.Context, userID any) ([]models.CartItem, error)
-	UpdateCartQuantity(ctx context.Context, productID, quantity int, userID any) error
-	RemoveFromCart(ctx context.Context, productID int, userID any) error
+	GetSession(ctx context.Context, UUID string) (int, error)
+	App(ctx context.Context, appID int) (models.App, error)
+}
+
+type Cart interface {
+	Contents(ctx context.Context, owner any) (cart.Contents, error)
+	UpdateItem(ctx context.Context, owner any, productID int64, quantity int) (cart.Contents, error)
+	RemoveItem(ctx context.Context, owner any, productID int64) (cart.Contents, error)
+	Subscribe(ctx context.Context, owner any) (*cart.Subscription, cart.Update, error)
 }
 
 type Handler struct {
 	logger  *zap.Logger
-	tmpl    *template.Template
+	tmpl    *views.Page
 	storage Storage
+	cart    Cart
+
+	heartbeat time.Duration
+	streams   *streamLimiter
 }
 
-func NewCartHandler(storage Storage, logger *zap.Logger) *Handler {
-	tmpl, err := template.ParseFiles("./html-templates/shopping_cart_page.html")
+func NewCartHandler(
+	storage Storage,
+	cart Cart,
+	heartbeat time.Duration,
+	maxStreams int,
+	maxStreamsPerIP int,
+	pages *views.Views,
+	logger *zap.Logger,
+) *Handler {
+	tmpl, err := pages.Page("cart.html")
 	if err != nil {
 		logger.Fatal("failed to parse products template", zap.Error(err))
 	}
@@ -42,6 +59,10 @@ func NewCartHandler(storage Storage, logger *zap.Logger) *Handler {
 		logger:  logger,
 		tmpl:    tmpl,
 		storage: storage,
+		cart:    cart,
+
+		heartbeat: heartbeat,
+		streams:   newStreamLimiter(maxStreams, maxStreamsPerIP),
 	}
 }
 
@@ -58,28 +79,23 @@ type PageData struct {
 	Success    bool              `json:"success"`
 	Error      string            `json:"error"`
 	CartItems  []models.CartItem `json:"cartItems"`
-}
-
-type Sum struct {
-	CartItems  []models.CartItem
-	CartCount  int
-	TotalItems int
-	Subtotal   float64
-	Shipping   float64
-	Tax        float64
-	Total      float64
+	CSRFToken  string            `json:"-"`
+	Locale     *i18n.Localizer   `json:"-"`
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	loc := i18n.FromContext(r.Context())
 	data := PageData{
-		Title: "Cart",
+		Title:     loc.T("cart.title"),
+		CSRFToken: csrf.Token(r),
+		Locale:    loc,
 	}
 
 	cookie, err := r.Cookie("auth_token")
 	if err == nil {
-		email, err := jwt.GetEmailFromToken(cookie.Value)
+		email, err := jwt.GetEmailFromToken(r.Context(), h.storage, cookie.Value)
 		if err != nil {
 			h.logger.Error("failed to parse token", zap.Error(err))
 		}
@@ -87,7 +103,6 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		data.Email = email
 	}
 
-	var cart []models.CartItem
 	var userID any
 	user, err := h.storage.User(r.Context(), data.Email)
 	if err != nil {
@@ -101,26 +116,24 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		userID = sCookie.Value
 	}
 
-	cart, err = h.storage.GetCart(r.Context(), userID)
+	contents, err := h.cart.Contents(r.Context(), userID)
 	if err != nil {
 		h.logger.Error("failed to fetch cart", zap.Error(err))
-		h.ServeHTTPWithError(w, "Failed to load your cart. Please try again later")
+		h.ServeHTTPWithError(w, r, "cart.error.load")
 		return
 	}
 
-	summary := calculateCartSum(cart)
-
-	data.CartItems = cart
-	data.TotalItems = summary.TotalItems
-	data.Subtotal = summary.Subtotal
-	data.Shipping = summary.Shipping
-	data.Tax = summary.Tax
-	data.Total = summary.Total
-	data.CartCount = summary.CartCount
+	data.CartItems = contents.Items
+	data.TotalItems = contents.TotalItems
+	data.Subtotal = contents.Subtotal
+	data.Shipping = contents.Shipping
+	data.Tax = contents.Tax
+	data.Total = contents.Total
+	data.CartCount = contents.CartCount
 
 	if err := h.tmpl.Execute(w, data); err != nil {
 		h.logger.Error("failed to execute home template", zap.Error(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		http.Error(w, loc.T("error.internal"), http.StatusInternalServerError)
 		return
 	}
 }
@@ -128,7 +141,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
 		h.logger.Error("failed to parse form", zap.Error(err))
-		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		http.Error(w, i18n.FromContext(r.Context()).T("error.invalid_form"), http.StatusBadRequest)
 		return
 	}
 
@@ -150,14 +163,9 @@ func (h *Handler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
 		quantity = 1
 	}
 
-	if quantity < 0 {
-		h.SendJSONError(w, "Quantity cannot be negative", http.StatusBadRequest)
-		return
-	}
-
 	cookie, err := r.Cookie("auth_token")
 	if err == nil {
-		email, err := jwt.GetEmailFromToken(cookie.Value)
+		email, err := jwt.GetEmailFromToken(r.Context(), h.storage, cookie.Value)
 		if err != nil {
 			h.logger.Error("failed to parse token", zap.Error(err))
 			return
@@ -176,41 +184,13 @@ func (h *Handler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
 		userID = sCookie.Value
 	}
 
-	err = h.storage.UpdateCartQuantity(r.Context(), productID, quantity, userID)
-	if err != nil {
-		h.logger.Error("failed to update cart quantity", zap.Error(err))
-		h.SendJSONError(w, "Failed to update cart. Please try again.", http.StatusInternalServerError)
-		return
-	}
-
-	time.Sleep(50 * time.Millisecond)
-
-	cart, err := h.storage.GetCart(r.Context(), userID)
+	contents, err := h.cart.UpdateItem(r.Context(), userID, int64(productID), quantity)
 	if err != nil {
-		h.logger.Error("failed to fetch cart", zap.Error(err))
-		h.SendJSONError(w, "Failed to load updated cart", http.StatusInternalServerError)
+		h.sendCartError(w, r, err, "cart.error.update")
 		return
 	}
 
-	sum := calculateCartSum(cart)
-
-	data := PageData{
-		Success:    true,
-		Title:      "Cart",
-		TotalItems: sum.TotalItems,
-		Subtotal:   sum.Subtotal,
-		Shipping:   sum.Shipping,
-		Tax:        sum.Tax,
-		CartItems:  cart,
-		CartCount:  sum.CartCount,
-		Total:      sum.Total,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		h.logger.Error("failed to encode JSON response", zap.Error(err))
-	}
+	h.sendContents(w, contents)
 }
 
 func (h *Handler) RemoveHandler(w http.ResponseWriter, r *http.Request) {
@@ -231,7 +211,7 @@ func (h *Handler) RemoveHandler(w http.ResponseWriter, r *http.Request) {
 
 	cookie, err := r.Cookie("auth_token")
 	if err == nil {
-		email, err := jwt.GetEmailFromToken(cookie.Value)
+		email, err := jwt.GetEmailFromToken(r.Context(), h.storage, cookie.Value)
 		if err != nil {
 			h.logger.Error("failed to parse token", zap.Error(err))
 			return
@@ -250,34 +230,27 @@ func (h *Handler) RemoveHandler(w http.ResponseWriter, r *http.Request) {
 		userID = sCookie.Value
 	}
 
-	err = h.storage.RemoveFromCart(r.Context(), productID, userID)
+	contents, err := h.cart.RemoveItem(r.Context(), userID, int64(productID))
 	if err != nil {
-		h.logger.Error("failed to remove from cart", zap.Error(err))
-		h.SendJSONError(w, "Failed to remove fro cart. Please try again later", http.StatusInternalServerError)
+		h.sendCartError(w, r, err, "cart.error.remove")
 		return
 	}
 
-	time.Sleep(50 * time.Millisecond)
-
-	cart, err := h.storage.GetCart(r.Context(), userID)
-	if err != nil {
-		h.logger.Error("failed to fetch cart", zap.Error(err))
-		h.SendJSONError(w, "Failed to load updated cart", http.StatusInternalServerError)
-		return
-	}
-
-	sum := calculateCartSum(cart)
+	h.sendContents(w, contents)
+}
 
+// sendContents answers a cart change with the updated cart.
+func (h *Handler) sendContents(w http.ResponseWriter, contents cart.Contents) {
 	data := PageData{
 		Success:    true,
 		Title:      "Cart",
-		TotalItems: sum.TotalItems,
-		Subtotal:   sum.Subtotal,
-		Shipping:   sum.Shipping,
-		Tax:        sum.Tax,
-		CartItems:  cart,
-		CartCount:  sum.CartCount,
-		Total:      sum.Total,
+		TotalItems: contents.TotalItems,
+		Subtotal:   contents.Subtotal,
+		Shipping:   contents.Shipping,
+		Tax:        contents.Tax,
+		CartItems:  contents.Items,
+		CartCount:  contents.CartCount,
+		Total:      contents.Total,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -287,34 +260,39 @@ func (h *Handler) RemoveHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func calculateCartSum(cart []models.CartItem) Sum {
-	var sum Sum
-
-	for _, v := range cart {
-		sum.Subtotal += v.ProductPrice * float64(v.Quantity)
-		sum.CartCount += v.Quantity
+// sendCartError answers a rejected cart change with the reason, or with the message of
+// fallbackKey if the change failed unexpectedly.
+func (h *Handler) sendCartError(w http.ResponseWriter, r *http.Request, err error, fallbackKey string) {
+	loc := i18n.FromContext(r.Context())
+
+	switch {
+	case errors.Is(err, cart.ErrInvalidQuantity):
+		h.SendJSONError(w, loc.T("cart.error.update_quantity"), http.StatusBadRequest)
+	case errors.Is(err, cart.ErrProductNotFound), errors.Is(err, cart.ErrNotInCart):
+		h.SendJSONError(w, loc.T("cart.error.not_in_cart"), http.StatusNotFound)
+	case errors.Is(err, cart.ErrOutOfStock):
+		h.SendJSONError(w, loc.T("cart.error.out_of_stock"), http.StatusConflict)
+	default:
+		h.logger.Error("failed to change cart", zap.Error(err))
+		h.SendJSONError(w, loc.T(fallbackKey), http.StatusInternalServerError)
 	}
-
-	sum.TotalItems = sum.CartCount
-	sum.Tax = sum.Subtotal * taxPercent
-	sum.Shipping = shippingPrice
-	sum.Total = sum.Subtotal + sum.Tax + sum.Shipping
-
-	return sum
 }
 
-func (h *Handler) ServeHTTPWithError(w http.ResponseWriter, errorMsg string) {
+func (h *Handler) ServeHTTPWithError(w http.ResponseWriter, r *http.Request, errorKey string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusBadRequest)
 
+	loc := i18n.FromContext(r.Context())
 	data := PageData{
-		Title: "Cart",
-		Error: errorMsg,
+		Title:     loc.T("cart.title"),
+		Error:     loc.T(errorKey),
+		CSRFToken: csrf.Token(r),
+		Locale:    loc,
 	}
 
 	if err := h.tmpl.Execute(w, data); err != nil {
 		h.logger.Error("failed to execute cart template with error", zap.Error(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		http.Error(w, loc.T("error.internal"), http.StatusInternalServerError)
 	}
 }
 
@@ -0,0 +1,175 @@
+package cart
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"shop/internal/http-server/middleware/realip"
+	"shop/internal/i18n"
+	"shop/internal/services/cart"
+	"shop/internal/storage"
+	"shop/lib/jwt"
+)
+
+// EventsHandler streams the item count of the cart as Server-Sent Events, so the badge of
+// every open page follows changes made elsewhere.
+//
+// Each event carries the ID of the update. A browser reconnecting with Last-Event-ID set to
+// the ID of the last update is only sent the updates after it.
+//
+// Streams are capped per cart owner, per client IP and in total, so neither made-up owners
+// nor many owners behind one client can hold an unbounded number of connections open.
+func (h *Handler) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	loc := i18n.FromContext(r.Context())
+
+	owner := h.owner(r)
+	if owner == nil {
+		// Nothing to follow; 204 tells EventSource not to reconnect.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	release, ok := h.streams.acquire(realip.ClientIP(r))
+	if !ok {
+		http.Error(w, loc.T("cart.error.too_many_streams"), http.StatusTooManyRequests)
+		return
+	}
+	defer release()
+
+	sub, last, err := h.cart.Subscribe(r.Context(), owner)
+	if errors.Is(err, cart.ErrTooManySubscribers) {
+		http.Error(w, loc.T("cart.error.too_many_streams"), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to subscribe to cart", zap.Error(err))
+		http.Error(w, loc.T("error.internal"), http.StatusInternalServerError)
+		return
+	}
+	defer sub.Close()
+
+	// The stream outlives the write timeout of the server.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Error("failed to clear write deadline", zap.Error(err))
+		http.Error(w, loc.T("error.internal"), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if r.Header.Get("Last-Event-ID") != last.ID {
+		fmt.Fprintf(w, "id: %s\nevent: cart\ndata: {\"count\":%d}\n\n", last.ID, last.Count)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case update, ok := <-sub.Updates():
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "id: %s\nevent: cart\ndata: {\"count\":%d}\n\n", update.ID, update.Count)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// owner returns the owner of the request's cart: the signed-in user if the auth token is valid,
+// otherwise the guest session if the server issued it. It returns nil for anonymous visitors,
+// so a client cannot follow a cart, nor get a stream cap of its own, by making up a session ID.
+func (h *Handler) owner(r *http.Request) any {
+	if cookie, err := r.Cookie("auth_token"); err == nil {
+		email, err := jwt.GetEmailFromToken(r.Context(), h.storage, cookie.Value)
+		if err != nil {
+			h.logger.Warn("invalid auth token", zap.Error(err))
+			return nil
+		}
+
+		user, err := h.storage.User(r.Context(), email)
+		if err != nil {
+			h.logger.Error("failed to fetch user", zap.Error(err))
+			return nil
+		}
+
+		return user.ID
+	}
+
+	sCookie, err := r.Cookie("session_id")
+	if err != nil {
+		return nil
+	}
+
+	if _, err := h.storage.GetSession(r.Context(), sCookie.Value); err != nil {
+		if !errors.Is(err, storage.ErrSessionNotFound) {
+			h.logger.Error("failed to fetch session", zap.Error(err))
+		}
+		return nil
+	}
+
+	return sCookie.Value
+}
+
+// streamLimiter caps the open event streams in total and per client IP.
+type streamLimiter struct {
+	max      int
+	maxPerIP int
+
+	mu    sync.Mutex
+	total int
+	perIP map[string]int
+}
+
+func newStreamLimiter(maxStreams, maxPerIP int) *streamLimiter {
+	return &streamLimiter{
+		max:      maxStreams,
+		maxPerIP: maxPerIP,
+		perIP:    make(map[string]int),
+	}
+}
+
+// acquire counts a stream of the client IP and returns the func to call when it ends, or
+// false if a cap is reached.
+func (l *streamLimiter) acquire(ip string) (func(), bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.total >= l.max || l.perIP[ip] >= l.maxPerIP {
+		return nil, false
+	}
+	l.total++
+	l.perIP[ip]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			l.total--
+			if l.perIP[ip]--; l.perIP[ip] == 0 {
+				delete(l.perIP, ip)
+			}
+		})
+	}, true
+}
@@ -0,0 +1,128 @@
+package cart
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/storage"
+	"shop/lib/jwt"
+)
+
+// fakeStorage knows one user and one guest session issued by the server.
+type fakeStorage struct{}
+
+func (fakeStorage) User(_ context.Context, email string) (models.User, error) {
+	if email != "user@example.com" {
+		return models.User{}, storage.ErrUserNotFound
+	}
+
+	return models.User{ID: 7, Email: email}, nil
+}
+
+func (fakeStorage) GetSession(_ context.Context, UUID string) (int, error) {
+	if UUID != "issued" {
+		return 0, storage.ErrSessionNotFound
+	}
+
+	return 1, nil
+}
+
+func (fakeStorage) App(_ context.Context, appID int) (models.App, error) {
+	if appID != 1 {
+		return models.App{}, storage.ErrAppNotFound
+	}
+
+	return models.App{ID: 1, Secret: "test-secret"}, nil
+}
+
+func newTestHandler() *Handler {
+	return &Handler{logger: zap.NewNop(), storage: fakeStorage{}, heartbeat: time.Second, streams: newStreamLimiter(10, 2)}
+}
+
+func TestOwner(t *testing.T) {
+	user := models.User{ID: 7, Email: "user@example.com"}
+	token, err := jwt.NewToken(user, models.App{ID: 1, Secret: "test-secret"}, nil, time.Hour)
+	if err != nil {
+		t.Fatalf("NewToken: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cookies map[string]string
+		want    any
+	}{
+		{name: "anonymous"},
+		{name: "issued session", cookies: map[string]string{"session_id": "issued"}, want: "issued"},
+		{name: "made-up session", cookies: map[string]string{"session_id": "made-up"}},
+		{name: "signed-in user", cookies: map[string]string{"auth_token": token}, want: 7},
+		{name: "user before session", cookies: map[string]string{"auth_token": token, "session_id": "issued"},
+			want: 7},
+		{name: "forged token", cookies: map[string]string{"auth_token": token + "x", "session_id": "issued"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/cart/events", nil)
+			for name, value := range tt.cookies {
+				r.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+
+			if got := newTestHandler().owner(r); got != tt.want {
+				t.Errorf("owner = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventsHandlerIgnoresMadeUpSessions(t *testing.T) {
+	h := newTestHandler()
+
+	r := httptest.NewRequest(http.MethodGet, "/cart/events", nil)
+	r.AddCookie(&http.Cookie{Name: "session_id", Value: "made-up"})
+	w := httptest.NewRecorder()
+	h.EventsHandler(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if h.streams.total != 0 {
+		t.Errorf("%d streams counted for an unknown owner", h.streams.total)
+	}
+}
+
+func TestStreamLimiter(t *testing.T) {
+	l := newStreamLimiter(3, 2)
+
+	first, ok := l.acquire("10.0.0.1")
+	if !ok {
+		t.Fatal("first stream refused")
+	}
+	if _, ok := l.acquire("10.0.0.1"); !ok {
+		t.Fatal("second stream of the IP refused")
+	}
+	if _, ok := l.acquire("10.0.0.1"); ok {
+		t.Error("third stream of the IP allowed past the per-IP cap")
+	}
+	if _, ok := l.acquire("10.0.0.2"); !ok {
+		t.Fatal("stream of another IP refused")
+	}
+	if _, ok := l.acquire("10.0.0.3"); ok {
+		t.Error("stream allowed past the total cap")
+	}
+
+	// Releasing twice frees one stream only.
+	first()
+	first()
+	if _, ok := l.acquire("10.0.0.3"); !ok {
+		t.Error("stream refused after one ended")
+	}
+	if _, ok := l.acquire("10.0.0.1"); ok {
+		t.Error("stream allowed past the total cap after a double release")
+	}
+}
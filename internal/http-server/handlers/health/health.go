@@ -0,0 +1,83 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const checkTimeout = 2 * time.Second
+
+// Target is a gRPC health service and the services to check on it. The status of the
+// server as a whole, the empty service name, is reported as Name.
+type Target struct {
+	Name     string
+	Client   healthpb.HealthClient
+	Services []string
+}
+
+type Handler struct {
+	targets []Target
+	logger  *zap.Logger
+}
+
+// NewHealthHandler returns a handler reporting the HTTP server together with the gRPC health
+// of the services of the targets.
+func NewHealthHandler(targets []Target, logger *zap.Logger) *Handler {
+	return &Handler{
+		targets: targets,
+		logger:  logger,
+	}
+}
+
+type Response struct {
+	Status    string            `json:"status"`
+	Timestamp string            `json:"timestamp"`
+	GRPC      map[string]string `json:"grpc"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	resp := Response{
+		Status:    "ok",
+		Timestamp: time.Now().Format(time.RFC3339),
+		GRPC:      make(map[string]string),
+	}
+	code := http.StatusOK
+
+	for _, target := range h.targets {
+		for _, service := range target.Services {
+			name := service
+			if name == "" {
+				name = target.Name
+			}
+
+			res, err := target.Client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+			if err != nil {
+				h.logger.Error("gRPC health check failed", zap.String("service", name), zap.Error(err))
+				resp.GRPC[name] = "UNREACHABLE"
+				resp.Status = "unavailable"
+				code = http.StatusServiceUnavailable
+				continue
+			}
+
+			resp.GRPC[name] = res.GetStatus().String()
+			if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+				resp.Status = "unavailable"
+				code = http.StatusServiceUnavailable
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.logger.Error("failed to encode health response", zap.Error(err))
+	}
+}
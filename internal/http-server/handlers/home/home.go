@@ -2,13 +2,15 @@ package home
 
 import (
 	"context"
-	"html/template"
 	"net/http"
 
 	"go.uber.org/zap"
 
 	"shop/internal/domain/models"
 	"shop/internal/http-server/cookies"
+	"shop/internal/http-server/middleware/csrf"
+	"shop/internal/http-server/views"
+	"shop/internal/i18n"
 	"shop/internal/random"
 	"shop/lib/jwt"
 )
@@ -18,16 +20,17 @@ type Storage interface {
 	CreateSession(ctx context.Context, UUID string) error
 	GetCartCount(ctx context.Context, userID any) (int, error)
 	User(ctx context.Context, email string) (models.User, error)
+	App(ctx context.Context, appID int) (models.App, error)
 }
 
 type Handler struct {
 	storage Storage
 	logger  *zap.Logger
-	tmpl    *template.Template
+	tmpl    *views.Page
 }
 
-func NewHomeHandler(storage Storage, logger *zap.Logger) *Handler {
-	tmpl, err := template.ParseFiles("./html-templates/home_page.html")
+func NewHomeHandler(storage Storage, pages *views.Views, logger *zap.Logger) *Handler {
+	tmpl, err := pages.Page("home.html")
 	if err != nil {
 		logger.Fatal("failed to parse home template", zap.Error(err))
 	}
@@ -47,6 +50,8 @@ type PageData struct {
 	Products  []models.Product
 	Success   bool `json:"success"`
 	Error     string
+	CSRFToken string
+	Locale    *i18n.Localizer
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -57,13 +62,16 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		user   models.User
 	)
 
+	loc := i18n.FromContext(r.Context())
 	data := PageData{
-		Title: "Welcome to Our Shop",
+		Title:     loc.T("home.title"),
+		CSRFToken: csrf.Token(r),
+		Locale:    loc,
 	}
 
 	cookie, err := r.Cookie("auth_token")
 	if err == nil {
-		email, err := jwt.GetEmailFromToken(cookie.Value)
+		email, err := jwt.GetEmailFromToken(r.Context(), h.storage, cookie.Value)
 		if err != nil {
 			h.logger.Error("failed to parse token", zap.Error(err))
 		}
@@ -103,7 +111,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		product, err = h.storage.GetProduct(r.Context(), random.RandomProductID(100))
 		if err != nil {
 			h.logger.Error("failed to fetch products", zap.Error(err))
-			data.Error = "Unable to load products at this time. Please try again later."
+			data.Error = loc.T("products.error.load")
 		}
 		products[i] = product
 	}
@@ -113,7 +121,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if err = h.tmpl.Execute(w, data); err != nil {
 		h.logger.Error("failed to execute home template", zap.Error(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		http.Error(w, loc.T("error.internal"), http.StatusInternalServerError)
 		return
 	}
 }
@@ -3,13 +3,19 @@ package products
 import (
 	"context"
 	"encoding/json"
-	"html/template"
+	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"go.uber.org/zap"
 
 	"shop/internal/domain/models"
+	"shop/internal/http-server/middleware/csrf"
+	"shop/internal/http-server/views"
+	"shop/internal/i18n"
+	"shop/internal/services/cart"
+	"shop/internal/services/catalog"
 	"shop/lib/jwt"
 )
 
@@ -19,22 +25,29 @@ const (
 )
 
 type Storage interface {
-	GetProducts(ctx context.Context, limit, offset int) ([]models.Product, error)
-	TotalProducts(ctx context.Context) (int, error)
 	User(ctx context.Context, email string) (models.User, error)
-	GetSession(ctx context.Context, UUID string) (int, error)
-	AddToCart(ctx context.Context, productID, quantity int, userID any) error
-	GetCartCount(ctx context.Context, userID any) (int, error)
+	App(ctx context.Context, appID int) (models.App, error)
+}
+
+type Catalog interface {
+	Browse(ctx context.Context, page, perPage int) (catalog.NumberedPage, error)
+}
+
+type Cart interface {
+	Count(ctx context.Context, owner any) (int, error)
+	AddItem(ctx context.Context, owner any, productID int64, quantity int) (cart.Contents, error)
 }
 
 type Handler struct {
 	logger  *zap.Logger
-	tmpl    *template.Template
+	tmpl    *views.Page
 	storage Storage
+	catalog Catalog
+	cart    Cart
 }
 
-func NewProductsHandler(storage Storage, logger *zap.Logger) *Handler {
-	tmpl, err := template.ParseFiles("./html-templates/products_page.html")
+func NewProductsHandler(storage Storage, catalog Catalog, cart Cart, pages *views.Views, logger *zap.Logger) *Handler {
+	tmpl, err := pages.Page("products.html")
 	if err != nil {
 		logger.Fatal("failed to parse products template", zap.Error(err))
 	}
@@ -43,6 +56,8 @@ func NewProductsHandler(storage Storage, logger *zap.Logger) *Handler {
 		logger:  logger,
 		tmpl:    tmpl,
 		storage: storage,
+		catalog: catalog,
+		cart:    cart,
 	}
 }
 
@@ -62,6 +77,8 @@ type PageData struct {
 	PrevPage      int
 	NextPage      int
 	PageNumbers   []int
+	CSRFToken     string
+	Locale        *i18n.Localizer
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -69,7 +86,11 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	pageStr := r.URL.Query().Get("page")
 	page := defaultPage
-	data := PageData{}
+	loc := i18n.FromContext(r.Context())
+	data := PageData{
+		CSRFToken: csrf.Token(r),
+		Locale:    loc,
+	}
 
 	if pageStr != "" {
 		p, err := strconv.Atoi(pageStr)
@@ -82,57 +103,35 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	data.Title = "Look at our products!"
-	data.CurrentPage = page
-
-	products, err := h.storage.GetProducts(r.Context(), productsPerPage, (page-1)*productsPerPage)
-	if err != nil {
-		h.logger.Warn("failed to fetch products", zap.Error(err))
-		data.Error = "Unable to load products at this time. Please try again later."
-		return
-	}
-	data.Products = products
+	data.Title = loc.T("products.title")
+	data.Error = r.URL.Query().Get("error")
 
-	totalProducts, err := h.storage.TotalProducts(r.Context())
+	listing, err := h.catalog.Browse(r.Context(), page, productsPerPage)
 	if err != nil {
-		h.logger.Warn("failed to fetch total products", zap.Error(err))
-	}
-	data.TotalProducts = totalProducts
-
-	totalPages := totalProducts/productsPerPage + 1
-	data.TotalPages = totalPages
-
-	startResult := (page-1)*productsPerPage + 1
-	endResult := startResult + len(products) - 1
-	if len(products) == 0 {
-		startResult = 0
-		endResult = 0
-	}
-	data.StartResult = startResult
-	data.EndResult = endResult
+		if errors.Is(err, catalog.ErrPageOutOfRange) {
+			http.Redirect(w, r, "/products?page=1", http.StatusFound)
+			return
+		}
 
-	prevPage := page - 1
-	if prevPage < 1 {
-		prevPage = 1
+		h.logger.Warn("failed to fetch products", zap.Error(err))
+		data.Error = loc.T("products.error.load")
 	}
-	data.PrevPage = prevPage
 
-	nextPage := page + 1
-	if nextPage > totalPages {
-		nextPage = totalPages
-	}
-	data.NextPage = nextPage
-
-	if page > totalPages && totalPages > 0 {
-		http.Redirect(w, r, "/products?page=1", http.StatusFound)
-		return
-	}
+	data.Products = listing.Products
+	data.CurrentPage = listing.Page
+	data.TotalPages = listing.TotalPages
+	data.TotalProducts = listing.TotalProducts
+	data.StartResult = listing.StartResult
+	data.EndResult = listing.EndResult
+	data.PrevPage = listing.PrevPage
+	data.NextPage = listing.NextPage
+	data.PageNumbers = listing.PageNumbers
 
 	var userID any
 
 	cookie, err := r.Cookie("auth_token")
 	if err == nil {
-		email, err := jwt.GetEmailFromToken(cookie.Value)
+		email, err := jwt.GetEmailFromToken(r.Context(), h.storage, cookie.Value)
 		if err != nil {
 			h.logger.Error("failed to parse token", zap.Error(err))
 		}
@@ -152,26 +151,26 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		userID = sCookie.Value
 	}
 
-	cartCount, err := h.storage.GetCartCount(r.Context(), userID)
+	cartCount, err := h.cart.Count(r.Context(), userID)
 	if err != nil {
 		h.logger.Error("failed to fetch cart count", zap.Error(err))
 	} else {
 		data.CartCount = cartCount
 	}
 
-	data.PageNumbers = generatePageNumbers(page, totalPages)
-
 	if err := h.tmpl.Execute(w, data); err != nil {
 		h.logger.Error("failed to execute home template", zap.Error(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		http.Error(w, loc.T("error.internal"), http.StatusInternalServerError)
 		return
 	}
 }
 
 func (h *Handler) AddToCart(w http.ResponseWriter, r *http.Request) {
+	loc := i18n.FromContext(r.Context())
+
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
 		h.logger.Error("failed to parse form", zap.Error(err))
-		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		http.Error(w, loc.T("error.invalid_form"), http.StatusBadRequest)
 		return
 	}
 	var (
@@ -193,7 +192,7 @@ func (h *Handler) AddToCart(w http.ResponseWriter, r *http.Request) {
 
 	cookie, err := r.Cookie("auth_token")
 	if err == nil {
-		email, err := jwt.GetEmailFromToken(cookie.Value)
+		email, err := jwt.GetEmailFromToken(r.Context(), h.storage, cookie.Value)
 		if err != nil {
 			h.logger.Error("failed to parse token", zap.Error(err))
 			return
@@ -212,15 +211,32 @@ func (h *Handler) AddToCart(w http.ResponseWriter, r *http.Request) {
 		userID = sCookie.Value
 	}
 
-	err = h.storage.AddToCart(r.Context(), productID, quantity, userID)
+	contents, err := h.cart.AddItem(r.Context(), userID, int64(productID), quantity)
 	if err != nil {
-		h.logger.Error("failed to add to cart", zap.Error(err))
-		return
-	}
+		key := "cart.error.add"
+		code := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, cart.ErrInvalidQuantity):
+			key, code = "cart.error.add_quantity", http.StatusBadRequest
+		case errors.Is(err, cart.ErrProductNotFound):
+			key, code = "cart.error.product_not_found", http.StatusNotFound
+		case errors.Is(err, cart.ErrOutOfStock):
+			key, code = "cart.error.out_of_stock", http.StatusConflict
+		default:
+			h.logger.Error("failed to add to cart", zap.Error(err))
+		}
+		message := loc.T(key)
+
+		if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			if err := json.NewEncoder(w).Encode(map[string]any{"success": false, "error": message}); err != nil {
+				h.logger.Error("failed to encode JSON response", zap.Error(err))
+			}
+			return
+		}
 
-	cartCount, err := h.storage.GetCartCount(r.Context(), userID)
-	if err != nil {
-		h.logger.Error("failed to fetch cart count", zap.Error(err))
+		http.Redirect(w, r, "/products?error="+url.QueryEscape(message), http.StatusSeeOther)
 		return
 	}
 
@@ -228,7 +244,7 @@ func (h *Handler) AddToCart(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		response := map[string]interface{}{
 			"success":   true,
-			"cartCount": cartCount,
+			"cartCount": contents.CartCount,
 		}
 		if err := json.NewEncoder(w).Encode(response); err != nil {
 			h.logger.Error("failed to encode JSON response", zap.Error(err))
@@ -238,23 +254,3 @@ func (h *Handler) AddToCart(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/products?success=item-added", http.StatusSeeOther)
 	}
 }
-
-func generatePageNumbers(currentPage, totalPages int) []int {
-	var pages []int
-
-	start := currentPage - 2
-	if start < 1 {
-		start = 1
-	}
-
-	end := currentPage + 2
-	if end > totalPages {
-		end = totalPages
-	}
-
-	for i := start; i <= end; i++ {
-		pages = append(pages, i)
-	}
-
-	return pages
-}
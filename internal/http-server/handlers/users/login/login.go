@@ -3,64 +3,100 @@ package login
 import (
 	"context"
 	"errors"
-	"html/template"
 	"net/http"
+	"slices"
 	"time"
 
 	ssov1 "github.com/Yuukiine/protos/gen/go/sso"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	"shop/internal/domain/models"
 	"shop/internal/grpc/auth"
 	"shop/internal/http-server/cookies"
+	"shop/internal/http-server/middleware/csrf"
+	"shop/internal/http-server/middleware/realip"
+	"shop/internal/http-server/redirect"
+	"shop/internal/http-server/views"
+	"shop/internal/i18n"
+	"shop/internal/services/cart"
 )
 
 type Storage interface {
 	User(ctx context.Context, email string) (models.User, error)
-	MoveCart(ctx context.Context, newUserID int, oldUserID any) error
-	GetCartCount(ctx context.Context, userID any) (int, error)
+}
+
+type Cart interface {
+	MergeGuestCart(ctx context.Context, userID int64, sessionID string) (cart.Contents, error)
 }
 
 type Handler struct {
 	storage    Storage
+	cart       Cart
 	AuthClient ssov1.AuthClient
 	logger     *zap.Logger
-	tmpl       *template.Template
+	tmpl       *views.Page
+	providers  []string
 }
 
-func NewLoginHandler(storage Storage, authClient ssov1.AuthClient, logger *zap.Logger) *Handler {
-	tmpl, err := template.ParseFiles("./html-templates/login_page.html")
+// NewLoginHandler returns a login handler. providers are names of external identity
+// providers shown as "Sign in with" buttons.
+func NewLoginHandler(
+	storage Storage,
+	cart Cart,
+	authClient ssov1.AuthClient,
+	providers []string,
+	pages *views.Views,
+	logger *zap.Logger,
+) *Handler {
+	tmpl, err := pages.Page("login.html")
 	if err != nil {
 		logger.Fatal("failed to parse home template", zap.Error(err))
 	}
 
 	return &Handler{
 		storage:    storage,
+		cart:       cart,
 		logger:     logger,
 		tmpl:       tmpl,
 		AuthClient: authClient,
+		providers:  providers,
 	}
 }
 
+// redirectErrors are the message keys other handlers can show on the login page through its
+// error query parameter, and whether they name the provider of the provider parameter. Other
+// values are ignored, so a link can't put arbitrary text on the page.
+var redirectErrors = map[string]bool{
+	"error.invalid_form":       false,
+	"error.internal_retry":     false,
+	"social.error.expired":     false,
+	"social.error.unavailable": true,
+	"social.error.cancelled":   true,
+	"social.error.failed":      true,
+	"social.error.unverified":  true,
+}
+
 type PageData struct {
-	Title   string
-	Error   string
-	Success string
-	Email   string
+	Title     string
+	Error     string
+	Success   string
+	Email     string
+	Providers []string
+	CSRFToken string
+	Locale    *i18n.Localizer
 }
 
 func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		h.logger.Error("failed to parse login form", zap.Error(err))
-		http.Redirect(w, r, "/login?error=Invalid+form+data", http.StatusSeeOther)
+		http.Redirect(w, r, "/login?error=error.invalid_form", http.StatusSeeOther)
 		return
 	}
 
-	var (
-		userID int
-		user   models.User
-		sessID any
-	)
+	var sessID string
 
 	cookie, err := r.Cookie("session_id")
 	if err == nil {
@@ -74,7 +110,9 @@ func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		h.logger.Warn("login attempt with missing credentials")
 		return
 	}
-	logResp, err := h.AuthClient.Login(r.Context(), &ssov1.LoginRequest{
+	ctx := metadata.AppendToOutgoingContext(r.Context(), auth.ClientIPKey, realip.ClientIP(r))
+
+	logResp, err := h.AuthClient.Login(ctx, &ssov1.LoginRequest{
 		Email:    email,
 		Password: password,
 		AppId:    1,
@@ -83,16 +121,24 @@ func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		h.logger.Error("login failed",
 			zap.String("email", email),
 			zap.Error(err))
-		if errors.Is(err, auth.ErrInvalidArgument) {
-			h.ServeHTTPWithError(w, "Invalid credentials. Please try again", email)
+		switch auth.LockReason(err) {
+		case auth.ReasonLoginLocked:
+			h.ServeHTTPWithError(w, r, "login.error.locked", email)
+			return
+		case auth.ReasonLoginDelayed:
+			h.ServeHTTPWithError(w, r, "login.error.delayed", email)
+			return
+		}
+		if status.Code(err) == codes.InvalidArgument {
+			h.ServeHTTPWithError(w, r, "login.error.invalid_credentials", email)
 			return
 		}
 		if errors.Is(err, auth.ErrNotFound) {
-			h.ServeHTTPWithError(w, "User with this email doesn't exist. Please complete registration, or try another credentials", email)
+			h.ServeHTTPWithError(w, r, "login.error.not_found", email)
 			return
 		}
 
-		h.ServeHTTPWithError(w, "Internal error. Please try again later", email)
+		h.ServeHTTPWithError(w, r, "error.internal_retry", email)
 		return
 	}
 
@@ -102,41 +148,33 @@ func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("user logged in successfully",
 		zap.String("email", email))
 
-	cartCount, err := h.storage.GetCartCount(r.Context(), sessID)
-	if err != nil {
-		h.logger.Error("failed to fetch cart count", zap.Error(err))
+	if sessID != "" {
+		h.mergeGuestCart(r.Context(), email, sessID)
 	}
 
-	if cartCount > 0 {
-		user, err = h.storage.User(r.Context(), email)
-		if err != nil {
-			h.logger.Error("failed to fetch user", zap.Error(err))
-			return
-		}
-		userID = user.ID
+	http.Redirect(w, r, redirect.Local(r.URL.Query().Get("redirect")), http.StatusSeeOther)
+}
 
-		err = h.storage.MoveCart(r.Context(), userID, sessID)
-		if err != nil {
-			h.logger.Error("failed to move cart", zap.Error(err))
-		}
+// mergeGuestCart moves the items the user added before logging in to their account.
+func (h *Handler) mergeGuestCart(ctx context.Context, email, sessionID string) {
+	user, err := h.storage.User(ctx, email)
+	if err != nil {
+		h.logger.Error("failed to fetch user", zap.Error(err))
+		return
 	}
 
-	h.logger.Info("cart moved successfully")
-
-	redirectTo := r.URL.Query().Get("redirect")
-	if redirectTo == "" {
-		redirectTo = "/"
+	if _, err := h.cart.MergeGuestCart(ctx, int64(user.ID), sessionID); err != nil {
+		if errors.Is(err, cart.ErrSessionNotFound) {
+			return
+		}
+		h.logger.Error("failed to merge guest cart", zap.Error(err))
+		return
 	}
 
-	http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+	h.logger.Info("guest cart merged", zap.String("email", email))
 }
 
 func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("auth_token")
-	if err == nil && cookie.Value != "" {
-		h.logger.Error("failed to logout on auth service", zap.Error(err))
-	}
-
 	cookies.ClearAuthCookie(w)
 
 	h.logger.Info("user logged out")
@@ -147,30 +185,59 @@ func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	loc := i18n.FromContext(r.Context())
 	data := PageData{
-		Title: "Login to Your Account",
-		Error: "",
+		Title:     loc.T("login.title"),
+		Error:     h.redirectError(r, loc),
+		Providers: h.providers,
+		CSRFToken: csrf.Token(r),
+		Locale:    loc,
 	}
 
 	if err := h.tmpl.Execute(w, data); err != nil {
 		h.logger.Error("failed to execute home template", zap.Error(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		http.Error(w, loc.T("error.internal"), http.StatusInternalServerError)
 		return
 	}
 }
 
-func (h *Handler) ServeHTTPWithError(w http.ResponseWriter, errorMsg, email string) {
+// redirectError returns the message of the error query parameter, if it is one of the
+// redirectErrors.
+func (h *Handler) redirectError(r *http.Request, loc *i18n.Localizer) string {
+	key := r.URL.Query().Get("error")
+
+	namesProvider, ok := redirectErrors[key]
+	if !ok {
+		return ""
+	}
+	if !namesProvider {
+		return loc.T(key)
+	}
+
+	provider := r.URL.Query().Get("provider")
+	if !slices.Contains(h.providers, provider) {
+		return ""
+	}
+
+	return loc.T(key, provider)
+}
+
+func (h *Handler) ServeHTTPWithError(w http.ResponseWriter, r *http.Request, errorKey, email string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusBadRequest)
 
+	loc := i18n.FromContext(r.Context())
 	data := PageData{
-		Title: "Login to Your Account",
-		Error: errorMsg,
-		Email: email,
+		Title:     loc.T("login.title"),
+		Error:     loc.T(errorKey),
+		Email:     email,
+		Providers: h.providers,
+		CSRFToken: csrf.Token(r),
+		Locale:    loc,
 	}
 
 	if err := h.tmpl.Execute(w, data); err != nil {
 		h.logger.Error("failed to execute login template with error", zap.Error(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		http.Error(w, loc.T("error.internal"), http.StatusInternalServerError)
 	}
 }
@@ -0,0 +1,58 @@
+package login
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"golang.org/x/text/language"
+
+	"shop/internal/i18n"
+	"shop/web"
+)
+
+func TestRedirectError(t *testing.T) {
+	bundle, err := i18n.New(web.FS, "en")
+	if err != nil {
+		t.Fatalf("i18n.New: %v", err)
+	}
+	loc := bundle.Localizer(language.English, "/login")
+	h := &Handler{providers: []string{"google"}}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "", want: ""},
+		{query: "error=social.error.expired", want: "Your sign in session has expired. Please try again"},
+		{query: "error=social.error.failed&provider=google", want: "Sign in with google failed. Please try again"},
+		{query: "error=social.error.failed&provider=Evil+Corp+support+at+%2B1-555", want: ""},
+		{query: "error=Call+%2B1-555+to+restore+your+account", want: ""},
+		{query: "error=admin.success.unlocked", want: ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/login?"+tt.query, nil)
+		if got := h.redirectError(r, loc); got != tt.want {
+			t.Errorf("redirectError(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestHandleLogout(t *testing.T) {
+	h := &Handler{logger: zap.NewNop()}
+
+	r := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	r.AddCookie(&http.Cookie{Name: "auth_token", Value: "token"})
+	w := httptest.NewRecorder()
+	h.HandleLogout(w, r)
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/" {
+		t.Errorf("response = %d to %q, want a redirect to /", w.Code, w.Header().Get("Location"))
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "auth_token" || cookies[0].MaxAge >= 0 {
+		t.Errorf("cookies = %v, want the auth cookie cleared", cookies)
+	}
+}
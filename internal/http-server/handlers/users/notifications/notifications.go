@@ -0,0 +1,136 @@
+package notifications
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/http-server/middleware/csrf"
+	"shop/internal/http-server/views"
+	"shop/internal/i18n"
+	"shop/lib/jwt"
+)
+
+type Storage interface {
+	NotificationPreferences(ctx context.Context, userID int64) (models.NotificationPreferences, error)
+	SaveNotificationPreferences(ctx context.Context, prefs models.NotificationPreferences) error
+	App(ctx context.Context, appID int) (models.App, error)
+}
+
+type Handler struct {
+	storage Storage
+	logger  *zap.Logger
+	tmpl    *views.Page
+}
+
+func NewNotificationsHandler(storage Storage, pages *views.Views, logger *zap.Logger) *Handler {
+	tmpl, err := pages.Page("notifications.html")
+	if err != nil {
+		logger.Fatal("failed to parse notifications template", zap.Error(err))
+	}
+
+	return &Handler{
+		storage: storage,
+		logger:  logger,
+		tmpl:    tmpl,
+	}
+}
+
+type PageData struct {
+	Title       string
+	User        string
+	Email       string
+	Error       string
+	Success     string
+	Preferences models.NotificationPreferences
+	CSRFToken   string
+	Locale      *i18n.Localizer
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.claims(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	loc := i18n.FromContext(r.Context())
+	data := PageData{
+		Title:     loc.T("notifications.title"),
+		User:      "true",
+		Email:     claims.Email,
+		Error:     r.URL.Query().Get("error"),
+		Success:   r.URL.Query().Get("success"),
+		CSRFToken: csrf.Token(r),
+		Locale:    loc,
+	}
+
+	prefs, err := h.storage.NotificationPreferences(r.Context(), claims.UID)
+	if err != nil {
+		h.logger.Error("failed to fetch notification preferences", zap.Int64("uid", claims.UID), zap.Error(err))
+		data.Error = loc.T("notifications.error.load")
+	}
+	data.Preferences = prefs
+
+	if err := h.tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute notifications template", zap.Error(err))
+		http.Error(w, loc.T("error.internal"), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleSave stores the preferences from the form; unchecked boxes are not submitted, so they opt out.
+func (h *Handler) HandleSave(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.claims(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.Error("failed to parse notifications form", zap.Error(err))
+		h.redirect(w, r, "error", "error.invalid_form")
+		return
+	}
+
+	prefs := models.NotificationPreferences{
+		UserID:  claims.UID,
+		Account: r.FormValue("account") == "on",
+		Orders:  r.FormValue("orders") == "on",
+	}
+
+	if err := h.storage.SaveNotificationPreferences(r.Context(), prefs); err != nil {
+		h.logger.Error("failed to save notification preferences", zap.Int64("uid", claims.UID), zap.Error(err))
+		h.redirect(w, r, "error", "error.internal_retry")
+		return
+	}
+
+	h.redirect(w, r, "success", "notifications.success.saved")
+}
+
+// claims returns the claims of the logged-in user, redirecting anonymous users to the login page.
+func (h *Handler) claims(w http.ResponseWriter, r *http.Request) (jwt.Claims, bool) {
+	cookie, err := r.Cookie("auth_token")
+	if err != nil || cookie.Value == "" {
+		http.Redirect(w, r, "/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		return jwt.Claims{}, false
+	}
+
+	claims, err := jwt.ParseToken(r.Context(), h.storage, cookie.Value)
+	if err != nil {
+		h.logger.Warn("failed to parse token", zap.Error(err))
+		http.Redirect(w, r, "/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		return jwt.Claims{}, false
+	}
+
+	return claims, true
+}
+
+// redirect sends the user back to the page, showing the message of key as param.
+func (h *Handler) redirect(w http.ResponseWriter, r *http.Request, param, key string, args ...any) {
+	message := i18n.FromContext(r.Context()).T(key, args...)
+	http.Redirect(w, r, "/account/notifications?"+param+"="+url.QueryEscape(message), http.StatusSeeOther)
+}
@@ -3,56 +3,63 @@ package register
 import (
 	"encoding/json"
 	"errors"
-	"html/template"
 	"net/http"
+	"net/url"
 	"time"
 
 	ssov1 "github.com/Yuukiine/protos/gen/go/sso"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"shop/internal/grpc/auth"
+	"shop/internal/http-server/middleware/csrf"
+	"shop/internal/http-server/views"
+	"shop/internal/i18n"
+	"shop/lib/password"
 )
 
-type Kafka interface {
-	SendMessage(message string) error
-	WaitForMessage(email string) error
-}
-
 type Handler struct {
-	kafka      Kafka
 	AuthClient ssov1.AuthClient
 	logger     *zap.Logger
-	tmpl       *template.Template
+	tmpl       *views.Page
+	minLength  int
 }
 
-func NewRegisterHandler(kafka Kafka, authClient ssov1.AuthClient, logger *zap.Logger) *Handler {
-	tmpl, err := template.ParseFiles("./html-templates/register_page.html")
+func NewRegisterHandler(authClient ssov1.AuthClient, minLength int, pages *views.Views, logger *zap.Logger) *Handler {
+	tmpl, err := pages.Page("register.html")
 	if err != nil {
 		logger.Fatal("failed to parse home template", zap.Error(err))
 	}
 
 	return &Handler{
-		kafka:      kafka,
 		logger:     logger,
 		tmpl:       tmpl,
 		AuthClient: authClient,
+		minLength:  minLength,
 	}
 }
 
 type PageData struct {
-	Email                string `json:"email"`
-	Password             string `json:"password"`
-	ConfirmPassword      string `json:"confirmPassword"`
-	Error                string `json:"error"`
-	EmailError           string `json:"emailError"`
-	PasswordError        string `json:"passwordError"`
-	ConfirmPasswordError string `json:"confirmPasswordError"`
+	Email                string          `json:"email"`
+	Password             string          `json:"password"`
+	ConfirmPassword      string          `json:"confirmPassword"`
+	Error                string          `json:"error"`
+	EmailError           string          `json:"emailError"`
+	PasswordError        string          `json:"passwordError"`
+	ConfirmPasswordError string          `json:"confirmPasswordError"`
+	CSRFToken            string          `json:"-"`
+	MinLength            int             `json:"-"`
+	Locale               *i18n.Localizer `json:"-"`
 }
 
 func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	loc := i18n.FromContext(r.Context())
+
 	if err := r.ParseForm(); err != nil {
 		h.logger.Error("failed to parse register form", zap.Error(err))
-		http.Redirect(w, r, "/register?error=Invalid+form+data", http.StatusSeeOther)
+		http.Redirect(w, r, "/register?error="+url.QueryEscape(loc.T("error.invalid_form")), http.StatusSeeOther)
 		return
 	}
 
@@ -60,7 +67,7 @@ func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
 		h.logger.Error("failed to decode JSON request", zap.Error(err))
-		h.ServeHTTPWithError(w, "Invalid request format", http.StatusBadRequest)
+		h.ServeHTTPWithError(w, r, "register.error.invalid_request", http.StatusBadRequest)
 		return
 	}
 
@@ -71,7 +78,7 @@ func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 	if password != confirm {
 		h.logger.Error("login failed",
 			zap.String("error", "mismatched passwords"))
-		h.ServeHTTPWithError(w, "Passwords don't match.", http.StatusBadRequest)
+		h.ServeHTTPWithError(w, r, "register.error.password_mismatch", http.StatusBadRequest)
 		return
 	}
 
@@ -82,30 +89,24 @@ func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		h.logger.Error("failed to register user", zap.Error(err))
 		if errors.Is(err, auth.ErrExists) {
-			h.ServeHTTPWithError(w, "User with this email already exists...", http.StatusConflict)
+			h.ServeHTTPWithError(w, r, "register.error.exists", http.StatusConflict)
 			return
 		}
-		h.ServeHTTPWithError(w, "Internal error. Please try again later.", http.StatusInternalServerError)
+		if fieldErrors := h.fieldViolations(loc, err); len(fieldErrors) > 0 {
+			h.serveFieldErrors(w, fieldErrors)
+			return
+		}
+		h.ServeHTTPWithError(w, r, "error.internal_retry", http.StatusInternalServerError)
 		return
 	}
 
 	h.logger.Info("user registered successfully",
 		zap.String("email", email))
 
-	err = h.kafka.SendMessage("Thanks for registering " + email + "! We hope to see you again")
-	if err != nil {
-		h.logger.Error("failed to send message", zap.Error(err))
-	}
-
-	err = h.kafka.WaitForMessage(email)
-	if err != nil {
-		h.logger.Error("sending message failed", zap.Error(err))
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Account created successfully! Redirecting to login...",
+		"message": loc.T("register.success"),
 	})
 	time.Sleep(1 * time.Second)
 }
@@ -113,17 +114,59 @@ func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
-	if err := h.tmpl.Execute(w, PageData{}); err != nil {
+	loc := i18n.FromContext(r.Context())
+
+	if err := h.tmpl.Execute(w, PageData{CSRFToken: csrf.Token(r), MinLength: h.minLength, Locale: loc}); err != nil {
 		h.logger.Error("failed to execute home template", zap.Error(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		http.Error(w, loc.T("error.internal"), http.StatusInternalServerError)
 		return
 	}
 }
 
-func (h *Handler) ServeHTTPWithError(w http.ResponseWriter, errorMsg string, status int) {
+func (h *Handler) ServeHTTPWithError(w http.ResponseWriter, r *http.Request, errorKey string, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": errorMsg,
+		"error": i18n.FromContext(r.Context()).T(errorKey),
 	})
 }
+
+// serveFieldErrors responds with the per-field messages the register page shows under the inputs.
+func (h *Handler) serveFieldErrors(w http.ResponseWriter, fieldErrors map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"fieldErrors": fieldErrors,
+	})
+}
+
+// fieldViolations extracts the field violations of an InvalidArgument status returned by the auth service.
+// Password policy violations are translated by their reason, the rest are shown as described.
+func (h *Handler) fieldViolations(loc *i18n.Localizer, err error) map[string]string {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.InvalidArgument {
+		return nil
+	}
+
+	fieldErrors := map[string]string{}
+	for _, detail := range st.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		if !ok {
+			continue
+		}
+		for _, v := range badRequest.GetFieldViolations() {
+			switch v.GetReason() {
+			case password.ReasonTooShort:
+				fieldErrors[v.GetField()] = loc.T("register.error.password_too_short", h.minLength)
+			case password.ReasonEqualsEmail:
+				fieldErrors[v.GetField()] = loc.T("register.error.password_equals_email")
+			case password.ReasonBreached:
+				fieldErrors[v.GetField()] = loc.T("register.error.password_breached")
+			default:
+				fieldErrors[v.GetField()] = v.GetDescription()
+			}
+		}
+	}
+
+	return fieldErrors
+}
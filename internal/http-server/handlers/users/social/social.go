@@ -0,0 +1,197 @@
+package social
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"shop/internal/http-server/cookies"
+	"shop/internal/http-server/redirect"
+	"shop/internal/services/auth"
+	"shop/internal/services/cart"
+	"shop/lib/jwt"
+	"shop/lib/oidc"
+)
+
+const (
+	appID   = 1
+	flowTTL = 10 * time.Minute
+)
+
+type Provider interface {
+	Name() string
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	Exchange(ctx context.Context, code, verifier, nonce string) (oidc.IDToken, error)
+}
+
+type IdentityLogin interface {
+	LoginWithIdentity(
+		ctx context.Context,
+		provider string,
+		subject string,
+		email string,
+		emailVerified bool,
+		appID int,
+	) (string, error)
+}
+
+type Cart interface {
+	MergeGuestCart(ctx context.Context, userID int64, sessionID string) (cart.Contents, error)
+}
+
+type Handler struct {
+	providers map[string]Provider
+	auth      IdentityLogin
+	cart      Cart
+	apps      jwt.AppProvider
+	logger    *zap.Logger
+}
+
+func NewSocialHandler(providers []Provider, auth IdentityLogin, cart Cart, apps jwt.AppProvider, logger *zap.Logger) *Handler {
+	byName := make(map[string]Provider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+
+	return &Handler{
+		providers: byName,
+		auth:      auth,
+		cart:      cart,
+		apps:      apps,
+		logger:    logger,
+	}
+}
+
+// HandleLogin starts the authorization code flow and redirects the user to the provider.
+func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+
+	provider, ok := h.providers[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	state, err1 := oidc.RandomString()
+	nonce, err2 := oidc.RandomString()
+	verifier, err3 := oidc.RandomString()
+	if err := errors.Join(err1, err2, err3); err != nil {
+		h.logger.Error("failed to generate oidc flow secrets", zap.Error(err))
+		h.redirectWithError(w, r, "error.internal_retry", "")
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		h.logger.Error("failed to build authorization url", zap.String("provider", name), zap.Error(err))
+		h.redirectWithError(w, r, "social.error.unavailable", name)
+		return
+	}
+
+	flow := strings.Join([]string{name, state, nonce, verifier, url.QueryEscape(r.URL.Query().Get("redirect"))}, "|")
+	cookies.SetOIDCCookie(w, flow, time.Now().Add(flowTTL))
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// HandleCallback finishes the flow: checks state, exchanges the code and logs the user in.
+func (h *Handler) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+
+	provider, ok := h.providers[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	cookie, err := r.Cookie(cookies.OIDCCookieName)
+	cookies.ClearOIDCCookie(w)
+	if err != nil {
+		h.logger.Warn("oidc callback without flow cookie", zap.String("provider", name))
+		h.redirectWithError(w, r, "social.error.expired", "")
+		return
+	}
+
+	flow := strings.SplitN(cookie.Value, "|", 5)
+	if len(flow) != 5 || flow[0] != name || flow[1] == "" || flow[1] != r.URL.Query().Get("state") {
+		h.logger.Warn("oidc state mismatch", zap.String("provider", name))
+		h.redirectWithError(w, r, "social.error.expired", "")
+		return
+	}
+	nonce, verifier := flow[2], flow[3]
+	redirectTo, _ := url.QueryUnescape(flow[4])
+
+	if e := r.URL.Query().Get("error"); e != "" {
+		h.logger.Warn("oidc provider returned error", zap.String("provider", name), zap.String("error", e))
+		h.redirectWithError(w, r, "social.error.cancelled", name)
+		return
+	}
+
+	idToken, err := provider.Exchange(r.Context(), r.URL.Query().Get("code"), verifier, nonce)
+	if err != nil {
+		h.logger.Error("failed to exchange code", zap.String("provider", name), zap.Error(err))
+		h.redirectWithError(w, r, "social.error.failed", name)
+		return
+	}
+
+	token, err := h.auth.LoginWithIdentity(r.Context(), name, idToken.Subject, idToken.Email, idToken.EmailVerified, appID)
+	if err != nil {
+		h.logger.Error("failed to login with identity", zap.String("provider", name), zap.Error(err))
+		if errors.Is(err, auth.ErrUnverifiedEmail) {
+			h.redirectWithError(w, r, "social.error.unverified", name)
+			return
+		}
+		h.redirectWithError(w, r, "error.internal_retry", "")
+		return
+	}
+
+	cookies.SetAuthCookie(w, token, time.Now().Add(72*time.Hour))
+
+	h.mergeGuestCart(r, token)
+
+	h.logger.Info("user logged in with external identity",
+		zap.String("provider", name),
+		zap.String("email", idToken.Email))
+
+	http.Redirect(w, r, redirect.Local(redirectTo), http.StatusSeeOther)
+}
+
+// mergeGuestCart moves items the user added before logging in to their account.
+func (h *Handler) mergeGuestCart(r *http.Request, token string) {
+	sCookie, err := r.Cookie("session_id")
+	if err != nil {
+		return
+	}
+
+	claims, err := jwt.ParseToken(r.Context(), h.apps, token)
+	if err != nil {
+		h.logger.Error("failed to parse token", zap.Error(err))
+		return
+	}
+
+	if _, err := h.cart.MergeGuestCart(r.Context(), claims.UID, sCookie.Value); err != nil {
+		if errors.Is(err, cart.ErrSessionNotFound) {
+			return
+		}
+		h.logger.Error("failed to merge guest cart", zap.Error(err))
+	}
+}
+
+// redirectWithError sends the user back to the login page showing the message of key. The page
+// looks the key up itself, so only its known errors can be shown; provider fills in the
+// messages naming the provider.
+func (h *Handler) redirectWithError(w http.ResponseWriter, r *http.Request, key, provider string) {
+	q := url.Values{}
+	q.Set("error", key)
+	if provider != "" {
+		q.Set("provider", provider)
+	}
+
+	http.Redirect(w, r, "/login?"+q.Encode(), http.StatusSeeOther)
+}
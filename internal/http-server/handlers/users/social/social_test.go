@@ -0,0 +1,241 @@
+package social
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/http-server/cookies"
+	"shop/internal/services/auth"
+	"shop/internal/services/cart"
+	"shop/internal/storage"
+	"shop/lib/oidc"
+	"shop/lib/oidc/oidctest"
+)
+
+type fakeIdentityLogin struct {
+	email string
+}
+
+func (f *fakeIdentityLogin) LoginWithIdentity(
+	_ context.Context,
+	_ string,
+	_ string,
+	email string,
+	emailVerified bool,
+	_ int,
+) (string, error) {
+	if !emailVerified {
+		return "", auth.ErrUnverifiedEmail
+	}
+	f.email = email
+
+	return "token", nil
+}
+
+// fakeApps knows app 1 only.
+type fakeApps struct{}
+
+func (fakeApps) App(_ context.Context, appID int) (models.App, error) {
+	if appID != 1 {
+		return models.App{}, storage.ErrAppNotFound
+	}
+
+	return models.App{ID: 1, Secret: "test-secret"}, nil
+}
+
+type fakeCart struct{}
+
+func (fakeCart) MergeGuestCart(context.Context, int64, string) (cart.Contents, error) {
+	return cart.Contents{}, cart.ErrSessionNotFound
+}
+
+func TestCallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		redirect string
+		claims   jwt.MapClaims
+		ttl      time.Duration
+		// tamper changes the callback query or the parts of the flow cookie
+		// (provider, state, nonce, verifier, redirect) before the callback.
+		tamper       func(q url.Values, flow []string) []string
+		wantLocation string
+		wantLogin    bool
+	}{
+		{
+			name:         "logs in and redirects back",
+			redirect:     "/cart",
+			wantLocation: "/cart",
+			wantLogin:    true,
+		},
+		{
+			name:         "backslash redirect",
+			redirect:     `/\evil.com`,
+			wantLocation: "/",
+			wantLogin:    true,
+		},
+		{
+			name:         "absolute redirect",
+			redirect:     "https://evil.com/",
+			wantLocation: "/",
+			wantLogin:    true,
+		},
+		{
+			name: "state mismatch",
+			tamper: func(q url.Values, flow []string) []string {
+				q.Set("state", "forged")
+				return flow
+			},
+			wantLocation: "/login?error=social.error.expired",
+		},
+		{
+			name: "missing flow cookie",
+			tamper: func(url.Values, []string) []string {
+				return nil
+			},
+			wantLocation: "/login?error=social.error.expired",
+		},
+		{
+			name: "pkce verifier mismatch",
+			tamper: func(_ url.Values, flow []string) []string {
+				flow[3] = "forged"
+				return flow
+			},
+			wantLocation: "/login?error=social.error.failed&provider=mock",
+		},
+		{
+			name: "nonce mismatch",
+			tamper: func(_ url.Values, flow []string) []string {
+				flow[2] = "forged"
+				return flow
+			},
+			wantLocation: "/login?error=social.error.failed&provider=mock",
+		},
+		{
+			name:         "expired id token",
+			ttl:          -time.Minute,
+			wantLocation: "/login?error=social.error.failed&provider=mock",
+		},
+		{
+			name:         "unverified email",
+			claims:       jwt.MapClaims{"sub": "subject", "email": "victim@example.com", "email_verified": false},
+			wantLocation: "/login?error=social.error.unverified&provider=mock",
+		},
+		{
+			name: "cancelled at the provider",
+			tamper: func(q url.Values, flow []string) []string {
+				q.Del("code")
+				q.Set("error", "access_denied")
+				return flow
+			},
+			wantLocation: "/login?error=social.error.cancelled&provider=mock",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := oidctest.New(t)
+			if tt.claims != nil {
+				srv.SetClaims(tt.claims)
+			}
+			if tt.ttl != 0 {
+				srv.SetTTL(tt.ttl)
+			}
+
+			identities := &fakeIdentityLogin{}
+			router := newRouter(srv, identities)
+
+			// The login redirects to the provider and keeps the flow in a cookie.
+			login := httptest.NewRecorder()
+			router.ServeHTTP(login, httptest.NewRequest(http.MethodGet,
+				"/auth/oidc/mock/login?redirect="+url.QueryEscape(tt.redirect), nil))
+			if login.Code != http.StatusFound {
+				t.Fatalf("login status = %d, want %d", login.Code, http.StatusFound)
+			}
+			flowCookie := findCookie(login.Result().Cookies(), cookies.OIDCCookieName)
+			if flowCookie == nil {
+				t.Fatal("login set no flow cookie")
+			}
+
+			callback := srv.Authorize(t, login.Header().Get("Location"))
+			q := callback.Query()
+			flow := strings.Split(flowCookie.Value, "|")
+			if tt.tamper != nil {
+				flow = tt.tamper(q, flow)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, callback.Path+"?"+q.Encode(), nil)
+			if flow != nil {
+				req.AddCookie(&http.Cookie{Name: cookies.OIDCCookieName, Value: strings.Join(flow, "|")})
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusSeeOther {
+				t.Fatalf("callback status = %d, want %d", rec.Code, http.StatusSeeOther)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("callback location = %q, want %q", got, tt.wantLocation)
+			}
+
+			authCookie := findCookie(rec.Result().Cookies(), "auth_token")
+			loggedIn := authCookie != nil && authCookie.Value != ""
+			if loggedIn != tt.wantLogin {
+				t.Errorf("logged in = %t, want %t", loggedIn, tt.wantLogin)
+			}
+			if tt.wantLogin && identities.email != "user@example.com" {
+				t.Errorf("logged in email = %q, want user@example.com", identities.email)
+			}
+		})
+	}
+}
+
+func TestUnknownProvider(t *testing.T) {
+	router := newRouter(oidctest.New(t), &fakeIdentityLogin{})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/other/login", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func newRouter(srv *oidctest.Server, identities IdentityLogin) http.Handler {
+	provider := oidc.New(oidc.Config{
+		Name:        "mock",
+		Issuer:      srv.URL,
+		ClientID:    "shop",
+		RedirectURL: "http://shop.test/auth/oidc/mock/callback",
+	}, srv.Client())
+
+	h := NewSocialHandler([]Provider{provider}, identities, fakeCart{}, fakeApps{}, zap.NewNop())
+
+	router := chi.NewRouter()
+	router.Route("/auth/oidc/{provider}", func(r chi.Router) {
+		r.Get("/login", h.HandleLogin)
+		r.Get("/callback", h.HandleCallback)
+	})
+
+	return router
+}
+
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, c := range cookies {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package authz
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"go.uber.org/zap"
+
+	"shop/internal/i18n"
+	"shop/lib/jwt"
+)
+
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, userID int64, permission string) (bool, error)
+}
+
+type ctxKey struct{}
+
+// ClaimsFromContext returns claims of the user authorized by RequirePermission.
+func ClaimsFromContext(ctx context.Context) (jwt.Claims, bool) {
+	claims, ok := ctx.Value(ctxKey{}).(jwt.Claims)
+	return claims, ok
+}
+
+// RequirePermission lets the request through only if the logged-in user has the permission.
+// The token is verified with the secret of its app from apps. Anonymous users and invalid
+// tokens are redirected to the login page, users without the permission get 403.
+func RequirePermission(
+	checker PermissionChecker,
+	apps jwt.AppProvider,
+	log *zap.Logger,
+	permission string,
+) func(next http.Handler) http.Handler {
+	log = log.With(
+		zap.String("component", "middleware/authz"),
+		zap.String("permission", permission),
+	)
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			cookie, err := r.Cookie("auth_token")
+			if err != nil || cookie.Value == "" {
+				http.Redirect(w, r, "/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+
+			claims, err := jwt.ParseToken(r.Context(), apps, cookie.Value)
+			if err != nil {
+				log.Warn("failed to parse token", zap.Error(err))
+				http.Redirect(w, r, "/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+
+			has, err := checker.HasPermission(r.Context(), claims.UID, permission)
+			if err != nil {
+				log.Error("failed to check permission", zap.Int64("uid", claims.UID), zap.Error(err))
+				http.Error(w, i18n.FromContext(r.Context()).T("error.internal"), http.StatusInternalServerError)
+				return
+			}
+
+			if !has {
+				log.Warn("permission denied", zap.Int64("uid", claims.UID), zap.String("path", r.URL.Path))
+				http.Error(w, i18n.FromContext(r.Context()).T("error.forbidden"), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, claims)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
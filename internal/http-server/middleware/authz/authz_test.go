@@ -0,0 +1,103 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/text/language"
+
+	"shop/internal/domain/models"
+	"shop/internal/i18n"
+	"shop/lib/jwt"
+	"shop/web"
+)
+
+// fakeApps knows app 1, whose secret is "app-secret".
+type fakeApps struct{}
+
+func (fakeApps) App(_ context.Context, appID int) (models.App, error) {
+	if appID != 1 {
+		return models.App{}, errors.New("app not found")
+	}
+
+	return models.App{ID: 1, Secret: "app-secret"}, nil
+}
+
+// fakeChecker grants its permissions to user 7 only.
+type fakeChecker struct {
+	permissions []string
+}
+
+func (c fakeChecker) HasPermission(_ context.Context, userID int64, permission string) (bool, error) {
+	for _, p := range c.permissions {
+		if userID == 7 && p == permission {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func TestRequirePermission(t *testing.T) {
+	bundle, err := i18n.New(web.FS, "en")
+	if err != nil {
+		t.Fatalf("i18n.New: %v", err)
+	}
+	loc := bundle.Localizer(language.English, "/admin")
+
+	token := func(secret string) string {
+		token, err := jwt.NewToken(models.User{ID: 7}, models.App{ID: 1, Secret: secret}, nil, time.Hour)
+		if err != nil {
+			t.Fatalf("NewToken: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		permissions []string
+		wantCode    int
+	}{
+		{name: "permitted", token: token("app-secret"), permissions: []string{models.PermUsersRead},
+			wantCode: http.StatusOK},
+		{name: "not permitted", token: token("app-secret"), wantCode: http.StatusForbidden},
+		{name: "anonymous", wantCode: http.StatusSeeOther},
+		{name: "token signed with another key", token: token("test-secret"), permissions: []string{models.PermUsersRead},
+			wantCode: http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if claims, ok := ClaimsFromContext(r.Context()); !ok || claims.UID != 7 {
+					t.Errorf("claims = %+v, %t; want user 7", claims, ok)
+				}
+			})
+			h := RequirePermission(fakeChecker{permissions: tt.permissions}, fakeApps{}, zap.NewNop(),
+				models.PermUsersRead)(next)
+
+			r := httptest.NewRequest(http.MethodGet, "/admin/", nil)
+			r = r.WithContext(i18n.WithLocalizer(r.Context(), loc))
+			if tt.token != "" {
+				r.AddCookie(&http.Cookie{Name: "auth_token", Value: tt.token})
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if w.Code == http.StatusSeeOther {
+				if got, want := w.Header().Get("Location"), "/login?redirect=%2Fadmin%2F"; got != want {
+					t.Errorf("Location = %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package csrf
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"shop/internal/http-server/cookies"
+	"shop/internal/http-server/views"
+	"shop/internal/i18n"
+)
+
+const (
+	// HeaderName is the header XHR requests carry the token in.
+	HeaderName = "X-CSRF-Token"
+	// FormField is the hidden form field HTML forms carry the token in.
+	FormField = "csrf_token"
+
+	tokenLength = 32
+)
+
+type ctxKey struct{}
+
+// Token returns the CSRF token of the request, to be rendered into pages.
+func Token(r *http.Request) string {
+	token, _ := r.Context().Value(ctxKey{}).(string)
+	return token
+}
+
+// New returns a double-submit cookie CSRF middleware.
+//
+// Every response carries a csrf_token cookie. State-changing requests must echo its
+// value in the X-CSRF-Token header or the csrf_token form field, otherwise they get 403.
+func New(pages *views.Views, log *zap.Logger) func(next http.Handler) http.Handler {
+	log = log.With(
+		zap.String("component", "middleware/csrf"),
+	)
+
+	tmpl, err := pages.Page("forbidden.html")
+	if err != nil {
+		log.Fatal("failed to parse forbidden template", zap.Error(err))
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			var token string
+
+			if cookie, err := r.Cookie(cookies.CSRFCookieName); err == nil && len(cookie.Value) > 0 {
+				token = cookie.Value
+			} else {
+				token, err = generateToken()
+				if err != nil {
+					log.Error("failed to generate csrf token", zap.Error(err))
+					http.Error(w, i18n.FromContext(r.Context()).T("error.internal"), http.StatusInternalServerError)
+					return
+				}
+				cookies.SetCSRFCookie(w, token)
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, token))
+
+			if isSafe(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sent := r.Header.Get(HeaderName)
+			if sent == "" {
+				sent = r.PostFormValue(FormField)
+			}
+
+			if sent == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				log.Warn("csrf token mismatch",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.String("remote_addr", r.RemoteAddr),
+				)
+				forbidden(w, r, tmpl, log)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func forbidden(w http.ResponseWriter, r *http.Request, tmpl *views.Page, log *zap.Logger) {
+	loc := i18n.FromContext(r.Context())
+	message := loc.T("csrf.error.message")
+
+	if r.Header.Get("X-Requested-With") == "XMLHttpRequest" || r.Header.Get("Content-Type") == "application/json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   message,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+
+	data := struct {
+		Title   string
+		Message string
+		Locale  *i18n.Localizer
+	}{
+		Title:   loc.T("error.forbidden"),
+		Message: message,
+		Locale:  loc,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Error("failed to execute forbidden template", zap.Error(err))
+	}
+}
+
+func isSafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+
+	return false
+}
+
+func generateToken() (string, error) {
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
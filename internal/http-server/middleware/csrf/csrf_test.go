@@ -0,0 +1,130 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"golang.org/x/text/language"
+
+	"shop/internal/http-server/cookies"
+	"shop/internal/http-server/views"
+	"shop/internal/i18n"
+	"shop/web"
+)
+
+// newTestHandler returns the middleware in front of a handler answering 200 with the token
+// the middleware put in the context.
+func newTestHandler(t *testing.T) http.Handler {
+	t.Helper()
+
+	pages, err := views.New(zap.NewNop(), web.FS, false)
+	if err != nil {
+		t.Fatalf("views.New: %v", err)
+	}
+	bundle, err := i18n.New(web.FS, "en")
+	if err != nil {
+		t.Fatalf("i18n.New: %v", err)
+	}
+	loc := bundle.Localizer(language.English, "/")
+
+	protect := New(pages, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(Token(r)))
+	}))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		protect.ServeHTTP(w, r.WithContext(i18n.WithLocalizer(r.Context(), loc)))
+	})
+}
+
+func TestTokenIsIssuedOnGet(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestHandler(t).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == cookies.CSRFCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil || cookie.Value == "" {
+		t.Fatal("no csrf cookie issued")
+	}
+	if body := w.Body.String(); body != cookie.Value {
+		t.Errorf("token for the page = %q, want the cookie value %q", body, cookie.Value)
+	}
+
+	// A visitor with a token keeps it.
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: cookies.CSRFCookieName, Value: "kept"})
+	w = httptest.NewRecorder()
+	newTestHandler(t).ServeHTTP(w, r)
+
+	if len(w.Result().Cookies()) != 0 || w.Body.String() != "kept" {
+		t.Errorf("cookies %v, token %q; want the token kept", w.Result().Cookies(), w.Body.String())
+	}
+}
+
+func TestStateChangingRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		cookie   string
+		header   string
+		form     string
+		wantCode int
+	}{
+		{name: "form token", method: http.MethodPost, cookie: "token", form: "token", wantCode: http.StatusOK},
+		{name: "header token", method: http.MethodDelete, cookie: "token", header: "token", wantCode: http.StatusOK},
+		{name: "no cookie", method: http.MethodPost, form: "token", wantCode: http.StatusForbidden},
+		{name: "no token sent", method: http.MethodPost, cookie: "token", wantCode: http.StatusForbidden},
+		{name: "form token mismatch", method: http.MethodPost, cookie: "token", form: "other", wantCode: http.StatusForbidden},
+		{name: "header token mismatch", method: http.MethodPut, cookie: "token", header: "other",
+			wantCode: http.StatusForbidden},
+		{name: "head without token", method: http.MethodHead, wantCode: http.StatusOK},
+		{name: "options without token", method: http.MethodOptions, wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body *strings.Reader
+			if tt.form != "" {
+				body = strings.NewReader(url.Values{FormField: {tt.form}}.Encode())
+			} else {
+				body = strings.NewReader("")
+			}
+			r := httptest.NewRequest(tt.method, "/cart/add", body)
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: cookies.CSRFCookieName, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set(HeaderName, tt.header)
+			}
+
+			w := httptest.NewRecorder()
+			newTestHandler(t).ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestForbiddenAnswersXHRWithJSON(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/cart/update", nil)
+	r.Header.Set("X-Requested-With", "XMLHttpRequest")
+	w := httptest.NewRecorder()
+	newTestHandler(t).ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("response = %d %s, want a 403 in JSON", w.Code, w.Header().Get("Content-Type"))
+	}
+}
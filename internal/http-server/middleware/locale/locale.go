@@ -0,0 +1,71 @@
+package locale
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"shop/internal/http-server/cookies"
+	"shop/internal/i18n"
+)
+
+// New returns a middleware putting the localizer of the visitor's locale into the request context.
+//
+// The locale is taken from, in order: a URL prefix like /ru/products, which is stripped before
+// routing and remembered in the lang cookie; the lang cookie; the Accept-Language header. Without
+// any of them the default locale of bundle is used. It has to run before routing to strip the prefix.
+func New(bundle *i18n.Bundle) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			tag, rest, ok := fromPath(bundle, r.URL.Path)
+			if ok {
+				cookies.SetLocaleCookie(w, tag.String())
+
+				u := *r.URL
+				u.Path, u.RawPath = rest, ""
+				r = r.Clone(r.Context())
+				r.URL = &u
+			}
+			if !ok {
+				tag, ok = fromCookie(bundle, r)
+			}
+			if !ok {
+				tag = bundle.Match(r.Header.Get("Accept-Language"))
+				w.Header().Add("Vary", "Accept-Language")
+			}
+
+			page := r.URL.Path
+			if r.URL.RawQuery != "" {
+				page += "?" + r.URL.RawQuery
+			}
+
+			ctx := i18n.WithLocalizer(r.Context(), bundle.Localizer(tag, page))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// fromPath returns the locale of the first segment of path and the rest of path, if the
+// segment names a supported locale.
+func fromPath(bundle *i18n.Bundle, path string) (language.Tag, string, bool) {
+	segment, rest, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+
+	tag, ok := bundle.Lookup(segment)
+	if !ok {
+		return language.Und, "", false
+	}
+
+	return tag, "/" + rest, true
+}
+
+func fromCookie(bundle *i18n.Bundle, r *http.Request) (language.Tag, bool) {
+	cookie, err := r.Cookie(cookies.LocaleCookieName)
+	if err != nil {
+		return language.Und, false
+	}
+
+	return bundle.Lookup(cookie.Value)
+}
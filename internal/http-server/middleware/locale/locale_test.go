@@ -0,0 +1,77 @@
+package locale
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"shop/internal/http-server/cookies"
+	"shop/internal/i18n"
+	"shop/web"
+)
+
+func TestLocalePrecedence(t *testing.T) {
+	tests := []struct {
+		name           string
+		defaultLocale  string
+		path           string
+		cookie         string
+		acceptLanguage string
+		wantLang       string
+		wantPath       string
+		wantCookie     string
+	}{
+		{name: "url before cookie and header", defaultLocale: "en", path: "/ru/products", cookie: "en",
+			acceptLanguage: "en", wantLang: "ru", wantPath: "/products", wantCookie: "ru"},
+		{name: "cookie before header", defaultLocale: "en", path: "/products", cookie: "ru",
+			acceptLanguage: "en-US,en;q=0.9", wantLang: "ru", wantPath: "/products"},
+		{name: "header before default", defaultLocale: "en", path: "/products", acceptLanguage: "ru-RU,ru;q=0.9",
+			wantLang: "ru", wantPath: "/products"},
+		{name: "default", defaultLocale: "en", path: "/products", wantLang: "en", wantPath: "/products"},
+		{name: "configured default", defaultLocale: "ru", path: "/products", wantLang: "ru", wantPath: "/products"},
+		{name: "unsupported header", defaultLocale: "ru", path: "/products", acceptLanguage: "fr",
+			wantLang: "ru", wantPath: "/products"},
+		{name: "unsupported cookie", defaultLocale: "en", path: "/products", cookie: "fr", acceptLanguage: "ru",
+			wantLang: "ru", wantPath: "/products"},
+		{name: "unsupported prefix", defaultLocale: "en", path: "/fr/products", cookie: "ru",
+			wantLang: "ru", wantPath: "/fr/products"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle, err := i18n.New(web.FS, tt.defaultLocale)
+			if err != nil {
+				t.Fatalf("i18n.New: %v", err)
+			}
+
+			var gotLang, gotPath string
+			h := New(bundle)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotLang, gotPath = i18n.FromContext(r.Context()).Lang(), r.URL.Path
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: cookies.LocaleCookieName, Value: tt.cookie})
+			}
+			if tt.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if gotLang != tt.wantLang || gotPath != tt.wantPath {
+				t.Errorf("locale %q at %q, want %q at %q", gotLang, gotPath, tt.wantLang, tt.wantPath)
+			}
+
+			var gotCookie string
+			for _, c := range w.Result().Cookies() {
+				if c.Name == cookies.LocaleCookieName {
+					gotCookie = c.Value
+				}
+			}
+			if gotCookie != tt.wantCookie {
+				t.Errorf("locale cookie = %q, want %q", gotCookie, tt.wantCookie)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package realip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// New returns a middleware setting the request's RemoteAddr to the client IP reported by a
+// trusted reverse proxy in X-Forwarded-For or X-Real-IP. proxies are the IPs or CIDR ranges
+// of those proxies; the headers of any other peer are ignored, since clients can send them to
+// pose as any address. Without proxies the middleware does nothing.
+func New(proxies []string) (func(next http.Handler) http.Handler, error) {
+	const op = "realip.New"
+
+	trusted := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		trusted = append(trusted, prefix)
+	}
+
+	isTrusted := func(addr netip.Addr) bool {
+		for _, prefix := range trusted {
+			if prefix.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if peer, err := peerAddr(r.RemoteAddr); err == nil && isTrusted(peer) {
+				if ip, ok := clientIP(r.Header, isTrusted); ok {
+					r.RemoteAddr = ip
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}, nil
+}
+
+// ClientIP returns the IP of the client of r. Behind the middleware of New, that is the
+// address reported by a trusted proxy; otherwise it is the address of the peer.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// clientIP returns the right-most address of X-Forwarded-For not belonging to a trusted
+// proxy, as the ones left of it were added by the client, or else X-Real-IP.
+func clientIP(header http.Header, isTrusted func(netip.Addr) bool) (string, bool) {
+	var hops []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		if !isTrusted(addr) || i == 0 {
+			return addr.Unmap().String(), true
+		}
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String(), true
+	}
+
+	return "", false
+}
+
+func peerAddr(remoteAddr string) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	return netip.ParseAddr(host)
+}
+
+func parsePrefix(proxy string) (netip.Prefix, error) {
+	if strings.Contains(proxy, "/") {
+		return netip.ParsePrefix(proxy)
+	}
+
+	addr, err := netip.ParseAddr(proxy)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,132 @@
+package realip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    []string
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{
+		{
+			name:       "no trusted proxies",
+			remoteAddr: "203.0.113.7:5000",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "203.0.113.7:5000",
+		},
+		{
+			name:       "untrusted peer",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "203.0.113.7:5000",
+			header:     http.Header{"X-Real-Ip": {"198.51.100.1"}},
+			want:       "203.0.113.7:5000",
+		},
+		{
+			name:       "trusted proxy",
+			proxies:    []string{"10.0.0.1"},
+			remoteAddr: "10.0.0.1:5000",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "client supplied hops are skipped",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.1:5000",
+			header:     http.Header{"X-Forwarded-For": {"192.0.2.9, 198.51.100.1, 10.0.0.2"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "x-real-ip",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.1:5000",
+			header:     http.Header{"X-Real-Ip": {"198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "no headers",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.1:5000",
+			want:       "10.0.0.1:5000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw, err := New(tt.proxies)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			var got string
+			h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRejectsInvalidProxy(t *testing.T) {
+	if _, err := New([]string{"not-an-ip"}); err == nil {
+		t.Error("New accepted an invalid proxy")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	mw, err := New([]string{"10.0.0.0/8", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{
+		{name: "peer", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "untrusted peer", remoteAddr: "203.0.113.7:5000",
+			header: http.Header{"X-Forwarded-For": {"198.51.100.1"}}, want: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.1:5000",
+			header: http.Header{"X-Forwarded-For": {"198.51.100.1"}}, want: "198.51.100.1"},
+		{name: "ipv6 peer", remoteAddr: "[2001:db8::7]:5000", want: "2001:db8::7"},
+		{name: "ipv6 client of a trusted proxy", remoteAddr: "[2001:db8::1]:5000",
+			header: http.Header{"X-Real-Ip": {"2001:db8::9"}}, want: "2001:db8::9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIP(r)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package redirect
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Local returns target if it is a path on this site, and "/" otherwise, so redirect
+// parameters can't send users to another site. Targets with backslashes are refused, as
+// browsers read "/\evil.com" like "//evil.com".
+func Local(target string) string {
+	if target == "" || strings.Contains(target, `\`) || strings.HasPrefix(target, "//") {
+		return "/"
+	}
+
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil || !strings.HasPrefix(u.Path, "/") {
+		return "/"
+	}
+
+	return target
+}
@@ -0,0 +1,28 @@
+package redirect
+
+import "testing"
+
+func TestLocal(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{target: "", want: "/"},
+		{target: "/cart", want: "/cart"},
+		{target: "/products?page=2#top", want: "/products?page=2#top"},
+		{target: "//evil.com", want: "/"},
+		{target: `/\evil.com`, want: "/"},
+		{target: `\\evil.com`, want: "/"},
+		{target: "https://evil.com/", want: "/"},
+		{target: "javascript:alert(1)", want: "/"},
+		{target: "/\tevil.com", want: "/"},
+		{target: "cart", want: "/"},
+		{target: "?next=/", want: "/"},
+	}
+
+	for _, tt := range tests {
+		if got := Local(tt.target); got != tt.want {
+			t.Errorf("Local(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
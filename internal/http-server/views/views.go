@@ -0,0 +1,217 @@
+package views
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	templatesDir = "templates"
+	staticDir    = "static"
+
+	// StaticPrefix is the path the static assets are served under.
+	StaticPrefix = "/static/"
+
+	hashLength = 8
+)
+
+// Views renders the pages of the templates directory and serves the static directory of files.
+//
+// A page is a file of templates/pages executed within the layouts and partials, which it fills
+// by defining their blocks. Pages link assets with the asset function, which returns a URL
+// with the content hash in the file name, so assets can be cached for good.
+//
+// With reload set, templates and assets are read from files again on every request, so edits
+// show up without a restart.
+type Views struct {
+	log    *zap.Logger
+	files  fs.FS
+	reload bool
+	assets *assets
+}
+
+// New returns views of files, which holds the templates and static directories.
+func New(log *zap.Logger, files fs.FS, reload bool) (*Views, error) {
+	const op = "views.New"
+
+	a, err := loadAssets(files)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Views{
+		log:    log.With(zap.String("component", "views")),
+		files:  files,
+		reload: reload,
+		assets: a,
+	}, nil
+}
+
+// Page is a parsed page template.
+type Page struct {
+	views *Views
+	name  string
+	tmpl  *template.Template
+}
+
+// Page parses the page of templates/pages named name.
+func (v *Views) Page(name string) (*Page, error) {
+	tmpl, err := v.parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Page{views: v, name: name, tmpl: tmpl}, nil
+}
+
+// Execute renders the page with data into w.
+func (p *Page) Execute(w io.Writer, data any) error {
+	tmpl := p.tmpl
+	if p.views.reload {
+		var err error
+		if tmpl, err = p.views.parse(p.name); err != nil {
+			return err
+		}
+	}
+
+	return tmpl.ExecuteTemplate(w, p.name, data)
+}
+
+func (v *Views) parse(name string) (*template.Template, error) {
+	const op = "views.parse"
+
+	a, err := v.currentAssets()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tmpl, err := template.New(name).
+		Funcs(template.FuncMap{"asset": a.url}).
+		ParseFS(v.files,
+			path.Join(templatesDir, "layouts", "*.html"),
+			path.Join(templatesDir, "partials", "*.html"),
+			path.Join(templatesDir, "pages", name),
+		)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, name, err)
+	}
+
+	return tmpl, nil
+}
+
+func (v *Views) currentAssets() (*assets, error) {
+	if !v.reload {
+		return v.assets, nil
+	}
+
+	return loadAssets(v.files)
+}
+
+// Static returns a handler serving the static assets under StaticPrefix. Hashed URLs are
+// cached for a year, plain ones are revalidated on every use.
+func (v *Views) Static() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a, err := v.currentAssets()
+		if err != nil {
+			v.log.Error("failed to load assets", zap.Error(err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		requested := strings.TrimPrefix(r.URL.Path, StaticPrefix)
+
+		name, hashed := a.byHashed[requested]
+		if !hashed {
+			name = requested
+		}
+		hash, ok := a.hashes[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		content, err := fs.ReadFile(v.files, path.Join(staticDir, name))
+		if err != nil {
+			v.log.Error("failed to read asset", zap.String("name", name), zap.Error(err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if hashed && !v.reload {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		w.Header().Set("ETag", `"`+hash+`"`)
+
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+	})
+}
+
+// assets maps the files of the static directory to their content hashes.
+type assets struct {
+	hashes   map[string]string
+	byHashed map[string]string
+}
+
+func loadAssets(files fs.FS) (*assets, error) {
+	const op = "views.loadAssets"
+
+	a := &assets{
+		hashes:   make(map[string]string),
+		byHashed: make(map[string]string),
+	}
+
+	err := fs.WalkDir(files, staticDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		content, err := fs.ReadFile(files, p)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(content)
+		name := strings.TrimPrefix(p, staticDir+"/")
+
+		a.hashes[name] = hex.EncodeToString(sum[:])[:hashLength]
+		a.byHashed[hashedName(name, a.hashes[name])] = name
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return a, nil
+}
+
+// url returns the URL of the asset name, with its hash in the file name.
+func (a *assets) url(name string) (string, error) {
+	hash, ok := a.hashes[name]
+	if !ok {
+		return "", errors.New("unknown asset " + name)
+	}
+
+	return StaticPrefix + hashedName(name, hash), nil
+}
+
+// hashedName puts hash before the extension of name: css/shop.css becomes css/shop.1a2b3c4d.css.
+func hashedName(name, hash string) string {
+	ext := path.Ext(name)
+
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
@@ -0,0 +1,160 @@
+package views
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"go.uber.org/zap"
+)
+
+const css = "body { color: black; }"
+
+func testFiles() fstest.MapFS {
+	return fstest.MapFS{
+		"static/css/shop.css":         {Data: []byte(css)},
+		"static/js/cart.js":           {Data: []byte("console.log('cart');")},
+		"templates/layouts/base.html": {Data: []byte(`{{define "base"}}<link href="{{asset "css/shop.css"}}">{{block "content" .}}{{end}}{{end}}`)},
+		"templates/partials/nav.html": {Data: []byte(`{{define "nav"}}<nav></nav>{{end}}`)},
+		"templates/pages/home.html":   {Data: []byte(`{{template "base" .}}{{define "content"}}Hello {{.}}{{end}}`)},
+	}
+}
+
+func newTestViews(t *testing.T, files fstest.MapFS, reload bool) *Views {
+	t.Helper()
+
+	v, err := New(zap.NewNop(), files, reload)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return v
+}
+
+func render(t *testing.T, page *Page, data any) string {
+	t.Helper()
+
+	var b strings.Builder
+	if err := page.Execute(&b, data); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	return b.String()
+}
+
+func TestAssetURLsAreStable(t *testing.T) {
+	sum := sha256.Sum256([]byte(css))
+	want := StaticPrefix + "css/shop." + hex.EncodeToString(sum[:])[:hashLength] + ".css"
+
+	first, err := newTestViews(t, testFiles(), false).assets.url("css/shop.css")
+	if err != nil {
+		t.Fatalf("url: %v", err)
+	}
+	second, err := newTestViews(t, testFiles(), false).assets.url("css/shop.css")
+	if err != nil {
+		t.Fatalf("url: %v", err)
+	}
+	if first != want || second != want {
+		t.Errorf("asset URLs %q and %q, want %q for both", first, second, want)
+	}
+
+	files := testFiles()
+	files["static/css/shop.css"] = &fstest.MapFile{Data: []byte("body { color: red; }")}
+	changed, err := newTestViews(t, files, false).assets.url("css/shop.css")
+	if err != nil {
+		t.Fatalf("url: %v", err)
+	}
+	if changed == want {
+		t.Errorf("asset URL %q did not change with the content", changed)
+	}
+
+	if _, err := newTestViews(t, testFiles(), false).assets.url("css/missing.css"); err == nil {
+		t.Error("url of an unknown asset succeeded")
+	}
+}
+
+func TestStatic(t *testing.T) {
+	v := newTestViews(t, testFiles(), false)
+	hashed, err := v.assets.url("css/shop.css")
+	if err != nil {
+		t.Fatalf("url: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		reload       bool
+		wantCode     int
+		wantCache    string
+		wantResponse string
+	}{
+		{name: "hashed", path: hashed, wantCode: http.StatusOK,
+			wantCache: "public, max-age=31536000, immutable", wantResponse: css},
+		{name: "plain", path: StaticPrefix + "css/shop.css", wantCode: http.StatusOK, wantCache: "no-cache",
+			wantResponse: css},
+		{name: "hashed in dev", path: hashed, reload: true, wantCode: http.StatusOK, wantCache: "no-cache",
+			wantResponse: css},
+		{name: "unknown hash", path: StaticPrefix + "css/shop.00000000.css", wantCode: http.StatusNotFound},
+		{name: "hash of another asset", path: strings.Replace(hashed, "css/shop", "js/cart", 1),
+			wantCode: http.StatusNotFound},
+		{name: "unknown asset", path: StaticPrefix + "css/missing.css", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newTestViews(t, testFiles(), tt.reload).Static().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				if got := w.Header().Get("Cache-Control"); strings.Contains(got, "immutable") {
+					t.Errorf("Cache-Control = %q on a missing asset", got)
+				}
+				return
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCache)
+			}
+			if got := w.Body.String(); got != tt.wantResponse {
+				t.Errorf("body = %q, want %q", got, tt.wantResponse)
+			}
+		})
+	}
+}
+
+func TestReloadPicksUpChangedTemplates(t *testing.T) {
+	tests := []struct {
+		name   string
+		reload bool
+		want   string
+	}{
+		{name: "dev", reload: true, want: "Welcome back world"},
+		{name: "production", reload: false, want: "Hello world"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := testFiles()
+			page, err := newTestViews(t, files, tt.reload).Page("home.html")
+			if err != nil {
+				t.Fatalf("Page: %v", err)
+			}
+			if got := render(t, page, "world"); !strings.Contains(got, "Hello world") {
+				t.Fatalf("page = %q, want Hello world", got)
+			}
+
+			files["templates/pages/home.html"] = &fstest.MapFile{
+				Data: []byte(`{{template "base" .}}{{define "content"}}Welcome back {{.}}{{end}}`),
+			}
+			if got := render(t, page, "world"); !strings.Contains(got, tt.want) {
+				t.Errorf("page after the edit = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+// Package i18n translates the user-facing strings of the shop and formats numbers,
+// prices and dates for a locale.
+//
+// Messages live in one YAML catalog per locale, locales/<tag>.yaml, mapping message keys
+// to fmt-style formats. A message with plural forms maps the CLDR plural categories
+// (zero, one, two, few, many, other) to formats instead; the form is chosen by the first
+// argument, so formats use indexed verbs like %[2]d to print the others first. Keys
+// missing from a catalog fall back to the catalog of the default locale.
+package i18n
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+	"golang.org/x/text/number"
+	"gopkg.in/yaml.v3"
+)
+
+const localesDir = "locales"
+
+// Keys of the catalog entries the formatting helpers are built on.
+const (
+	keyCurrency = "format.currency"
+	keyDate     = "format.date"
+	keyDateTime = "format.datetime"
+)
+
+// pluralForms are the CLDR plural categories in the order they are matched.
+var pluralForms = []string{"zero", "one", "two", "few", "many", "other"}
+
+// Bundle holds the catalogs of all supported locales.
+type Bundle struct {
+	tags    []language.Tag
+	matcher language.Matcher
+	catalog *catalog.Builder
+	strings map[language.Tag]map[string]string
+}
+
+// New loads the catalogs of files' locales directory. fallback is the locale used when
+// none of the preferred ones is supported; its catalog must exist.
+func New(files fs.FS, fallback string) (*Bundle, error) {
+	const op = "i18n.New"
+
+	names, err := fs.Glob(files, path.Join(localesDir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	catalogs := make(map[language.Tag]map[string]any, len(names))
+	var tags []language.Tag
+	for _, name := range names {
+		tag, err := language.Parse(strings.TrimSuffix(path.Base(name), ".yaml"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, name, err)
+		}
+
+		content, err := fs.ReadFile(files, name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		messages := make(map[string]any)
+		if err := yaml.Unmarshal(content, &messages); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, name, err)
+		}
+
+		catalogs[tag] = messages
+		tags = append(tags, tag)
+	}
+
+	fallbackTag, err := language.Parse(fallback)
+	if err != nil {
+		return nil, fmt.Errorf("%s: default locale: %w", op, err)
+	}
+	defaults, ok := catalogs[fallbackTag]
+	if !ok {
+		return nil, fmt.Errorf("%s: no catalog for default locale %s", op, fallback)
+	}
+
+	// The default locale comes first, Match falls back to it.
+	ordered := []language.Tag{fallbackTag}
+	for _, tag := range tags {
+		if tag != fallbackTag {
+			ordered = append(ordered, tag)
+		}
+	}
+
+	b := &Bundle{
+		tags:    ordered,
+		matcher: language.NewMatcher(ordered),
+		catalog: catalog.NewBuilder(),
+		strings: make(map[language.Tag]map[string]string, len(ordered)),
+	}
+
+	for _, tag := range ordered {
+		b.strings[tag] = make(map[string]string)
+
+		for key := range defaults {
+			value, ok := catalogs[tag][key]
+			if !ok {
+				value = defaults[key]
+			}
+			if err := b.set(tag, key, value); err != nil {
+				return nil, fmt.Errorf("%s: %s: %s: %w", op, tag, key, err)
+			}
+		}
+		for key := range catalogs[tag] {
+			if _, ok := defaults[key]; !ok {
+				return nil, fmt.Errorf("%s: %s: %s is missing from the default catalog", op, tag, key)
+			}
+		}
+	}
+
+	return b, nil
+}
+
+func (b *Bundle) set(tag language.Tag, key string, value any) error {
+	switch v := value.(type) {
+	case string:
+		b.strings[tag][key] = v
+		return b.catalog.SetString(tag, key, v)
+	case map[string]any:
+		var selectors []any
+		for _, form := range pluralForms {
+			format, ok := v[form]
+			if !ok {
+				continue
+			}
+			if _, ok := format.(string); !ok {
+				return fmt.Errorf("plural form %s is not a string", form)
+			}
+			selectors = append(selectors, form, format)
+		}
+		if len(selectors) != 2*len(v) {
+			return errors.New("unknown plural forms")
+		}
+		if _, ok := v["other"]; !ok {
+			return errors.New("plural form other is required")
+		}
+		return b.catalog.Set(tag, key, plural.Selectf(1, "%d", selectors...))
+	default:
+		return fmt.Errorf("unsupported message of type %T", value)
+	}
+}
+
+// Locales returns the supported locales, the default one first.
+func (b *Bundle) Locales() []language.Tag {
+	return b.tags
+}
+
+// Lookup returns the supported locale named code, like "en" or "ru".
+func (b *Bundle) Lookup(code string) (language.Tag, bool) {
+	tag, err := language.Parse(code)
+	if err != nil {
+		return language.Und, false
+	}
+	for _, t := range b.tags {
+		if t == tag {
+			return t, true
+		}
+	}
+
+	return language.Und, false
+}
+
+// Match returns the supported locale closest to an Accept-Language header value,
+// or the default one.
+func (b *Bundle) Match(acceptLanguage string) language.Tag {
+	preferred, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(preferred) == 0 {
+		return b.tags[0]
+	}
+
+	// Without a match the matcher picks English rather than its first tag.
+	_, i, confidence := b.matcher.Match(preferred...)
+	if confidence == language.No {
+		return b.tags[0]
+	}
+
+	return b.tags[i]
+}
+
+// Localizer returns the localizer of tag for the page at path. path, without a locale
+// prefix, is what links to the translations of the page point to.
+func (b *Bundle) Localizer(tag language.Tag, path string) *Localizer {
+	return &Localizer{
+		bundle:  b,
+		tag:     tag,
+		path:    path,
+		printer: message.NewPrinter(tag, message.Catalog(b.catalog)),
+	}
+}
+
+// Localizer translates messages and formats values for one locale.
+type Localizer struct {
+	bundle  *Bundle
+	tag     language.Tag
+	path    string
+	printer *message.Printer
+}
+
+// Lang returns the BCP 47 tag of the locale, for the lang attribute of pages.
+func (l *Localizer) Lang() string {
+	return l.tag.String()
+}
+
+// T returns the message of key formatted with args. Unknown keys are returned as they are.
+func (l *Localizer) T(key string, args ...any) string {
+	return l.printer.Sprintf(key, args...)
+}
+
+// Number formats v with the digit grouping and decimal separator of the locale.
+func (l *Localizer) Number(v any) string {
+	return l.printer.Sprint(number.Decimal(v))
+}
+
+// Currency formats a price with two decimals and the currency sign where the locale puts it.
+func (l *Localizer) Currency(amount float64) string {
+	return l.printer.Sprintf(keyCurrency, number.Decimal(amount, number.Scale(2)))
+}
+
+// Date formats the date of t.
+func (l *Localizer) Date(t time.Time) string {
+	return t.Format(l.T(keyDate))
+}
+
+// DateTime formats the date and time of t.
+func (l *Localizer) DateTime(t time.Time) string {
+	return t.Format(l.T(keyDateTime))
+}
+
+// Messages returns the plain messages whose keys start with prefix and a dot, keyed
+// by the rest of the key, for scripts to show.
+func (l *Localizer) Messages(prefix string) map[string]string {
+	prefix += "."
+
+	messages := make(map[string]string)
+	for key, value := range l.bundle.strings[l.tag] {
+		if rest, ok := strings.CutPrefix(key, prefix); ok {
+			messages[rest] = value
+		}
+	}
+
+	return messages
+}
+
+// Alternate is a link to the page in one of the supported locales.
+type Alternate struct {
+	Lang    string
+	Name    string
+	URL     string
+	Current bool
+}
+
+// Alternates returns links to the page in every supported locale.
+func (l *Localizer) Alternates() []Alternate {
+	alternates := make([]Alternate, 0, len(l.bundle.tags))
+	for _, tag := range l.bundle.tags {
+		alternates = append(alternates, Alternate{
+			Lang:    tag.String(),
+			Name:    cases.Title(tag).String(display.Self.Name(tag)),
+			URL:     "/" + tag.String() + l.path,
+			Current: tag == l.tag,
+		})
+	}
+
+	return alternates
+}
+
+type ctxKey struct{}
+
+// WithLocalizer returns a copy of ctx carrying l.
+func WithLocalizer(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the localizer of the request, set by the locale middleware.
+func FromContext(ctx context.Context) *Localizer {
+	l, _ := ctx.Value(ctxKey{}).(*Localizer)
+	return l
+}
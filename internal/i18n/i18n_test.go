@@ -0,0 +1,96 @@
+package i18n
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+
+	"shop/web"
+)
+
+// defaultLocale is the default of i18n.default_locale in the config.
+const defaultLocale = "en"
+
+func TestMissingKeyFallsBackToTheDefaultLocale(t *testing.T) {
+	files := fstest.MapFS{
+		"locales/en.yaml": {Data: []byte("greeting: \"Hello, %s\"\nfarewell: \"Goodbye\"\n")},
+		"locales/ru.yaml": {Data: []byte("greeting: \"Привет, %s\"\n")},
+	}
+	bundle, err := New(files, "en")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	loc := bundle.Localizer(language.Russian, "/")
+
+	tests := []struct {
+		key  string
+		args []any
+		want string
+	}{
+		{key: "greeting", args: []any{"Анна"}, want: "Привет, Анна"},
+		{key: "farewell", want: "Goodbye"},
+		{key: "unknown.key", want: "unknown.key"},
+	}
+
+	for _, tt := range tests {
+		if got := loc.T(tt.key, tt.args...); got != tt.want {
+			t.Errorf("T(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestNewRejectsKeysMissingFromTheDefaultLocale(t *testing.T) {
+	files := fstest.MapFS{
+		"locales/en.yaml": {Data: []byte("greeting: \"Hello\"\n")},
+		"locales/ru.yaml": {Data: []byte("greeting: \"Привет\"\nfarewell: \"Пока\"\n")},
+	}
+
+	if _, err := New(files, "en"); err == nil || !strings.Contains(err.Error(), "farewell") {
+		t.Errorf("New error = %v, want the key missing from the default catalog", err)
+	}
+	if _, err := New(files, "de"); err == nil {
+		t.Error("New succeeded without a catalog of the default locale")
+	}
+}
+
+// TestShippedLocalesAreComplete guards against keys that are only translated in the default
+// locale and would silently show up in it on the pages of the other locales.
+func TestShippedLocalesAreComplete(t *testing.T) {
+	catalogs := make(map[string]map[string]any)
+	names, err := fs.Glob(web.FS, path.Join(localesDir, "*.yaml"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	for _, name := range names {
+		content, err := fs.ReadFile(web.FS, name)
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		messages := make(map[string]any)
+		if err := yaml.Unmarshal(content, &messages); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		catalogs[strings.TrimSuffix(path.Base(name), ".yaml")] = messages
+	}
+
+	defaults, ok := catalogs[defaultLocale]
+	if !ok || len(catalogs) < 2 {
+		t.Fatalf("locales = %v, want the default locale %s and others", names, defaultLocale)
+	}
+	for locale, messages := range catalogs {
+		for key := range defaults {
+			if _, ok := messages[key]; !ok {
+				t.Errorf("%s: %s is missing", locale, key)
+			}
+		}
+	}
+
+	if _, err := New(web.FS, defaultLocale); err != nil {
+		t.Errorf("New: %v", err)
+	}
+}
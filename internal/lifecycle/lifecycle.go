@@ -0,0 +1,163 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Manager runs the servers and workers of a process and shuts them down on SIGINT or SIGTERM,
+// or once one of them fails. Shutdown hooks run one at a time in reverse order of registration,
+// like deferred calls, so a component is stopped before the ones it was built on.
+type Manager struct {
+	log     *zap.Logger
+	timeout time.Duration
+
+	mu    sync.Mutex
+	hooks []hook
+
+	failed chan error
+}
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// New returns a manager giving the shutdown hooks timeout in total to finish.
+func New(log *zap.Logger, timeout time.Duration) *Manager {
+	return &Manager{
+		log:     log.With(zap.String("component", "lifecycle")),
+		timeout: timeout,
+		failed:  make(chan error, 1),
+	}
+}
+
+// OnShutdown registers fn to be called on shutdown. fn should give up once ctx is done.
+func (m *Manager) OnShutdown(name string, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hooks = append(m.hooks, hook{name: name, fn: fn})
+}
+
+// OnClose registers close to be called on shutdown.
+func (m *Manager) OnClose(name string, close func() error) {
+	m.OnShutdown(name, func(context.Context) error {
+		return close()
+	})
+}
+
+// Serve starts serve in a goroutine and registers shutdown to stop it. An error returned
+// by serve shuts the process down, so serve must return nil once stopped by shutdown.
+func (m *Manager) Serve(name string, serve func() error, shutdown func(ctx context.Context) error) {
+	m.OnShutdown(name, shutdown)
+
+	go func() {
+		if err := serve(); err != nil {
+			m.fail(fmt.Errorf("%s: %w", name, err))
+		}
+	}()
+}
+
+// Go starts run in a goroutine. On shutdown its context is canceled and the manager
+// waits for it to return.
+func (m *Manager) Go(name string, run func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	m.OnShutdown(name, func(shutdownCtx context.Context) error {
+		cancel()
+
+		select {
+		case <-done:
+			return nil
+		case <-shutdownCtx.Done():
+			return shutdownCtx.Err()
+		}
+	})
+
+	go func() {
+		defer close(done)
+		run(ctx)
+	}()
+}
+
+// Wait blocks until the process is signaled to stop or a server fails, then runs the
+// shutdown hooks. It returns the error of the failed server, if any, joined with those
+// of the hooks.
+func (m *Manager) Wait() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	var cause error
+	select {
+	case sign := <-signals:
+		m.log.Info("shutting down", zap.String("signal", sign.String()))
+	case cause = <-m.failed:
+		m.log.Error("shutting down after failure", zap.Error(cause))
+	}
+
+	// A second signal kills the process instead of waiting for the drain.
+	signal.Stop(signals)
+
+	return errors.Join(cause, m.shutdown())
+}
+
+// shutdown runs the hooks in reverse order within the timeout. A hook still running
+// when the timeout expires is abandoned so the rest still get their turn: they are called
+// with the expired context and reported as timed out unless they return at once.
+func (m *Manager) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	m.mu.Lock()
+	hooks := m.hooks
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		log := m.log.With(zap.String("hook", h.name))
+		start := time.Now()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- h.fn(ctx)
+		}()
+
+		var err error
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			select {
+			case err = <-done:
+			default:
+				err = fmt.Errorf("timed out: %w", ctx.Err())
+			}
+		}
+
+		if err != nil {
+			log.Error("shutdown hook failed", zap.Duration("took", time.Since(start)), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+		log.Info("shutdown hook done", zap.Duration("took", time.Since(start)))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *Manager) fail(err error) {
+	select {
+	case m.failed <- err:
+	default:
+	}
+}
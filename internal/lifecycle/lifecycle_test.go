@@ -0,0 +1,134 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// recorder records the names of the components in the order they are stopped.
+type recorder struct {
+	mu      sync.Mutex
+	stopped []string
+}
+
+func (r *recorder) stop(name string, err error) func(ctx context.Context) error {
+	return func(context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.stopped = append(r.stopped, name)
+		return err
+	}
+}
+
+func (r *recorder) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.stopped)
+}
+
+func TestShutdownRunsHooksInReverseOrder(t *testing.T) {
+	m := New(zap.NewNop(), time.Second)
+	rec := &recorder{}
+
+	m.OnShutdown("storage", rec.stop("storage", nil))
+	m.OnClose("events", func() error { return rec.stop("events", nil)(context.Background()) })
+	m.Go("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		_ = rec.stop("worker", nil)(ctx)
+	})
+	m.Serve("http", func() error { return nil }, rec.stop("http", nil))
+
+	if err := m.shutdown(); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if got, want := rec.names(), []string{"http", "worker", "events", "storage"}; !slices.Equal(got, want) {
+		t.Errorf("stopped %v, want %v", got, want)
+	}
+}
+
+func TestShutdownAbandonsHooksPastTheTimeout(t *testing.T) {
+	m := New(zap.NewNop(), 50*time.Millisecond)
+	rec := &recorder{}
+
+	m.OnShutdown("storage", rec.stop("storage", nil))
+	// The server ignores the context and never returns.
+	m.OnShutdown("http", func(context.Context) error {
+		select {}
+	})
+
+	start := time.Now()
+	err := m.shutdown()
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("shutdown took %v with a timeout of 50ms", took)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "http: timed out") {
+		t.Errorf("shutdown error = %v, want the http hook timed out", err)
+	}
+	// The hooks after the one that timed out are still called, with the expired context.
+	deadline := time.Now().Add(time.Second)
+	for len(rec.names()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := rec.names(); !slices.Equal(got, []string{"storage"}) {
+		t.Errorf("stopped %v, want the storage still stopped after the timeout", got)
+	}
+}
+
+func TestShutdownJoinsHookErrors(t *testing.T) {
+	m := New(zap.NewNop(), time.Second)
+	rec := &recorder{}
+	errStorage, errHTTP := errors.New("database is locked"), errors.New("listener closed")
+
+	m.OnShutdown("storage", rec.stop("storage", errStorage))
+	m.OnShutdown("events", rec.stop("events", nil))
+	m.OnShutdown("http", rec.stop("http", errHTTP))
+
+	err := m.shutdown()
+	if !errors.Is(err, errStorage) || !errors.Is(err, errHTTP) {
+		t.Errorf("shutdown error = %v, want both hook errors", err)
+	}
+	if got, want := rec.names(), []string{"http", "events", "storage"}; !slices.Equal(got, want) {
+		t.Errorf("stopped %v, want every hook run despite the errors: %v", got, want)
+	}
+}
+
+func TestWaitShutsDownWhenAServerFails(t *testing.T) {
+	m := New(zap.NewNop(), time.Second)
+	rec := &recorder{}
+	errServe, errStop := errors.New("address already in use"), errors.New("not started")
+
+	m.OnShutdown("storage", rec.stop("storage", nil))
+	m.Serve("http", func() error { return errServe }, rec.stop("http", errStop))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, errServe) || !errors.Is(err, errStop) {
+			t.Errorf("Wait error = %v, want the serve error joined with the hook error", err)
+		}
+		if !strings.Contains(err.Error(), "http: address already in use") {
+			t.Errorf("Wait error = %v, want the serve error named after the server", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return after the server failed")
+	}
+
+	if got, want := rec.names(), []string{"http", "storage"}; !slices.Equal(got, want) {
+		t.Errorf("stopped %v, want %v", got, want)
+	}
+}
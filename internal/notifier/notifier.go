@@ -0,0 +1,183 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"path"
+	"strconv"
+	texttemplate "text/template"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/events"
+	"shop/web"
+)
+
+// Group is the consumer group of the notifier subscriptions.
+const Group = "notifier"
+
+// Categories of emails a user can opt out of; security emails have none.
+const (
+	categoryAccount  = "account"
+	categoryOrders   = "orders"
+	categorySecurity = ""
+)
+
+type Storage interface {
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+	NotificationPreferences(ctx context.Context, userID int64) (models.NotificationPreferences, error)
+}
+
+// Notifier turns domain events into emails rendered from the templates in
+// web/templates/emails: <name>.html for the HTML body and <name>.txt for the text body,
+// which also defines the "subject" template.
+type Notifier struct {
+	log       *zap.Logger
+	sender    EmailSender
+	storage   Storage
+	baseURL   string
+	templates map[string]emailTemplate
+}
+
+type emailTemplate struct {
+	html *template.Template
+	text *texttemplate.Template
+}
+
+// TemplateData is what email templates are executed with.
+type TemplateData struct {
+	Email   string
+	BaseURL string
+	Event   any
+}
+
+func New(log *zap.Logger, sender EmailSender, storage Storage, baseURL string) *Notifier {
+	const dir = "templates/emails"
+
+	n := &Notifier{
+		log:       log.With(zap.String("component", "notifier")),
+		sender:    sender,
+		storage:   storage,
+		baseURL:   baseURL,
+		templates: make(map[string]emailTemplate),
+	}
+
+	for _, name := range []string{"welcome", "order_placed", "password_reset"} {
+		html, err := template.ParseFS(web.FS, path.Join(dir, name+".html"), path.Join(dir, "layout.html"))
+		if err != nil {
+			log.Fatal("failed to parse email template", zap.String("template", name), zap.Error(err))
+		}
+
+		text, err := texttemplate.ParseFS(web.FS, path.Join(dir, name+".txt"))
+		if err != nil {
+			log.Fatal("failed to parse email template", zap.String("template", name), zap.Error(err))
+		}
+
+		// The HTML title reuses the subject of the text template.
+		if _, err := html.AddParseTree("subject", text.Lookup("subject").Tree); err != nil {
+			log.Fatal("failed to add email subject", zap.String("template", name), zap.Error(err))
+		}
+
+		n.templates[name] = emailTemplate{html: html, text: text}
+	}
+
+	return n
+}
+
+// Subscribe starts handling the events that send emails.
+func (n *Notifier) Subscribe(ctx context.Context, sub events.Subscriber) error {
+	const op = "notifier.Subscribe"
+
+	if err := events.Subscribe(ctx, sub, events.TopicUserRegistered, Group, n.UserRegistered); err != nil {
+		return fmt.Errorf("%s, %w", op, err)
+	}
+	if err := events.Subscribe(ctx, sub, events.TopicOrderPlaced, Group, n.OrderPlaced); err != nil {
+		return fmt.Errorf("%s, %w", op, err)
+	}
+	if err := events.Subscribe(ctx, sub, events.TopicPasswordResetRequested, Group, n.PasswordResetRequested); err != nil {
+		return fmt.Errorf("%s, %w", op, err)
+	}
+
+	return nil
+}
+
+func (n *Notifier) UserRegistered(ctx context.Context, event events.UserRegistered) error {
+	return n.notify(ctx, event.UserID, event.Email, categoryAccount, "welcome", event)
+}
+
+func (n *Notifier) OrderPlaced(ctx context.Context, event events.OrderPlaced) error {
+	user, err := n.storage.UserByID(ctx, event.UserID)
+	if err != nil {
+		return fmt.Errorf("notifier.OrderPlaced: %w", err)
+	}
+
+	return n.notify(ctx, event.UserID, user.Email, categoryOrders, "order_placed", event)
+}
+
+func (n *Notifier) PasswordResetRequested(ctx context.Context, event events.PasswordResetRequested) error {
+	return n.notify(ctx, event.UserID, event.Email, categorySecurity, "password_reset", event)
+}
+
+// notify renders the template and sends it to the user unless the user opted out of the category.
+func (n *Notifier) notify(ctx context.Context, userID int64, to, category, name string, event any) error {
+	const op = "notifier.notify"
+
+	log := n.log.With(
+		zap.Int64("user_id", userID),
+		zap.String("template", name),
+		zap.String("trace_id", events.TraceIDFromContext(ctx)),
+	)
+
+	if category != categorySecurity {
+		prefs, err := n.storage.NotificationPreferences(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if (category == categoryAccount && !prefs.Account) || (category == categoryOrders && !prefs.Orders) {
+			log.Info("email skipped by user preferences")
+			return nil
+		}
+	}
+
+	email, err := n.render(name, to, TemplateData{Email: to, BaseURL: n.baseURL, Event: event})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := n.sender.Send(ctx, email); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email sent")
+
+	return nil
+}
+
+func (n *Notifier) render(name, to string, data TemplateData) (Email, error) {
+	tmpl, ok := n.templates[name]
+	if !ok {
+		return Email{}, fmt.Errorf("unknown email template %s", strconv.Quote(name))
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Email{}, fmt.Errorf("failed to render subject of %s: %w", name, err)
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return Email{}, fmt.Errorf("failed to render text of %s: %w", name, err)
+	}
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return Email{}, fmt.Errorf("failed to render html of %s: %w", name, err)
+	}
+
+	return Email{
+		To:      to,
+		Subject: subject.String(),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,148 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	texttemplate "text/template"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/events"
+	"shop/internal/storage"
+)
+
+// fakeStorage knows user 7, with the default preferences unless prefs is set.
+type fakeStorage struct {
+	prefs *models.NotificationPreferences
+}
+
+func (fakeStorage) UserByID(_ context.Context, userID int64) (models.User, error) {
+	if userID != 7 {
+		return models.User{}, storage.ErrUserNotFound
+	}
+
+	return models.User{ID: 7, Email: "user@example.com"}, nil
+}
+
+func (s fakeStorage) NotificationPreferences(_ context.Context, userID int64) (models.NotificationPreferences, error) {
+	if s.prefs != nil {
+		return *s.prefs, nil
+	}
+
+	return models.DefaultNotificationPreferences(userID), nil
+}
+
+// fakeSubscriber keeps the handlers of the subscribed topics.
+type fakeSubscriber struct {
+	handlers map[string]events.Handler
+}
+
+func (s *fakeSubscriber) Subscribe(_ context.Context, topic, _ string, handler events.Handler) error {
+	s.handlers[topic] = handler
+	return nil
+}
+
+var orderPlaced = events.OrderPlaced{
+	OrderID: 42,
+	UserID:  7,
+	Items:   []events.OrderPlacedItem{{ProductID: 1, Quantity: 2, Price: 5}},
+	Total:   12.5,
+}
+
+func TestPreferencesSuppressEmails(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefs    *models.NotificationPreferences
+		send     func(ctx context.Context, n *Notifier) error
+		wantTo   string
+		wantSkip bool
+	}{
+		{name: "welcome", send: func(ctx context.Context, n *Notifier) error {
+			return n.UserRegistered(ctx, events.UserRegistered{UserID: 7, Email: "new@example.com"})
+		}, wantTo: "new@example.com"},
+		{name: "welcome opted out", prefs: &models.NotificationPreferences{UserID: 7, Orders: true},
+			send: func(ctx context.Context, n *Notifier) error {
+				return n.UserRegistered(ctx, events.UserRegistered{UserID: 7, Email: "new@example.com"})
+			}, wantSkip: true},
+		{name: "order", send: func(ctx context.Context, n *Notifier) error {
+			return n.OrderPlaced(ctx, orderPlaced)
+		}, wantTo: "user@example.com"},
+		{name: "order opted out", prefs: &models.NotificationPreferences{UserID: 7, Account: true},
+			send: func(ctx context.Context, n *Notifier) error {
+				return n.OrderPlaced(ctx, orderPlaced)
+			}, wantSkip: true},
+		{name: "password reset ignores preferences", prefs: &models.NotificationPreferences{UserID: 7},
+			send: func(ctx context.Context, n *Notifier) error {
+				return n.PasswordResetRequested(ctx, events.PasswordResetRequested{UserID: 7, Email: "user@example.com"})
+			}, wantTo: "user@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := NewMemorySender()
+			n := New(zap.NewNop(), sender, fakeStorage{prefs: tt.prefs}, "https://shop.example")
+
+			if err := tt.send(context.Background(), n); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+
+			sent := sender.Sent()
+			if tt.wantSkip {
+				if len(sent) != 0 {
+					t.Errorf("sent %d emails to a user who opted out", len(sent))
+				}
+				return
+			}
+			if len(sent) != 1 || sent[0].To != tt.wantTo {
+				t.Fatalf("sent %+v, want one email to %s", sent, tt.wantTo)
+			}
+			if !strings.Contains(sent[0].Text, "https://shop.example") {
+				t.Errorf("text body %q does not link to the shop", sent[0].Text)
+			}
+		})
+	}
+}
+
+func TestRenderErrorReachesTheConsumer(t *testing.T) {
+	sender := NewMemorySender()
+	n := New(zap.NewNop(), sender, fakeStorage{}, "https://shop.example")
+	sub := &fakeSubscriber{handlers: make(map[string]events.Handler)}
+	if err := n.Subscribe(context.Background(), sub); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	// A template referring to a field the event does not have fails when it is executed.
+	tmpl := n.templates["order_placed"]
+	tmpl.text = texttemplate.Must(texttemplate.New("order_placed").
+		Parse(`{{define "subject"}}Order {{.Event.Number}}{{end}}`))
+	n.templates["order_placed"] = tmpl
+
+	msg, err := events.NewMessage(context.Background(), events.TopicOrderPlaced, "42", orderPlaced)
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+
+	err = sub.handlers[events.TopicOrderPlaced.Name](context.Background(), msg)
+	if err == nil {
+		t.Fatal("handler succeeded with a broken template")
+	}
+	// A permanent error would skip the retries and dead-letter the event at once.
+	if events.IsPermanent(err) {
+		t.Errorf("handler error %v is permanent, want it retried", err)
+	}
+	if len(sender.Sent()) != 0 {
+		t.Error("an email was sent with a broken template")
+	}
+}
+
+func TestStorageErrorReachesTheConsumer(t *testing.T) {
+	n := New(zap.NewNop(), NewMemorySender(), fakeStorage{}, "https://shop.example")
+
+	err := n.OrderPlaced(context.Background(), events.OrderPlaced{OrderID: 1, UserID: 8})
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("OrderPlaced error = %v, want %v", err, storage.ErrUserNotFound)
+	}
+}
@@ -0,0 +1,182 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Email is a message with a plain text and an HTML body.
+type Email struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type EmailSender interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// SMTPSender sends emails through an SMTP server, authenticating with PLAIN auth if a username is set.
+type SMTPSender struct {
+	addr     string
+	host     string
+	from     string
+	username string
+	password string
+}
+
+func NewSMTPSender(host string, port int, username, password, from string) *SMTPSender {
+	return &SMTPSender{
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		host:     host,
+		from:     from,
+		username: username,
+		password: password,
+	}
+}
+
+func (s *SMTPSender) Send(_ context.Context, email Email) error {
+	const op = "notifier.SMTPSender.Send"
+
+	msg, err := compose(s.from, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+
+	if err := smtp.SendMail(s.addr, auth, s.from, []string{email.To}, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// FileSender writes every email as an .eml file into a directory, for local development.
+type FileSender struct {
+	dir  string
+	from string
+}
+
+func NewFileSender(dir, from string) *FileSender {
+	return &FileSender{dir: dir, from: from}
+}
+
+func (s *FileSender) Send(_ context.Context, email Email) error {
+	const op = "notifier.FileSender.Send"
+
+	msg, err := compose(s.from, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), sanitize(email.To))
+	if err := os.WriteFile(filepath.Join(s.dir, name), msg, 0o644); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MemorySender keeps sent emails in memory, for tests.
+type MemorySender struct {
+	mu   sync.Mutex
+	sent []Email
+}
+
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+func (s *MemorySender) Send(_ context.Context, email Email) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, email)
+
+	return nil
+}
+
+// Sent returns the emails sent so far.
+func (s *MemorySender) Sent() []Email {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Email(nil), s.sent...)
+}
+
+// compose builds a multipart/alternative MIME message with the text and HTML bodies.
+func compose(from string, email Email) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", email.Text},
+		{"text/html; charset=UTF-8", email.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", email.To)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+// sanitize makes an email address safe to use in a file name.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '@', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
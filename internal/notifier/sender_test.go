@@ -0,0 +1,80 @@
+package notifier
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestFileSenderWritesTheRenderedEmail(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	n := New(zap.NewNop(), NewFileSender(dir, "shop@example.com"), fakeStorage{}, "https://shop.example")
+
+	if err := n.OrderPlaced(context.Background(), orderPlaced); err != nil {
+		t.Fatalf("OrderPlaced: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*-user@example.com.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("written files = %v, %v; want one .eml of user@example.com", files, err)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if from, to := msg.Header.Get("From"), msg.Header.Get("To"); from != "shop@example.com" || to != "user@example.com" {
+		t.Errorf("from %q to %q, want shop@example.com to user@example.com", from, to)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Your order #42" {
+		t.Errorf("subject = %q, %v; want Your order #42", subject, err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q, %v; want multipart/alternative", mediaType, err)
+	}
+
+	bodies := make(map[string]string)
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		bodies[mediaType] = string(body)
+	}
+
+	for _, mediaType := range []string{"text/plain", "text/html"} {
+		body := bodies[mediaType]
+		if !strings.Contains(body, "order #42") || !strings.Contains(body, "$12.50") {
+			t.Errorf("%s body = %q, want the order number and total", mediaType, body)
+		}
+	}
+	if !strings.Contains(bodies["text/html"], "<title>Your order #42</title>") {
+		t.Errorf("html body = %q, want the subject as title", bodies["text/html"])
+	}
+}
@@ -0,0 +1,231 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/events"
+)
+
+type Storage interface {
+	PendingOutbox(ctx context.Context, afterID int64, limit int) ([]models.OutboxMessage, error)
+	MarkOutboxSent(ctx context.Context, ids ...int64) error
+	MarkOutboxFailed(ctx context.Context, id int64, nextAttemptAt time.Time, reason string) error
+	ParkOutbox(ctx context.Context, id int64, reason string) error
+	OutboxBacklog(ctx context.Context) (int, time.Time, error)
+}
+
+var (
+	pendingGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "shop",
+		Subsystem: "outbox",
+		Name:      "pending_messages",
+		Help:      "Outbox messages not published yet.",
+	})
+	lagGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "shop",
+		Subsystem: "outbox",
+		Name:      "lag_seconds",
+		Help:      "Age of the oldest outbox message not published yet.",
+	})
+	publishedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "shop",
+		Subsystem: "outbox",
+		Name:      "published_total",
+		Help:      "Outbox messages published.",
+	})
+	failuresCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "shop",
+		Subsystem: "outbox",
+		Name:      "publish_failures_total",
+		Help:      "Failed attempts to publish an outbox message.",
+	})
+	parkedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "shop",
+		Subsystem: "outbox",
+		Name:      "parked_total",
+		Help:      "Outbox messages set aside after failing every attempt.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(pendingGauge, lagGauge, publishedCounter, failuresCounter, parkedCounter)
+}
+
+// Relay publishes outbox messages in the order they were saved. A message that fails is
+// retried with exponential backoff, and the later messages with its topic and key wait, so
+// consumers never see the events of a key out of order; other keys go on. A message failing
+// maxAttempts times is parked, so a poison message cannot hold up its key forever.
+// Delivery is at least once.
+type Relay struct {
+	log         *zap.Logger
+	storage     Storage
+	publisher   events.Publisher
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+}
+
+func New(
+	log *zap.Logger,
+	storage Storage,
+	publisher events.Publisher,
+	interval time.Duration,
+	batchSize int,
+	maxAttempts int,
+	baseBackoff time.Duration,
+	maxBackoff time.Duration,
+) *Relay {
+	return &Relay{
+		log:         log.With(zap.String("component", "outbox/relay")),
+		storage:     storage,
+		publisher:   publisher,
+		interval:    interval,
+		batchSize:   batchSize,
+		maxAttempts: maxAttempts,
+		baseBackoff: baseBackoff,
+		maxBackoff:  maxBackoff,
+	}
+}
+
+// Run relays messages every interval until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.relay(ctx)
+		r.observe(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay goes through the pending messages once, publishing those that are due and whose
+// key is not held up by an earlier message.
+func (r *Relay) relay(ctx context.Context) {
+	held := make(map[string]bool)
+
+	var afterID int64
+	for ctx.Err() == nil {
+		msgs, err := r.storage.PendingOutbox(ctx, afterID, r.batchSize)
+		if err != nil {
+			r.log.Error("failed to fetch outbox messages", zap.Error(err))
+			return
+		}
+
+		for _, msg := range msgs {
+			afterID = msg.ID
+
+			key := msg.Topic + "/" + msg.Key
+			if held[key] || !r.publish(ctx, msg) {
+				held[key] = true
+			}
+		}
+
+		if len(msgs) < r.batchSize {
+			return
+		}
+	}
+}
+
+// publish publishes msg unless it is still backing off. It reports whether the message is
+// out of the way of the later ones of its key, that is sent or parked.
+func (r *Relay) publish(ctx context.Context, msg models.OutboxMessage) bool {
+	if time.Now().Before(msg.NextAttemptAt) {
+		return false
+	}
+
+	err := r.publisher.Publish(ctx, events.Message{
+		Topic:   msg.Topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: msg.Headers,
+	})
+	if err != nil {
+		failuresCounter.Inc()
+
+		if msg.Attempts+1 >= r.maxAttempts {
+			return r.park(ctx, msg, err)
+		}
+
+		next := time.Now().Add(r.backoff(msg.Attempts))
+		r.log.Warn("failed to publish outbox message",
+			zap.Int64("id", msg.ID),
+			zap.String("topic", msg.Topic),
+			zap.Int("attempts", msg.Attempts+1),
+			zap.Time("next_attempt_at", next),
+			zap.Error(err))
+
+		if err := r.storage.MarkOutboxFailed(ctx, msg.ID, next, err.Error()); err != nil {
+			r.log.Error("failed to record outbox failure", zap.Int64("id", msg.ID), zap.Error(err))
+		}
+
+		return false
+	}
+
+	if err := r.storage.MarkOutboxSent(ctx, msg.ID); err != nil {
+		// The message goes out again on the next run; consumers must tolerate duplicates anyway.
+		r.log.Error("failed to mark outbox message sent", zap.Int64("id", msg.ID), zap.Error(err))
+		return false
+	}
+	publishedCounter.Inc()
+
+	return true
+}
+
+// park sets aside msg, which failed its last attempt with err.
+func (r *Relay) park(ctx context.Context, msg models.OutboxMessage, err error) bool {
+	r.log.Error("parked outbox message after its last attempt",
+		zap.Int64("id", msg.ID),
+		zap.String("topic", msg.Topic),
+		zap.String("key", msg.Key),
+		zap.Int("attempts", msg.Attempts+1),
+		zap.Error(err))
+
+	if err := r.storage.ParkOutbox(ctx, msg.ID, err.Error()); err != nil {
+		r.log.Error("failed to park outbox message", zap.Int64("id", msg.ID), zap.Error(err))
+		return false
+	}
+	parkedCounter.Inc()
+
+	return true
+}
+
+// backoff returns the delay after the given number of previous failed attempts.
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.baseBackoff
+	for range attempts {
+		delay *= 2
+		if delay >= r.maxBackoff {
+			return r.maxBackoff
+		}
+	}
+
+	return delay
+}
+
+func (r *Relay) observe(ctx context.Context) {
+	pending, oldest, err := r.storage.OutboxBacklog(ctx)
+	if err != nil {
+		r.log.Error("failed to fetch outbox backlog", zap.Error(err))
+		return
+	}
+
+	pendingGauge.Set(float64(pending))
+	if pending == 0 {
+		lagGauge.Set(0)
+		return
+	}
+	lagGauge.Set(time.Since(oldest).Seconds())
+}
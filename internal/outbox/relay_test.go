@@ -0,0 +1,159 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/events"
+)
+
+// fakeStorage keeps the outbox in memory.
+type fakeStorage struct {
+	msgs   []models.OutboxMessage
+	sent   []int64
+	parked []int64
+}
+
+func (s *fakeStorage) PendingOutbox(_ context.Context, afterID int64, limit int) ([]models.OutboxMessage, error) {
+	var pending []models.OutboxMessage
+	for _, m := range s.msgs {
+		if m.ID > afterID && !slices.Contains(s.sent, m.ID) && !slices.Contains(s.parked, m.ID) && len(pending) < limit {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending, nil
+}
+
+func (s *fakeStorage) MarkOutboxSent(_ context.Context, ids ...int64) error {
+	s.sent = append(s.sent, ids...)
+	return nil
+}
+
+func (s *fakeStorage) MarkOutboxFailed(_ context.Context, id int64, nextAttemptAt time.Time, _ string) error {
+	for i := range s.msgs {
+		if s.msgs[i].ID == id {
+			s.msgs[i].Attempts++
+			s.msgs[i].NextAttemptAt = nextAttemptAt
+		}
+	}
+
+	return nil
+}
+
+func (s *fakeStorage) ParkOutbox(_ context.Context, id int64, _ string) error {
+	s.parked = append(s.parked, id)
+	return nil
+}
+
+func (s *fakeStorage) OutboxBacklog(context.Context) (int, time.Time, error) {
+	return 0, time.Time{}, nil
+}
+
+// fakePublisher fails the messages with the poison key.
+type fakePublisher struct {
+	published []string
+}
+
+func (p *fakePublisher) Publish(_ context.Context, msgs ...events.Message) error {
+	for _, msg := range msgs {
+		if msg.Key == "poison" {
+			return errors.New("broker rejected the message")
+		}
+		p.published = append(p.published, msg.Key)
+	}
+
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name          string
+		msgs          []models.OutboxMessage
+		wantPublished []string
+		wantParked    []int64
+	}{
+		{
+			name: "publishes in order",
+			msgs: []models.OutboxMessage{
+				{ID: 1, Topic: "orders", Key: "a"},
+				{ID: 2, Topic: "orders", Key: "b"},
+				{ID: 3, Topic: "orders", Key: "a"},
+			},
+			wantPublished: []string{"a", "b", "a"},
+		},
+		{
+			name: "backing off message holds up only its key",
+			msgs: []models.OutboxMessage{
+				{ID: 1, Topic: "orders", Key: "a", NextAttemptAt: later},
+				{ID: 2, Topic: "orders", Key: "b"},
+				{ID: 3, Topic: "orders", Key: "a"},
+				{ID: 4, Topic: "stock", Key: "a"},
+			},
+			wantPublished: []string{"b", "a"},
+		},
+		{
+			name: "failed message holds up only its key",
+			msgs: []models.OutboxMessage{
+				{ID: 1, Topic: "orders", Key: "poison"},
+				{ID: 2, Topic: "orders", Key: "b"},
+				{ID: 3, Topic: "orders", Key: "poison"},
+			},
+			wantPublished: []string{"b"},
+		},
+		{
+			name: "parks message at its last attempt",
+			msgs: []models.OutboxMessage{
+				{ID: 1, Topic: "orders", Key: "poison", Attempts: 2},
+				{ID: 2, Topic: "orders", Key: "b"},
+			},
+			wantPublished: []string{"b"},
+			wantParked:    []int64{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &fakeStorage{msgs: tt.msgs}
+			publisher := &fakePublisher{}
+			// A batch of two makes the relay page through the outbox.
+			r := New(zap.NewNop(), storage, publisher, time.Second, 2, 3, time.Minute, time.Hour)
+
+			r.relay(context.Background())
+
+			if !slices.Equal(publisher.published, tt.wantPublished) {
+				t.Errorf("published %v, want %v", publisher.published, tt.wantPublished)
+			}
+			if !slices.Equal(storage.parked, tt.wantParked) {
+				t.Errorf("parked %v, want %v", storage.parked, tt.wantParked)
+			}
+		})
+	}
+}
+
+func TestRelayRetriesWithBackoff(t *testing.T) {
+	storage := &fakeStorage{msgs: []models.OutboxMessage{{ID: 1, Topic: "orders", Key: "poison"}}}
+	r := New(zap.NewNop(), storage, &fakePublisher{}, time.Second, 10, 3, time.Minute, time.Hour)
+
+	r.relay(context.Background())
+	r.relay(context.Background())
+
+	msg := storage.msgs[0]
+	if msg.Attempts != 1 {
+		t.Errorf("attempts = %d, want 1; the second run must wait for the backoff", msg.Attempts)
+	}
+	if wait := time.Until(msg.NextAttemptAt); wait < 59*time.Second || wait > time.Minute {
+		t.Errorf("next attempt in %v, want the base backoff", wait)
+	}
+	if len(storage.parked) != 0 {
+		t.Errorf("parked %v before the last attempt", storage.parked)
+	}
+}
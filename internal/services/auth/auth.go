@@ -7,11 +7,12 @@ import (
 	"time"
 
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 
+	"shop/internal/config"
 	"shop/internal/domain/models"
 	"shop/internal/storage"
 	"shop/lib/jwt"
+	"shop/lib/password"
 )
 
 type Auth struct {
@@ -19,7 +20,12 @@ type Auth struct {
 	usrSaver    UserSaver
 	usrProvider UserProvider
 	appProvider AppProvider
+	attempts    AttemptsProvider
+	identities  IdentityProvider
 	tokenTTL    time.Duration
+	lockout     config.LockoutConfig
+	hasher      *password.Hasher
+	policy      *password.Policy
 }
 
 type UserSaver interface {
@@ -27,12 +33,20 @@ type UserSaver interface {
 		ctx context.Context,
 		email string,
 		passHash []byte,
+		method string,
 	) (uid int64, err error)
+	UpdatePassHash(ctx context.Context, userID int64, passHash []byte) error
+	AssignRole(ctx context.Context, userID int64, role string) error
+	RevokeRole(ctx context.Context, userID int64, role string) error
 }
 
 type UserProvider interface {
 	User(ctx context.Context, email string) (models.User, error)
 	IsAdmin(ctx context.Context, userID int64) (bool, error)
+	UserRoles(ctx context.Context, userID int64) ([]string, error)
+	HasPermission(ctx context.Context, userID int64, permission string) (bool, error)
+	UsersWithRoles(ctx context.Context) ([]models.UserRoles, error)
+	Roles(ctx context.Context) ([]models.Role, error)
 }
 
 type AppProvider interface {
@@ -44,6 +58,10 @@ var (
 	ErrInvalidAppID       = errors.New("invalid app id")
 	ErrUserExists         = errors.New("user already exists")
 	ErrUserNotFound       = errors.New("user not found")
+	ErrAccountLocked      = errors.New("too many failed login attempts")
+	ErrLoginDelayed       = errors.New("login attempts delayed after failures")
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrWeakPassword       = errors.New("password does not meet the policy")
 )
 
 // New returns a new instance of the Auth service
@@ -52,14 +70,24 @@ func New(
 	usrSaver UserSaver,
 	usrProvider UserProvider,
 	appProvider AppProvider,
+	attempts AttemptsProvider,
+	identities IdentityProvider,
 	tokenTTL time.Duration,
+	lockout config.LockoutConfig,
+	hasher *password.Hasher,
+	policy *password.Policy,
 ) *Auth {
 	return &Auth{
 		usrSaver:    usrSaver,
 		usrProvider: usrProvider,
 		log:         log,
 		appProvider: appProvider,
+		attempts:    attempts,
+		identities:  identities,
 		tokenTTL:    tokenTTL,
+		lockout:     lockout,
+		hasher:      hasher,
+		policy:      policy,
 	}
 }
 
@@ -67,26 +95,45 @@ func New(
 //
 // If user exists, but password is incorrect, returns error
 // If user doesn't exist, returns error.
+// If the email or client IP has too many failed attempts, returns a LockError.
 func (a *Auth) Login(
 	ctx context.Context,
 	email string,
 	password string,
 	appID int,
+	clientIP string,
 ) (string, error) {
 	const op = "auth.Login"
 
 	log := a.log.With(
 		zap.String("op", op),
 		zap.String("username", email),
+		zap.String("ip", clientIP),
 	)
 
 	log.Info("attempting to login user")
 
+	if err := a.checkLocked(ctx, email, clientIP); err != nil {
+		var lockErr *LockError
+		if errors.As(err, &lockErr) {
+			log.Warn("login rejected: " + err.Error())
+
+			return "", fmt.Errorf("%s, %w", op, err)
+		}
+		log.Error("failed to check login attempts: " + err.Error())
+
+		return "", fmt.Errorf("%s, %w", op, err)
+	}
+
 	user, err := a.usrProvider.User(ctx, email)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
 			log.Warn("user not found: " + err.Error())
 
+			if err := a.registerIPFailure(ctx, clientIP); err != nil {
+				log.Error("failed to register failed attempt: " + err.Error())
+			}
+
 			return "", fmt.Errorf("%s, %w", op, ErrUserNotFound)
 		}
 		log.Error("failed to get user: " + err.Error())
@@ -94,20 +141,46 @@ func (a *Auth) Login(
 		return "", fmt.Errorf("%s, %w", op, err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
-		log.Error("invalid credentials: " + err.Error())
+	match, err := a.hasher.Verify(user.PassHash, password)
+	if err != nil {
+		log.Error("failed to verify password: " + err.Error())
+
+		return "", fmt.Errorf("%s, %w", op, err)
+	}
+
+	if !match {
+		log.Error("invalid credentials")
+
+		if err := a.registerFailure(ctx, email, clientIP); err != nil {
+			log.Error("failed to register failed attempt: " + err.Error())
+		}
 
 		return "", ErrInvalidCredentials
 	}
 
+	if a.hasher.NeedsRehash(user.PassHash) {
+		a.rehash(ctx, log, user, password)
+	}
+
+	if err := a.resetFailures(ctx, email, clientIP); err != nil {
+		log.Error("failed to reset login attempts: " + err.Error())
+	}
+
 	app, err := a.appProvider.App(ctx, appID)
 	if err != nil {
 		return "", fmt.Errorf("%s, %w", op, err)
 	}
 
+	roles, err := a.usrProvider.UserRoles(ctx, int64(user.ID))
+	if err != nil {
+		log.Error("failed to get user roles: " + err.Error())
+
+		return "", fmt.Errorf("%s, %w", op, err)
+	}
+
 	log.Info("user logged in successfully")
 
-	token, err := jwt.NewToken(user, app, a.tokenTTL)
+	token, err := jwt.NewToken(user, app, roles, a.tokenTTL)
 	if err != nil {
 		log.Error("failed to generate token: " + err.Error())
 
@@ -129,14 +202,20 @@ func (a *Auth) RegisterNewUser(ctx context.Context, email string, pass string) (
 
 	log.Info("registering new user")
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.MinCost)
+	if err := a.policy.Validate(email, pass); err != nil {
+		log.Warn("password rejected by policy: " + err.Error())
+
+		return 0, fmt.Errorf("%s, %w: %w", op, ErrWeakPassword, err)
+	}
+
+	passHash, err := a.hasher.Hash(pass)
 	if err != nil {
 		log.Error("failed to hash password: ", zap.Error(err))
 
 		return 0, fmt.Errorf("%s, %w", op, err)
 	}
 
-	id, err := a.usrSaver.SaveUser(ctx, email, passHash)
+	id, err := a.usrSaver.SaveUser(ctx, email, passHash, models.RegistrationPassword)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
 			log.Error("user already exists: ", zap.Error(err))
@@ -154,6 +233,23 @@ func (a *Auth) RegisterNewUser(ctx context.Context, email string, pass string) (
 	return id, nil
 }
 
+// rehash upgrades the stored hash of the user to the configured algorithm and cost.
+// Failures are only logged, the user is logged in anyway.
+func (a *Auth) rehash(ctx context.Context, log *zap.Logger, user models.User, password string) {
+	passHash, err := a.hasher.Hash(password)
+	if err != nil {
+		log.Error("failed to rehash password: " + err.Error())
+		return
+	}
+
+	if err := a.usrSaver.UpdatePassHash(ctx, int64(user.ID), passHash); err != nil {
+		log.Error("failed to save rehashed password: " + err.Error())
+		return
+	}
+
+	log.Info("password hash upgraded")
+}
+
 // IsAdmin checks if user is an admin
 func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 	const op = "auth.isAdmin"
@@ -167,10 +263,10 @@ func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 
 	isAdmin, err := a.usrProvider.IsAdmin(ctx, userID)
 	if err != nil {
-		if errors.Is(err, storage.ErrAppNotFound) {
-			log.Warn("app not found: " + err.Error())
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found: " + err.Error())
 
-			return false, fmt.Errorf("%s, %w", op, ErrInvalidAppID)
+			return false, fmt.Errorf("%s, %w", op, ErrUserNotFound)
 		}
 
 		return false, fmt.Errorf("%s, %w", op, err)
@@ -180,3 +276,146 @@ func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 
 	return isAdmin, nil
 }
+
+// HasPermission checks if any of the user's roles grants the permission
+func (a *Auth) HasPermission(ctx context.Context, userID int64, permission string) (bool, error) {
+	const op = "auth.HasPermission"
+
+	log := a.log.With(
+		zap.String("op", op),
+		zap.Int64("userID", userID),
+		zap.String("permission", permission),
+	)
+
+	has, err := a.usrProvider.HasPermission(ctx, userID, permission)
+	if err != nil {
+		log.Error("failed to check permission: " + err.Error())
+
+		return false, fmt.Errorf("%s, %w", op, err)
+	}
+
+	log.Debug("checked permission", zap.Bool("granted", has))
+
+	return has, nil
+}
+
+// UserRoles returns names of the roles assigned to the user
+func (a *Auth) UserRoles(ctx context.Context, userID int64) ([]string, error) {
+	const op = "auth.UserRoles"
+
+	roles, err := a.usrProvider.UserRoles(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, fmt.Errorf("%s, %w", op, ErrUserNotFound)
+		}
+
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// UsersWithRoles returns every user with the names of their roles
+func (a *Auth) UsersWithRoles(ctx context.Context) ([]models.UserRoles, error) {
+	const op = "auth.UsersWithRoles"
+
+	users, err := a.usrProvider.UsersWithRoles(ctx)
+	if err != nil {
+		a.log.With(zap.String("op", op)).Error("failed to list users: " + err.Error())
+
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return users, nil
+}
+
+// Roles returns every role with its permissions
+func (a *Auth) Roles(ctx context.Context) ([]models.Role, error) {
+	const op = "auth.Roles"
+
+	roles, err := a.usrProvider.Roles(ctx)
+	if err != nil {
+		a.log.With(zap.String("op", op)).Error("failed to list roles: " + err.Error())
+
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// AssignRole grants the role to the user
+func (a *Auth) AssignRole(ctx context.Context, userID int64, role string) error {
+	const op = "auth.AssignRole"
+
+	log := a.log.With(
+		zap.String("op", op),
+		zap.Int64("userID", userID),
+		zap.String("role", role),
+	)
+
+	if err := a.usrSaver.AssignRole(ctx, userID, role); err != nil {
+		if errors.Is(err, storage.ErrRoleNotFound) {
+			log.Warn("role not found: " + err.Error())
+
+			return fmt.Errorf("%s, %w", op, ErrRoleNotFound)
+		}
+		log.Error("failed to assign role: " + err.Error())
+
+		return fmt.Errorf("%s, %w", op, err)
+	}
+
+	log.Info("role assigned")
+
+	return nil
+}
+
+// RevokeRole takes the role away from the user
+func (a *Auth) RevokeRole(ctx context.Context, userID int64, role string) error {
+	const op = "auth.RevokeRole"
+
+	log := a.log.With(
+		zap.String("op", op),
+		zap.Int64("userID", userID),
+		zap.String("role", role),
+	)
+
+	if err := a.usrSaver.RevokeRole(ctx, userID, role); err != nil {
+		log.Error("failed to revoke role: " + err.Error())
+
+		return fmt.Errorf("%s, %w", op, err)
+	}
+
+	log.Info("role revoked")
+
+	return nil
+}
+
+// BootstrapAdmins grants the admin role to the users with the given emails, which is how the
+// first admin is created. Emails of users who have not registered yet are skipped, so they
+// are granted the role on a later start.
+func (a *Auth) BootstrapAdmins(ctx context.Context, emails []string) error {
+	const op = "auth.BootstrapAdmins"
+
+	log := a.log.With(
+		zap.String("op", op),
+	)
+
+	for _, email := range emails {
+		user, err := a.usrProvider.User(ctx, email)
+		if err != nil {
+			if errors.Is(err, storage.ErrUserNotFound) {
+				log.Warn("admin not registered yet", zap.String("email", email))
+
+				continue
+			}
+
+			return fmt.Errorf("%s, %w", op, err)
+		}
+
+		if err := a.AssignRole(ctx, int64(user.ID), models.RoleAdmin); err != nil {
+			return fmt.Errorf("%s, %w", op, err)
+		}
+	}
+
+	return nil
+}
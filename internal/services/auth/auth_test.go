@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"shop/internal/domain/models"
+	"shop/lib/password"
+)
+
+func (s *fakeStorage) UpdatePassHash(_ context.Context, userID int64, passHash []byte) error {
+	for email, u := range s.users {
+		if int64(u.ID) == userID {
+			u.PassHash = passHash
+			s.users[email] = u
+		}
+	}
+
+	return nil
+}
+
+func TestLoginUpgradesTheHash(t *testing.T) {
+	argon2id, err := password.NewHasher(password.AlgorithmArgon2id, 0, password.Argon2Params{
+		Time: 1, Memory: 64, Threads: 1, KeyLength: 16, SaltLength: 8,
+	})
+	if err != nil {
+		t.Fatalf("NewHasher: %v", err)
+	}
+	current, err := argon2id.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		hash       []byte
+		wantRehash bool
+	}{
+		{name: "bcrypt hash", hash: legacy, wantRehash: true},
+		{name: "current hash", hash: current},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeStorage(models.User{ID: 1, Email: "user@example.com", PassHash: tt.hash})
+			a := newTestAuth(t, s)
+			a.hasher = argon2id
+
+			if _, err := a.Login(context.Background(), "user@example.com", "correct horse", 1, "198.51.100.1"); err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+
+			stored := s.users["user@example.com"].PassHash
+			if rehashed := !bytes.Equal(stored, tt.hash); rehashed != tt.wantRehash {
+				t.Fatalf("hash rehashed = %v, want %v", rehashed, tt.wantRehash)
+			}
+			if argon2id.NeedsRehash(stored) {
+				t.Errorf("stored hash %s is not of the configured algorithm", stored)
+			}
+			if ok, err := argon2id.Verify(stored, "correct horse"); err != nil || !ok {
+				t.Errorf("Verify of the stored hash = %v, %v; want a match", ok, err)
+			}
+		})
+	}
+}
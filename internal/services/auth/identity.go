@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/storage"
+	"shop/lib/jwt"
+)
+
+type IdentityProvider interface {
+	UserByIdentity(ctx context.Context, provider, subject string) (models.User, error)
+	SaveIdentity(ctx context.Context, identity models.Identity) error
+}
+
+var ErrUnverifiedEmail = errors.New("email is not verified by identity provider")
+
+// LoginWithIdentity logs in the user linked to an external identity and returns access token.
+//
+// Unknown identities are linked to the user with the same email, or to a newly provisioned
+// user if there is none, but only if the provider verified the email.
+func (a *Auth) LoginWithIdentity(
+	ctx context.Context,
+	provider string,
+	subject string,
+	email string,
+	emailVerified bool,
+	appID int,
+) (string, error) {
+	const op = "auth.LoginWithIdentity"
+
+	log := a.log.With(
+		zap.String("op", op),
+		zap.String("provider", provider),
+		zap.String("email", email),
+	)
+
+	log.Info("attempting to login user with external identity")
+
+	user, err := a.identities.UserByIdentity(ctx, provider, subject)
+	if err != nil {
+		if !errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("failed to get user by identity: " + err.Error())
+
+			return "", fmt.Errorf("%s, %w", op, err)
+		}
+
+		user, err = a.linkIdentity(ctx, log, provider, subject, email, emailVerified)
+		if err != nil {
+			return "", fmt.Errorf("%s, %w", op, err)
+		}
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		return "", fmt.Errorf("%s, %w", op, err)
+	}
+
+	roles, err := a.usrProvider.UserRoles(ctx, int64(user.ID))
+	if err != nil {
+		log.Error("failed to get user roles: " + err.Error())
+
+		return "", fmt.Errorf("%s, %w", op, err)
+	}
+
+	token, err := jwt.NewToken(user, app, roles, a.tokenTTL)
+	if err != nil {
+		log.Error("failed to generate token: " + err.Error())
+
+		return "", fmt.Errorf("%s, %w", op, err)
+	}
+
+	log.Info("user logged in with external identity", zap.Int("uid", user.ID))
+
+	return token, nil
+}
+
+// linkIdentity links the identity to the user with the same email, provisioning the user if needed.
+// It refuses emails the provider has not verified, which anyone could claim.
+func (a *Auth) linkIdentity(
+	ctx context.Context,
+	log *zap.Logger,
+	provider string,
+	subject string,
+	email string,
+	emailVerified bool,
+) (models.User, error) {
+	if email == "" {
+		log.Warn("identity has no email")
+
+		return models.User{}, ErrInvalidCredentials
+	}
+
+	if !emailVerified {
+		log.Warn("refusing identity with unverified email")
+
+		return models.User{}, ErrUnverifiedEmail
+	}
+
+	user, err := a.usrProvider.User(ctx, email)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		user, err = a.provisionUser(ctx, email)
+		if err != nil {
+			log.Error("failed to provision user: " + err.Error())
+
+			return models.User{}, err
+		}
+
+		log.Info("provisioned user for external identity", zap.Int("uid", user.ID))
+	} else if err != nil {
+		log.Error("failed to get user: " + err.Error())
+
+		return models.User{}, err
+	}
+
+	err = a.identities.SaveIdentity(ctx, models.Identity{
+		UserID:   int64(user.ID),
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	})
+	if err != nil {
+		log.Error("failed to link identity: " + err.Error())
+
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// provisionUser creates a user that can only log in through external identities.
+func (a *Auth) provisionUser(ctx context.Context, email string) (models.User, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return models.User{}, err
+	}
+
+	passHash, err := a.hasher.Hash(base64.RawStdEncoding.EncodeToString(secret))
+	if err != nil {
+		return models.User{}, err
+	}
+
+	id, err := a.usrSaver.SaveUser(ctx, email, passHash, models.RegistrationOIDC)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return models.User{
+		ID:       int(id),
+		Email:    email,
+		PassHash: passHash,
+	}, nil
+}
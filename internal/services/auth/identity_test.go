@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"shop/internal/config"
+	"shop/internal/domain/models"
+	"shop/internal/storage"
+	"shop/lib/password"
+)
+
+// fakeStorage keeps users, identities and login attempts in memory. The embedded interfaces
+// leave the methods the tests don't use unimplemented.
+type fakeStorage struct {
+	UserSaver
+	UserProvider
+
+	users      map[string]models.User
+	identities map[string]int64
+	attempts   map[string]models.LoginAttempts
+}
+
+func newFakeStorage(users ...models.User) *fakeStorage {
+	s := &fakeStorage{
+		users:      make(map[string]models.User),
+		identities: make(map[string]int64),
+		attempts:   make(map[string]models.LoginAttempts),
+	}
+	for _, u := range users {
+		s.users[u.Email] = u
+	}
+
+	return s
+}
+
+func (s *fakeStorage) User(_ context.Context, email string) (models.User, error) {
+	u, ok := s.users[email]
+	if !ok {
+		return models.User{}, storage.ErrUserNotFound
+	}
+
+	return u, nil
+}
+
+func (s *fakeStorage) SaveUser(_ context.Context, email string, passHash []byte, _ string) (int64, error) {
+	id := len(s.users) + 1
+	s.users[email] = models.User{ID: id, Email: email, PassHash: passHash}
+
+	return int64(id), nil
+}
+
+func (s *fakeStorage) UserRoles(context.Context, int64) ([]string, error) {
+	return []string{models.RoleCustomer}, nil
+}
+
+func (s *fakeStorage) App(_ context.Context, appID int) (models.App, error) {
+	return models.App{ID: appID, Name: "shop", Secret: "secret"}, nil
+}
+
+func (s *fakeStorage) UserByIdentity(_ context.Context, provider, subject string) (models.User, error) {
+	id, ok := s.identities[provider+"|"+subject]
+	if !ok {
+		return models.User{}, storage.ErrUserNotFound
+	}
+	for _, u := range s.users {
+		if int64(u.ID) == id {
+			return u, nil
+		}
+	}
+
+	return models.User{}, storage.ErrUserNotFound
+}
+
+func (s *fakeStorage) SaveIdentity(_ context.Context, identity models.Identity) error {
+	s.identities[identity.Provider+"|"+identity.Subject] = identity.UserID
+	return nil
+}
+
+func newTestAuth(t *testing.T, s *fakeStorage) *Auth {
+	t.Helper()
+
+	hasher, err := password.NewHasher(password.AlgorithmBcrypt, 4, password.Argon2Params{})
+	if err != nil {
+		t.Fatalf("NewHasher: %v", err)
+	}
+
+	return New(zap.NewNop(), s, s, s, s, s, time.Hour, config.LockoutConfig{}, hasher, nil)
+}
+
+func TestLoginWithIdentity(t *testing.T) {
+	existing := models.User{ID: 1, Email: "user@example.com", PassHash: []byte("hash")}
+
+	tests := []struct {
+		name          string
+		email         string
+		emailVerified bool
+		wantErr       error
+		wantUsers     int
+		wantLinked    bool
+	}{
+		{
+			name:          "links verified email",
+			email:         existing.Email,
+			emailVerified: true,
+			wantUsers:     1,
+			wantLinked:    true,
+		},
+		{
+			name:          "provisions verified email",
+			email:         "new@example.com",
+			emailVerified: true,
+			wantUsers:     2,
+			wantLinked:    true,
+		},
+		{
+			name:      "refuses to link unverified email",
+			email:     existing.Email,
+			wantErr:   ErrUnverifiedEmail,
+			wantUsers: 1,
+		},
+		{
+			name:      "refuses to provision unverified email",
+			email:     "new@example.com",
+			wantErr:   ErrUnverifiedEmail,
+			wantUsers: 1,
+		},
+		{
+			name:          "refuses identity without email",
+			emailVerified: true,
+			wantErr:       ErrInvalidCredentials,
+			wantUsers:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeStorage(existing)
+			a := newTestAuth(t, s)
+
+			token, err := a.LoginWithIdentity(context.Background(), "mock", "subject", tt.email, tt.emailVerified, 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LoginWithIdentity error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && token == "" {
+				t.Error("LoginWithIdentity returned no token")
+			}
+			if len(s.users) != tt.wantUsers {
+				t.Errorf("users = %d, want %d", len(s.users), tt.wantUsers)
+			}
+			if _, linked := s.identities["mock|subject"]; linked != tt.wantLinked {
+				t.Errorf("identity linked = %t, want %t", linked, tt.wantLinked)
+			}
+		})
+	}
+}
+
+func TestLoginWithLinkedIdentity(t *testing.T) {
+	s := newFakeStorage(models.User{ID: 1, Email: "user@example.com"})
+	s.identities["mock|subject"] = 1
+	a := newTestAuth(t, s)
+
+	// A linked identity logs in even if the provider no longer reports the email as verified.
+	if _, err := a.LoginWithIdentity(context.Background(), "mock", "subject", "other@example.com", false, 1); err != nil {
+		t.Fatalf("LoginWithIdentity: %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"shop/internal/config"
+	"shop/internal/domain/models"
+)
+
+func (s *fakeStorage) LoginAttempts(_ context.Context, key string) (models.LoginAttempts, error) {
+	attempts, ok := s.attempts[key]
+	if !ok {
+		return models.LoginAttempts{Key: key}, nil
+	}
+
+	return attempts, nil
+}
+
+func (s *fakeStorage) AddLoginFailure(_ context.Context, key, ip string, now, since time.Time) (int, error) {
+	attempts := s.attempts[key]
+	if attempts.LastFailedAt.Before(since) {
+		attempts.Failures = 0
+	}
+	attempts.Key, attempts.LastIP, attempts.LastFailedAt = key, ip, now
+	attempts.Failures++
+	s.attempts[key] = attempts
+
+	return attempts.Failures, nil
+}
+
+func (s *fakeStorage) LockLogin(_ context.Context, key string, until time.Time) error {
+	attempts := s.attempts[key]
+	if attempts.LockedUntil.Before(until) {
+		attempts.LockedUntil = until
+		s.attempts[key] = attempts
+	}
+
+	return nil
+}
+
+func (s *fakeStorage) ResetLoginAttempts(_ context.Context, key string) error {
+	delete(s.attempts, key)
+	return nil
+}
+
+// waitForUnlock ends the locks of all keys, as if their time had passed.
+func (s *fakeStorage) waitForUnlock() {
+	for key, attempts := range s.attempts {
+		attempts.LockedUntil = time.Time{}
+		s.attempts[key] = attempts
+	}
+}
+
+var testLockout = config.LockoutConfig{
+	FreeAttempts:     2,
+	MaxEmailAttempts: 6,
+	MaxIPAttempts:    100,
+	BaseDelay:        time.Second,
+	MaxDelay:         3 * time.Second,
+	LockoutDuration:  15 * time.Minute,
+}
+
+// newLockoutAuth returns an Auth with testLockout that knows user@example.com with the
+// password "correct horse".
+func newLockoutAuth(t *testing.T) (*Auth, *fakeStorage) {
+	t.Helper()
+
+	s := newFakeStorage()
+	a := newTestAuth(t, s)
+	a.lockout = testLockout
+
+	hash, err := a.hasher.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	s.users["user@example.com"] = models.User{ID: 1, Email: "user@example.com", PassHash: hash}
+
+	return a, s
+}
+
+// lockedFor returns how long key is locked from now, rounded to the second.
+func lockedFor(s *fakeStorage, key string) time.Duration {
+	until := s.attempts[key].LockedUntil
+	if until.IsZero() {
+		return 0
+	}
+
+	return time.Until(until).Round(time.Second)
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 1, want: time.Second},
+		{failures: 2, want: 2 * time.Second},
+		{failures: 3, want: 4 * time.Second},
+		{failures: 4, want: 5 * time.Second},
+		{failures: 100, want: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.failures, time.Second, 5*time.Second); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginFailuresDelayThenLock(t *testing.T) {
+	a, s := newLockoutAuth(t)
+	ctx := context.Background()
+
+	// Two free attempts, then a delay doubling up to MaxDelay, then the lockout.
+	wantLocks := []time.Duration{0, 0, time.Second, 2 * time.Second, 3 * time.Second, testLockout.LockoutDuration}
+	for i, want := range wantLocks {
+		s.waitForUnlock()
+
+		if _, err := a.Login(ctx, "user@example.com", "wrong", 1, "198.51.100.1"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failure %d: Login error = %v, want %v", i+1, err, ErrInvalidCredentials)
+		}
+		if got := lockedFor(s, emailKey("user@example.com")); got != want {
+			t.Errorf("failure %d: email locked for %v, want %v", i+1, got, want)
+		}
+	}
+
+	// The right password does not get past the lock.
+	_, err := a.Login(ctx, "user@example.com", "correct horse", 1, "198.51.100.1")
+	var lockErr *LockError
+	if !errors.As(err, &lockErr) || !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Login error = %v, want a LockError of %v", err, ErrAccountLocked)
+	}
+	if lockErr.Until != s.attempts[emailKey("user@example.com")].LockedUntil {
+		t.Errorf("locked until %v, want the lock of the email", lockErr.Until)
+	}
+}
+
+func TestLoginDuringBackoffIsDelayed(t *testing.T) {
+	a, _ := newLockoutAuth(t)
+	ctx := context.Background()
+
+	for range testLockout.FreeAttempts + 1 {
+		_, _ = a.Login(ctx, "user@example.com", "wrong", 1, "198.51.100.1")
+	}
+
+	_, err := a.Login(ctx, "user@example.com", "correct horse", 1, "198.51.100.1")
+	if !errors.Is(err, ErrLoginDelayed) || errors.Is(err, ErrAccountLocked) {
+		t.Errorf("Login error = %v, want %v", err, ErrLoginDelayed)
+	}
+}
+
+func TestLoginLockedByIP(t *testing.T) {
+	a, s := newLockoutAuth(t)
+	a.lockout.MaxIPAttempts = 3
+	ctx := context.Background()
+
+	hash, err := a.hasher.Hash("battery staple")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	s.users["other@example.com"] = models.User{ID: 2, Email: "other@example.com", PassHash: hash}
+
+	// Failures spread over emails still add up for the IP.
+	for _, email := range []string{"user@example.com", "other@example.com", "nobody@example.com"} {
+		s.waitForUnlock()
+		_, _ = a.Login(ctx, email, "wrong", 1, "198.51.100.1")
+	}
+
+	if _, err := a.Login(ctx, "other@example.com", "battery staple", 1, "198.51.100.1"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("Login from the locked IP error = %v, want %v", err, ErrAccountLocked)
+	}
+	if _, err := a.Login(ctx, "other@example.com", "battery staple", 1, "203.0.113.7"); err != nil {
+		t.Errorf("Login from another IP: %v", err)
+	}
+}
+
+func TestLoginOfUnknownEmailCountsOnlyTheIP(t *testing.T) {
+	a, s := newLockoutAuth(t)
+
+	for range 3 {
+		if _, err := a.Login(context.Background(), "nobody@example.com", "wrong", 1, "198.51.100.1"); !errors.Is(err, ErrUserNotFound) {
+			t.Fatalf("Login error = %v, want %v", err, ErrUserNotFound)
+		}
+		s.waitForUnlock()
+	}
+
+	if _, ok := s.attempts[emailKey("nobody@example.com")]; ok {
+		t.Error("failures of an unknown email were counted for the email")
+	}
+	if got := s.attempts[ipKey("198.51.100.1")].Failures; got != 3 {
+		t.Errorf("IP failures = %d, want 3", got)
+	}
+}
+
+func TestLoginResetsFailures(t *testing.T) {
+	a, s := newLockoutAuth(t)
+	ctx := context.Background()
+
+	for range testLockout.FreeAttempts {
+		_, _ = a.Login(ctx, "user@example.com", "wrong", 1, "198.51.100.1")
+	}
+	if _, err := a.Login(ctx, "user@example.com", "correct horse", 1, "198.51.100.1"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	if len(s.attempts) != 0 {
+		t.Errorf("attempts = %v, want them reset", s.attempts)
+	}
+}
+
+func TestUnlockUser(t *testing.T) {
+	a, s := newLockoutAuth(t)
+	ctx := context.Background()
+
+	for range testLockout.MaxEmailAttempts {
+		s.waitForUnlock()
+		_, _ = a.Login(ctx, "user@example.com", "wrong", 1, "198.51.100.1")
+	}
+	s.attempts[ipKey("198.51.100.1")] = models.LoginAttempts{
+		Key:         ipKey("198.51.100.1"),
+		Failures:    testLockout.MaxIPAttempts,
+		LockedUntil: time.Now().Add(time.Hour),
+	}
+
+	if err := a.UnlockUser(ctx, "user@example.com"); err != nil {
+		t.Fatalf("UnlockUser: %v", err)
+	}
+
+	if len(s.attempts) != 0 {
+		t.Errorf("attempts = %v, want the email and its last IP unlocked", s.attempts)
+	}
+	if _, err := a.Login(ctx, "user@example.com", "correct horse", 1, "198.51.100.1"); err != nil {
+		t.Errorf("Login after unlock: %v", err)
+	}
+}
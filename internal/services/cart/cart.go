@@ -0,0 +1,262 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/storage"
+	"shop/lib/pricing"
+)
+
+// MaxQuantity caps the quantity of a single product in the cart.
+const MaxQuantity = 99
+
+var (
+	ErrInvalidQuantity = errors.New("quantity must be between 1 and 99")
+	ErrProductNotFound = errors.New("product not found")
+	ErrNotInCart       = errors.New("product is not in the cart")
+	ErrOutOfStock      = errors.New("not enough items in stock")
+	ErrSessionNotFound = errors.New("guest session not found")
+)
+
+type Storage interface {
+	GetProduct(ctx context.Context, id int) (models.Product, error)
+	GetSession(ctx context.Context, UUID string) (int, error)
+	GetCart(ctx context.Context, userID any) ([]models.CartItem, error)
+	AddToCart(ctx context.Context, productID, quantity int, userID any) error
+	UpdateCartQuantity(ctx context.Context, productID, quantity int, userID any) error
+	RemoveFromCart(ctx context.Context, productID int, userID any) error
+	MergeCart(ctx context.Context, userID int64, sessionID string) error
+}
+
+// Cart manages shopping carts. A cart belongs to an owner: the ID of a user, or the
+// session ID of a guest. Changes of the item count are published to hub.
+type Cart struct {
+	log     *zap.Logger
+	storage Storage
+	hub     *Hub
+}
+
+func New(log *zap.Logger, storage Storage, hub *Hub) *Cart {
+	return &Cart{
+		log:     log,
+		storage: storage,
+		hub:     hub,
+	}
+}
+
+// Contents is a cart with its totals.
+type Contents struct {
+	Items []models.CartItem
+	pricing.Sum
+}
+
+func (c *Cart) Contents(ctx context.Context, owner any) (Contents, error) {
+	const op = "cart.Contents"
+
+	items, err := c.storage.GetCart(ctx, owner)
+	if err != nil {
+		c.log.Error("failed to get cart", zap.String("op", op), zap.Error(err))
+
+		return Contents{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return Contents{Items: items, Sum: pricing.Calculate(items)}, nil
+}
+
+// Count returns the number of items in the cart.
+func (c *Cart) Count(ctx context.Context, owner any) (int, error) {
+	contents, err := c.Contents(ctx, owner)
+	if err != nil {
+		return 0, err
+	}
+
+	return contents.CartCount, nil
+}
+
+// AddItem adds quantity units of the product to the cart. The resulting quantity may not
+// exceed MaxQuantity nor the stock of the product.
+func (c *Cart) AddItem(ctx context.Context, owner any, productID int64, quantity int) (Contents, error) {
+	const op = "cart.AddItem"
+
+	if quantity < 1 || quantity > MaxQuantity {
+		return Contents{}, fmt.Errorf("%s, %w", op, ErrInvalidQuantity)
+	}
+
+	product, err := c.product(ctx, productID)
+	if err != nil {
+		return Contents{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	current, err := c.Contents(ctx, owner)
+	if err != nil {
+		return Contents{}, err
+	}
+
+	item, _ := find(current.Items, productID)
+	if item.Quantity+quantity > MaxQuantity {
+		return Contents{}, fmt.Errorf("%s, %w", op, ErrInvalidQuantity)
+	}
+	if item.Quantity+quantity > product.Stock {
+		return Contents{}, fmt.Errorf("%s, %w", op, ErrOutOfStock)
+	}
+
+	if err := c.storage.AddToCart(ctx, int(productID), quantity, owner); err != nil {
+		c.log.Error("failed to add to cart", zap.String("op", op), zap.Error(err))
+
+		return Contents{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return c.changed(ctx, owner)
+}
+
+// UpdateItem sets the quantity of a product already in the cart; 0 removes it.
+func (c *Cart) UpdateItem(ctx context.Context, owner any, productID int64, quantity int) (Contents, error) {
+	const op = "cart.UpdateItem"
+
+	if quantity < 0 || quantity > MaxQuantity {
+		return Contents{}, fmt.Errorf("%s, %w", op, ErrInvalidQuantity)
+	}
+	if quantity == 0 {
+		return c.RemoveItem(ctx, owner, productID)
+	}
+
+	product, err := c.product(ctx, productID)
+	if err != nil {
+		return Contents{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	if err := c.inCart(ctx, owner, productID); err != nil {
+		return Contents{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	if quantity > product.Stock {
+		return Contents{}, fmt.Errorf("%s, %w", op, ErrOutOfStock)
+	}
+
+	if err := c.storage.UpdateCartQuantity(ctx, int(productID), quantity, owner); err != nil {
+		c.log.Error("failed to update cart quantity", zap.String("op", op), zap.Error(err))
+
+		return Contents{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return c.changed(ctx, owner)
+}
+
+func (c *Cart) RemoveItem(ctx context.Context, owner any, productID int64) (Contents, error) {
+	const op = "cart.RemoveItem"
+
+	if err := c.inCart(ctx, owner, productID); err != nil {
+		return Contents{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	if err := c.storage.RemoveFromCart(ctx, int(productID), owner); err != nil {
+		c.log.Error("failed to remove from cart", zap.String("op", op), zap.Error(err))
+
+		return Contents{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return c.changed(ctx, owner)
+}
+
+// MergeGuestCart moves the cart of the guest session into the cart of the user, adding up
+// the quantities of products in both, and empties the guest cart.
+func (c *Cart) MergeGuestCart(ctx context.Context, userID int64, sessionID string) (Contents, error) {
+	const op = "cart.MergeGuestCart"
+
+	if _, err := c.storage.GetSession(ctx, sessionID); err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return Contents{}, fmt.Errorf("%s, %w", op, ErrSessionNotFound)
+		}
+		c.log.Error("failed to get session", zap.String("op", op), zap.Error(err))
+
+		return Contents{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	if err := c.storage.MergeCart(ctx, userID, sessionID); err != nil {
+		c.log.Error("failed to merge cart", zap.String("op", op), zap.Error(err))
+
+		return Contents{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	c.hub.Publish(sessionID, 0)
+
+	return c.changed(ctx, userID)
+}
+
+// Subscribe subscribes to the item count of the owner's cart and returns the current count.
+// The subscription has to be closed when no longer needed.
+func (c *Cart) Subscribe(ctx context.Context, owner any) (*Subscription, Update, error) {
+	const op = "cart.Subscribe"
+
+	sub, last, err := c.hub.subscribe(owner)
+	if err != nil {
+		return nil, Update{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	if last.ID == "" {
+		count, err := c.Count(ctx, owner)
+		if err != nil {
+			sub.Close()
+
+			return nil, Update{}, fmt.Errorf("%s, %w", op, err)
+		}
+		last = c.hub.initial(owner, count)
+	}
+
+	return sub, last, nil
+}
+
+// changed returns the contents of the owner's cart after a change and publishes its item count.
+func (c *Cart) changed(ctx context.Context, owner any) (Contents, error) {
+	contents, err := c.Contents(ctx, owner)
+	if err != nil {
+		return Contents{}, err
+	}
+
+	c.hub.Publish(owner, contents.CartCount)
+
+	return contents, nil
+}
+
+func (c *Cart) product(ctx context.Context, productID int64) (models.Product, error) {
+	product, err := c.storage.GetProduct(ctx, int(productID))
+	if err != nil {
+		if errors.Is(err, storage.ErrProductNotFound) {
+			return models.Product{}, ErrProductNotFound
+		}
+		c.log.Error("failed to get product", zap.Error(err))
+
+		return models.Product{}, err
+	}
+
+	return product, nil
+}
+
+// inCart returns ErrNotInCart if the product is not in the cart.
+func (c *Cart) inCart(ctx context.Context, owner any, productID int64) error {
+	contents, err := c.Contents(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := find(contents.Items, productID); !ok {
+		return ErrNotInCart
+	}
+
+	return nil
+}
+
+func find(items []models.CartItem, productID int64) (models.CartItem, bool) {
+	for _, item := range items {
+		if int64(item.ProductID) == productID {
+			return item, true
+		}
+	}
+
+	return models.CartItem{}, false
+}
@@ -0,0 +1,297 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/storage"
+)
+
+// fakeStorage keeps products, carts and guest sessions in memory. Carts are keyed like the hub
+// keys their owners.
+type fakeStorage struct {
+	products map[int]models.Product
+	carts    map[string][]models.CartItem
+	sessions map[string]int
+	err      error
+}
+
+func newFakeStorage(items ...models.CartItem) *fakeStorage {
+	return &fakeStorage{
+		products: map[int]models.Product{
+			1: {ID: 1, Name: "Mug", Price: 10, Stock: 5},
+			2: {ID: 2, Name: "Poster", Price: 4, Stock: 100},
+			3: {ID: 3, Name: "Sold out", Price: 1, Stock: 0},
+		},
+		carts:    map[string][]models.CartItem{ownerKey(int64(7)): items},
+		sessions: map[string]int{"guest": 1},
+	}
+}
+
+func (s *fakeStorage) GetProduct(_ context.Context, id int) (models.Product, error) {
+	product, ok := s.products[id]
+	if !ok {
+		return models.Product{}, storage.ErrProductNotFound
+	}
+
+	return product, nil
+}
+
+func (s *fakeStorage) GetSession(_ context.Context, UUID string) (int, error) {
+	id, ok := s.sessions[UUID]
+	if !ok {
+		return 0, storage.ErrSessionNotFound
+	}
+
+	return id, nil
+}
+
+func (s *fakeStorage) GetCart(_ context.Context, userID any) ([]models.CartItem, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return slices.Clone(s.carts[ownerKey(userID)]), nil
+}
+
+func (s *fakeStorage) AddToCart(_ context.Context, productID, quantity int, userID any) error {
+	key := ownerKey(userID)
+	for i, item := range s.carts[key] {
+		if item.ProductID == productID {
+			s.carts[key][i].Quantity += quantity
+			return nil
+		}
+	}
+
+	product := s.products[productID]
+	s.carts[key] = append(s.carts[key], models.CartItem{
+		ProductID:    productID,
+		ProductName:  product.Name,
+		ProductPrice: product.Price,
+		Quantity:     quantity,
+	})
+
+	return nil
+}
+
+func (s *fakeStorage) UpdateCartQuantity(_ context.Context, productID, quantity int, userID any) error {
+	key := ownerKey(userID)
+	for i, item := range s.carts[key] {
+		if item.ProductID == productID {
+			s.carts[key][i].Quantity = quantity
+		}
+	}
+
+	return nil
+}
+
+func (s *fakeStorage) RemoveFromCart(_ context.Context, productID int, userID any) error {
+	key := ownerKey(userID)
+	s.carts[key] = slices.DeleteFunc(s.carts[key], func(item models.CartItem) bool {
+		return item.ProductID == productID
+	})
+
+	return nil
+}
+
+func (s *fakeStorage) MergeCart(_ context.Context, userID int64, sessionID string) error {
+	for _, item := range s.carts[ownerKey(sessionID)] {
+		if err := s.AddToCart(context.Background(), item.ProductID, item.Quantity, userID); err != nil {
+			return err
+		}
+	}
+	delete(s.carts, ownerKey(sessionID))
+
+	return nil
+}
+
+func mug(quantity int) models.CartItem {
+	return models.CartItem{ProductID: 1, ProductName: "Mug", ProductPrice: 10, Quantity: quantity}
+}
+
+// quantities returns the quantity of every product in the cart by product ID.
+func quantities(items []models.CartItem) map[int]int {
+	q := make(map[int]int, len(items))
+	for _, item := range items {
+		q[item.ProductID] = item.Quantity
+	}
+
+	return q
+}
+
+func TestAddItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		cart      []models.CartItem
+		productID int64
+		quantity  int
+		wantErr   error
+		want      map[int]int
+	}{
+		{name: "new product", productID: 1, quantity: 2, want: map[int]int{1: 2}},
+		{name: "adds up", cart: []models.CartItem{mug(2)}, productID: 1, quantity: 3, want: map[int]int{1: 5}},
+		{name: "zero quantity", productID: 1, quantity: 0, wantErr: ErrInvalidQuantity},
+		{name: "over the maximum", productID: 2, quantity: MaxQuantity + 1, wantErr: ErrInvalidQuantity},
+		{name: "sum over the maximum", cart: []models.CartItem{{ProductID: 2, Quantity: MaxQuantity}},
+			productID: 2, quantity: 1, wantErr: ErrInvalidQuantity},
+		{name: "unknown product", productID: 42, quantity: 1, wantErr: ErrProductNotFound},
+		{name: "out of stock", productID: 3, quantity: 1, wantErr: ErrOutOfStock},
+		{name: "sum over the stock", cart: []models.CartItem{mug(4)}, productID: 1, quantity: 2, wantErr: ErrOutOfStock},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeStorage(tt.cart...)
+			c := New(zap.NewNop(), s, NewHub(1))
+
+			contents, err := c.AddItem(context.Background(), int64(7), tt.productID, tt.quantity)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddItem error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if got := quantities(s.carts[ownerKey(int64(7))]); !maps.Equal(got, quantities(tt.cart)) {
+					t.Errorf("cart changed to %v on error", got)
+				}
+				return
+			}
+			if got := quantities(contents.Items); !maps.Equal(got, tt.want) {
+				t.Errorf("cart = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		cart      []models.CartItem
+		productID int64
+		quantity  int
+		wantErr   error
+		want      map[int]int
+	}{
+		{name: "sets the quantity", cart: []models.CartItem{mug(1)}, productID: 1, quantity: 4, want: map[int]int{1: 4}},
+		{name: "zero removes", cart: []models.CartItem{mug(1)}, productID: 1, quantity: 0, want: map[int]int{}},
+		{name: "negative quantity", cart: []models.CartItem{mug(1)}, productID: 1, quantity: -1, wantErr: ErrInvalidQuantity},
+		{name: "over the maximum", cart: []models.CartItem{mug(1)}, productID: 1, quantity: MaxQuantity + 1,
+			wantErr: ErrInvalidQuantity},
+		{name: "unknown product", productID: 42, quantity: 1, wantErr: ErrProductNotFound},
+		{name: "not in cart", productID: 2, quantity: 1, wantErr: ErrNotInCart},
+		{name: "out of stock", cart: []models.CartItem{mug(1)}, productID: 1, quantity: 6, wantErr: ErrOutOfStock},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeStorage(tt.cart...)
+			c := New(zap.NewNop(), s, NewHub(1))
+
+			contents, err := c.UpdateItem(context.Background(), int64(7), tt.productID, tt.quantity)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateItem error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if got := quantities(contents.Items); !maps.Equal(got, tt.want) {
+					t.Errorf("cart = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		cart      []models.CartItem
+		productID int64
+		wantErr   error
+		want      map[int]int
+	}{
+		{name: "removes", cart: []models.CartItem{mug(1), {ProductID: 2, Quantity: 3}}, productID: 1,
+			want: map[int]int{2: 3}},
+		{name: "not in cart", cart: []models.CartItem{mug(1)}, productID: 2, wantErr: ErrNotInCart},
+		{name: "empty cart", productID: 1, wantErr: ErrNotInCart},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(zap.NewNop(), newFakeStorage(tt.cart...), NewHub(1))
+
+			contents, err := c.RemoveItem(context.Background(), int64(7), tt.productID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RemoveItem error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if got := quantities(contents.Items); !maps.Equal(got, tt.want) {
+					t.Errorf("cart = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStorageErrorsAreReturned(t *testing.T) {
+	s := newFakeStorage()
+	s.err = errors.New("database is locked")
+	c := New(zap.NewNop(), s, NewHub(1))
+
+	if _, err := c.AddItem(context.Background(), int64(7), 1, 1); !errors.Is(err, s.err) {
+		t.Errorf("AddItem error = %v, want %v", err, s.err)
+	}
+	if _, err := c.RemoveItem(context.Background(), int64(7), 1); !errors.Is(err, s.err) {
+		t.Errorf("RemoveItem error = %v, want %v", err, s.err)
+	}
+}
+
+func TestMergeGuestCart(t *testing.T) {
+	s := newFakeStorage(mug(1))
+	s.carts[ownerKey("guest")] = []models.CartItem{mug(2), {ProductID: 2, Quantity: 1}}
+	hub := NewHub(1)
+	c := New(zap.NewNop(), s, hub)
+
+	guest, _, err := c.Subscribe(context.Background(), "guest")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer guest.Close()
+
+	contents, err := c.MergeGuestCart(context.Background(), 7, "guest")
+	if err != nil {
+		t.Fatalf("MergeGuestCart: %v", err)
+	}
+	if got, want := quantities(contents.Items), map[int]int{1: 3, 2: 1}; !maps.Equal(got, want) {
+		t.Errorf("cart = %v, want %v", got, want)
+	}
+	if update := <-guest.Updates(); update.Count != 0 {
+		t.Errorf("guest cart count = %d, want 0", update.Count)
+	}
+
+	if _, err := c.MergeGuestCart(context.Background(), 7, "expired"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("MergeGuestCart error = %v, want %v", err, ErrSessionNotFound)
+	}
+}
+
+func TestChangesArePublished(t *testing.T) {
+	c := New(zap.NewNop(), newFakeStorage(), NewHub(1))
+
+	sub, initial, err := c.Subscribe(context.Background(), int64(7))
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+	if initial.Count != 0 {
+		t.Errorf("initial count = %d, want 0", initial.Count)
+	}
+
+	if _, err := c.AddItem(context.Background(), int64(7), 2, 3); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	if update := <-sub.Updates(); update.Count != 3 || update.ID == initial.ID {
+		t.Errorf("update = %+v, want a new update with count 3", update)
+	}
+}
@@ -0,0 +1,181 @@
+package cart
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrTooManySubscribers is returned when an owner already has the maximum number of subscriptions.
+var ErrTooManySubscribers = errors.New("too many cart subscriptions")
+
+// Update is the item count of a cart after a change. IDs are unique across updates and
+// restarts of the process.
+type Update struct {
+	ID    string
+	Count int
+}
+
+// Hub fans cart updates out to the subscribers of each owner.
+//
+// It keeps the last update of an owner while the owner has subscribers, so a reconnecting
+// subscriber can tell whether it missed it. Publish never blocks: a subscriber only ever
+// needs the latest count, so an update replaces one still waiting in its buffer.
+type Hub struct {
+	maxSubscribers int
+	epoch          string
+
+	mu     sync.Mutex
+	seq    uint64
+	owners map[string]*ownerSubs
+	closed bool
+}
+
+type ownerSubs struct {
+	last Update
+	subs map[*Subscription]struct{}
+}
+
+// NewHub returns a hub allowing up to maxSubscribers subscriptions per owner.
+func NewHub(maxSubscribers int) *Hub {
+	return &Hub{
+		maxSubscribers: maxSubscribers,
+		epoch:          strconv.FormatInt(time.Now().UnixNano(), 36),
+		owners:         make(map[string]*ownerSubs),
+	}
+}
+
+type Subscription struct {
+	hub     *Hub
+	key     string
+	updates chan Update
+}
+
+// Publish delivers the new item count of the owner's cart to its subscribers.
+func (h *Hub) Publish(owner any, count int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	o, ok := h.owners[ownerKey(owner)]
+	if !ok {
+		return
+	}
+
+	o.last = h.next(count)
+	for sub := range o.subs {
+		sub.send(o.last)
+	}
+}
+
+// subscribe registers a subscription for the owner and returns it with the last update
+// of the owner, which has an empty ID if there is none.
+func (h *Hub) subscribe(owner any) (*Subscription, Update, error) {
+	key := ownerKey(owner)
+	sub := &Subscription{hub: h, key: key, updates: make(chan Update, 1)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(sub.updates)
+		return sub, Update{}, nil
+	}
+
+	// The owner is added only with its first subscription, so a rejected one leaves no entry.
+	o, ok := h.owners[key]
+	if !ok {
+		o = &ownerSubs{subs: make(map[*Subscription]struct{})}
+	}
+	if len(o.subs) >= h.maxSubscribers {
+		return nil, Update{}, ErrTooManySubscribers
+	}
+	o.subs[sub] = struct{}{}
+	h.owners[key] = o
+
+	return sub, o.last, nil
+}
+
+// initial records count as the first update of the owner, unless an update was published
+// meanwhile, and returns the last update.
+func (h *Hub) initial(owner any, count int) Update {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	o, ok := h.owners[ownerKey(owner)]
+	if !ok {
+		return h.next(count)
+	}
+	if o.last.ID == "" {
+		o.last = h.next(count)
+	}
+
+	return o.last
+}
+
+// Close closes the channels of all subscriptions.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+
+	for key, o := range h.owners {
+		for sub := range o.subs {
+			close(sub.updates)
+		}
+		delete(h.owners, key)
+	}
+}
+
+func (h *Hub) next(count int) Update {
+	h.seq++
+
+	return Update{ID: h.epoch + "-" + strconv.FormatUint(h.seq, 10), Count: count}
+}
+
+// Updates returns the channel of updates, closed when the subscription or the hub is closed.
+func (s *Subscription) Updates() <-chan Update {
+	return s.updates
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	o, ok := s.hub.owners[s.key]
+	if !ok {
+		return
+	}
+	if _, ok := o.subs[s]; !ok {
+		return
+	}
+	delete(o.subs, s)
+	close(s.updates)
+
+	if len(o.subs) == 0 {
+		delete(s.hub.owners, s.key)
+	}
+}
+
+// send replaces a pending update with update. Callers hold the hub lock.
+func (s *Subscription) send(update Update) {
+	select {
+	case <-s.updates:
+	default:
+	}
+	s.updates <- update
+}
+
+// ownerKey tells the carts of users and guest sessions apart.
+func ownerKey(owner any) string {
+	if sessionID, ok := owner.(string); ok {
+		return "session:" + sessionID
+	}
+
+	return fmt.Sprintf("user:%v", owner)
+}
@@ -0,0 +1,29 @@
+package cart
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRejectedSubscriptionLeavesNoOwner(t *testing.T) {
+	h := NewHub(0)
+	if _, _, err := h.subscribe(int64(7)); !errors.Is(err, ErrTooManySubscribers) {
+		t.Fatalf("subscribe error = %v, want %v", err, ErrTooManySubscribers)
+	}
+	if len(h.owners) != 0 {
+		t.Errorf("owners = %v after a rejected subscription, want none", h.owners)
+	}
+
+	h = NewHub(1)
+	sub, _, err := h.subscribe(int64(7))
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if _, _, err := h.subscribe(int64(7)); !errors.Is(err, ErrTooManySubscribers) {
+		t.Fatalf("subscribe over the cap error = %v, want %v", err, ErrTooManySubscribers)
+	}
+	sub.Close()
+	if len(h.owners) != 0 {
+		t.Errorf("owners = %v after the only subscription closed, want none", h.owners)
+	}
+}
@@ -0,0 +1,212 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/storage"
+	"shop/lib/cursor"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	ErrProductNotFound  = errors.New("product not found")
+	ErrInvalidPageToken = errors.New("invalid page token")
+	ErrPageOutOfRange   = errors.New("page out of range")
+)
+
+type Storage interface {
+	GetProduct(ctx context.Context, id int) (models.Product, error)
+	GetProducts(ctx context.Context, limit, offset int) ([]models.Product, error)
+	TotalProducts(ctx context.Context) (int, error)
+	ListProducts(ctx context.Context, filter models.ProductFilter, afterID int64, limit int) ([]models.Product, error)
+	ProductsByIDs(ctx context.Context, ids []int64) ([]models.Product, error)
+	Categories(ctx context.Context) ([]models.Category, error)
+}
+
+// Catalog serves the products and categories of the shop.
+type Catalog struct {
+	log     *zap.Logger
+	storage Storage
+}
+
+func New(log *zap.Logger, storage Storage) *Catalog {
+	return &Catalog{
+		log:     log,
+		storage: storage,
+	}
+}
+
+// ProductPage is a page of a product listing.
+type ProductPage struct {
+	Products      []models.Product
+	NextPageToken string // empty on the last page
+}
+
+// NumberedPage is a page of the product list browsed by page number.
+type NumberedPage struct {
+	Products      []models.Product
+	Page          int
+	TotalPages    int
+	TotalProducts int
+	StartResult   int // position of the first product of the page, from 1; 0 if the page is empty
+	EndResult     int
+	PrevPage      int
+	NextPage      int
+	PageNumbers   []int // the pages around Page to link to
+}
+
+func (c *Catalog) Product(ctx context.Context, id int64) (models.Product, error) {
+	const op = "catalog.Product"
+
+	product, err := c.storage.GetProduct(ctx, int(id))
+	if err != nil {
+		if errors.Is(err, storage.ErrProductNotFound) {
+			return models.Product{}, fmt.Errorf("%s, %w", op, ErrProductNotFound)
+		}
+
+		c.log.Error("failed to get product", zap.String("op", op), zap.Error(err))
+
+		return models.Product{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return product, nil
+}
+
+// ListProducts returns the page of products matching the filter after pageToken, the
+// next_page_token of the previous page. pageSize 0 means DefaultPageSize; it is capped at
+// MaxPageSize.
+func (c *Catalog) ListProducts(
+	ctx context.Context,
+	filter models.ProductFilter,
+	pageToken string,
+	pageSize int,
+) (ProductPage, error) {
+	const op = "catalog.ListProducts"
+
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	pageSize = min(pageSize, MaxPageSize)
+
+	afterID, err := cursor.Decode(pageToken)
+	if err != nil {
+		return ProductPage{}, fmt.Errorf("%s, %w", op, ErrInvalidPageToken)
+	}
+
+	// One extra row tells whether there is a next page.
+	products, err := c.storage.ListProducts(ctx, filter, afterID, pageSize+1)
+	if err != nil {
+		c.log.Error("failed to list products", zap.String("op", op), zap.Error(err))
+
+		return ProductPage{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	var page ProductPage
+	if len(products) > pageSize {
+		products = products[:pageSize]
+		page.NextPageToken = cursor.Encode(products[pageSize-1].ID)
+	}
+	page.Products = products
+
+	return page, nil
+}
+
+// Browse returns the numbered page, from 1, of the product list. A page past the last one
+// returns ErrPageOutOfRange.
+func (c *Catalog) Browse(ctx context.Context, page, perPage int) (NumberedPage, error) {
+	const op = "catalog.Browse"
+
+	page = max(page, 1)
+
+	total, err := c.storage.TotalProducts(ctx)
+	if err != nil {
+		c.log.Error("failed to count products", zap.String("op", op), zap.Error(err))
+
+		return NumberedPage{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	totalPages := max((total+perPage-1)/perPage, 1)
+	if page > totalPages {
+		return NumberedPage{}, fmt.Errorf("%s, %w", op, ErrPageOutOfRange)
+	}
+
+	products, err := c.storage.GetProducts(ctx, perPage, (page-1)*perPage)
+	if err != nil {
+		c.log.Error("failed to get products", zap.String("op", op), zap.Error(err))
+
+		return NumberedPage{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	result := NumberedPage{
+		Products:      products,
+		Page:          page,
+		TotalPages:    totalPages,
+		TotalProducts: total,
+		PrevPage:      max(page-1, 1),
+		NextPage:      min(page+1, totalPages),
+	}
+	if len(products) > 0 {
+		result.StartResult = (page-1)*perPage + 1
+		result.EndResult = result.StartResult + len(products) - 1
+	}
+	for p := max(page-2, 1); p <= min(page+2, totalPages); p++ {
+		result.PageNumbers = append(result.PageNumbers, p)
+	}
+
+	return result, nil
+}
+
+// ProductsByIDs returns the products with the ids in the order of ids, and the ids of the
+// products not found.
+func (c *Catalog) ProductsByIDs(ctx context.Context, ids []int64) ([]models.Product, []int64, error) {
+	const op = "catalog.ProductsByIDs"
+
+	products, err := c.storage.ProductsByIDs(ctx, ids)
+	if err != nil {
+		c.log.Error("failed to get products", zap.String("op", op), zap.Error(err))
+
+		return nil, nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	byID := make(map[int64]models.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	var (
+		found    []models.Product
+		notFound []int64
+	)
+	for _, id := range ids {
+		p, ok := byID[id]
+		if !ok {
+			notFound = append(notFound, id)
+			continue
+		}
+		found = append(found, p)
+	}
+
+	return found, notFound, nil
+}
+
+func (c *Catalog) Categories(ctx context.Context) ([]models.Category, error) {
+	const op = "catalog.Categories"
+
+	categories, err := c.storage.Categories(ctx)
+	if err != nil {
+		c.log.Error("failed to get categories", zap.String("op", op), zap.Error(err))
+
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return categories, nil
+}
@@ -0,0 +1,241 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/storage"
+	"shop/lib/cursor"
+)
+
+// fakeStorage serves products with the IDs 1 to n, in stock if their ID is even and in the
+// "even" or "odd" category accordingly.
+type fakeStorage struct {
+	products []models.Product
+	limits   []int
+}
+
+func newFakeStorage(n int) *fakeStorage {
+	s := &fakeStorage{}
+	for id := int64(1); id <= int64(n); id++ {
+		p := models.Product{ID: id, Name: fmt.Sprintf("Product %d", id), Price: float64(id), Category: "odd"}
+		if id%2 == 0 {
+			p.Stock, p.Category = 1, "even"
+		}
+		s.products = append(s.products, p)
+	}
+
+	return s
+}
+
+func (s *fakeStorage) GetProduct(_ context.Context, id int) (models.Product, error) {
+	for _, p := range s.products {
+		if p.ID == int64(id) {
+			return p, nil
+		}
+	}
+
+	return models.Product{}, storage.ErrProductNotFound
+}
+
+func (s *fakeStorage) GetProducts(_ context.Context, limit, offset int) ([]models.Product, error) {
+	offset = min(offset, len(s.products))
+
+	return s.products[offset:min(offset+limit, len(s.products))], nil
+}
+
+func (s *fakeStorage) TotalProducts(context.Context) (int, error) {
+	return len(s.products), nil
+}
+
+func (s *fakeStorage) ListProducts(
+	_ context.Context,
+	filter models.ProductFilter,
+	afterID int64,
+	limit int,
+) ([]models.Product, error) {
+	s.limits = append(s.limits, limit)
+
+	var products []models.Product
+	for _, p := range s.products {
+		switch {
+		case p.ID <= afterID,
+			filter.Category != "" && p.Category != filter.Category,
+			filter.InStockOnly && p.Stock == 0,
+			filter.MinPrice > 0 && p.Price < filter.MinPrice,
+			filter.MaxPrice > 0 && p.Price > filter.MaxPrice,
+			!strings.Contains(p.Name, filter.Query):
+			continue
+		}
+		if len(products) == limit {
+			break
+		}
+		products = append(products, p)
+	}
+
+	return products, nil
+}
+
+func (s *fakeStorage) ProductsByIDs(_ context.Context, ids []int64) ([]models.Product, error) {
+	var products []models.Product
+	for _, p := range s.products {
+		if slices.Contains(ids, p.ID) {
+			products = append(products, p)
+		}
+	}
+
+	return products, nil
+}
+
+func (s *fakeStorage) Categories(context.Context) ([]models.Category, error) {
+	return nil, nil
+}
+
+func ids(products []models.Product) []int64 {
+	ids := make([]int64, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, p.ID)
+	}
+
+	return ids
+}
+
+func TestListProducts(t *testing.T) {
+	tests := []struct {
+		name      string
+		total     int
+		filter    models.ProductFilter
+		pageToken string
+		pageSize  int
+		wantErr   error
+		wantIDs   []int64
+		wantNext  string
+		wantLimit int
+	}{
+		{name: "first page", total: 5, pageSize: 2, wantIDs: []int64{1, 2}, wantNext: cursor.Encode(2), wantLimit: 3},
+		{name: "next page", total: 5, pageToken: cursor.Encode(2), pageSize: 2, wantIDs: []int64{3, 4},
+			wantNext: cursor.Encode(4), wantLimit: 3},
+		{name: "last page", total: 4, pageToken: cursor.Encode(2), pageSize: 2, wantIDs: []int64{3, 4}, wantLimit: 3},
+		{name: "default page size", total: 3, wantIDs: []int64{1, 2, 3}, wantLimit: DefaultPageSize + 1},
+		{name: "capped page size", total: 3, pageSize: MaxPageSize + 1, wantIDs: []int64{1, 2, 3},
+			wantLimit: MaxPageSize + 1},
+		{name: "filter", total: 6, filter: models.ProductFilter{Category: "even", MinPrice: 3}, pageSize: 10,
+			wantIDs: []int64{4, 6}, wantLimit: 11},
+		{name: "in stock only", total: 5, filter: models.ProductFilter{InStockOnly: true}, pageSize: 1,
+			wantIDs: []int64{2}, wantNext: cursor.Encode(2), wantLimit: 2},
+		{name: "no match", total: 5, filter: models.ProductFilter{Query: "Lamp"}, wantIDs: []int64{},
+			wantLimit: DefaultPageSize + 1},
+		{name: "malformed page token", total: 5, pageToken: "not a cursor", wantErr: ErrInvalidPageToken},
+		{name: "negative page token", total: 5, pageToken: cursor.Encode(-1), wantErr: ErrInvalidPageToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeStorage(tt.total)
+			c := New(zap.NewNop(), s)
+
+			page, err := c.ListProducts(context.Background(), tt.filter, tt.pageToken, tt.pageSize)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ListProducts error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(s.limits) != 0 {
+					t.Error("storage was queried with an invalid page token")
+				}
+				return
+			}
+			if got := ids(page.Products); !slices.Equal(got, tt.wantIDs) {
+				t.Errorf("products = %v, want %v", got, tt.wantIDs)
+			}
+			if page.NextPageToken != tt.wantNext {
+				t.Errorf("next page token = %q, want %q", page.NextPageToken, tt.wantNext)
+			}
+			if !slices.Equal(s.limits, []int{tt.wantLimit}) {
+				t.Errorf("storage limits = %v, want [%d]", s.limits, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestBrowse(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int
+		page    int
+		wantErr error
+		want    NumberedPage
+		wantIDs []int64
+	}{
+		{name: "first page", total: 7, page: 1, wantIDs: []int64{1, 2, 3}, want: NumberedPage{
+			Page: 1, TotalPages: 3, TotalProducts: 7, StartResult: 1, EndResult: 3, PrevPage: 1, NextPage: 2,
+			PageNumbers: []int{1, 2, 3},
+		}},
+		{name: "last page", total: 7, page: 3, wantIDs: []int64{7}, want: NumberedPage{
+			Page: 3, TotalPages: 3, TotalProducts: 7, StartResult: 7, EndResult: 7, PrevPage: 2, NextPage: 3,
+			PageNumbers: []int{1, 2, 3},
+		}},
+		{name: "page before the first", total: 7, page: 0, wantIDs: []int64{1, 2, 3}, want: NumberedPage{
+			Page: 1, TotalPages: 3, TotalProducts: 7, StartResult: 1, EndResult: 3, PrevPage: 1, NextPage: 2,
+			PageNumbers: []int{1, 2, 3},
+		}},
+		{name: "empty catalog", total: 0, page: 1, wantIDs: []int64{}, want: NumberedPage{
+			Page: 1, TotalPages: 1, PrevPage: 1, NextPage: 1, PageNumbers: []int{1},
+		}},
+		{name: "page past the last", total: 7, page: 4, wantErr: ErrPageOutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(zap.NewNop(), newFakeStorage(tt.total))
+
+			got, err := c.Browse(context.Background(), tt.page, 3)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Browse error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if ids := ids(got.Products); !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("products = %v, want %v", ids, tt.wantIDs)
+			}
+			got.Products = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("page = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProduct(t *testing.T) {
+	c := New(zap.NewNop(), newFakeStorage(2))
+
+	if p, err := c.Product(context.Background(), 2); err != nil || p.ID != 2 {
+		t.Errorf("Product(2) = %+v, %v; want product 2", p, err)
+	}
+	if _, err := c.Product(context.Background(), 3); !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("Product(3) error = %v, want %v", err, ErrProductNotFound)
+	}
+}
+
+func TestProductsByIDs(t *testing.T) {
+	c := New(zap.NewNop(), newFakeStorage(5))
+
+	found, notFound, err := c.ProductsByIDs(context.Background(), []int64{4, 9, 1, 7})
+	if err != nil {
+		t.Fatalf("ProductsByIDs: %v", err)
+	}
+	if got := ids(found); !slices.Equal(got, []int64{4, 1}) {
+		t.Errorf("found %v, want [4 1] in the order asked", got)
+	}
+	if !slices.Equal(notFound, []int64{9, 7}) {
+		t.Errorf("not found %v, want [9 7]", notFound)
+	}
+}
@@ -0,0 +1,203 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/storage"
+	"shop/lib/cursor"
+	"shop/lib/pricing"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	ErrEmptyCart        = errors.New("cart is empty")
+	ErrOutOfStock       = errors.New("not enough items in stock")
+	ErrOrderNotFound    = errors.New("order not found")
+	ErrOrderFinalized   = errors.New("order can no longer be cancelled")
+	ErrInvalidPageToken = errors.New("invalid page token")
+)
+
+type Storage interface {
+	GetCart(ctx context.Context, userID any) ([]models.CartItem, error)
+	CreateOrder(ctx context.Context, order models.Order) (int64, error)
+	Order(ctx context.Context, orderID int64) (models.Order, error)
+	Orders(ctx context.Context, userID, beforeID int64, limit int) ([]models.Order, error)
+	CancelOrder(ctx context.Context, orderID int64) error
+}
+
+// CartUpdates is told about carts emptied by placing an order.
+type CartUpdates interface {
+	Publish(owner any, count int)
+}
+
+// Checkout turns carts into orders and manages the orders of users.
+type Checkout struct {
+	log     *zap.Logger
+	storage Storage
+	carts   CartUpdates
+}
+
+func New(log *zap.Logger, storage Storage, carts CartUpdates) *Checkout {
+	return &Checkout{
+		log:     log,
+		storage: storage,
+		carts:   carts,
+	}
+}
+
+// OrderPage is a page of the orders of a user, newest first.
+type OrderPage struct {
+	Orders        []models.Order
+	NextPageToken string // empty on the last page
+}
+
+// PlaceOrder places an order for the contents of the cart of the user, priced as the cart
+// shows it. The items are taken out of stock and the cart is emptied.
+func (c *Checkout) PlaceOrder(ctx context.Context, userID int64) (models.Order, error) {
+	const op = "checkout.PlaceOrder"
+
+	log := c.log.With(zap.String("op", op), zap.Int64("user_id", userID))
+
+	cart, err := c.storage.GetCart(ctx, userID)
+	if err != nil {
+		log.Error("failed to get cart", zap.Error(err))
+
+		return models.Order{}, fmt.Errorf("%s, %w", op, err)
+	}
+	if len(cart) == 0 {
+		return models.Order{}, fmt.Errorf("%s, %w", op, ErrEmptyCart)
+	}
+
+	sum := pricing.Calculate(cart)
+
+	order := models.Order{
+		UserID:   userID,
+		Status:   models.OrderStatusPlaced,
+		Subtotal: sum.Subtotal,
+		Shipping: sum.Shipping,
+		Tax:      sum.Tax,
+		Total:    sum.Total,
+	}
+	for _, item := range cart {
+		order.Items = append(order.Items, models.OrderItem{
+			ProductID:   int64(item.ProductID),
+			ProductName: item.ProductName,
+			Price:       item.ProductPrice,
+			Quantity:    item.Quantity,
+		})
+	}
+
+	orderID, err := c.storage.CreateOrder(ctx, order)
+	if err != nil {
+		if errors.Is(err, storage.ErrOutOfStock) {
+			return models.Order{}, fmt.Errorf("%s, %w", op, ErrOutOfStock)
+		}
+		log.Error("failed to create order", zap.Error(err))
+
+		return models.Order{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	log.Info("order placed", zap.Int64("order_id", orderID))
+	c.carts.Publish(userID, 0)
+
+	placed, err := c.storage.Order(ctx, orderID)
+	if err != nil {
+		log.Error("failed to get order", zap.Error(err))
+
+		return models.Order{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return placed, nil
+}
+
+// Order returns the order if it belongs to the user. Orders of other users are reported as
+// not found so their ids are not disclosed.
+func (c *Checkout) Order(ctx context.Context, userID, orderID int64) (models.Order, error) {
+	const op = "checkout.Order"
+
+	order, err := c.storage.Order(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, storage.ErrOrderNotFound) {
+			return models.Order{}, fmt.Errorf("%s, %w", op, ErrOrderNotFound)
+		}
+		c.log.Error("failed to get order", zap.String("op", op), zap.Error(err))
+
+		return models.Order{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	if order.UserID != userID {
+		return models.Order{}, fmt.Errorf("%s, %w", op, ErrOrderNotFound)
+	}
+
+	return order, nil
+}
+
+// Orders returns the page of the orders of the user after pageToken, the next_page_token
+// of the previous page. pageSize 0 means DefaultPageSize; it is capped at MaxPageSize.
+func (c *Checkout) Orders(ctx context.Context, userID int64, pageToken string, pageSize int) (OrderPage, error) {
+	const op = "checkout.Orders"
+
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	pageSize = min(pageSize, MaxPageSize)
+
+	beforeID, err := cursor.Decode(pageToken)
+	if err != nil {
+		return OrderPage{}, fmt.Errorf("%s, %w", op, ErrInvalidPageToken)
+	}
+
+	// One extra row tells whether there is a next page.
+	orders, err := c.storage.Orders(ctx, userID, beforeID, pageSize+1)
+	if err != nil {
+		c.log.Error("failed to list orders", zap.String("op", op), zap.Error(err))
+
+		return OrderPage{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	var page OrderPage
+	if len(orders) > pageSize {
+		orders = orders[:pageSize]
+		page.NextPageToken = cursor.Encode(orders[pageSize-1].ID)
+	}
+	page.Orders = orders
+
+	return page, nil
+}
+
+// CancelOrder cancels the order of the user and returns its items to stock.
+func (c *Checkout) CancelOrder(ctx context.Context, userID, orderID int64) (models.Order, error) {
+	const op = "checkout.CancelOrder"
+
+	order, err := c.Order(ctx, userID, orderID)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	if err := c.storage.CancelOrder(ctx, order.ID); err != nil {
+		if errors.Is(err, storage.ErrOrderFinalized) {
+			return models.Order{}, fmt.Errorf("%s, %w", op, ErrOrderFinalized)
+		}
+		c.log.Error("failed to cancel order", zap.String("op", op), zap.Error(err))
+
+		return models.Order{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	order, err = c.storage.Order(ctx, order.ID)
+	if err != nil {
+		c.log.Error("failed to get order", zap.String("op", op), zap.Error(err))
+
+		return models.Order{}, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return order, nil
+}
@@ -0,0 +1,257 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+	"shop/internal/storage"
+	"shop/lib/cursor"
+)
+
+// fakeStorage keeps carts and orders in memory and takes ordered items out of stock.
+type fakeStorage struct {
+	stock  map[int64]int
+	carts  map[int64][]models.CartItem
+	orders []models.Order
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{
+		stock: map[int64]int{1: 5, 2: 1},
+		carts: map[int64][]models.CartItem{
+			7: {
+				{ProductID: 1, ProductName: "Mug", ProductPrice: 10, Quantity: 2},
+				{ProductID: 2, ProductName: "Poster", ProductPrice: 5, Quantity: 1},
+			},
+		},
+	}
+}
+
+func (s *fakeStorage) GetCart(_ context.Context, userID any) ([]models.CartItem, error) {
+	return slices.Clone(s.carts[userID.(int64)]), nil
+}
+
+func (s *fakeStorage) CreateOrder(_ context.Context, order models.Order) (int64, error) {
+	for _, item := range order.Items {
+		if s.stock[item.ProductID] < item.Quantity {
+			return 0, storage.ErrOutOfStock
+		}
+	}
+	for _, item := range order.Items {
+		s.stock[item.ProductID] -= item.Quantity
+	}
+
+	order.ID = int64(len(s.orders) + 1)
+	s.orders = append(s.orders, order)
+	delete(s.carts, order.UserID)
+
+	return order.ID, nil
+}
+
+func (s *fakeStorage) Order(_ context.Context, orderID int64) (models.Order, error) {
+	if orderID < 1 || int(orderID) > len(s.orders) {
+		return models.Order{}, storage.ErrOrderNotFound
+	}
+
+	return s.orders[orderID-1], nil
+}
+
+func (s *fakeStorage) Orders(_ context.Context, userID, beforeID int64, limit int) ([]models.Order, error) {
+	var orders []models.Order
+	for _, order := range slices.Backward(s.orders) {
+		if order.UserID == userID && (beforeID == 0 || order.ID < beforeID) && len(orders) < limit {
+			orders = append(orders, order)
+		}
+	}
+
+	return orders, nil
+}
+
+func (s *fakeStorage) CancelOrder(_ context.Context, orderID int64) error {
+	order := &s.orders[orderID-1]
+	if order.Status != models.OrderStatusPlaced {
+		return storage.ErrOrderFinalized
+	}
+
+	order.Status = models.OrderStatusCancelled
+	for _, item := range order.Items {
+		s.stock[item.ProductID] += item.Quantity
+	}
+
+	return nil
+}
+
+// fakeCarts records the published cart counts.
+type fakeCarts struct {
+	published map[any]int
+}
+
+func (c *fakeCarts) Publish(owner any, count int) {
+	c.published[owner] = count
+}
+
+func TestPlaceOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  int64
+		stock   map[int64]int
+		wantErr error
+	}{
+		{name: "places", userID: 7},
+		{name: "empty cart", userID: 8, wantErr: ErrEmptyCart},
+		{name: "out of stock", userID: 7, stock: map[int64]int{1: 5, 2: 0}, wantErr: ErrOutOfStock},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeStorage()
+			if tt.stock != nil {
+				s.stock = tt.stock
+			}
+			carts := &fakeCarts{published: make(map[any]int)}
+			c := New(zap.NewNop(), s, carts)
+
+			order, err := c.PlaceOrder(context.Background(), tt.userID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PlaceOrder error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(s.orders) != 0 || len(carts.published) != 0 {
+					t.Errorf("failed order created %v and published %v", s.orders, carts.published)
+				}
+				return
+			}
+
+			if order.ID != 1 || order.UserID != 7 || order.Status != models.OrderStatusPlaced || len(order.Items) != 2 {
+				t.Errorf("order = %+v, want the placed order of user 7 with 2 items", order)
+			}
+			// 2 × 10 + 5, plus 21% tax and 4.99 shipping.
+			if order.Subtotal != 25 || math.Abs(order.Total-(25*1.21+4.99)) > 1e-9 {
+				t.Errorf("subtotal %v, total %v; want 25 and %v", order.Subtotal, order.Total, 25*1.21+4.99)
+			}
+			if s.stock[1] != 3 || s.stock[2] != 0 {
+				t.Errorf("stock = %v, want the ordered items taken out", s.stock)
+			}
+			if count, ok := carts.published[int64(7)]; !ok || count != 0 {
+				t.Errorf("published %v, want the emptied cart of user 7", carts.published)
+			}
+		})
+	}
+}
+
+func TestOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  int64
+		orderID int64
+		wantErr error
+	}{
+		{name: "own order", userID: 7, orderID: 1},
+		{name: "order of another user", userID: 8, orderID: 1, wantErr: ErrOrderNotFound},
+		{name: "unknown order", userID: 7, orderID: 2, wantErr: ErrOrderNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeStorage()
+			s.orders = []models.Order{{ID: 1, UserID: 7, Status: models.OrderStatusPlaced}}
+			c := New(zap.NewNop(), s, &fakeCarts{published: make(map[any]int)})
+
+			order, err := c.Order(context.Background(), tt.userID, tt.orderID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Order error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && order.ID != tt.orderID {
+				t.Errorf("order = %+v, want order %d", order, tt.orderID)
+			}
+		})
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  int64
+		status  string
+		wantErr error
+	}{
+		{name: "cancels", userID: 7, status: models.OrderStatusPlaced},
+		{name: "already cancelled", userID: 7, status: models.OrderStatusCancelled, wantErr: ErrOrderFinalized},
+		{name: "order of another user", userID: 8, status: models.OrderStatusPlaced, wantErr: ErrOrderNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeStorage()
+			s.orders = []models.Order{{ID: 1, UserID: 7, Status: tt.status,
+				Items: []models.OrderItem{{ProductID: 1, Quantity: 2}}}}
+			c := New(zap.NewNop(), s, &fakeCarts{published: make(map[any]int)})
+
+			order, err := c.CancelOrder(context.Background(), tt.userID, 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CancelOrder error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if s.stock[1] != 5 {
+					t.Errorf("stock = %d, want it unchanged", s.stock[1])
+				}
+				return
+			}
+			if order.Status != models.OrderStatusCancelled || s.stock[1] != 7 {
+				t.Errorf("order %+v, stock %d; want the order cancelled and its items back in stock", order, s.stock[1])
+			}
+		})
+	}
+}
+
+func TestOrders(t *testing.T) {
+	tests := []struct {
+		name      string
+		pageToken string
+		pageSize  int
+		wantErr   error
+		wantIDs   []int64
+		wantNext  string
+	}{
+		{name: "first page", pageSize: 2, wantIDs: []int64{5, 3}, wantNext: cursor.Encode(3)},
+		{name: "last page", pageToken: cursor.Encode(3), pageSize: 2, wantIDs: []int64{1}},
+		{name: "default page size", wantIDs: []int64{5, 3, 1}},
+		{name: "invalid page token", pageToken: "%", wantErr: ErrInvalidPageToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeStorage()
+			// Odd orders are of user 7, even ones of user 8.
+			for id := int64(1); id <= 5; id++ {
+				s.orders = append(s.orders, models.Order{ID: id, UserID: 7 + (id+1)%2})
+			}
+			c := New(zap.NewNop(), s, &fakeCarts{published: make(map[any]int)})
+
+			page, err := c.Orders(context.Background(), 7, tt.pageToken, tt.pageSize)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Orders error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var ids []int64
+			for _, order := range page.Orders {
+				ids = append(ids, order.ID)
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("orders = %v, want %v", ids, tt.wantIDs)
+			}
+			if page.NextPageToken != tt.wantNext {
+				t.Errorf("next page token = %q, want %q", page.NextPageToken, tt.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package changes
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"shop/internal/domain/models"
+)
+
+// Bus fans product changes out to subscribers.
+//
+// Publish never blocks: every subscriber has a bounded buffer and changes that do not fit
+// are dropped and counted, so a slow subscriber cannot hold up writers or other subscribers.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+type Subscription struct {
+	bus     *Bus
+	events  chan models.ProductChange
+	dropped atomic.Int64
+}
+
+// Subscribe returns a subscription buffering up to buffer changes.
+func (b *Bus) Subscribe(buffer int) *Subscription {
+	sub := &Subscription{
+		bus:    b,
+		events: make(chan models.ProductChange, buffer),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.events)
+		return sub
+	}
+	b.subs[sub] = struct{}{}
+
+	return sub
+}
+
+// Publish delivers the changes to every subscriber with room in its buffer.
+func (b *Bus) Publish(changes ...models.ProductChange) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		for _, change := range changes {
+			select {
+			case sub.events <- change:
+			default:
+				sub.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// Close closes the channels of all subscriptions.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Events returns the channel of changes, closed when the subscription or the bus is closed.
+func (s *Subscription) Events() <-chan models.ProductChange {
+	return s.events
+}
+
+// Dropped returns the number of changes dropped since the previous call because the buffer was full.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if _, ok := s.bus.subs[s]; !ok {
+		return
+	}
+	delete(s.bus.subs, s)
+	close(s.events)
+}
@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"shop/internal/domain/models"
+	"shop/internal/storage/changes"
+)
+
+const (
+	publishBatch = 500
+	// pollInterval is how often PollChanges looks for changes written by other processes.
+	pollInterval = time.Second
+)
+
+// Changes returns the bus the storage publishes product changes to after its writes.
+func (s *Storage) Changes() *changes.Bus {
+	return s.changes
+}
+
+// ProductChanges returns up to limit logged product changes with seq greater than afterSeq, oldest first.
+func (s *Storage) ProductChanges(ctx context.Context, afterSeq int64, limit int) ([]models.ProductChange, error) {
+	const op = "storage.ProductChanges"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT pc.seq, pc.product_id, COALESCE(c.name, ''), pc.kind, pc.price, pc.stock, pc.changed_at
+		FROM product_changes AS pc
+		LEFT JOIN products AS p ON p.id = pc.product_id
+		LEFT JOIN categories AS c ON c.id = p.category_id
+		WHERE pc.seq > ?
+		ORDER BY pc.seq
+		LIMIT ?`, afterSeq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query product changes: %w", op, err)
+	}
+	defer rows.Close()
+
+	var result []models.ProductChange
+	for rows.Next() {
+		var c models.ProductChange
+		if err := rows.Scan(&c.Seq, &c.ProductID, &c.Category, &c.Kind, &c.Price, &c.Stock, &c.ChangedAt); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan product change: %w", op, err)
+		}
+		result = append(result, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to scan product changes: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// LastProductChange returns the seq of the latest logged product change, 0 if there is none.
+func (s *Storage) LastProductChange(ctx context.Context) (int64, error) {
+	const op = "storage.LastProductChange"
+
+	var seq int64
+	row := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM product_changes`)
+	if err := row.Scan(&seq); err != nil {
+		return 0, fmt.Errorf("%s: failed to fetch last product change: %w", op, err)
+	}
+
+	return seq, nil
+}
+
+// PollChanges publishes the changes other processes write to the database, which the storage
+// doesn't see committed, until ctx is done.
+func (s *Storage) PollChanges(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.publishChanges(ctx)
+		}
+	}
+}
+
+// publishChanges publishes the changes logged since the last publish, including those
+// written to the database by other processes. It is called after committed writes and
+// by PollChanges; if reading fails, the changes are published by the next call.
+func (s *Storage) publishChanges(ctx context.Context) {
+	s.publishMu.Lock()
+	defer s.publishMu.Unlock()
+
+	for {
+		batch, err := s.ProductChanges(context.WithoutCancel(ctx), s.publishedSeq, publishBatch)
+		if err != nil || len(batch) == 0 {
+			return
+		}
+
+		s.changes.Publish(batch...)
+		s.publishedSeq = batch[len(batch)-1].Seq
+
+		if len(batch) < publishBatch {
+			return
+		}
+	}
+}
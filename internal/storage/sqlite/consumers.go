@@ -0,0 +1,151 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"shop/internal/domain/models"
+	"shop/internal/storage"
+)
+
+// EventProcessed reports whether the consumer group already handled the event.
+func (s *Storage) EventProcessed(ctx context.Context, group, eventID string) (bool, error) {
+	const op = "storage.EventProcessed"
+
+	var exists bool
+	row := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM processed_events WHERE group_name = ? AND event_id = ?)`, group, eventID)
+	if err := row.Scan(&exists); err != nil {
+		return false, fmt.Errorf("%s: failed to query processed events: %w", op, err)
+	}
+
+	return exists, nil
+}
+
+func (s *Storage) MarkEventProcessed(ctx context.Context, group, eventID string) error {
+	const op = "storage.MarkEventProcessed"
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO processed_events (group_name, event_id)
+		VALUES(?, ?)`, group, eventID)
+	if err != nil {
+		return fmt.Errorf("%s: failed to insert processed event: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveDeadLetter(ctx context.Context, dl models.DeadLetter) (int64, error) {
+	const op = "storage.SaveDeadLetter"
+
+	headers, err := json.Marshal(dl.Headers)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to encode headers: %w", op, err)
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO dead_letters (group_name, original_topic, replay_topic, key, value, headers, error, attempts, failed_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		dl.Group, dl.OriginalTopic, dl.ReplayTopic, dl.Key, dl.Value, string(headers), dl.Error, dl.Attempts, dl.FailedAt.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to insert dead letter: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to get dead letter id: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// DeadLetters returns up to limit dead letters, newest first.
+func (s *Storage) DeadLetters(ctx context.Context, limit int) ([]models.DeadLetter, error) {
+	const op = "storage.DeadLetters"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, group_name, original_topic, replay_topic, key, value, headers, error, attempts, failed_at, replayed_at
+		FROM dead_letters
+		ORDER BY id DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query dead letters: %w", op, err)
+	}
+	defer rows.Close()
+
+	var letters []models.DeadLetter
+	for rows.Next() {
+		dl, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		letters = append(letters, dl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to scan dead letters: %w", op, err)
+	}
+
+	return letters, nil
+}
+
+func (s *Storage) DeadLetter(ctx context.Context, id int64) (models.DeadLetter, error) {
+	const op = "storage.DeadLetter"
+
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, group_name, original_topic, replay_topic, key, value, headers, error, attempts, failed_at, replayed_at
+		FROM dead_letters
+		WHERE id = ?`, id)
+
+	dl, err := scanDeadLetter(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DeadLetter{}, fmt.Errorf("%s: dead letter not found: %w", op, storage.ErrDeadLetterNotFound)
+		}
+
+		return models.DeadLetter{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return dl, nil
+}
+
+func (s *Storage) MarkDeadLetterReplayed(ctx context.Context, id int64) error {
+	const op = "storage.MarkDeadLetterReplayed"
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE dead_letters SET replayed_at = ?
+		WHERE id = ?`, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: failed to update dead letter %d: %w", op, id, err)
+	}
+
+	return nil
+}
+
+func scanDeadLetter(row interface{ Scan(dest ...any) error }) (models.DeadLetter, error) {
+	var (
+		dl         models.DeadLetter
+		headers    string
+		replayedAt sql.NullTime
+	)
+	err := row.Scan(&dl.ID, &dl.Group, &dl.OriginalTopic, &dl.ReplayTopic, &dl.Key, &dl.Value,
+		&headers, &dl.Error, &dl.Attempts, &dl.FailedAt, &replayedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DeadLetter{}, err
+		}
+
+		return models.DeadLetter{}, fmt.Errorf("failed to scan dead letter: %w", err)
+	}
+	if err := json.Unmarshal([]byte(headers), &dl.Headers); err != nil {
+		return models.DeadLetter{}, fmt.Errorf("failed to decode headers of dead letter %d: %w", dl.ID, err)
+	}
+	if replayedAt.Valid {
+		dl.ReplayedAt = &replayedAt.Time
+	}
+
+	return dl, nil
+}
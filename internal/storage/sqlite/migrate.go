@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrate applies every migration from the migrations directory that has not been applied yet.
+// Applied versions are tracked in the schema_migrations table.
+func (s *Storage) migrate(ctx context.Context) error {
+	const op = "storage.migrate"
+
+	_, err := s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations
+		(
+			version    TEXT     not null primary key,
+			applied_at DATETIME default CURRENT_TIMESTAMP not null
+		)`)
+	if err != nil {
+		return fmt.Errorf("%s: failed to create schema_migrations: %w", op, err)
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("%s: failed to list migrations: %w", op, err)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		version := strings.TrimSuffix(strings.TrimPrefix(file, "migrations/"), ".sql")
+
+		var applied int
+		row := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, version)
+		if err := row.Scan(&applied); err != nil {
+			return fmt.Errorf("%s: failed to check migration %s: %w", op, version, err)
+		}
+		if applied > 0 {
+			continue
+		}
+
+		query, err := migrations.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("%s: failed to read migration %s: %w", op, version, err)
+		}
+
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, string(query)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("%s: failed to apply migration %s: %w", op, version, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("%s: failed to record migration %s: %w", op, version, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("%s: failed to commit migration %s: %w", op, version, err)
+		}
+	}
+
+	return nil
+}
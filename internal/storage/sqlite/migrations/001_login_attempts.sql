@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS login_attempts
+(
+    key            TEXT     not null
+        constraint login_attempts_pk
+            primary key,
+    failures       INTEGER  default 0 not null,
+    last_failed_at DATETIME not null,
+    locked_until   DATETIME
+);
@@ -0,0 +1,66 @@
+CREATE TABLE IF NOT EXISTS roles
+(
+    id   INTEGER not null
+        constraint roles_pk
+            primary key autoincrement,
+    name TEXT    not null
+        constraint roles_pk_2
+            unique
+);
+
+CREATE TABLE IF NOT EXISTS permissions
+(
+    id   INTEGER not null
+        constraint permissions_pk
+            primary key autoincrement,
+    name TEXT    not null
+        constraint permissions_pk_2
+            unique
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions
+(
+    role_id       INTEGER not null
+        constraint role_permissions_roles_id_fk
+            references roles
+            on delete cascade,
+    permission_id INTEGER not null
+        constraint role_permissions_permissions_id_fk
+            references permissions
+            on delete cascade,
+    constraint role_permissions_pk
+        primary key (role_id, permission_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles
+(
+    user_id INTEGER not null,
+    role_id INTEGER not null
+        constraint user_roles_roles_id_fk
+            references roles
+            on delete cascade,
+    constraint user_roles_pk
+        primary key (user_id, role_id)
+);
+
+INSERT OR IGNORE INTO roles (name)
+VALUES ('customer'),
+       ('support'),
+       ('catalog_manager'),
+       ('admin');
+
+INSERT OR IGNORE INTO permissions (name)
+VALUES ('catalog:write'),
+       ('orders:read'),
+       ('orders:manage'),
+       ('users:read'),
+       ('users:unlock'),
+       ('roles:manage');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles AS r
+         JOIN permissions AS p
+WHERE (r.name = 'support' AND p.name IN ('orders:read', 'users:read', 'users:unlock'))
+   OR (r.name = 'catalog_manager' AND p.name IN ('catalog:write'))
+   OR r.name = 'admin';
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS identities
+(
+    id         INTEGER  not null
+        constraint identities_pk
+            primary key autoincrement,
+    user_id    INTEGER  not null,
+    provider   TEXT     not null,
+    subject    TEXT     not null,
+    email      TEXT,
+    created_at DATETIME default CURRENT_TIMESTAMP not null,
+    constraint identities_pk_2
+        unique (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS identities_user_id_index
+    on identities (user_id);
@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS orders
+(
+    id         INTEGER  not null
+        constraint orders_pk
+            primary key autoincrement,
+    user_id    INTEGER  not null
+        constraint orders_users_id_fk
+            references users
+            on delete cascade,
+    status     TEXT     not null,
+    subtotal   REAL     not null,
+    shipping   REAL     not null,
+    tax        REAL     not null,
+    total      REAL     not null,
+    created_at DATETIME default CURRENT_TIMESTAMP not null
+);
+
+CREATE INDEX IF NOT EXISTS orders_user_id_index
+    on orders (user_id);
+
+CREATE TABLE IF NOT EXISTS order_items
+(
+    order_id     INTEGER not null
+        constraint order_items_orders_id_fk
+            references orders
+            on delete cascade,
+    product_id   INTEGER not null
+        constraint order_items_products_id_fk
+            references products
+            on delete restrict,
+    product_name TEXT    not null,
+    price        REAL    not null,
+    quantity     INTEGER not null,
+    constraint order_items_pk
+        primary key (order_id, product_id)
+);
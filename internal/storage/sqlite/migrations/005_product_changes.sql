@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS product_changes
+(
+    seq        INTEGER  not null
+        constraint product_changes_pk
+            primary key autoincrement,
+    product_id INTEGER  not null,
+    kind       TEXT     not null,
+    price      REAL     not null,
+    stock      INTEGER  not null,
+    changed_at DATETIME default CURRENT_TIMESTAMP not null
+);
+
+-- Every stock or price change of a product is logged, whoever writes it,
+-- so watchers can resume from the sequence number of the last change they saw.
+CREATE TRIGGER IF NOT EXISTS product_changes_stock
+    AFTER UPDATE OF stock ON products
+    WHEN old.stock IS NOT new.stock
+BEGIN
+    INSERT INTO product_changes (product_id, kind, price, stock)
+    VALUES (new.id, 'stock', new.price, new.stock);
+END;
+
+CREATE TRIGGER IF NOT EXISTS product_changes_price
+    AFTER UPDATE OF price ON products
+    WHEN old.price IS NOT new.price
+BEGIN
+    INSERT INTO product_changes (product_id, kind, price, stock)
+    VALUES (new.id, 'price', new.price, new.stock);
+END;
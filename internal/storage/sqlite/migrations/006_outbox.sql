@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id              INTEGER  not null
+        constraint outbox_pk
+            primary key autoincrement,
+    topic           TEXT     not null,
+    key             TEXT     not null,
+    value           BLOB     not null,
+    headers         TEXT     not null,
+    created_at      DATETIME default CURRENT_TIMESTAMP not null,
+    attempts        INTEGER  default 0 not null,
+    next_attempt_at DATETIME default CURRENT_TIMESTAMP not null,
+    last_error      TEXT     default '' not null,
+    sent_at         DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_index
+    on outbox (id) WHERE sent_at IS NULL;
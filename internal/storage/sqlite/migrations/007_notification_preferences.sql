@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS notification_preferences
+(
+    user_id    INTEGER  not null
+        constraint notification_preferences_pk
+            primary key
+        constraint notification_preferences_users_id_fk
+            references users
+            on delete cascade,
+    account    INTEGER  default 1 not null,
+    orders     INTEGER  default 1 not null,
+    updated_at DATETIME default CURRENT_TIMESTAMP not null
+);
@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS processed_events
+(
+    group_name   TEXT     not null,
+    event_id     TEXT     not null,
+    processed_at DATETIME default CURRENT_TIMESTAMP not null,
+    constraint processed_events_pk
+        primary key (group_name, event_id)
+);
+
+CREATE TABLE IF NOT EXISTS dead_letters
+(
+    id             INTEGER  not null
+        constraint dead_letters_pk
+            primary key autoincrement,
+    group_name     TEXT     not null,
+    original_topic TEXT     not null,
+    replay_topic   TEXT     not null,
+    key            TEXT     not null,
+    value          BLOB     not null,
+    headers        TEXT     not null,
+    error          TEXT     not null,
+    attempts       INTEGER  not null,
+    failed_at      DATETIME not null,
+    replayed_at    DATETIME
+);
+
+INSERT OR IGNORE INTO permissions (name)
+VALUES ('events:manage');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles AS r
+         JOIN permissions AS p
+WHERE r.name = 'admin'
+  AND p.name = 'events:manage';
@@ -0,0 +1,75 @@
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         INTEGER  not null
+        constraint webhooks_pk
+            primary key autoincrement,
+    url        TEXT     not null,
+    secret     TEXT     not null,
+    active     INTEGER  default 1 not null,
+    created_at DATETIME default CURRENT_TIMESTAMP not null
+);
+
+CREATE TABLE IF NOT EXISTS webhook_events
+(
+    webhook_id INTEGER not null
+        constraint webhook_events_webhooks_id_fk
+            references webhooks
+            on delete cascade,
+    event_type TEXT    not null,
+    constraint webhook_events_pk
+        primary key (webhook_id, event_type)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              INTEGER  not null
+        constraint webhook_deliveries_pk
+            primary key autoincrement,
+    webhook_id      INTEGER  not null
+        constraint webhook_deliveries_webhooks_id_fk
+            references webhooks
+            on delete cascade,
+    event_id        TEXT     not null,
+    event_type      TEXT     not null,
+    payload         BLOB     not null,
+    status          TEXT     default 'pending' not null,
+    attempts        INTEGER  default 0 not null,
+    next_attempt_at DATETIME default CURRENT_TIMESTAMP not null,
+    response_code   INTEGER  default 0 not null,
+    last_error      TEXT     default '' not null,
+    created_at      DATETIME default CURRENT_TIMESTAMP not null,
+    delivered_at    DATETIME,
+    constraint webhook_deliveries_pk_2
+        unique (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_index
+    on webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS webhook_attempts
+(
+    id            INTEGER  not null
+        constraint webhook_attempts_pk
+            primary key autoincrement,
+    delivery_id   INTEGER  not null
+        constraint webhook_attempts_webhook_deliveries_id_fk
+            references webhook_deliveries
+            on delete cascade,
+    attempted_at  DATETIME not null,
+    response_code INTEGER  not null,
+    error         TEXT     not null,
+    duration_ms   INTEGER  not null
+);
+
+CREATE INDEX IF NOT EXISTS webhook_attempts_delivery_index
+    on webhook_attempts (delivery_id);
+
+INSERT OR IGNORE INTO permissions (name)
+VALUES ('webhooks:manage');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles AS r
+         JOIN permissions AS p
+WHERE r.name = 'admin'
+  AND p.name = 'webhooks:manage';
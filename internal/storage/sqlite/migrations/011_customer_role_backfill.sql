@@ -0,0 +1,4 @@
+INSERT OR IGNORE INTO user_roles (user_id, role_id)
+SELECT u.id, r.id
+FROM users AS u
+         JOIN roles AS r ON r.name = 'customer';
@@ -0,0 +1,6 @@
+ALTER TABLE outbox ADD COLUMN parked_at DATETIME;
+
+DROP INDEX IF EXISTS outbox_pending_index;
+
+CREATE INDEX IF NOT EXISTS outbox_pending_index
+    on outbox (id) WHERE sent_at IS NULL AND parked_at IS NULL;
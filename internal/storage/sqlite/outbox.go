@@ -0,0 +1,170 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"shop/internal/domain/models"
+	"shop/internal/events"
+)
+
+// enqueue saves the messages to the outbox in the transaction of the change they announce.
+func enqueue(ctx context.Context, tx *sql.Tx, msgs ...events.Message) error {
+	for _, msg := range msgs {
+		headers, err := json.Marshal(msg.Headers)
+		if err != nil {
+			return fmt.Errorf("failed to encode headers: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO outbox (topic, key, value, headers)
+			VALUES(?, ?, ?, ?)`, msg.Topic, msg.Key, msg.Value, string(headers))
+		if err != nil {
+			return fmt.Errorf("failed to insert outbox message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// enqueueStockChanged enqueues a StockChanged event with the new stock of every product
+// whose stock the transaction changed by the given delta, in the order of product IDs.
+func enqueueStockChanged(ctx context.Context, tx *sql.Tx, deltas map[int64]int) error {
+	for _, productID := range slices.Sorted(maps.Keys(deltas)) {
+		delta := deltas[productID]
+		var stock int
+		row := tx.QueryRowContext(ctx, `SELECT stock FROM products WHERE id = ?`, productID)
+		if err := row.Scan(&stock); err != nil {
+			return fmt.Errorf("failed to fetch stock of product %d: %w", productID, err)
+		}
+
+		msg, err := events.NewMessage(ctx, events.TopicStockChanged, strconv.FormatInt(productID, 10), events.StockChanged{
+			ProductID: productID,
+			Stock:     stock,
+			Delta:     delta,
+		})
+		if err != nil {
+			return err
+		}
+		if err := enqueue(ctx, tx, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PendingOutbox returns up to limit unsent outbox messages with IDs greater than afterID,
+// oldest first. Parked messages are left out.
+func (s *Storage) PendingOutbox(ctx context.Context, afterID int64, limit int) ([]models.OutboxMessage, error) {
+	const op = "storage.PendingOutbox"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, topic, key, value, headers, created_at, attempts, next_attempt_at
+		FROM outbox
+		WHERE sent_at IS NULL AND parked_at IS NULL AND id > ?
+		ORDER BY id
+		LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query outbox: %w", op, err)
+	}
+	defer rows.Close()
+
+	var msgs []models.OutboxMessage
+	for rows.Next() {
+		var (
+			m       models.OutboxMessage
+			headers string
+		)
+		err := rows.Scan(&m.ID, &m.Topic, &m.Key, &m.Value, &headers, &m.CreatedAt, &m.Attempts, &m.NextAttemptAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to scan outbox message: %w", op, err)
+		}
+		if err := json.Unmarshal([]byte(headers), &m.Headers); err != nil {
+			return nil, fmt.Errorf("%s: failed to decode headers of outbox message %d: %w", op, m.ID, err)
+		}
+		msgs = append(msgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to scan outbox messages: %w", op, err)
+	}
+
+	return msgs, nil
+}
+
+func (s *Storage) MarkOutboxSent(ctx context.Context, ids ...int64) error {
+	const op = "storage.MarkOutboxSent"
+
+	for _, id := range ids {
+		_, err := s.db.ExecContext(ctx, `
+			UPDATE outbox SET sent_at = ?, last_error = ''
+			WHERE id = ?`, time.Now().UTC(), id)
+		if err != nil {
+			return fmt.Errorf("%s: failed to update outbox message %d: %w", op, id, err)
+		}
+	}
+
+	return nil
+}
+
+// MarkOutboxFailed records a failed publish of the message and when to try it again.
+func (s *Storage) MarkOutboxFailed(ctx context.Context, id int64, nextAttemptAt time.Time, reason string) error {
+	const op = "storage.MarkOutboxFailed"
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE outbox SET attempts = attempts + 1, next_attempt_at = ?, last_error = ?
+		WHERE id = ?`, nextAttemptAt.UTC(), reason, id)
+	if err != nil {
+		return fmt.Errorf("%s: failed to update outbox message %d: %w", op, id, err)
+	}
+
+	return nil
+}
+
+// ParkOutbox records the last failed publish of the message and sets it aside for good.
+func (s *Storage) ParkOutbox(ctx context.Context, id int64, reason string) error {
+	const op = "storage.ParkOutbox"
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE outbox SET attempts = attempts + 1, parked_at = ?, last_error = ?
+		WHERE id = ?`, time.Now().UTC(), reason, id)
+	if err != nil {
+		return fmt.Errorf("%s: failed to update outbox message %d: %w", op, id, err)
+	}
+
+	return nil
+}
+
+// OutboxBacklog returns the number of unsent outbox messages and when the oldest was created,
+// zero if there are none. Parked messages are not counted.
+func (s *Storage) OutboxBacklog(ctx context.Context) (int, time.Time, error) {
+	const op = "storage.OutboxBacklog"
+
+	var count int
+	row := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM outbox WHERE sent_at IS NULL AND parked_at IS NULL`)
+	if err := row.Scan(&count); err != nil {
+		return 0, time.Time{}, fmt.Errorf("%s: failed to count outbox messages: %w", op, err)
+	}
+	if count == 0 {
+		return 0, time.Time{}, nil
+	}
+
+	var oldest time.Time
+	row = s.db.QueryRowContext(ctx, `
+		SELECT created_at
+		FROM outbox
+		WHERE sent_at IS NULL AND parked_at IS NULL
+		ORDER BY id
+		LIMIT 1`)
+	if err := row.Scan(&oldest); err != nil {
+		return 0, time.Time{}, fmt.Errorf("%s: failed to fetch oldest outbox message: %w", op, err)
+	}
+
+	return count, oldest, nil
+}
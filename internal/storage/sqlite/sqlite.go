@@ -5,17 +5,27 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/mattn/go-sqlite3"
 	_ "github.com/mattn/go-sqlite3"
 
 	"shop/internal/domain/models"
+	"shop/internal/events"
 	"shop/internal/storage"
+	"shop/internal/storage/changes"
 )
 
 type Storage struct {
-	db *sql.DB
+	db      *sql.DB
+	changes *changes.Bus
+
+	publishMu    sync.Mutex
+	publishedSeq int64
 }
 
 func New(path string) (*Storage, error) {
@@ -24,7 +34,23 @@ func New(path string) (*Storage, error) {
 		return nil, err
 	}
 
-	return &Storage{db: db}, nil
+	s := &Storage{db: db, changes: changes.NewBus()}
+
+	if err := s.migrate(context.Background()); err != nil {
+		return nil, err
+	}
+
+	s.publishedSeq, err = s.LastProductChange(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Close closes the database. Pending queries are waited for.
+func (s *Storage) Close() error {
+	return s.db.Close()
 }
 
 func (s *Storage) GetProduct(ctx context.Context, id int) (models.Product, error) {
@@ -98,15 +124,138 @@ func (s *Storage) GetProducts(ctx context.Context, limit, offset int) ([]models.
 	return p, nil
 }
 
-func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
-	stmt, err := s.db.Prepare(`
-		INSERT INTO users(email, pass_hash) 
-		VALUES(?, ?)`)
+// ListProducts returns up to limit products matching the filter with id greater than afterID, ordered by id.
+func (s *Storage) ListProducts(ctx context.Context, filter models.ProductFilter, afterID int64, limit int) ([]models.Product, error) {
+	const op = "storage.ListProducts"
+
+	query := `
+		SELECT p.id, p.name, p.description, p.price, p.stock, c.name
+		FROM products AS p
+		JOIN categories AS c ON c.id = p.category_id
+		WHERE p.id > ?`
+	args := []any{afterID}
+
+	if filter.Category != "" {
+		query += ` AND c.name = ?`
+		args = append(args, filter.Category)
+	}
+	if filter.Query != "" {
+		query += ` AND p.name LIKE '%' || ? || '%'`
+		args = append(args, filter.Query)
+	}
+	if filter.MinPrice > 0 {
+		query += ` AND p.price >= ?`
+		args = append(args, filter.MinPrice)
+	}
+	if filter.MaxPrice > 0 {
+		query += ` AND p.price <= ?`
+		args = append(args, filter.MaxPrice)
+	}
+	if filter.InStockOnly {
+		query += ` AND p.stock > 0`
+	}
+
+	query += ` ORDER BY p.id LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to prepare statement: %w", err)
+		return nil, fmt.Errorf("%s: failed to query products: %w", op, err)
 	}
+	defer rows.Close()
 
-	res, err := stmt.ExecContext(ctx, email, passHash)
+	var products []models.Product
+	for rows.Next() {
+		var p models.Product
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock, &p.Category); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan product: %w", op, err)
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to scan products: %w", op, err)
+	}
+
+	return products, nil
+}
+
+// ProductsByIDs returns the products with the given ids; missing ids are skipped.
+func (s *Storage) ProductsByIDs(ctx context.Context, ids []int64) ([]models.Product, error) {
+	const op = "storage.ProductsByIDs"
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p.id, p.name, p.description, p.price, p.stock, c.name
+		FROM products AS p
+		JOIN categories AS c ON c.id = p.category_id
+		WHERE p.id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query products: %w", op, err)
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var p models.Product
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock, &p.Category); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan product: %w", op, err)
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to scan products: %w", op, err)
+	}
+
+	return products, nil
+}
+
+func (s *Storage) Categories(ctx context.Context) ([]models.Category, error) {
+	const op = "storage.Categories"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name
+		FROM categories
+		ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query categories: %w", op, err)
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan category: %w", op, err)
+		}
+		categories = append(categories, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to scan categories: %w", op, err)
+	}
+
+	return categories, nil
+}
+
+// SaveUser saves the user with the customer role and queues the UserRegistered event
+// of the registration method.
+func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte, method string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO users(email, pass_hash) 
+		VALUES(?, ?)`, email, passHash)
 	if err != nil {
 		var sqliteErr sqlite3.Error
 
@@ -122,9 +271,45 @@ func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (
 		return 0, fmt.Errorf("failed to fetch user id: %w", err)
 	}
 
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_roles(user_id, role_id)
+		SELECT ?, id FROM roles WHERE name = ?`, id, models.RoleCustomer)
+	if err != nil {
+		return 0, fmt.Errorf("failed to assign default role: %w", err)
+	}
+
+	msg, err := events.NewMessage(ctx, events.TopicUserRegistered, strconv.FormatInt(id, 10), events.UserRegistered{
+		UserID: id,
+		Email:  email,
+		Method: method,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := enqueue(ctx, tx, msg); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit user: %w", err)
+	}
+
 	return id, nil
 }
 
+func (s *Storage) UpdatePassHash(ctx context.Context, userID int64, passHash []byte) error {
+	const op = "storage.UpdatePassHash"
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE users SET pass_hash = ?
+		WHERE id = ?`, passHash, userID)
+	if err != nil {
+		return fmt.Errorf("%s: failed to update password hash: %w", op, err)
+	}
+
+	return nil
+}
+
 func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	const op = "storage.User"
 
@@ -151,45 +336,275 @@ func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	return user, nil
 }
 
+func (s *Storage) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	const op = "storage.UserByID"
+
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, email, pass_hash
+		FROM users
+		WHERE id = ?`, userID)
+
+	var user models.User
+	err := row.Scan(&user.ID, &user.Email, &user.PassHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: user not found: %w", op, storage.ErrUserNotFound)
+		}
+
+		return models.User{}, fmt.Errorf("%s: failed to fetch user: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// NotificationPreferences returns the preferences of the user, the defaults if never saved.
+func (s *Storage) NotificationPreferences(ctx context.Context, userID int64) (models.NotificationPreferences, error) {
+	const op = "storage.NotificationPreferences"
+
+	prefs := models.NotificationPreferences{UserID: userID}
+	row := s.db.QueryRowContext(ctx, `
+		SELECT account, orders
+		FROM notification_preferences
+		WHERE user_id = ?`, userID)
+
+	err := row.Scan(&prefs.Account, &prefs.Orders)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DefaultNotificationPreferences(userID), nil
+		}
+
+		return models.NotificationPreferences{}, fmt.Errorf("%s: failed to fetch preferences: %w", op, err)
+	}
+
+	return prefs, nil
+}
+
+func (s *Storage) SaveNotificationPreferences(ctx context.Context, prefs models.NotificationPreferences) error {
+	const op = "storage.SaveNotificationPreferences"
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO notification_preferences (user_id, account, orders, updated_at)
+		VALUES(?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(user_id) DO UPDATE SET
+			account = excluded.account,
+			orders = excluded.orders,
+			updated_at = excluded.updated_at`, prefs.UserID, prefs.Account, prefs.Orders)
+	if err != nil {
+		return fmt.Errorf("%s: failed to save preferences: %w", op, err)
+	}
+
+	return nil
+}
+
 func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 	const op = "storage.IsAdmin"
-	stmt, err := s.db.Prepare(`
-		SELECT is_admin 
-		FROM users 
-		WHERE user_id = ?`)
+
+	roles, err := s.UserRoles(ctx, userID)
 	if err != nil {
-		return false, fmt.Errorf("%s: failed to prepare statement: %w", op, err)
+		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	row := stmt.QueryRowContext(ctx, userID)
+	for _, role := range roles {
+		if role == models.RoleAdmin {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// UserRoles returns names of the roles assigned to the user.
+func (s *Storage) UserRoles(ctx context.Context, userID int64) ([]string, error) {
+	const op = "storage.UserRoles"
 
-	var isAdmin bool
+	var exists bool
 
-	err = row.Scan(&isAdmin)
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)`, userID).Scan(&exists)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, fmt.Errorf("%s: user not found: %w", op, storage.ErrUserNotFound)
+		return nil, fmt.Errorf("%s: failed to fetch user: %w", op, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%s: user not found: %w", op, storage.ErrUserNotFound)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.name
+		FROM user_roles AS ur
+		JOIN roles AS r ON r.id = ur.role_id
+		WHERE ur.user_id = ?
+		ORDER BY r.name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query roles: %w", op, err)
+	}
+	defer rows.Close()
+
+	var roles []string
+
+	for rows.Next() {
+		var role string
+
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan role: %w", op, err)
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to scan roles: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// HasPermission reports whether any of the user's roles grants the permission.
+func (s *Storage) HasPermission(ctx context.Context, userID int64, permission string) (bool, error) {
+	const op = "storage.HasPermission"
+
+	var has bool
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1
+			FROM user_roles AS ur
+			JOIN role_permissions AS rp ON rp.role_id = ur.role_id
+			JOIN permissions AS p ON p.id = rp.permission_id
+			WHERE ur.user_id = ? AND p.name = ?)`, userID, permission).Scan(&has)
+	if err != nil {
+		return false, fmt.Errorf("%s: failed to check permission: %w", op, err)
+	}
+
+	return has, nil
+}
+
+func (s *Storage) AssignRole(ctx context.Context, userID int64, role string) error {
+	const op = "storage.AssignRole"
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO user_roles (user_id, role_id)
+		SELECT ?, id FROM roles WHERE name = ?`, userID, role)
+	if err != nil {
+		return fmt.Errorf("%s: failed to assign role: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		var exists bool
+
+		err = s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM roles WHERE name = ?)`, role).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("%s: failed to fetch role: %w", op, err)
 		}
+		if !exists {
+			return fmt.Errorf("%s: role not found: %w", op, storage.ErrRoleNotFound)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeRole(ctx context.Context, userID int64, role string) error {
+	const op = "storage.RevokeRole"
 
-		return false, fmt.Errorf("%s: failed to fetch user: %w", op, err)
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM user_roles
+		WHERE user_id = ? AND role_id = (SELECT id FROM roles WHERE name = ?)`, userID, role)
+	if err != nil {
+		return fmt.Errorf("%s: failed to revoke role: %w", op, err)
 	}
 
-	return isAdmin, nil
+	return nil
 }
 
-func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
-	stmt, err := s.db.Prepare(`
-		SELECT app_id, name, secret 
-		FROM apps 
-		WHERE app_id = ?`)
+// Roles returns all roles together with the permissions they grant.
+func (s *Storage) Roles(ctx context.Context) ([]models.Role, error) {
+	const op = "storage.Roles"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.id, r.name, COALESCE(p.name, '')
+		FROM roles AS r
+		LEFT JOIN role_permissions AS rp ON rp.role_id = r.id
+		LEFT JOIN permissions AS p ON p.id = rp.permission_id
+		ORDER BY r.id, p.name`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query roles: %w", op, err)
+	}
+	defer rows.Close()
+
+	var roles []models.Role
+
+	for rows.Next() {
+		var (
+			role       models.Role
+			permission string
+		)
+
+		if err := rows.Scan(&role.ID, &role.Name, &permission); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan role: %w", op, err)
+		}
+
+		if len(roles) == 0 || roles[len(roles)-1].ID != role.ID {
+			roles = append(roles, role)
+		}
+		if permission != "" {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, permission)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to scan roles: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// UsersWithRoles returns every user with the names of their roles.
+func (s *Storage) UsersWithRoles(ctx context.Context) ([]models.UserRoles, error) {
+	const op = "storage.UsersWithRoles"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT u.id, u.email, COALESCE(r.name, '')
+		FROM users AS u
+		LEFT JOIN user_roles AS ur ON ur.user_id = u.id
+		LEFT JOIN roles AS r ON r.id = ur.role_id
+		ORDER BY u.id, r.name`)
 	if err != nil {
-		return models.App{}, fmt.Errorf("failed to prepare statement: %w", err)
+		return nil, fmt.Errorf("%s: failed to query users: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []models.UserRoles
+
+	for rows.Next() {
+		var (
+			user models.UserRoles
+			role string
+		)
+
+		if err := rows.Scan(&user.UserID, &user.Email, &role); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan user: %w", op, err)
+		}
+
+		if len(users) == 0 || users[len(users)-1].UserID != user.UserID {
+			users = append(users, user)
+		}
+		if role != "" {
+			last := &users[len(users)-1]
+			last.Roles = append(last.Roles, role)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to scan users: %w", op, err)
 	}
 
-	row := stmt.QueryRowContext(ctx, appID)
+	return users, nil
+}
+
+func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT app_id, name, secret 
+		FROM apps 
+		WHERE app_id = ?`, appID)
 
 	var app models.App
-	err = row.Scan(&app.ID, &app.Name, &app.Secret)
+	err := row.Scan(&app.ID, &app.Name, &app.Secret)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.App{}, fmt.Errorf("app not found: %w", storage.ErrAppNotFound)
@@ -260,7 +675,7 @@ func (s *Storage) GetSession(ctx context.Context, UUID string) (int, error) {
 	err = row.Scan(&id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return 0, fmt.Errorf("%s: session not found %w", op, err)
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
 		}
 
 		return 0, fmt.Errorf("%s: failed to fetch session: %w", op, err)
@@ -392,22 +807,414 @@ func (s *Storage) RemoveFromCart(ctx context.Context, productID int, userID any)
 	return nil
 }
 
-func (s *Storage) MoveCart(ctx context.Context, newUserID int, oldUserID any) error {
-	const op = "storage.MoveCart"
+func (s *Storage) LoginAttempts(ctx context.Context, key string) (models.LoginAttempts, error) {
+	const op = "storage.LoginAttempts"
 
-	fmt.Println("start moving")
+	row := s.db.QueryRowContext(ctx, `
+		SELECT key, failures, last_failed_at, locked_until, last_ip
+		FROM login_attempts
+		WHERE key = ?`, key)
 
-	stmt, err := s.db.Prepare(`
-		UPDATE cart SET user_id = ?
-		WHERE user_id = ?`)
+	var (
+		attempts    models.LoginAttempts
+		lockedUntil sql.NullTime
+	)
+
+	err := row.Scan(&attempts.Key, &attempts.Failures, &attempts.LastFailedAt, &lockedUntil, &attempts.LastIP)
 	if err != nil {
-		return fmt.Errorf("%s: failed to prepare statement: %w", op, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.LoginAttempts{Key: key}, nil
+		}
+
+		return models.LoginAttempts{}, fmt.Errorf("%s: failed to fetch login attempts: %w", op, err)
+	}
+	attempts.LockedUntil = lockedUntil.Time
+
+	return attempts, nil
+}
+
+// AddLoginFailure counts a failed login of key from ip in one statement, so concurrent
+// failures are all counted. Failures before since are forgotten. It returns the failures
+// counted so far.
+func (s *Storage) AddLoginFailure(ctx context.Context, key, ip string, now, since time.Time) (int, error) {
+	const op = "storage.AddLoginFailure"
+
+	var failures int
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO login_attempts (key, failures, last_failed_at, last_ip)
+		VALUES(?, 1, ?, ?)
+		ON CONFLICT(key)
+		DO UPDATE SET failures = CASE WHEN login_attempts.last_failed_at < ? THEN 1
+		                              ELSE login_attempts.failures + 1 END,
+		              last_failed_at = excluded.last_failed_at,
+		              last_ip = excluded.last_ip
+		RETURNING failures`, key, now.UTC(), ip, since.UTC()).Scan(&failures)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to save login failure: %w", op, err)
 	}
 
-	fmt.Println("end moving")
-	_, err = stmt.ExecContext(ctx, newUserID, oldUserID)
+	return failures, nil
+}
+
+// LockLogin locks key until the given time, unless it is already locked for longer.
+func (s *Storage) LockLogin(ctx context.Context, key string, until time.Time) error {
+	const op = "storage.LockLogin"
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE login_attempts
+		SET locked_until = ?
+		WHERE key = ? AND (locked_until IS NULL OR locked_until < ?)`, until.UTC(), key, until.UTC())
 	if err != nil {
-		return fmt.Errorf("%s: failed to move cart: %w", op, err)
+		return fmt.Errorf("%s: failed to lock login: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ResetLoginAttempts(ctx context.Context, key string) error {
+	const op = "storage.ResetLoginAttempts"
+
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM login_attempts WHERE key = ?`, key)
+	if err != nil {
+		return fmt.Errorf("%s: failed to reset login attempts: %w", op, err)
+	}
+
+	return nil
+}
+
+// UserByIdentity returns the user linked to the external identity.
+func (s *Storage) UserByIdentity(ctx context.Context, provider, subject string) (models.User, error) {
+	const op = "storage.UserByIdentity"
+
+	row := s.db.QueryRowContext(ctx, `
+		SELECT u.id, u.email, u.pass_hash
+		FROM identities AS i
+		JOIN users AS u ON u.id = i.user_id
+		WHERE i.provider = ? AND i.subject = ?`, provider, subject)
+
+	var user models.User
+	err := row.Scan(&user.ID, &user.Email, &user.PassHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: user not found: %w", op, storage.ErrUserNotFound)
+		}
+
+		return models.User{}, fmt.Errorf("%s: failed to fetch user: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// SaveIdentity links the external identity to the user.
+func (s *Storage) SaveIdentity(ctx context.Context, identity models.Identity) error {
+	const op = "storage.SaveIdentity"
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO identities (user_id, provider, subject, email)
+		VALUES(?, ?, ?, ?)`, identity.UserID, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+
+		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+			return fmt.Errorf("%s: %w", op, storage.ErrIdentityExists)
+		}
+
+		return fmt.Errorf("%s: failed to save identity: %w", op, err)
+	}
+
+	return nil
+}
+
+// MergeCart moves the guest cart of the session into the cart of the user, adding up quantities
+// of products that are in both carts.
+func (s *Storage) MergeCart(ctx context.Context, userID int64, sessionID string) error {
+	const op = "storage.MergeCart"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO cart (product_id, quantity, user_id)
+		SELECT product_id, quantity, ?
+		FROM cart
+		WHERE user_id = ?
+		ON CONFLICT(user_id, product_id)
+		DO UPDATE SET quantity = quantity + excluded.quantity`, userID, sessionID)
+	if err != nil {
+		return fmt.Errorf("%s: failed to merge cart: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM cart WHERE user_id = ?`, sessionID)
+	if err != nil {
+		return fmt.Errorf("%s: failed to clear guest cart: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: failed to commit transaction: %w", op, err)
+	}
+
+	return nil
+}
+
+// CreateOrder saves the order, takes its items out of stock and empties the cart of the user.
+// Returns storage.ErrOutOfStock if any item has insufficient stock; nothing is changed then.
+func (s *Storage) CreateOrder(ctx context.Context, order models.Order) (int64, error) {
+	const op = "storage.CreateOrder"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	for _, item := range order.Items {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE products SET stock = stock - ?
+			WHERE id = ? AND stock >= ?`, item.Quantity, item.ProductID, item.Quantity)
+		if err != nil {
+			return 0, fmt.Errorf("%s: failed to update stock: %w", op, err)
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("%s: failed to update stock: %w", op, err)
+		}
+		if affected == 0 {
+			return 0, fmt.Errorf("%s: product %d: %w", op, item.ProductID, storage.ErrOutOfStock)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO orders (user_id, status, subtotal, shipping, tax, total)
+		VALUES(?, ?, ?, ?, ?, ?)`,
+		order.UserID, order.Status, order.Subtotal, order.Shipping, order.Tax, order.Total)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to insert order: %w", op, err)
+	}
+
+	orderID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to get order id: %w", op, err)
+	}
+
+	for _, item := range order.Items {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO order_items (order_id, product_id, product_name, price, quantity)
+			VALUES(?, ?, ?, ?, ?)`, orderID, item.ProductID, item.ProductName, item.Price, item.Quantity)
+		if err != nil {
+			return 0, fmt.Errorf("%s: failed to insert order item: %w", op, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM cart WHERE user_id = ?`, order.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to clear cart: %w", op, err)
+	}
+
+	placed := events.OrderPlaced{OrderID: orderID, UserID: order.UserID, Total: order.Total}
+	for _, item := range order.Items {
+		placed.Items = append(placed.Items, events.OrderPlacedItem{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			Price:     item.Price,
+		})
+	}
+	msg, err := events.NewMessage(ctx, events.TopicOrderPlaced, strconv.FormatInt(order.UserID, 10), placed)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := enqueue(ctx, tx, msg); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deltas := make(map[int64]int, len(order.Items))
+	for _, item := range order.Items {
+		deltas[item.ProductID] -= item.Quantity
+	}
+	if err := enqueueStockChanged(ctx, tx, deltas); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: failed to commit transaction: %w", op, err)
+	}
+	s.publishChanges(ctx)
+
+	return orderID, nil
+}
+
+func (s *Storage) Order(ctx context.Context, orderID int64) (models.Order, error) {
+	const op = "storage.Order"
+
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, status, subtotal, shipping, tax, total, created_at
+		FROM orders
+		WHERE id = ?`, orderID)
+
+	var o models.Order
+	err := row.Scan(&o.ID, &o.UserID, &o.Status, &o.Subtotal, &o.Shipping, &o.Tax, &o.Total, &o.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Order{}, fmt.Errorf("%s: %w", op, storage.ErrOrderNotFound)
+		}
+
+		return models.Order{}, fmt.Errorf("%s: failed to fetch order: %w", op, err)
+	}
+
+	o.Items, err = s.orderItems(ctx, o.ID)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return o, nil
+}
+
+// Orders returns up to limit orders of the user older than the order beforeID, newest first.
+// A beforeID of 0 starts from the newest order.
+func (s *Storage) Orders(ctx context.Context, userID, beforeID int64, limit int) ([]models.Order, error) {
+	const op = "storage.Orders"
+
+	if beforeID == 0 {
+		beforeID = math.MaxInt64
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, status, subtotal, shipping, tax, total, created_at
+		FROM orders
+		WHERE user_id = ? AND id < ?
+		ORDER BY id DESC
+		LIMIT ?`, userID, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query orders: %w", op, err)
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		var o models.Order
+		if err := rows.Scan(&o.ID, &o.UserID, &o.Status, &o.Subtotal, &o.Shipping, &o.Tax, &o.Total, &o.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan order: %w", op, err)
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to scan orders: %w", op, err)
+	}
+
+	for i := range orders {
+		orders[i].Items, err = s.orderItems(ctx, orders[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return orders, nil
+}
+
+func (s *Storage) orderItems(ctx context.Context, orderID int64) ([]models.OrderItem, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT product_id, product_name, price, quantity
+		FROM order_items
+		WHERE order_id = ?
+		ORDER BY product_id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []models.OrderItem
+	for rows.Next() {
+		var item models.OrderItem
+		if err := rows.Scan(&item.ProductID, &item.ProductName, &item.Price, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan order item: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan order items: %w", err)
+	}
+
+	return items, nil
+}
+
+// CancelOrder marks a placed order as cancelled and puts its items back in stock.
+// Returns storage.ErrOrderFinalized if the order is not in the placed status.
+func (s *Storage) CancelOrder(ctx context.Context, orderID int64) error {
+	const op = "storage.CancelOrder"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE orders SET status = ?
+		WHERE id = ? AND status = ?`, models.OrderStatusCancelled, orderID, models.OrderStatusPlaced)
+	if err != nil {
+		return fmt.Errorf("%s: failed to update order: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to update order: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrOrderFinalized)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE products SET stock = stock + (
+			SELECT quantity FROM order_items
+			WHERE order_items.order_id = ? AND order_items.product_id = products.id)
+		WHERE id IN (SELECT product_id FROM order_items WHERE order_id = ?)`, orderID, orderID)
+	if err != nil {
+		return fmt.Errorf("%s: failed to restock items: %w", op, err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT product_id, quantity FROM order_items WHERE order_id = ?`, orderID)
+	if err != nil {
+		return fmt.Errorf("%s: failed to query order items: %w", op, err)
+	}
+	deltas := make(map[int64]int)
+	for rows.Next() {
+		var (
+			productID int64
+			quantity  int
+		)
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("%s: failed to scan order item: %w", op, err)
+		}
+		deltas[productID] += quantity
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: failed to scan order items: %w", op, err)
+	}
+
+	if err := enqueueStockChanged(ctx, tx, deltas); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: failed to commit transaction: %w", op, err)
+	}
+	s.publishChanges(ctx)
+
+	return nil
+}
+
+// Ping checks that the database is reachable.
+func (s *Storage) Ping(ctx context.Context) error {
+	const op = "storage.Ping"
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
@@ -0,0 +1,377 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"shop/internal/domain/models"
+)
+
+// newTestStorage opens a storage on a copy of the repository's schema database.
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	return openTestStorage(t, copySchema(t))
+}
+
+// copySchema copies the repository's schema database, which has the tables the app does not
+// migrate itself, into a temporary directory and returns its path.
+func copySchema(t *testing.T) string {
+	t.Helper()
+
+	schema, err := os.ReadFile(filepath.Join("..", "..", "..", "identifier.sqlite"))
+	if err != nil {
+		t.Fatalf("read schema database: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "shop.db")
+	if err := os.WriteFile(path, schema, 0o600); err != nil {
+		t.Fatalf("write database: %v", err)
+	}
+
+	return path
+}
+
+func openTestStorage(t *testing.T, path string) *Storage {
+	t.Helper()
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	return s
+}
+
+func TestAddLoginFailureCountsConcurrentFailures(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	const n = 20
+
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.AddLoginFailure(ctx, "email:a@b.cd", "10.0.0.1", now, now.Add(-time.Minute)); err != nil {
+				t.Errorf("AddLoginFailure: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	attempts, err := s.LoginAttempts(ctx, "email:a@b.cd")
+	if err != nil {
+		t.Fatalf("LoginAttempts: %v", err)
+	}
+	if attempts.Failures != n {
+		t.Errorf("failures = %d, want %d", attempts.Failures, n)
+	}
+	if attempts.LastIP != "10.0.0.1" {
+		t.Errorf("last ip = %q, want 10.0.0.1", attempts.LastIP)
+	}
+}
+
+func TestAddLoginFailureForgetsOldFailures(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour)
+
+	for i := range 3 {
+		if _, err := s.AddLoginFailure(ctx, "ip:10.0.0.1", "10.0.0.1", start.Add(time.Duration(i)*time.Second), start.Add(-time.Minute)); err != nil {
+			t.Fatalf("AddLoginFailure: %v", err)
+		}
+	}
+
+	now := time.Now()
+	failures, err := s.AddLoginFailure(ctx, "ip:10.0.0.1", "10.0.0.1", now, now.Add(-15*time.Minute))
+	if err != nil {
+		t.Fatalf("AddLoginFailure: %v", err)
+	}
+	if failures != 1 {
+		t.Errorf("failures = %d, want 1", failures)
+	}
+}
+
+func TestLockLoginKeepsLongerLock(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := s.AddLoginFailure(ctx, "email:a@b.cd", "", now, now); err != nil {
+		t.Fatalf("AddLoginFailure: %v", err)
+	}
+
+	long := now.Add(15 * time.Minute)
+	if err := s.LockLogin(ctx, "email:a@b.cd", long); err != nil {
+		t.Fatalf("LockLogin: %v", err)
+	}
+	if err := s.LockLogin(ctx, "email:a@b.cd", now.Add(time.Second)); err != nil {
+		t.Fatalf("LockLogin: %v", err)
+	}
+
+	attempts, err := s.LoginAttempts(ctx, "email:a@b.cd")
+	if err != nil {
+		t.Fatalf("LoginAttempts: %v", err)
+	}
+	if !attempts.LockedUntil.Equal(long.UTC()) {
+		t.Errorf("locked until %s, want %s", attempts.LockedUntil, long.UTC())
+	}
+}
+
+func TestMigrationsBackfillCustomerRole(t *testing.T) {
+	path := copySchema(t)
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO users (email, pass_hash) VALUES ('old@b.cd', 'hash')`)
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	s := openTestStorage(t, path)
+
+	user, err := s.User(context.Background(), "old@b.cd")
+	if err != nil {
+		t.Fatalf("User: %v", err)
+	}
+	roles, err := s.UserRoles(context.Background(), int64(user.ID))
+	if err != nil {
+		t.Fatalf("UserRoles: %v", err)
+	}
+	if len(roles) != 1 || roles[0] != models.RoleCustomer {
+		t.Errorf("roles = %v, want [%s]", roles, models.RoleCustomer)
+	}
+}
+
+func TestPollChangesPublishesChangesOfOtherProcesses(t *testing.T) {
+	path := copySchema(t)
+	s := openTestStorage(t, path)
+	// other stands for another process writing to the same database.
+	other := openTestStorage(t, path)
+
+	sub := s.Changes().Subscribe(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.PollChanges(ctx)
+
+	var productID int64
+	if err := other.db.QueryRow(`SELECT MIN(id) FROM products`).Scan(&productID); err != nil {
+		t.Fatalf("query product: %v", err)
+	}
+	if _, err := other.db.Exec(`UPDATE products SET stock = stock + 1 WHERE id = ?`, productID); err != nil {
+		t.Fatalf("update stock: %v", err)
+	}
+
+	select {
+	case change := <-sub.Events():
+		if change.ProductID != productID {
+			t.Errorf("change of product %d, want %d", change.ProductID, productID)
+		}
+	case <-time.After(3 * pollInterval):
+		t.Fatal("change written by another process was not published")
+	}
+}
+
+func TestEnqueueStockChangedOrdersByProduct(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	rows, err := s.db.Query(`SELECT id FROM products ORDER BY id DESC LIMIT 5`)
+	if err != nil {
+		t.Fatalf("query products: %v", err)
+	}
+	deltas := make(map[int64]int)
+	var want []string
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("scan product: %v", err)
+		}
+		deltas[id] = -1
+		want = append([]string{strconv.FormatInt(id, 10)}, want...)
+	}
+	rows.Close()
+	if len(deltas) < 2 {
+		t.Fatalf("schema database has %d products, want at least 2", len(deltas))
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if err := enqueueStockChanged(ctx, tx, deltas); err != nil {
+		t.Fatalf("enqueueStockChanged: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	msgs, err := s.PendingOutbox(ctx, 0, 100)
+	if err != nil {
+		t.Fatalf("PendingOutbox: %v", err)
+	}
+	var got []string
+	for _, m := range msgs {
+		got = append(got, m.Key)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("outbox keys = %v, want %v", got, want)
+	}
+}
+
+func TestParkOutboxSetsMessageAside(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b"} {
+		if _, err := s.db.Exec(`INSERT INTO outbox (topic, key, value, headers) VALUES ('orders', ?, '', '{}')`, key); err != nil {
+			t.Fatalf("insert outbox message: %v", err)
+		}
+	}
+
+	msgs, err := s.PendingOutbox(ctx, 0, 10)
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("PendingOutbox = %v, %v; want 2 messages", msgs, err)
+	}
+	if err := s.ParkOutbox(ctx, msgs[0].ID, "poison"); err != nil {
+		t.Fatalf("ParkOutbox: %v", err)
+	}
+
+	pending, err := s.PendingOutbox(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("PendingOutbox: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != msgs[1].ID {
+		t.Errorf("pending = %v, want only message %d", pending, msgs[1].ID)
+	}
+	if after, _ := s.PendingOutbox(ctx, msgs[1].ID, 10); len(after) != 0 {
+		t.Errorf("pending after %d = %v, want none", msgs[1].ID, after)
+	}
+
+	count, _, err := s.OutboxBacklog(ctx)
+	if err != nil {
+		t.Fatalf("OutboxBacklog: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("backlog = %d, want 1", count)
+	}
+}
+
+func TestClaimWebhookDeliveriesClaimsEachDeliveryOnce(t *testing.T) {
+	path := copySchema(t)
+	s := openTestStorage(t, path)
+	other := openTestStorage(t, path)
+	ctx := context.Background()
+
+	if _, err := s.CreateWebhook(ctx, "https://partner.example/hook", []string{"order.placed"}, "secret"); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	const n = 20
+	for i := range n {
+		if _, err := s.EnqueueWebhookDeliveries(ctx, strconv.Itoa(i), "order.placed", []byte("{}")); err != nil {
+			t.Fatalf("EnqueueWebhookDeliveries: %v", err)
+		}
+	}
+
+	// Two deliverers claiming at once, each through its own connection, never share a delivery.
+	var (
+		mu      sync.Mutex
+		claimed []int64
+		wg      sync.WaitGroup
+	)
+	for _, storage := range []*Storage{s, other, s, other} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			deliveries, err := storage.ClaimWebhookDeliveries(ctx, n/2, time.Hour)
+			if err != nil {
+				t.Errorf("ClaimWebhookDeliveries: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, d := range deliveries {
+				if d.URL != "https://partner.example/hook" || d.Secret != "secret" {
+					t.Errorf("delivery %d has webhook %q, %q", d.ID, d.URL, d.Secret)
+				}
+				claimed = append(claimed, d.ID)
+			}
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(claimed)
+	if len(claimed) != n || len(slices.Compact(claimed)) != n {
+		t.Errorf("claimed %v, want each of the %d deliveries once", claimed, n)
+	}
+
+	// A claimed delivery is due again only once its lease is over.
+	if _, err := s.db.Exec(`UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?`,
+		time.Now().Add(-time.Second).UTC(), claimed[0]); err != nil {
+		t.Fatalf("expire lease: %v", err)
+	}
+	deliveries, err := s.ClaimWebhookDeliveries(ctx, n, time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].ID != claimed[0] {
+		t.Errorf("claimed %v after the lease of %d ran out, want only it", deliveries, claimed[0])
+	}
+}
+
+func TestClaimWebhookDeliveriesSkipsPausedWebhooks(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	var ids []int64
+	for _, url := range []string{"https://a.example/hook", "https://b.example/hook"} {
+		id, err := s.CreateWebhook(ctx, url, []string{"order.placed"}, "secret")
+		if err != nil {
+			t.Fatalf("CreateWebhook: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := s.EnqueueWebhookDeliveries(ctx, "event-1", "order.placed", []byte("{}")); err != nil {
+		t.Fatalf("EnqueueWebhookDeliveries: %v", err)
+	}
+
+	// Pausing after the event was enqueued holds back the delivery already waiting.
+	if err := s.SetWebhookActive(ctx, ids[1], false); err != nil {
+		t.Fatalf("SetWebhookActive: %v", err)
+	}
+	deliveries, err := s.ClaimWebhookDeliveries(ctx, 10, time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].WebhookID != ids[0] {
+		t.Fatalf("claimed %+v, want only the delivery of webhook %d", deliveries, ids[0])
+	}
+
+	if err := s.SetWebhookActive(ctx, ids[1], true); err != nil {
+		t.Fatalf("SetWebhookActive: %v", err)
+	}
+	deliveries, err = s.ClaimWebhookDeliveries(ctx, 10, time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].WebhookID != ids[1] {
+		t.Errorf("claimed %+v after the resume, want the delivery of webhook %d", deliveries, ids[1])
+	}
+}
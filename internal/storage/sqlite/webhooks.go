@@ -0,0 +1,302 @@
+package sqlite
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"shop/internal/domain/models"
+	"shop/internal/storage"
+)
+
+func (s *Storage) CreateWebhook(ctx context.Context, url string, eventTypes []string, secret string) (int64, error) {
+	const op = "storage.CreateWebhook"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO webhooks (url, secret) VALUES(?, ?)`, url, secret)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to insert webhook: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to get webhook id: %w", op, err)
+	}
+
+	for _, eventType := range eventTypes {
+		_, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO webhook_events (webhook_id, event_type)
+			VALUES(?, ?)`, id, eventType)
+		if err != nil {
+			return 0, fmt.Errorf("%s: failed to insert webhook event: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: failed to commit transaction: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Webhooks returns all webhooks with the event types they receive.
+func (s *Storage) Webhooks(ctx context.Context) ([]models.Webhook, error) {
+	const op = "storage.Webhooks"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT w.id, w.url, w.secret, w.active, w.created_at, COALESCE(e.event_type, '')
+		FROM webhooks AS w
+		LEFT JOIN webhook_events AS e ON e.webhook_id = w.id
+		ORDER BY w.id, e.event_type`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query webhooks: %w", op, err)
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		var (
+			w         models.Webhook
+			eventType string
+		)
+		if err := rows.Scan(&w.ID, &w.URL, &w.Secret, &w.Active, &w.CreatedAt, &eventType); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan webhook: %w", op, err)
+		}
+
+		if n := len(webhooks); n == 0 || webhooks[n-1].ID != w.ID {
+			webhooks = append(webhooks, w)
+		}
+		if eventType != "" {
+			last := &webhooks[len(webhooks)-1]
+			last.Events = append(last.Events, eventType)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to scan webhooks: %w", op, err)
+	}
+
+	return webhooks, nil
+}
+
+func (s *Storage) SetWebhookActive(ctx context.Context, id int64, active bool) error {
+	const op = "storage.SetWebhookActive"
+
+	res, err := s.db.ExecContext(ctx, `UPDATE webhooks SET active = ? WHERE id = ?`, active, id)
+	if err != nil {
+		return fmt.Errorf("%s: failed to update webhook: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to update webhook: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebhookNotFound)
+	}
+
+	return nil
+}
+
+// DeleteWebhook deletes the webhook with its deliveries and their log.
+func (s *Storage) DeleteWebhook(ctx context.Context, id int64) error {
+	const op = "storage.DeleteWebhook"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM webhook_attempts
+		WHERE delivery_id IN (SELECT id FROM webhook_deliveries WHERE webhook_id = ?)`,
+		`DELETE FROM webhook_deliveries WHERE webhook_id = ?`,
+		`DELETE FROM webhook_events WHERE webhook_id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("%s: failed to delete webhook data: %w", op, err)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("%s: failed to delete webhook: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to delete webhook: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebhookNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: failed to commit transaction: %w", op, err)
+	}
+
+	return nil
+}
+
+// EnqueueWebhookDeliveries creates a pending delivery of the event for every active webhook
+// receiving its type and returns how many were created. An event is delivered to a webhook once.
+func (s *Storage) EnqueueWebhookDeliveries(ctx context.Context, eventID, eventType string, payload []byte) (int, error) {
+	const op = "storage.EnqueueWebhookDeliveries"
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+		SELECT w.id, ?, ?, ?
+		FROM webhooks AS w
+		JOIN webhook_events AS e ON e.webhook_id = w.id
+		WHERE w.active = 1 AND e.event_type = ?`, eventID, eventType, payload, eventType)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to insert deliveries: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to insert deliveries: %w", op, err)
+	}
+
+	return int(affected), nil
+}
+
+// ClaimWebhookDeliveries claims up to limit pending deliveries of active webhooks whose next
+// attempt is due and returns them, oldest first; deliveries of a paused webhook wait for it to
+// be resumed. A claim moves the next attempt lease ahead in the same statement, so concurrent
+// deliverers never get the same delivery, and one that stops midway leaves its deliveries due
+// again once the lease is over.
+func (s *Storage) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	const op = "storage.ClaimWebhookDeliveries"
+
+	now := time.Now().UTC()
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT d.id
+			FROM webhook_deliveries d
+			JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status = ? AND d.next_attempt_at <= ? AND w.active
+			ORDER BY d.id
+			LIMIT ?)
+		RETURNING id, webhook_id,
+			(SELECT url FROM webhooks WHERE webhooks.id = webhook_deliveries.webhook_id),
+			(SELECT secret FROM webhooks WHERE webhooks.id = webhook_deliveries.webhook_id),
+			event_id, event_type, payload, status, attempts, next_attempt_at, created_at`,
+		now.Add(lease), models.WebhookDeliveryPending, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to claim deliveries: %w", op, err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Secret, &d.EventID, &d.EventType, &d.Payload, &d.Status,
+			&d.Attempts, &d.NextAttemptAt, &d.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to scan delivery: %w", op, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to scan deliveries: %w", op, err)
+	}
+
+	// RETURNING gives the rows in no particular order.
+	slices.SortFunc(deliveries, func(a, b models.WebhookDelivery) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return deliveries, nil
+}
+
+// RecordWebhookAttempt logs the attempt and moves its delivery to status, to be tried again
+// at nextAttemptAt if it is still pending.
+func (s *Storage) RecordWebhookAttempt(
+	ctx context.Context,
+	attempt models.WebhookAttempt,
+	status string,
+	nextAttemptAt time.Time,
+) error {
+	const op = "storage.RecordWebhookAttempt"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO webhook_attempts (delivery_id, attempted_at, response_code, error, duration_ms)
+		VALUES(?, ?, ?, ?, ?)`,
+		attempt.DeliveryID, attempt.AttemptedAt.UTC(), attempt.ResponseCode, attempt.Error, attempt.Duration.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("%s: failed to insert attempt: %w", op, err)
+	}
+
+	var deliveredAt sql.NullTime
+	if status == models.WebhookDeliveryDelivered {
+		deliveredAt = sql.NullTime{Time: attempt.AttemptedAt.UTC(), Valid: true}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, next_attempt_at = ?, response_code = ?, last_error = ?, delivered_at = ?
+		WHERE id = ?`,
+		status, nextAttemptAt.UTC(), attempt.ResponseCode, attempt.Error, deliveredAt, attempt.DeliveryID)
+	if err != nil {
+		return fmt.Errorf("%s: failed to update delivery %d: %w", op, attempt.DeliveryID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: failed to commit transaction: %w", op, err)
+	}
+
+	return nil
+}
+
+// WebhookAttempts returns up to limit latest delivery attempts made to the webhook, newest first.
+func (s *Storage) WebhookAttempts(ctx context.Context, webhookID int64, limit int) ([]models.WebhookAttempt, error) {
+	const op = "storage.WebhookAttempts"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT a.id, a.delivery_id, d.event_type, a.attempted_at, a.response_code, a.error, a.duration_ms
+		FROM webhook_attempts AS a
+		JOIN webhook_deliveries AS d ON d.id = a.delivery_id
+		WHERE d.webhook_id = ?
+		ORDER BY a.id DESC
+		LIMIT ?`, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query attempts: %w", op, err)
+	}
+	defer rows.Close()
+
+	var attempts []models.WebhookAttempt
+	for rows.Next() {
+		var (
+			a          models.WebhookAttempt
+			durationMS int64
+		)
+		err := rows.Scan(&a.ID, &a.DeliveryID, &a.EventType, &a.AttemptedAt, &a.ResponseCode, &a.Error, &durationMS)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to scan attempt: %w", op, err)
+		}
+		a.Duration = time.Duration(durationMS) * time.Millisecond
+		attempts = append(attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to scan attempts: %w", op, err)
+	}
+
+	return attempts, nil
+}
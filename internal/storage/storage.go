@@ -3,8 +3,16 @@ package storage
 import "errors"
 
 var (
-	ErrUserNotFound    = errors.New("user not found")
-	ErrUserExists      = errors.New("user already exists")
-	ErrAppNotFound     = errors.New("app not found")
-	ErrProductNotFound = errors.New("product not found")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("user already exists")
+	ErrAppNotFound        = errors.New("app not found")
+	ErrProductNotFound    = errors.New("product not found")
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrIdentityExists     = errors.New("identity already linked")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrOutOfStock         = errors.New("insufficient stock")
+	ErrOrderNotFound      = errors.New("order not found")
+	ErrOrderFinalized     = errors.New("order can no longer be changed")
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	ErrWebhookNotFound    = errors.New("webhook not found")
 )
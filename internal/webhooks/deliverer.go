@@ -0,0 +1,198 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+)
+
+// maxResponseBody is how much of a response is read before the connection is reused.
+const maxResponseBody = 64 << 10
+
+type DelivererStorage interface {
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt, status string, nextAttemptAt time.Time) error
+}
+
+var deliveriesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "shop",
+	Subsystem: "webhooks",
+	Name:      "attempts_total",
+	Help:      "Webhook delivery attempts by result: delivered, retry or failed.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(deliveriesCounter)
+}
+
+// Deliverer sends due webhook deliveries. A delivery succeeds on a 2xx response; otherwise
+// it is retried with exponential backoff and fails for good after maxAttempts. Every attempt
+// is logged with its response code. Deliveries are independent and may arrive out of order.
+// Deliveries are claimed for as long as sending a whole batch may take, so several deliverers
+// can share the storage.
+type Deliverer struct {
+	log         *zap.Logger
+	storage     DelivererStorage
+	client      *http.Client
+	lease       time.Duration
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+}
+
+func NewDeliverer(
+	log *zap.Logger,
+	storage DelivererStorage,
+	timeout time.Duration,
+	interval time.Duration,
+	batchSize int,
+	maxAttempts int,
+	baseBackoff time.Duration,
+	maxBackoff time.Duration,
+) *Deliverer {
+	return &Deliverer{
+		log:         log.With(zap.String("component", "webhooks/deliverer")),
+		storage:     storage,
+		client:      &http.Client{Timeout: timeout},
+		lease:       timeout * time.Duration(max(batchSize, 1)),
+		interval:    interval,
+		batchSize:   batchSize,
+		maxAttempts: maxAttempts,
+		baseBackoff: baseBackoff,
+		maxBackoff:  maxBackoff,
+	}
+}
+
+// Run sends due deliveries every interval until ctx is done.
+func (d *Deliverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Deliverer) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries, err := d.storage.ClaimWebhookDeliveries(ctx, d.batchSize, d.lease)
+		if err != nil {
+			d.log.Error("failed to fetch webhook deliveries", zap.Error(err))
+			return
+		}
+
+		for _, delivery := range deliveries {
+			d.deliver(ctx, delivery)
+		}
+
+		if len(deliveries) < d.batchSize {
+			return
+		}
+	}
+}
+
+func (d *Deliverer) deliver(ctx context.Context, delivery models.WebhookDelivery) {
+	log := d.log.With(
+		zap.Int64("delivery_id", delivery.ID),
+		zap.Int64("webhook_id", delivery.WebhookID),
+		zap.String("type", delivery.EventType),
+	)
+
+	attempt := models.WebhookAttempt{DeliveryID: delivery.ID, AttemptedAt: time.Now()}
+	attempt.ResponseCode, attempt.Error = d.send(ctx, delivery)
+	attempt.Duration = time.Since(attempt.AttemptedAt)
+
+	if ctx.Err() != nil {
+		// Shutting down: the delivery is sent again once its claim is over.
+		return
+	}
+
+	status, next := models.WebhookDeliveryDelivered, time.Now()
+	switch {
+	case attempt.Error == "":
+		log.Info("webhook delivered", zap.Int("code", attempt.ResponseCode))
+	case delivery.Attempts+1 >= d.maxAttempts:
+		status = models.WebhookDeliveryFailed
+		log.Error("webhook delivery failed for good",
+			zap.Int("attempts", delivery.Attempts+1),
+			zap.Int("code", attempt.ResponseCode),
+			zap.String("error", attempt.Error))
+	default:
+		status, next = models.WebhookDeliveryPending, time.Now().Add(d.backoff(delivery.Attempts))
+		log.Warn("webhook delivery failed, retrying later",
+			zap.Int("attempts", delivery.Attempts+1),
+			zap.Int("code", attempt.ResponseCode),
+			zap.Time("next_attempt_at", next),
+			zap.String("error", attempt.Error))
+	}
+
+	result := status
+	if status == models.WebhookDeliveryPending {
+		result = "retry"
+	}
+	deliveriesCounter.WithLabelValues(result).Inc()
+
+	if err := d.storage.RecordWebhookAttempt(ctx, attempt, status, next); err != nil {
+		// The delivery is sent again once its claim is over; receivers dedupe by the event ID.
+		log.Error("failed to record webhook attempt", zap.Error(err))
+	}
+}
+
+// send posts the signed payload and returns the response code and, unless it is 2xx, the error.
+func (d *Deliverer) send(ctx context.Context, delivery models.WebhookDelivery) (int, string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "shop-webhooks/1")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, now, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Sprintf("unexpected status %s", resp.Status)
+	}
+
+	return resp.StatusCode, ""
+}
+
+// backoff returns the delay after the given number of previous failed attempts.
+func (d *Deliverer) backoff(attempts int) time.Duration {
+	delay := d.baseBackoff
+	for range attempts {
+		delay *= 2
+		if delay >= d.maxBackoff {
+			return d.maxBackoff
+		}
+	}
+
+	return delay
+}
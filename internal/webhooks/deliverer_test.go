@@ -0,0 +1,181 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"shop/internal/domain/models"
+)
+
+// fakeStorage hands out its deliveries on the first claim and records the attempts.
+type fakeStorage struct {
+	mu         sync.Mutex
+	deliveries []models.WebhookDelivery
+	attempts   []recordedAttempt
+}
+
+type recordedAttempt struct {
+	attempt models.WebhookAttempt
+	status  string
+	next    time.Time
+}
+
+func (s *fakeStorage) ClaimWebhookDeliveries(_ context.Context, limit int, _ time.Duration) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := min(limit, len(s.deliveries))
+	claimed := s.deliveries[:n]
+	s.deliveries = s.deliveries[n:]
+
+	return claimed, nil
+}
+
+func (s *fakeStorage) RecordWebhookAttempt(
+	_ context.Context,
+	attempt models.WebhookAttempt,
+	status string,
+	nextAttemptAt time.Time,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attempts = append(s.attempts, recordedAttempt{attempt: attempt, status: status, next: nextAttemptAt})
+	return nil
+}
+
+// receiver is a partner endpoint verifying the signature of every request and answering
+// with code.
+func receiver(t *testing.T, secret string, code int) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+
+		err = Verify(secret, r.Header.Get(TimestampHeader), r.Header.Get(SignatureHeader), body, time.Minute)
+		if err != nil {
+			t.Errorf("Verify: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if got := r.Header.Get(EventHeader); got != "order.placed" {
+			t.Errorf("event header = %q, want order.placed", got)
+		}
+		if got := r.Header.Get(DeliveryHeader); got != "7" {
+			t.Errorf("delivery header = %q, want 7", got)
+		}
+
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestDeliver(t *testing.T) {
+	const (
+		secret      = "partner-secret"
+		maxAttempts = 4
+		baseBackoff = time.Minute
+		maxBackoff  = 3 * time.Minute
+	)
+
+	tests := []struct {
+		name       string
+		code       int
+		attempts   int
+		wantStatus string
+		wantDelay  time.Duration
+	}{
+		{name: "delivered", code: http.StatusNoContent, wantStatus: models.WebhookDeliveryDelivered},
+		{name: "first retry", code: http.StatusInternalServerError, wantStatus: models.WebhookDeliveryPending,
+			wantDelay: baseBackoff},
+		{name: "backoff doubles", code: http.StatusServiceUnavailable, attempts: 1,
+			wantStatus: models.WebhookDeliveryPending, wantDelay: 2 * baseBackoff},
+		{name: "backoff is capped", code: http.StatusBadGateway, attempts: 2,
+			wantStatus: models.WebhookDeliveryPending, wantDelay: maxBackoff},
+		{name: "fails at last attempt", code: http.StatusInternalServerError, attempts: maxAttempts - 1,
+			wantStatus: models.WebhookDeliveryFailed},
+		{name: "redirect is not a delivery", code: http.StatusNotModified, wantStatus: models.WebhookDeliveryPending,
+			wantDelay: baseBackoff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := receiver(t, secret, tt.code)
+			storage := &fakeStorage{deliveries: []models.WebhookDelivery{{
+				ID:        7,
+				WebhookID: 1,
+				URL:       srv.URL,
+				Secret:    secret,
+				EventType: "order.placed",
+				Payload:   []byte(`{"id":"event-1"}`),
+				Attempts:  tt.attempts,
+			}}}
+			d := NewDeliverer(zap.NewNop(), storage, time.Second, time.Second, 10, maxAttempts, baseBackoff, maxBackoff)
+
+			start := time.Now()
+			d.deliverDue(context.Background())
+
+			if len(storage.attempts) != 1 {
+				t.Fatalf("recorded %d attempts, want 1", len(storage.attempts))
+			}
+			got := storage.attempts[0]
+			if got.status != tt.wantStatus {
+				t.Errorf("status = %s, want %s (%s)", got.status, tt.wantStatus, got.attempt.Error)
+			}
+			if got.attempt.DeliveryID != 7 || got.attempt.ResponseCode != tt.code {
+				t.Errorf("attempt = %+v, want delivery 7 answered with %d", got.attempt, tt.code)
+			}
+			if delivered := tt.wantStatus == models.WebhookDeliveryDelivered; delivered != (got.attempt.Error == "") {
+				t.Errorf("attempt error = %q", got.attempt.Error)
+			}
+			if tt.wantStatus == models.WebhookDeliveryPending {
+				if delay := got.next.Sub(start); delay < tt.wantDelay || delay > tt.wantDelay+5*time.Second {
+					t.Errorf("next attempt in %v, want %v", delay, tt.wantDelay)
+				}
+			}
+		})
+	}
+}
+
+func TestDeliverSignsWithTheTimestamp(t *testing.T) {
+	var signature, timestamp string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature, timestamp = r.Header.Get(SignatureHeader), r.Header.Get(TimestampHeader)
+	}))
+	t.Cleanup(srv.Close)
+
+	payload := []byte(`{"id":"event-1"}`)
+	storage := &fakeStorage{deliveries: []models.WebhookDelivery{{ID: 7, URL: srv.URL, Secret: "s", Payload: payload}}}
+	NewDeliverer(zap.NewNop(), storage, time.Second, time.Second, 10, 3, time.Minute, time.Hour).
+		deliverDue(context.Background())
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header %q: %v", timestamp, err)
+	}
+	if want := Sign("s", time.Unix(unix, 0), payload); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+	// A signature of another secret or a replay with a later timestamp does not verify.
+	if err := Verify("other", timestamp, signature, payload, time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify with another secret = %v, want %v", err, ErrInvalidSignature)
+	}
+	later := strconv.FormatInt(unix+1, 10)
+	if err := Verify("s", later, signature, payload, time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify with another timestamp = %v, want %v", err, ErrInvalidSignature)
+	}
+}
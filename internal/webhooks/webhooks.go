@@ -0,0 +1,163 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"shop/internal/events"
+)
+
+// Group is the consumer group of the webhook subscriptions.
+const Group = "webhooks"
+
+// Headers of webhook requests. The signature is "sha256=" and the hex HMAC-SHA256, keyed
+// with the webhook secret, of the timestamp header, a dot and the body.
+const (
+	EventHeader     = "X-Shop-Event"
+	DeliveryHeader  = "X-Shop-Delivery"
+	TimestampHeader = "X-Shop-Timestamp"
+	SignatureHeader = "X-Shop-Signature"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrExpiredTimestamp = errors.New("webhook timestamp out of tolerance")
+)
+
+// EventTypes are the events partners can subscribe to.
+var EventTypes = []string{
+	events.OrderPlaced{}.EventType(),
+	events.StockChanged{}.EventType(),
+}
+
+// Payload is the JSON body of a webhook request.
+type Payload struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Sign returns the signature of body sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a received webhook request. Requests
+// older than tolerance are rejected, so a captured request cannot be replayed later.
+func Verify(secret, timestamp, signature string, body []byte, tolerance time.Duration) error {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	sentAt := time.Unix(unix, 0)
+	if age := time.Since(sentAt); age > tolerance || age < -tolerance {
+		return ErrExpiredTimestamp
+	}
+
+	if !hmac.Equal([]byte(Sign(secret, sentAt, body)), []byte(strings.TrimSpace(signature))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// NewSecret returns a random secret for a new webhook.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+type DispatcherStorage interface {
+	EnqueueWebhookDeliveries(ctx context.Context, eventID, eventType string, payload []byte) (int, error)
+}
+
+// Dispatcher turns events into pending deliveries for the webhooks receiving them; the
+// Deliverer sends them.
+type Dispatcher struct {
+	log     *zap.Logger
+	storage DispatcherStorage
+}
+
+func NewDispatcher(log *zap.Logger, storage DispatcherStorage) *Dispatcher {
+	return &Dispatcher{
+		log:     log.With(zap.String("component", "webhooks/dispatcher")),
+		storage: storage,
+	}
+}
+
+// Subscribe starts handling the events partners can subscribe to.
+func (d *Dispatcher) Subscribe(ctx context.Context, sub events.Subscriber) error {
+	const op = "webhooks.Subscribe"
+
+	if err := events.Subscribe(ctx, sub, events.TopicOrderPlaced, Group, dispatch[events.OrderPlaced](d)); err != nil {
+		return fmt.Errorf("%s, %w", op, err)
+	}
+	if err := events.Subscribe(ctx, sub, events.TopicStockChanged, Group, dispatch[events.StockChanged](d)); err != nil {
+		return fmt.Errorf("%s, %w", op, err)
+	}
+
+	return nil
+}
+
+func dispatch[T events.Event](d *Dispatcher) func(ctx context.Context, event T) error {
+	return func(ctx context.Context, event T) error {
+		const op = "webhooks.dispatch"
+
+		env, _ := events.EnvelopeFromContext(ctx)
+		if env.ID == "" {
+			// Events predating envelopes have no ID to deduplicate deliveries by.
+			env.ID = uuid.NewString()
+		}
+		if env.OccurredAt.IsZero() {
+			env.OccurredAt = time.Now().UTC()
+		}
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			return events.Permanent(fmt.Errorf("%s: failed to encode %s: %w", op, event.EventType(), err))
+		}
+
+		payload, err := json.Marshal(Payload{
+			ID:         env.ID,
+			Type:       event.EventType(),
+			OccurredAt: env.OccurredAt,
+			Data:       data,
+		})
+		if err != nil {
+			return events.Permanent(fmt.Errorf("%s: failed to encode payload: %w", op, err))
+		}
+
+		n, err := d.storage.EnqueueWebhookDeliveries(ctx, env.ID, event.EventType(), payload)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if n > 0 {
+			d.log.Debug("webhook deliveries enqueued", zap.String("event_id", env.ID), zap.String("type", event.EventType()), zap.Int("count", n))
+		}
+
+		return nil
+	}
+}
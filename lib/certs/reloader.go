@@ -0,0 +1,198 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrNoCertificates = errors.New("no certificates found in CA file")
+
+// Reloader holds a key pair and an optional CA bundle and reloads them when the files change,
+// so certificates can be rotated without a restart.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	caPool  *x509.CertPool
+	modTime time.Time
+}
+
+// NewReloader loads the files. certFile and keyFile may be empty for a client without a certificate,
+// caFile may be empty to use the system roots (client) or not verify clients (server).
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *Reloader) load() error {
+	const op = "certs.load"
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("%s: failed to load key pair: %w", op, err)
+		}
+		cert = &pair
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("%s: failed to read CA file: %w", op, err)
+		}
+
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s: %w", op, ErrNoCertificates)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = cert
+	r.caPool = pool
+	r.modTime = modTime
+
+	return nil
+}
+
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// Watch checks the files every interval and reloads them when they change, until ctx is done.
+// A failed reload keeps the previous certificates. A non-positive interval disables reloading.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, log *zap.Logger) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		modTime, err := r.latestModTime()
+		if err != nil {
+			log.Error("failed to stat certificate files", zap.Error(err))
+			continue
+		}
+
+		r.mu.RLock()
+		changed := modTime.After(r.modTime)
+		r.mu.RUnlock()
+
+		if !changed {
+			continue
+		}
+
+		if err := r.load(); err != nil {
+			log.Error("failed to reload certificates", zap.Error(err))
+			continue
+		}
+
+		log.Info("certificates reloaded", zap.String("cert_file", r.certFile))
+	}
+}
+
+// ServerConfig returns a server TLS config using the current certificate. When a CA file is set,
+// clients must present a certificate signed by it.
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			if r.cert == nil {
+				return nil, errors.New("no server certificate configured")
+			}
+
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+				NextProtos:   []string{"h2"},
+			}
+			if r.caPool != nil {
+				cfg.ClientCAs = r.caPool
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+
+			return cfg, nil
+		},
+	}
+}
+
+// ClientConfig returns a client TLS config that checks the server against the CA file
+// (or system roots) and presents the current certificate if one is set.
+// The CA pool is the one loaded at the time of the call.
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		RootCAs:    r.caPool,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			if r.cert == nil {
+				return &tls.Certificate{}, nil
+			}
+
+			return r.cert, nil
+		},
+	}
+}
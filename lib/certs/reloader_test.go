@@ -0,0 +1,218 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// authority is a CA generated for the test that issues leaf certificates.
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newAuthority(t *testing.T, dir, name string) *authority {
+	t.Helper()
+
+	key := generateKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse CA certificate: %v", err)
+	}
+
+	file := filepath.Join(dir, name+".crt")
+	writePEM(t, file, "CERTIFICATE", der)
+
+	return &authority{cert: cert, key: key, file: file}
+}
+
+// issue writes a leaf certificate and its key signed by the CA and returns their files.
+func (a *authority) issue(t *testing.T, dir, name string, usage x509.ExtKeyUsage) (certFile, keyFile string) {
+	t.Helper()
+
+	key := generateKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatalf("create %s certificate: %v", name, err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal %s key: %v", name, err)
+	}
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+
+	return certFile, keyFile
+}
+
+func generateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	return key
+}
+
+func writePEM(t *testing.T, file, blockType string, der []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", file, err)
+	}
+}
+
+// serveMTLS starts a gRPC server requiring client certificates signed by ca and returns its address.
+func serveMTLS(t *testing.T, dir string, ca *authority) string {
+	t.Helper()
+
+	certFile, keyFile := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	reloader, err := NewReloader(certFile, keyFile, ca.file)
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(reloader.ServerConfig())))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String()
+}
+
+func check(t *testing.T, addr string, client *Reloader) error {
+	t.Helper()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(client.ClientConfig("server"))))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+
+	return err
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, dir, "ca")
+	other := newAuthority(t, dir, "other-ca")
+	addr := serveMTLS(t, dir, ca)
+
+	clientCert, clientKey := ca.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+	otherCert, otherKey := other.issue(t, dir, "intruder", x509.ExtKeyUsageClientAuth)
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		want     codes.Code
+	}{
+		{name: "client certificate", certFile: clientCert, keyFile: clientKey, want: codes.OK},
+		{name: "no client certificate", want: codes.Unavailable},
+		{name: "certificate of another CA", certFile: otherCert, keyFile: otherKey, want: codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewReloader(tt.certFile, tt.keyFile, ca.file)
+			if err != nil {
+				t.Fatalf("NewReloader: %v", err)
+			}
+
+			err = check(t, addr, client)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("code = %v, want %v (%v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestClientRejectsUnknownServer(t *testing.T) {
+	dir := t.TempDir()
+	addr := serveMTLS(t, dir, newAuthority(t, dir, "ca"))
+
+	// The client trusts another CA, so it must refuse the server before sending its certificate.
+	other := newAuthority(t, dir, "other-ca")
+	certFile, keyFile := other.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+	client, err := NewReloader(certFile, keyFile, other.file)
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+
+	if err := check(t, addr, client); status.Code(err) != codes.Unavailable {
+		t.Errorf("code = %v, want %v (%v)", status.Code(err), codes.Unavailable, err)
+	}
+}
+
+func TestNewReloaderRejectsEmptyCA(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(file, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("write CA: %v", err)
+	}
+
+	if _, err := NewReloader("", "", file); !errors.Is(err, ErrNoCertificates) {
+		t.Errorf("NewReloader error = %v, want %v", err, ErrNoCertificates)
+	}
+}
@@ -0,0 +1,36 @@
+// Package cursor encodes the positions used for cursor pagination and stream resumption.
+// Cursors are opaque to clients; they carry the id of the last item a client has seen.
+package cursor
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+)
+
+// Encode returns the cursor of the position after id.
+func Encode(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// Decode returns the id the cursor was made from, 0 for an empty cursor.
+func Decode(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if id < 0 {
+		return 0, errors.New("negative id in cursor")
+	}
+
+	return id, nil
+}
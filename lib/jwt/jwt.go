@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,14 +11,45 @@ import (
 	"shop/internal/domain/models"
 )
 
-func NewToken(user models.User, app models.App, duration time.Duration) (string, error) {
+// AppProvider returns the app a token was issued for; its secret signs the token.
+type AppProvider interface {
+	App(ctx context.Context, appID int) (models.App, error)
+}
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the claims carried by tokens issued with NewToken.
+type Claims struct {
+	UID   int64
+	Email string
+	AppID int
+	Roles []string
+}
+
+// HasRole reports whether the token carries the given role.
+func (c Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+func NewToken(user models.User, app models.App, roles []string, duration time.Duration) (string, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 
+	if roles == nil {
+		roles = []string{}
+	}
+
 	claims := token.Claims.(jwt.MapClaims)
 	claims["uid"] = user.ID
 	claims["email"] = user.Email
 	claims["exp"] = time.Now().Add(duration).Unix()
 	claims["app_id"] = app.ID
+	claims["roles"] = roles
 
 	tokenString, err := token.SignedString([]byte(app.Secret))
 	if err != nil {
@@ -26,28 +59,68 @@ func NewToken(user models.User, app models.App, duration time.Duration) (string,
 	return tokenString, nil
 }
 
-func GetEmailFromToken(tokenString string) (string, error) {
-	const op = "jwt.ParseTokenForEmail"
+// ParseToken verifies the token with the secret of the app in its app_id claim and returns its
+// claims. Tokens without the uid, app_id or exp claims are rejected.
+func ParseToken(ctx context.Context, apps AppProvider, tokenString string) (Claims, error) {
+	const op = "jwt.ParseToken"
 
-	secretKey := []byte("test-secret")
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("%s: unexpected signing method: %v", op, token.Header["alg"])
+		mapClaims, _ := token.Claims.(jwt.MapClaims)
+
+		appID, ok := mapClaims["app_id"].(float64)
+		if !ok {
+			return nil, errors.New("missing app_id claim")
+		}
+
+		app, err := apps.App(ctx, int(appID))
+		if err != nil {
+			return nil, err
+		}
+		if app.Secret == "" {
+			return nil, fmt.Errorf("app %d has no secret", app.ID)
 		}
-		return secretKey, nil
-	})
 
+		return []byte(app.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
 	if err != nil {
-		return "", err
+		return Claims{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidToken, err)
+	}
+
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
 	}
 
-	var email string
+	uid, ok := mapClaims["uid"].(float64)
+	if !ok {
+		return Claims{}, fmt.Errorf("%s: missing uid claim: %w", op, ErrInvalidToken)
+	}
+
+	claims := Claims{
+		UID:   int64(uid),
+		AppID: int(mapClaims["app_id"].(float64)),
+	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		email = claims["email"].(string)
-	} else {
-		return "", fmt.Errorf("invalid token")
+	if email, ok := mapClaims["email"].(string); ok {
+		claims.Email = email
+	}
+	if roles, ok := mapClaims["roles"].([]interface{}); ok {
+		for _, role := range roles {
+			if r, ok := role.(string); ok {
+				claims.Roles = append(claims.Roles, r)
+			}
+		}
+	}
+
+	return claims, nil
+}
+
+// GetEmailFromToken verifies the token like ParseToken and returns its email claim.
+func GetEmailFromToken(ctx context.Context, apps AppProvider, tokenString string) (string, error) {
+	claims, err := ParseToken(ctx, apps, tokenString)
+	if err != nil {
+		return "", err
 	}
 
-	return email, nil
+	return claims.Email, nil
 }
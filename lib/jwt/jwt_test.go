@@ -0,0 +1,107 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"shop/internal/domain/models"
+)
+
+// fakeApps knows app 1, whose secret is "app-secret".
+type fakeApps struct{}
+
+func (fakeApps) App(_ context.Context, appID int) (models.App, error) {
+	if appID != 1 {
+		return models.App{}, errors.New("app not found")
+	}
+
+	return models.App{ID: 1, Secret: "app-secret"}, nil
+}
+
+func sign(t *testing.T, claims jwt.MapClaims, key string) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	return token
+}
+
+func TestParseToken(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		key     string
+		wantErr bool
+	}{
+		{name: "valid", claims: jwt.MapClaims{"uid": 7, "app_id": 1, "exp": exp, "email": "user@example.com"},
+			key: "app-secret"},
+		{name: "wrong key", claims: jwt.MapClaims{"uid": 7, "app_id": 1, "exp": exp}, key: "test-secret", wantErr: true},
+		{name: "unknown app", claims: jwt.MapClaims{"uid": 7, "app_id": 2, "exp": exp}, key: "app-secret", wantErr: true},
+		{name: "expired", claims: jwt.MapClaims{"uid": 7, "app_id": 1, "exp": time.Now().Add(-time.Minute).Unix()},
+			key: "app-secret", wantErr: true},
+		{name: "without uid", claims: jwt.MapClaims{"app_id": 1, "exp": exp}, key: "app-secret", wantErr: true},
+		{name: "without app_id", claims: jwt.MapClaims{"uid": 7, "exp": exp}, key: "app-secret", wantErr: true},
+		{name: "without exp", claims: jwt.MapClaims{"uid": 7, "app_id": 1}, key: "app-secret", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseToken(context.Background(), fakeApps{}, sign(t, tt.claims, tt.key))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("ParseToken error = %v, want %v", err, ErrInvalidToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseToken: %v", err)
+			}
+			if claims.UID != 7 || claims.AppID != 1 || claims.Email != "user@example.com" {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestParseTokenRejectsOtherAlgorithms(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"uid": 7, "app_id": 1, "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	if _, err := ParseToken(context.Background(), fakeApps{}, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseToken error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestNewTokenRoundTrip(t *testing.T) {
+	token, err := NewToken(models.User{ID: 7, Email: "user@example.com"}, models.App{ID: 1, Secret: "app-secret"},
+		[]string{models.RoleAdmin}, time.Hour)
+	if err != nil {
+		t.Fatalf("NewToken: %v", err)
+	}
+
+	claims, err := ParseToken(context.Background(), fakeApps{}, token)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if claims.UID != 7 || !claims.HasRole(models.RoleAdmin) {
+		t.Errorf("claims = %+v, want user 7 with the admin role", claims)
+	}
+
+	email, err := GetEmailFromToken(context.Background(), fakeApps{}, token)
+	if err != nil || email != "user@example.com" {
+		t.Errorf("GetEmailFromToken = %q, %v; want user@example.com", email, err)
+	}
+}
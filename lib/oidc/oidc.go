@@ -0,0 +1,315 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid id token")
+	ErrNonceMismatch  = errors.New("nonce mismatch")
+)
+
+// Config describes an OpenID Connect relying party registration.
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Provider runs the authorization code flow with PKCE against an OpenID Connect provider.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]*rsa.PublicKey
+}
+
+// IDToken holds the claims of a verified ID token the shop cares about.
+type IDToken struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func New(cfg Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Provider{
+		cfg:    cfg,
+		client: client,
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL returns the URL to send the user to, with state, nonce and the S256 code challenge for verifier.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	const op = "oidc.AuthCodeURL"
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens and returns the verified ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (IDToken, error) {
+	const op = "oidc.Exchange"
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%s: failed to call token endpoint: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return IDToken{}, fmt.Errorf("%s: token endpoint returned %s", op, resp.Status)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return IDToken{}, fmt.Errorf("%s: failed to decode token response: %w", op, err)
+	}
+	if tokens.IDToken == "" {
+		return IDToken{}, fmt.Errorf("%s: no id_token in response: %w", op, ErrInvalidIDToken)
+	}
+
+	idToken, err := p.Verify(ctx, tokens.IDToken, nonce)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return idToken, nil
+}
+
+// Verify checks signature, issuer, audience, expiry and nonce of a raw ID token.
+func (p *Provider) Verify(ctx context.Context, raw, nonce string) (IDToken, error) {
+	const op = "oidc.Verify"
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims := jwt.MapClaims{}
+
+	_, err = jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidIDToken, err)
+	}
+
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return IDToken{}, fmt.Errorf("%s: %w", op, ErrNonceMismatch)
+	}
+
+	var idToken IDToken
+
+	idToken.Subject, _ = claims["sub"].(string)
+	idToken.Email, _ = claims["email"].(string)
+	idToken.EmailVerified, _ = claims["email_verified"].(bool)
+
+	if idToken.Subject == "" {
+		return IDToken{}, fmt.Errorf("%s: missing subject: %w", op, ErrInvalidIDToken)
+	}
+
+	return idToken, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d discovery
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("failed to discover provider: %w", err)
+	}
+
+	if d.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("issuer mismatch: expected %q, got %q", p.cfg.Issuer, d.Issuer)
+	}
+
+	p.discovery = &d
+
+	return p.discovery, nil
+}
+
+// key returns the signing key with the given id, refetching the key set once if the key is unknown.
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, d.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	key, ok = keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// RandomString returns a URL-safe random string for state, nonce and PKCE verifiers.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge returns the S256 PKCE challenge for the verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,162 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"shop/lib/oidc"
+	"shop/lib/oidc/oidctest"
+)
+
+const (
+	clientID    = "shop"
+	redirectURL = "http://shop.test/auth/oidc/mock/callback"
+	nonce       = "nonce"
+)
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		ttl      time.Duration
+		verifier string
+		nonce    string
+		want     oidc.IDToken
+		wantErr  error
+	}{
+		{
+			name: "valid",
+			want: oidc.IDToken{Subject: "subject", Email: "user@example.com", EmailVerified: true},
+		},
+		{
+			name:   "unverified email",
+			claims: jwt.MapClaims{"sub": "subject", "email": "user@example.com", "email_verified": false},
+			want:   oidc.IDToken{Subject: "subject", Email: "user@example.com"},
+		},
+		{
+			name:     "pkce verifier mismatch",
+			verifier: "another-verifier",
+			wantErr:  errAny,
+		},
+		{
+			name:    "nonce mismatch",
+			nonce:   "another-nonce",
+			wantErr: oidc.ErrNonceMismatch,
+		},
+		{
+			name:    "expired id token",
+			ttl:     -time.Minute,
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:    "other audience",
+			claims:  jwt.MapClaims{"sub": "subject", "aud": "another-client"},
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:    "other issuer",
+			claims:  jwt.MapClaims{"sub": "subject", "iss": "https://evil.example.com"},
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:    "no subject",
+			claims:  jwt.MapClaims{"email": "user@example.com"},
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := oidctest.New(t)
+			if tt.claims != nil {
+				srv.SetClaims(tt.claims)
+			}
+			if tt.ttl != 0 {
+				srv.SetTTL(tt.ttl)
+			}
+
+			p := oidc.New(oidc.Config{
+				Name:        "mock",
+				Issuer:      srv.URL,
+				ClientID:    clientID,
+				RedirectURL: redirectURL,
+			}, srv.Client())
+
+			ctx := context.Background()
+
+			verifier, err := oidc.RandomString()
+			if err != nil {
+				t.Fatalf("RandomString: %v", err)
+			}
+
+			authURL, err := p.AuthCodeURL(ctx, "state", nonce, verifier)
+			if err != nil {
+				t.Fatalf("AuthCodeURL: %v", err)
+			}
+			callback := srv.Authorize(t, authURL)
+			if got := callback.Query().Get("state"); got != "state" {
+				t.Fatalf("callback state = %q, want %q", got, "state")
+			}
+
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			wantNonce := nonce
+			if tt.nonce != "" {
+				wantNonce = tt.nonce
+			}
+
+			got, err := p.Exchange(ctx, callback.Query().Get("code"), verifier, wantNonce)
+			switch {
+			case tt.wantErr == errAny:
+				if err == nil {
+					t.Fatal("Exchange succeeded, want error")
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Exchange error = %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("Exchange: %v", err)
+			case got != tt.want:
+				t.Errorf("Exchange = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsReusedCode(t *testing.T) {
+	srv := oidctest.New(t)
+	p := oidc.New(oidc.Config{Issuer: srv.URL, ClientID: clientID, RedirectURL: redirectURL}, srv.Client())
+	ctx := context.Background()
+
+	authURL, err := p.AuthCodeURL(ctx, "state", nonce, "verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code := srv.Authorize(t, authURL).Query().Get("code")
+
+	if _, err := p.Exchange(ctx, code, "verifier", nonce); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if _, err := p.Exchange(ctx, code, "verifier", nonce); err == nil {
+		t.Error("second Exchange of the code succeeded")
+	}
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	srv := oidctest.New(t)
+	// Discovery is fetched from the same document, but the issuer it names differs.
+	p := oidc.New(oidc.Config{Issuer: srv.URL + "/", ClientID: clientID, RedirectURL: redirectURL}, srv.Client())
+
+	if _, err := p.AuthCodeURL(context.Background(), "state", nonce, "verifier"); err == nil {
+		t.Error("AuthCodeURL succeeded with a mismatched issuer")
+	}
+}
+
+// errAny marks cases that only expect some error, like a token endpoint rejecting the request.
+var errAny = errors.New("any error")
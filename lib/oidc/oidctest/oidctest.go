@@ -0,0 +1,234 @@
+// Package oidctest runs a mock OpenID Connect provider for tests of relying parties.
+//
+// The provider serves discovery, a key set, an authorization endpoint that approves every
+// request at once and a token endpoint that checks the PKCE verifier of the code.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "test-key"
+
+// Server is a mock OpenID Connect provider. Its URL is the issuer.
+type Server struct {
+	*httptest.Server
+
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	claims jwt.MapClaims
+	ttl    time.Duration
+	grants map[string]grant
+}
+
+type grant struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+// New starts a provider, closed when the test ends. ID tokens are issued for the subject
+// "subject" with the verified email "user@example.com" until SetClaims says otherwise.
+func New(t testing.TB) *Server {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("oidctest: generate key: %v", err)
+	}
+
+	s := &Server{
+		key: key,
+		claims: jwt.MapClaims{
+			"sub":            "subject",
+			"email":          "user@example.com",
+			"email_verified": true,
+		},
+		ttl:    time.Hour,
+		grants: make(map[string]grant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("GET /jwks", s.handleKeys)
+	mux.HandleFunc("GET /authorize", s.handleAuthorize)
+	mux.HandleFunc("POST /token", s.handleToken)
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// SetClaims replaces the claims of the ID tokens issued next. iss, aud, nonce, iat and exp
+// are filled in unless set.
+func (s *Server) SetClaims(claims jwt.MapClaims) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.claims = claims
+}
+
+// SetTTL sets how long the ID tokens issued next are valid. A negative ttl issues expired tokens.
+func (s *Server) SetTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ttl = ttl
+}
+
+// Authorize plays the user approving the request of authURL and returns the URL the
+// provider sends them back to, carrying the code and state.
+func (s *Server) Authorize(t testing.TB, authURL string) *url.URL {
+	t.Helper()
+
+	client := s.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("oidctest: authorize: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("oidctest: authorize returned %s", resp.Status)
+	}
+
+	callback, err := resp.Location()
+	if err != nil {
+		t.Fatalf("oidctest: authorize: %v", err)
+	}
+
+	return callback
+}
+
+func (s *Server) handleDiscovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) handleKeys(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{
+		"keys": []map[string]string{{
+			"kid": keyID,
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	code := rand.Text()
+
+	s.mu.Lock()
+	s.grants[code] = grant{
+		clientID:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+	}
+	s.mu.Unlock()
+
+	callback, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	params := callback.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	callback.RawQuery = params.Encode()
+
+	http.Redirect(w, r, callback.String(), http.StatusFound)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	code := r.PostForm.Get("code")
+	g, ok := s.grants[code]
+	delete(s.grants, code)
+	claims := jwt.MapClaims{}
+	for k, v := range s.claims {
+		claims[k] = v
+	}
+	ttl := s.ttl
+	s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || g.redirectURI != r.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	defaults := map[string]any{
+		"iss":   s.URL,
+		"aud":   g.clientID,
+		"nonce": g.nonce,
+		"iat":   now.Unix(),
+		"exp":   now.Add(ttl).Unix(),
+	}
+	for k, v := range defaults {
+		if _, ok := claims[k]; !ok {
+			claims[k] = v
+		}
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]string{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
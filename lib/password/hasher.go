@@ -0,0 +1,173 @@
+package password
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown password hashing algorithm")
+	ErrMalformedHash    = errors.New("malformed password hash")
+)
+
+// Argon2Params are the argon2id parameters; Memory is in KiB.
+type Argon2Params struct {
+	Time       uint32
+	Memory     uint32
+	Threads    uint8
+	KeyLength  uint32
+	SaltLength uint32
+}
+
+// Hasher hashes passwords with the configured algorithm and verifies hashes of any supported algorithm,
+// so hashes created with older settings keep working until they are upgraded.
+type Hasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     Argon2Params
+}
+
+func NewHasher(algorithm string, bcryptCost int, argon2 Argon2Params) (*Hasher, error) {
+	switch algorithm {
+	case AlgorithmBcrypt:
+		if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgorithmArgon2id:
+		if argon2.Time == 0 || argon2.Memory == 0 || argon2.Threads == 0 || argon2.KeyLength == 0 || argon2.SaltLength == 0 {
+			return nil, errors.New("argon2id parameters must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, algorithm)
+	}
+
+	return &Hasher{
+		algorithm:  algorithm,
+		bcryptCost: bcryptCost,
+		argon2:     argon2,
+	}, nil
+}
+
+// Hash returns the hash of the password in the configured algorithm.
+func (h *Hasher) Hash(password string) ([]byte, error) {
+	if h.algorithm == AlgorithmBcrypt {
+		return bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+	}
+
+	salt := make([]byte, h.argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.argon2.Time, h.argon2.Memory, h.argon2.Threads, h.argon2.KeyLength)
+
+	return []byte(fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.argon2.Memory,
+		h.argon2.Time,
+		h.argon2.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+// Verify reports whether the password matches the hash.
+func (h *Hasher) Verify(hash []byte, password string) (bool, error) {
+	if bytes.HasPrefix(hash, []byte("$argon2id$")) {
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, err
+		}
+
+		other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLength)
+
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// NeedsRehash reports whether the hash was made with another algorithm or with parameters that
+// differ from the configured ones, so lowering a cost rehashes too.
+func (h *Hasher) NeedsRehash(hash []byte) bool {
+	if bytes.HasPrefix(hash, []byte("$argon2id$")) {
+		if h.algorithm != AlgorithmArgon2id {
+			return true
+		}
+
+		params, salt, _, err := decodeArgon2(hash)
+		if err != nil {
+			return true
+		}
+
+		return params.Time != h.argon2.Time ||
+			params.Memory != h.argon2.Memory ||
+			params.Threads != h.argon2.Threads ||
+			params.KeyLength != h.argon2.KeyLength ||
+			uint32(len(salt)) != h.argon2.SaltLength
+	}
+
+	if h.algorithm != AlgorithmBcrypt {
+		return true
+	}
+
+	cost, err := bcrypt.Cost(hash)
+	if err != nil {
+		return true
+	}
+
+	return cost != h.bcryptCost
+}
+
+func decodeArgon2(hash []byte) (Argon2Params, []byte, []byte, error) {
+	// $argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+	params.KeyLength = uint32(len(key))
+	params.SaltLength = uint32(len(salt))
+
+	return params, salt, key, nil
+}
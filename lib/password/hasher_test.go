@@ -0,0 +1,165 @@
+package password
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2 = Argon2Params{Time: 1, Memory: 64, Threads: 1, KeyLength: 16, SaltLength: 8}
+
+func newHasher(t *testing.T, algorithm string, bcryptCost int, params Argon2Params) *Hasher {
+	t.Helper()
+
+	h, err := NewHasher(algorithm, bcryptCost, params)
+	if err != nil {
+		t.Fatalf("NewHasher: %v", err)
+	}
+
+	return h
+}
+
+func TestNewHasher(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		cost      int
+		params    Argon2Params
+		wantErr   bool
+	}{
+		{name: "bcrypt", algorithm: AlgorithmBcrypt, cost: bcrypt.MinCost},
+		{name: "bcrypt cost too low", algorithm: AlgorithmBcrypt, cost: bcrypt.MinCost - 1, wantErr: true},
+		{name: "argon2id", algorithm: AlgorithmArgon2id, params: testArgon2},
+		{name: "argon2id without memory", algorithm: AlgorithmArgon2id,
+			params: Argon2Params{Time: 1, Threads: 1, KeyLength: 16, SaltLength: 8}, wantErr: true},
+		{name: "unknown algorithm", algorithm: "md5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHasher(tt.algorithm, tt.cost, tt.params); (err != nil) != tt.wantErr {
+				t.Errorf("NewHasher error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHashAndVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		prefix string
+	}{
+		{name: "argon2id", hasher: newHasher(t, AlgorithmArgon2id, 0, testArgon2), prefix: "$argon2id$v=19$m=64,t=1,p=1$"},
+		{name: "bcrypt", hasher: newHasher(t, AlgorithmBcrypt, bcrypt.MinCost, Argon2Params{}), prefix: "$2a$04$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.hasher.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if !bytes.HasPrefix(hash, []byte(tt.prefix)) {
+				t.Errorf("hash = %s, want prefix %s", hash, tt.prefix)
+			}
+
+			if ok, err := tt.hasher.Verify(hash, "correct horse"); err != nil || !ok {
+				t.Errorf("Verify of the password = %v, %v; want a match", ok, err)
+			}
+			if ok, err := tt.hasher.Verify(hash, "battery staple"); err != nil || ok {
+				t.Errorf("Verify of another password = %v, %v; want no match", ok, err)
+			}
+
+			other, err := tt.hasher.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if bytes.Equal(hash, other) {
+				t.Error("two hashes of the password are equal, want them salted")
+			}
+		})
+	}
+}
+
+func TestVerifyLegacyHashes(t *testing.T) {
+	h := newHasher(t, AlgorithmArgon2id, 0, testArgon2)
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+	if ok, err := h.Verify(legacy, "correct horse"); err != nil || !ok {
+		t.Errorf("Verify of a bcrypt hash = %v, %v; want a match", ok, err)
+	}
+	if ok, err := h.Verify(legacy, "battery staple"); err != nil || ok {
+		t.Errorf("Verify of a bcrypt hash with another password = %v, %v; want no match", ok, err)
+	}
+
+	// A hash made with other argon2id parameters still verifies.
+	older, err := newHasher(t, AlgorithmArgon2id, 0, Argon2Params{
+		Time: 2, Memory: 32, Threads: 2, KeyLength: 32, SaltLength: 16,
+	}).Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if ok, err := h.Verify(older, "correct horse"); err != nil || !ok {
+		t.Errorf("Verify of an argon2id hash with other parameters = %v, %v; want a match", ok, err)
+	}
+
+	if _, err := h.Verify([]byte("$argon2id$v=19$broken"), "correct horse"); !errors.Is(err, ErrMalformedHash) {
+		t.Errorf("Verify of a malformed hash error = %v, want %v", err, ErrMalformedHash)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon2id := newHasher(t, AlgorithmArgon2id, 0, testArgon2)
+	bcrypt4 := newHasher(t, AlgorithmBcrypt, bcrypt.MinCost, Argon2Params{})
+
+	hash := func(h *Hasher) []byte {
+		t.Helper()
+
+		hash, err := h.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("Hash: %v", err)
+		}
+
+		return hash
+	}
+
+	stronger := testArgon2
+	stronger.Time = 2
+	longerSalt := testArgon2
+	longerSalt.SaltLength = 16
+
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		hash   []byte
+		want   bool
+	}{
+		{name: "current argon2id", hasher: argon2id, hash: hash(argon2id)},
+		{name: "current bcrypt", hasher: bcrypt4, hash: hash(bcrypt4)},
+		{name: "bcrypt to argon2id", hasher: argon2id, hash: hash(bcrypt4), want: true},
+		{name: "argon2id to bcrypt", hasher: bcrypt4, hash: hash(argon2id), want: true},
+		{name: "raised argon2id time", hasher: newHasher(t, AlgorithmArgon2id, 0, stronger), hash: hash(argon2id),
+			want: true},
+		{name: "lowered argon2id time", hasher: argon2id, hash: hash(newHasher(t, AlgorithmArgon2id, 0, stronger)),
+			want: true},
+		{name: "longer salt", hasher: newHasher(t, AlgorithmArgon2id, 0, longerSalt), hash: hash(argon2id), want: true},
+		{name: "raised bcrypt cost", hasher: newHasher(t, AlgorithmBcrypt, bcrypt.MinCost+1, Argon2Params{}),
+			hash: hash(bcrypt4), want: true},
+		{name: "malformed argon2id", hasher: argon2id, hash: []byte("$argon2id$v=19$broken"), want: true},
+		{name: "malformed bcrypt", hasher: bcrypt4, hash: []byte("not a hash"), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
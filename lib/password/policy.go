@@ -0,0 +1,114 @@
+package password
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrTooShort     = errors.New("password is too short")
+	ErrBreached     = errors.New("password has appeared in a data breach")
+	ErrEqualsEmail  = errors.New("password must not be the same as the email")
+	ErrPolicyFailed = errors.New("password does not meet the policy")
+)
+
+// Reasons of violations, stable codes clients can show their own message for.
+const (
+	ReasonTooShort    = "PASSWORD_TOO_SHORT"
+	ReasonEqualsEmail = "PASSWORD_EQUALS_EMAIL"
+	ReasonBreached    = "PASSWORD_BREACHED"
+)
+
+// Policy decides whether a password is acceptable for an account.
+type Policy struct {
+	minLength int
+	breached  map[string]struct{}
+}
+
+// NewPolicy returns a policy. breachedListPath is a file with one known breached password per line;
+// empty path disables the breach check.
+func NewPolicy(minLength int, breachedListPath string) (*Policy, error) {
+	p := &Policy{
+		minLength: minLength,
+		breached:  map[string]struct{}{},
+	}
+
+	if breachedListPath == "" {
+		return p, nil
+	}
+
+	f, err := os.Open(breachedListPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached passwords list: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.breached[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached passwords list: %w", err)
+	}
+
+	return p, nil
+}
+
+func (p *Policy) MinLength() int {
+	return p.minLength
+}
+
+// Violation is returned by Validate; Message can be shown to the user as is.
+type Violation struct {
+	Err     error
+	Reason  string
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return v.Message
+}
+
+func (v *Violation) Unwrap() []error {
+	return []error{ErrPolicyFailed, v.Err}
+}
+
+// Validate returns nil if the password is acceptable for the email, otherwise a *Violation.
+func (p *Policy) Validate(email, password string) error {
+	if utf8.RuneCountInString(password) < p.minLength {
+		return &Violation{
+			Err:     ErrTooShort,
+			Reason:  ReasonTooShort,
+			Message: fmt.Sprintf("Password must be at least %d characters long", p.minLength),
+		}
+	}
+
+	lower := strings.ToLower(password)
+
+	localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+	if lower == strings.ToLower(email) || (localPart != "" && lower == localPart) {
+		return &Violation{
+			Err:     ErrEqualsEmail,
+			Reason:  ReasonEqualsEmail,
+			Message: "Password must not be the same as your email",
+		}
+	}
+
+	if _, ok := p.breached[lower]; ok {
+		return &Violation{
+			Err:     ErrBreached,
+			Reason:  ReasonBreached,
+			Message: "This password has appeared in a data breach. Please choose a different one",
+		}
+	}
+
+	return nil
+}
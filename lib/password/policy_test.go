@@ -0,0 +1,71 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(list, []byte("# known breached\nPassword123\n\n  letmein1  \n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	p, err := NewPolicy(8, list)
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		password   string
+		wantErr    error
+		wantReason string
+	}{
+		{name: "acceptable", password: "correct horse"},
+		{name: "too short", password: "short", wantErr: ErrTooShort, wantReason: ReasonTooShort},
+		{name: "runes are counted", password: "пароль12"},
+		{name: "email", password: "UserUser@Example.com", wantErr: ErrEqualsEmail, wantReason: ReasonEqualsEmail},
+		{name: "local part of the email", password: "useruser", wantErr: ErrEqualsEmail,
+			wantReason: ReasonEqualsEmail},
+		{name: "breached", password: "password123", wantErr: ErrBreached, wantReason: ReasonBreached},
+		{name: "breached with spaces in the list", password: "letmein1", wantErr: ErrBreached,
+			wantReason: ReasonBreached},
+		{name: "comment is not a password", password: "# known breached"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Validate("useruser@example.com", tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+
+			if !errors.Is(err, ErrPolicyFailed) {
+				t.Errorf("Validate error = %v, want it to match %v", err, ErrPolicyFailed)
+			}
+			var v *Violation
+			if !errors.As(err, &v) || v.Reason != tt.wantReason || v.Message == "" {
+				t.Errorf("violation = %+v, want reason %s with a message", v, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestNewPolicyWithoutBreachedList(t *testing.T) {
+	p, err := NewPolicy(8, "")
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	if err := p.Validate("user@example.com", "password123"); err != nil {
+		t.Errorf("Validate = %v, want no breach check", err)
+	}
+
+	if _, err := NewPolicy(8, filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("NewPolicy with a missing list succeeded")
+	}
+}
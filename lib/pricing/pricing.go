@@ -0,0 +1,35 @@
+package pricing
+
+import "shop/internal/domain/models"
+
+const (
+	ShippingPrice = 4.99
+	TaxPercent    = 0.21
+)
+
+// Sum holds the cart totals shown to customers. Every client (web, gRPC) must use Calculate
+// so they all show the same numbers.
+type Sum struct {
+	CartCount  int
+	TotalItems int
+	Subtotal   float64
+	Shipping   float64
+	Tax        float64
+	Total      float64
+}
+
+func Calculate(cart []models.CartItem) Sum {
+	var sum Sum
+
+	for _, v := range cart {
+		sum.Subtotal += v.ProductPrice * float64(v.Quantity)
+		sum.CartCount += v.Quantity
+	}
+
+	sum.TotalItems = sum.CartCount
+	sum.Tax = sum.Subtotal * TaxPercent
+	sum.Shipping = ShippingPrice
+	sum.Total = sum.Subtotal + sum.Tax + sum.Shipping
+
+	return sum
+}
@@ -0,0 +1,277 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: cart/cart.proto
+
+package cartv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Cart_GetCart_FullMethodName        = "/cart.Cart/GetCart"
+	Cart_AddItem_FullMethodName        = "/cart.Cart/AddItem"
+	Cart_UpdateItem_FullMethodName     = "/cart.Cart/UpdateItem"
+	Cart_RemoveItem_FullMethodName     = "/cart.Cart/RemoveItem"
+	Cart_MergeGuestCart_FullMethodName = "/cart.Cart/MergeGuestCart"
+)
+
+// CartClient is the client API for Cart service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Cart operates on the cart of the user from the bearer token in the "authorization" metadata.
+type CartClient interface {
+	GetCart(ctx context.Context, in *GetCartRequest, opts ...grpc.CallOption) (*CartContents, error)
+	AddItem(ctx context.Context, in *AddItemRequest, opts ...grpc.CallOption) (*CartContents, error)
+	UpdateItem(ctx context.Context, in *UpdateItemRequest, opts ...grpc.CallOption) (*CartContents, error)
+	RemoveItem(ctx context.Context, in *RemoveItemRequest, opts ...grpc.CallOption) (*CartContents, error)
+	MergeGuestCart(ctx context.Context, in *MergeGuestCartRequest, opts ...grpc.CallOption) (*CartContents, error)
+}
+
+type cartClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCartClient(cc grpc.ClientConnInterface) CartClient {
+	return &cartClient{cc}
+}
+
+func (c *cartClient) GetCart(ctx context.Context, in *GetCartRequest, opts ...grpc.CallOption) (*CartContents, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CartContents)
+	err := c.cc.Invoke(ctx, Cart_GetCart_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cartClient) AddItem(ctx context.Context, in *AddItemRequest, opts ...grpc.CallOption) (*CartContents, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CartContents)
+	err := c.cc.Invoke(ctx, Cart_AddItem_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cartClient) UpdateItem(ctx context.Context, in *UpdateItemRequest, opts ...grpc.CallOption) (*CartContents, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CartContents)
+	err := c.cc.Invoke(ctx, Cart_UpdateItem_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cartClient) RemoveItem(ctx context.Context, in *RemoveItemRequest, opts ...grpc.CallOption) (*CartContents, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CartContents)
+	err := c.cc.Invoke(ctx, Cart_RemoveItem_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cartClient) MergeGuestCart(ctx context.Context, in *MergeGuestCartRequest, opts ...grpc.CallOption) (*CartContents, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CartContents)
+	err := c.cc.Invoke(ctx, Cart_MergeGuestCart_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CartServer is the server API for Cart service.
+// All implementations must embed UnimplementedCartServer
+// for forward compatibility.
+//
+// Cart operates on the cart of the user from the bearer token in the "authorization" metadata.
+type CartServer interface {
+	GetCart(context.Context, *GetCartRequest) (*CartContents, error)
+	AddItem(context.Context, *AddItemRequest) (*CartContents, error)
+	UpdateItem(context.Context, *UpdateItemRequest) (*CartContents, error)
+	RemoveItem(context.Context, *RemoveItemRequest) (*CartContents, error)
+	MergeGuestCart(context.Context, *MergeGuestCartRequest) (*CartContents, error)
+	mustEmbedUnimplementedCartServer()
+}
+
+// UnimplementedCartServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCartServer struct{}
+
+func (UnimplementedCartServer) GetCart(context.Context, *GetCartRequest) (*CartContents, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCart not implemented")
+}
+func (UnimplementedCartServer) AddItem(context.Context, *AddItemRequest) (*CartContents, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddItem not implemented")
+}
+func (UnimplementedCartServer) UpdateItem(context.Context, *UpdateItemRequest) (*CartContents, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateItem not implemented")
+}
+func (UnimplementedCartServer) RemoveItem(context.Context, *RemoveItemRequest) (*CartContents, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveItem not implemented")
+}
+func (UnimplementedCartServer) MergeGuestCart(context.Context, *MergeGuestCartRequest) (*CartContents, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MergeGuestCart not implemented")
+}
+func (UnimplementedCartServer) mustEmbedUnimplementedCartServer() {}
+func (UnimplementedCartServer) testEmbeddedByValue()              {}
+
+// UnsafeCartServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CartServer will
+// result in compilation errors.
+type UnsafeCartServer interface {
+	mustEmbedUnimplementedCartServer()
+}
+
+func RegisterCartServer(s grpc.ServiceRegistrar, srv CartServer) {
+	// If the following call pancis, it indicates UnimplementedCartServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Cart_ServiceDesc, srv)
+}
+
+func _Cart_GetCart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartServer).GetCart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Cart_GetCart_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartServer).GetCart(ctx, req.(*GetCartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cart_AddItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartServer).AddItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Cart_AddItem_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartServer).AddItem(ctx, req.(*AddItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cart_UpdateItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartServer).UpdateItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Cart_UpdateItem_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartServer).UpdateItem(ctx, req.(*UpdateItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cart_RemoveItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartServer).RemoveItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Cart_RemoveItem_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartServer).RemoveItem(ctx, req.(*RemoveItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cart_MergeGuestCart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MergeGuestCartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartServer).MergeGuestCart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Cart_MergeGuestCart_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartServer).MergeGuestCart(ctx, req.(*MergeGuestCartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Cart_ServiceDesc is the grpc.ServiceDesc for Cart service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Cart_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cart.Cart",
+	HandlerType: (*CartServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCart",
+			Handler:    _Cart_GetCart_Handler,
+		},
+		{
+			MethodName: "AddItem",
+			Handler:    _Cart_AddItem_Handler,
+		},
+		{
+			MethodName: "UpdateItem",
+			Handler:    _Cart_UpdateItem_Handler,
+		},
+		{
+			MethodName: "RemoveItem",
+			Handler:    _Cart_RemoveItem_Handler,
+		},
+		{
+			MethodName: "MergeGuestCart",
+			Handler:    _Cart_MergeGuestCart_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "cart/cart.proto",
+}
@@ -0,0 +1,172 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: events/envelope.proto
+
+package eventsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Envelope wraps a domain event published to the event broker.
+type Envelope struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`            // unique id of the event
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`        // event type, e.g. "user.registered"
+	Version       int32                  `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"` // schema version of data
+	OccurredAt    *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=occurred_at,json=occurredAt,proto3" json:"occurred_at,omitempty"`
+	TraceId       string                 `protobuf:"bytes,5,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"` // id of the request that caused the event
+	Data          []byte                 `protobuf:"bytes,6,opt,name=data,proto3" json:"data,omitempty"`                      // JSON encoded event
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Envelope) Reset() {
+	*x = Envelope{}
+	mi := &file_events_envelope_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Envelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Envelope) ProtoMessage() {}
+
+func (x *Envelope) ProtoReflect() protoreflect.Message {
+	mi := &file_events_envelope_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Envelope.ProtoReflect.Descriptor instead.
+func (*Envelope) Descriptor() ([]byte, []int) {
+	return file_events_envelope_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Envelope) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Envelope) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Envelope) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Envelope) GetOccurredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OccurredAt
+	}
+	return nil
+}
+
+func (x *Envelope) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *Envelope) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_events_envelope_proto protoreflect.FileDescriptor
+
+const file_events_envelope_proto_rawDesc = "" +
+	"\n" +
+	"\x15events/envelope.proto\x12\x06events\x1a\x1fgoogle/protobuf/timestamp.proto\"\xb4\x01\n" +
+	"\bEnvelope\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\x05R\aversion\x12;\n" +
+	"\voccurred_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"occurredAt\x12\x19\n" +
+	"\btrace_id\x18\x05 \x01(\tR\atraceId\x12\x12\n" +
+	"\x04data\x18\x06 \x01(\fR\x04dataB$Z\"shop/protos/gen/go/events;eventsv1b\x06proto3"
+
+var (
+	file_events_envelope_proto_rawDescOnce sync.Once
+	file_events_envelope_proto_rawDescData []byte
+)
+
+func file_events_envelope_proto_rawDescGZIP() []byte {
+	file_events_envelope_proto_rawDescOnce.Do(func() {
+		file_events_envelope_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_events_envelope_proto_rawDesc), len(file_events_envelope_proto_rawDesc)))
+	})
+	return file_events_envelope_proto_rawDescData
+}
+
+var file_events_envelope_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_events_envelope_proto_goTypes = []any{
+	(*Envelope)(nil),              // 0: events.Envelope
+	(*timestamppb.Timestamp)(nil), // 1: google.protobuf.Timestamp
+}
+var file_events_envelope_proto_depIdxs = []int32{
+	1, // 0: events.Envelope.occurred_at:type_name -> google.protobuf.Timestamp
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_events_envelope_proto_init() }
+func file_events_envelope_proto_init() {
+	if File_events_envelope_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_events_envelope_proto_rawDesc), len(file_events_envelope_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_events_envelope_proto_goTypes,
+		DependencyIndexes: file_events_envelope_proto_depIdxs,
+		MessageInfos:      file_events_envelope_proto_msgTypes,
+	}.Build()
+	File_events_envelope_proto = out.File
+	file_events_envelope_proto_goTypes = nil
+	file_events_envelope_proto_depIdxs = nil
+}
@@ -0,0 +1,168 @@
+function updateQuantity(productId, currentQty, change) {
+  const newQty = parseInt(currentQty) + change;
+  if (newQty < 1 || newQty > 99) return;
+  updateCartItem(productId, newQty);
+}
+
+function updateQuantityDirect(productId, newQty) {
+  const qty = parseInt(newQty);
+  if (isNaN(qty) || qty < 1 || qty > 99) {
+    // Reset to current value if invalid
+    const input = document.querySelector(`[data-item-id="${productId}"] .qty-input`);
+    if (input) {
+      input.value = input.defaultValue;
+    }
+    return;
+  }
+  updateCartItem(productId, qty);
+}
+
+function updateCartItem(productId, quantity) {
+  const cartItem = document.querySelector(`[data-item-id="${productId}"]`);
+  if (!cartItem) return;
+
+  cartItem.classList.add('updating');
+
+  const formData = new FormData();
+  formData.append('product_id', productId);
+  formData.append('quantity', quantity);
+
+  fetch('/cart/update', {
+    method: 'POST',
+    body: formData,
+    headers: {
+      'X-Requested-With': 'XMLHttpRequest',
+      'X-CSRF-Token': document.querySelector('meta[name="csrf-token"]').content
+    }
+  })
+          .then(response => {
+            if (!response.ok) {
+              throw new Error(`HTTP error! status: ${response.status}`);
+            }
+            return response.json();
+          })
+          .then(data => {
+            if (data.success) {
+              updateCartDisplay(data);
+            } else {
+              throw new Error(data.error || 'Failed to update cart');
+            }
+          })
+          .catch(error => {
+            console.error('Error:', error);
+            alert(messages.update_failed);
+            // Reset the input to its previous value
+            const input = cartItem.querySelector('.qty-input');
+            if (input) {
+              input.value = input.defaultValue;
+            }
+          })
+          .finally(() => {
+            cartItem.classList.remove('updating');
+          });
+}
+
+function removeItem(productId) {
+  if (!confirm(messages.confirm_remove)) {
+    return;
+  }
+
+  const cartItem = document.querySelector(`[data-item-id="${productId}"]`);
+  if (!cartItem) return;
+
+  cartItem.classList.add('updating');
+
+  const formData = new FormData();
+  formData.append('product_id', productId);
+
+  fetch('/cart/remove', {
+    method: 'POST',
+    body: formData,
+    headers: {
+      'X-Requested-With': 'XMLHttpRequest',
+      'X-CSRF-Token': document.querySelector('meta[name="csrf-token"]').content
+    }
+  })
+          .then(response => {
+            if (!response.ok) {
+              throw new Error(`HTTP error! status: ${response.status}`);
+            }
+            return response.json();
+          })
+          .then(data => {
+            if (data.success) {
+              if (data.cartCount === 0) {
+                // Reload page to show empty cart
+                location.reload();
+              } else {
+                // Remove the item from display
+                cartItem.remove();
+                updateCartDisplay(data);
+              }
+            } else {
+              throw new Error(data.error || 'Failed to remove item');
+            }
+          })
+          .catch(error => {
+            console.error('Error:', error);
+            alert(messages.remove_failed);
+            cartItem.classList.remove('updating');
+          });
+}
+
+const priceFormat = new Intl.NumberFormat(document.documentElement.lang, {style: 'currency', currency: 'USD'});
+
+function updateCartDisplay(data) {
+  updateCartCount(data.cartCount);
+
+  // Update summary values
+  const elements = {
+    subtotal: data.subtotal,
+    shipping: data.shipping,
+    tax: data.tax,
+    total: data.total
+  };
+
+  Object.entries(elements).forEach(([key, value]) => {
+    if (value !== undefined) {
+      const element = document.getElementById(key);
+      if (element) {
+        element.textContent = priceFormat.format(value);
+      }
+    }
+  });
+
+  // Update cart header
+  if (data.totalItems !== undefined) {
+    const cartHeader = document.getElementById('cart-items-heading');
+    if (cartHeader) {
+      cartHeader.textContent = messages.cart_items.replace('%d', data.totalItems);
+    }
+  }
+}
+
+// Handle checkout button loading state
+const checkoutBtn = document.getElementById('checkoutBtn');
+if (checkoutBtn) {
+  checkoutBtn.addEventListener('click', function(e) {
+    const button = e.target;
+    button.disabled = true;
+    button.innerHTML = '<span class="loading-spinner"></span>' + messages.processing;
+
+    // Re-enable button after 5 seconds as fallback
+    setTimeout(() => {
+      if (button.disabled) {
+        button.disabled = false;
+        button.innerHTML = messages.checkout;
+      }
+    }, 5000);
+  });
+}
+
+// Initialize quantity input default values for reset functionality
+document.addEventListener('DOMContentLoaded', function() {
+  const qtyInputs = document.querySelectorAll('.qty-input');
+  qtyInputs.forEach(input => {
+    input.defaultValue = input.value;
+  });
+});
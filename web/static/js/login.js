@@ -0,0 +1,27 @@
+// Add form submission handling
+document.getElementById('loginForm').addEventListener('submit', function(e) {
+    const btn = document.getElementById('loginBtn');
+    btn.disabled = true;
+    btn.classList.add('loading');
+    btn.textContent = messages.signing_in;
+});
+
+// Re-enable button if there's an error (page reload)
+window.addEventListener('load', function() {
+    const btn = document.getElementById('loginBtn');
+    btn.disabled = false;
+    btn.classList.remove('loading');
+    btn.textContent = messages.sign_in;
+});
+
+// Focus first empty field
+window.addEventListener('load', function() {
+    const emailField = document.getElementById('email');
+    const passwordField = document.getElementById('password');
+
+    if (!emailField.value) {
+        emailField.focus();
+    } else {
+        passwordField.focus();
+    }
+});
@@ -0,0 +1,169 @@
+const form = document.getElementById('registerForm');
+const passwordInput = document.getElementById('password');
+const confirmPasswordInput = document.getElementById('confirmPassword');
+const registerBtn = document.getElementById('registerBtn');
+const errorMessage = document.getElementById('error-message');
+const successMessage = document.getElementById('success-message');
+
+// Password validation requirements
+const requirements = {
+  length: new RegExp(`.{${form.dataset.minLength},}`),
+  uppercase: /[A-Z]/,
+  lowercase: /[a-z]/,
+  number: /\d/,
+  special: /[!@#$%^&*(),.?":{}|<>]/
+};
+
+// Real-time password validation
+passwordInput.addEventListener('input', function() {
+  const password = this.value;
+  let validCount = 0;
+
+  Object.keys(requirements).forEach(key => {
+    const element = document.getElementById(key);
+    if (requirements[key].test(password)) {
+      element.classList.add('valid');
+      validCount++;
+    } else {
+      element.classList.remove('valid');
+    }
+  });
+
+  // Enable/disable register button based on password validity
+  updateSubmitButton();
+});
+
+// Confirm password validation
+confirmPasswordInput.addEventListener('input', updateSubmitButton);
+
+function updateSubmitButton() {
+  const password = passwordInput.value;
+  const confirmPassword = confirmPasswordInput.value;
+
+  // Check if password meets all requirements
+  const passwordValid = Object.keys(requirements).every(key =>
+          requirements[key].test(password)
+  );
+
+  // Check if passwords match
+  const passwordsMatch = password === confirmPassword && confirmPassword !== '';
+
+  registerBtn.disabled = !(passwordValid && passwordsMatch);
+}
+
+// Form submission
+form.addEventListener('submit', async function(e) {
+  // Clear previous field errors
+  document.querySelectorAll('.field-error').forEach(el => el.style.display = 'none');
+  document.querySelectorAll('input.error').forEach(el => el.classList.remove('error'));
+
+  // For non-JS submission (fallback), allow normal form submission
+  if (!window.fetch) {
+    return; // Let the form submit normally
+  }
+
+  e.preventDefault();
+
+  const formData = new FormData(form);
+  const data = {
+    email: formData.get('email'),
+    password: formData.get('password'),
+    confirmPassword: formData.get('confirmPassword')
+  };
+
+  // Client-side validation
+  let hasErrors = false;
+
+  // Check if passwords match
+  if (data.password !== data.confirmPassword) {
+    showFieldError('confirmPassword', messages.passwords_mismatch);
+    hasErrors = true;
+  }
+
+  // Check password requirements
+  const password = data.password;
+  const passwordValid = Object.keys(requirements).every(key =>
+          requirements[key].test(password)
+  );
+
+  if (!passwordValid) {
+    showFieldError('password', messages.password_requirements);
+    hasErrors = true;
+  }
+
+  if (hasErrors) {
+    return;
+  }
+
+  // Hide previous messages
+  errorMessage.style.display = 'none';
+  successMessage.style.display = 'none';
+
+  // Disable button during submission
+  registerBtn.disabled = true;
+  registerBtn.textContent = messages.creating_account;
+
+  try {
+    const response = await fetch('/register', {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        'X-CSRF-Token': document.querySelector('meta[name="csrf-token"]').content,
+      },
+      body: JSON.stringify(data)
+    });
+
+    if (response.ok) {
+      const result = await response.json();
+      successMessage.textContent = result.message;
+      successMessage.style.display = 'block';
+
+      // Redirect to login after success
+      setTimeout(() => {
+        window.location.href = '/login';
+      }, 2000);
+    } else {
+      const result = await response.json();
+
+      // Handle field-specific errors
+      if (result.fieldErrors) {
+        Object.keys(result.fieldErrors).forEach(field => {
+          showFieldError(field, result.fieldErrors[field]);
+        });
+      }
+
+      // Handle general error
+      if (result.error) {
+        errorMessage.textContent = result.error;
+        errorMessage.style.display = 'block';
+      }
+    }
+  } catch (error) {
+    errorMessage.textContent = messages.network_error;
+    errorMessage.style.display = 'block';
+  } finally {
+    registerBtn.disabled = false;
+    registerBtn.textContent = messages.create_account;
+    updateSubmitButton(); // Re-evaluate button state
+  }
+});
+
+function showFieldError(fieldName, message) {
+  const field = document.getElementById(fieldName);
+  if (field) {
+    field.classList.add('error');
+
+    // Create or update error message
+    let errorEl = field.parentNode.querySelector('.field-error');
+    if (!errorEl) {
+      errorEl = document.createElement('div');
+      errorEl.className = 'field-error';
+      field.parentNode.appendChild(errorEl);
+    }
+    errorEl.textContent = message;
+    errorEl.style.display = 'block';
+  }
+}
+
+// Initialize button state
+updateSubmitButton();
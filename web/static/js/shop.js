@@ -0,0 +1,117 @@
+function handleAddToCart(form) {
+    const button = form.querySelector('.add-to-cart-btn');
+    const originalText = button.innerHTML;
+
+    // Show loading state
+    button.disabled = true;
+    button.classList.add('btn-loading');
+    button.innerHTML = '<span class="loading-spinner"></span>' + messages.adding;
+
+    // Create FormData
+    const formData = new FormData(form);
+
+    // Send AJAX request
+    fetch(form.action, {
+        method: 'POST',
+        body: formData,
+        headers: {
+            'X-Requested-With': 'XMLHttpRequest',
+            'X-CSRF-Token': document.querySelector('meta[name="csrf-token"]').content
+        }
+    })
+        .then(response => {
+            if (response.ok) {
+                return response.json();
+            }
+            throw new Error('Network response was not ok');
+        })
+        .then(data => {
+            if (data.success) {
+                // Show success state
+                button.classList.remove('btn-loading');
+                button.classList.add('btn-success');
+                button.innerHTML = '✓ ' + messages.added;
+
+                // Update cart count if provided
+                if (data.cartCount !== undefined) {
+                    updateCartCount(data.cartCount);
+                }
+
+                // Reset button after 2 seconds
+                setTimeout(() => {
+                    button.disabled = false;
+                    button.classList.remove('btn-success');
+                    button.innerHTML = originalText;
+                }, 2000);
+            } else {
+                throw new Error(data.error || 'Failed to add item to cart');
+            }
+        })
+        .catch(error => {
+            console.error('Error:', error);
+
+            // Show error state
+            button.classList.remove('btn-loading');
+            button.style.background = '#e74c3c';
+            button.innerHTML = messages.add_failed;
+
+            // Reset button after 3 seconds
+            setTimeout(() => {
+                button.disabled = false;
+                button.style.background = '';
+                button.innerHTML = originalText;
+            }, 3000);
+        });
+
+    // Prevent form from submitting normally
+    return false;
+}
+
+function updateCartCount(count) {
+    const cartCount = document.querySelector('.cart-count');
+    if (count > 0) {
+        if (cartCount) {
+            cartCount.textContent = count;
+        } else {
+            // Create cart count element if it doesn't exist
+            const cartBtn = document.getElementById('cartBtn');
+            const countSpan = document.createElement('span');
+            countSpan.className = 'cart-count';
+            countSpan.textContent = count;
+            cartBtn.appendChild(countSpan);
+        }
+    } else if (cartCount) {
+        cartCount.remove();
+    }
+}
+
+// Follow cart changes made in other tabs. EventSource reconnects by itself and sends
+// the ID of the last event, so no update is missed.
+function watchCart() {
+    const cartBtn = document.getElementById('cartBtn');
+    if (!cartBtn || !cartBtn.dataset.events || !window.EventSource) {
+        return;
+    }
+
+    const events = new EventSource(cartBtn.dataset.events);
+    events.addEventListener('cart', event => {
+        updateCartCount(JSON.parse(event.data).count);
+    });
+}
+
+document.addEventListener('DOMContentLoaded', watchCart);
+
+function toggleDropdown() {
+    const dropdown = document.getElementById('userDropdown');
+    dropdown.classList.toggle('show');
+}
+
+// Close dropdown when clicking outside
+window.onclick = function(event) {
+    if (!event.target.matches('.user-email')) {
+        const dropdown = document.getElementById('userDropdown');
+        if (dropdown && dropdown.classList.contains('show')) {
+            dropdown.classList.remove('show');
+        }
+    }
+}
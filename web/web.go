@@ -0,0 +1,10 @@
+// Package web holds the HTML templates, static assets and message catalogs, embedded into
+// the binaries so they run from any working directory.
+package web
+
+import "embed"
+
+// FS holds the templates, static and locales directories.
+//
+//go:embed templates static locales
+var FS embed.FS